@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func runAddress(args []string) error {
+	fs := flag.NewFlagSet("address", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	key, err := openBackend(context.Background(), *keys)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	output.print(struct {
+		Address string `json:"address"`
+	}{key.Address().Hex()}, func() {
+		fmt.Println("Address:", key.Address().Hex())
+	})
+	return nil
+}
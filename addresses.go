@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/accounts"
+)
+
+func runAddresses(args []string) error {
+	fs := flag.NewFlagSet("addresses", flag.ExitOnError)
+	mnemonicFile := fs.String("mnemonic-file", "", "File containing the BIP-39 mnemonic (prompted when omitted)")
+	path := fs.String("derivation-path", keys.DefaultDerivationPath, "Derivation path of the first address")
+	count := fs.Int("count", 5, "Number of addresses to list")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *count <= 0 {
+		return errors.New("count must be positive")
+	}
+	start, err := accounts.ParseDerivationPath(*path)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := readPassphrase(*mnemonicFile, "Mnemonic: ")
+	if err != nil {
+		return err
+	}
+	master, err := keys.MnemonicToMaster(mnemonic)
+	if err != nil {
+		return err
+	}
+	defer master.Wipe()
+
+	type derived struct {
+		Path    string `json:"path"`
+		Address string `json:"address"`
+	}
+	var out []derived
+	next := accounts.DefaultIterator(start)
+	for i := 0; i < *count; i++ {
+		p := next()
+		key, err := master.Derive(p)
+		if err != nil {
+			return err
+		}
+		out = append(out, derived{p.String(), key.Address().Hex()})
+		key.Destroy()
+	}
+	output.print(out, func() {
+		for _, d := range out {
+			fmt.Printf("%s %s\n", d.Path, d.Address)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func addAlertFlags(fs *flag.FlagSet) *string {
+	return fs.String("alerts-config", "", "JSON file configuring the Slack and Telegram channels for policy alerts")
+}
+
+func runAck(args []string) error {
+	fs := flag.NewFlagSet("ack", flag.ExitOnError)
+	dir := fs.String("approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements")
+	operator := fs.String("operator", currentUser(), "Operator name recorded with the acknowledgement")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: secure-signer ack [flags] <alert-id>")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one alert ID is required")
+	}
+	idBytes, err := hexutil.Decode(fs.Arg(0))
+	if err != nil || len(idBytes) != common.HashLength {
+		return fmt.Errorf("invalid alert ID %q", fs.Arg(0))
+	}
+	ack := signer.AlertAck{ID: common.BytesToHash(idBytes), Operator: *operator, At: time.Now().UTC()}
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(signer.AckPath(*dir, ack.ID), data); err != nil {
+		return fmt.Errorf("failed to record acknowledgement: %v", err)
+	}
+	fmt.Println("Acknowledged:", ack.ID.Hex())
+	return nil
+}
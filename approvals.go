@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func runApprove(args []string) error {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	keyOpts := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the pending approval requests")
+	output := addOutputFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: secure-signer approve [flags] <request-id>")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one request ID is required")
+	}
+	idBytes, err := hexutil.Decode(fs.Arg(0))
+	if err != nil || len(idBytes) != common.HashLength {
+		return fmt.Errorf("invalid request ID %q", fs.Arg(0))
+	}
+	id := common.BytesToHash(idBytes)
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	req, err := store.ApprovalRequest(id)
+	if err != nil {
+		return fmt.Errorf("failed to load approval request: %v", err)
+	}
+	if req == nil {
+		return fmt.Errorf("no pending approval request %s", id.Hex())
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(req.Tx); err != nil {
+		return fmt.Errorf("failed to decode request transaction: %v", err)
+	}
+	// Never approve something other than what is displayed.
+	if types.LatestSignerForChainID(req.ChainID).Hash(tx) != id {
+		return errors.New("request transaction does not match its ID")
+	}
+
+	ctx := context.Background()
+	key, err := openKeyBackend(ctx, *keyOpts, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	sig, err := keys.SignPersonalMessage(ctx, key, id.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign approval: %v", err)
+	}
+	// Approvals given meanwhile by others are kept.
+	err = store.UpdateApprovalRequest(id, func(cur *signer.ApprovalRequest) (*signer.ApprovalRequest, error) {
+		if cur == nil {
+			return nil, errors.New("request is no longer pending")
+		}
+		req = cur.AddApproval(signer.ApprovalEntry{Approver: key.Address(), Signature: sig})
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save approval: %v", err)
+	}
+
+	output.print(struct {
+		Request   string `json:"request"`
+		Approver  string `json:"approver"`
+		Approvals int    `json:"approvals"`
+	}{id.Hex(), key.Address().Hex(), len(req.Approvals)}, func() {
+		fmt.Println("Request:", id.Hex())
+		fmt.Println("ChainID:", req.ChainID)
+		fmt.Println("From:", req.From.Hex())
+		if tx.To() != nil {
+			fmt.Println("To:", tx.To().Hex())
+		}
+		fmt.Println("Nonce:", tx.Nonce())
+		fmt.Println("Value:", txbuilder.FormatWei(tx.Value()))
+		fmt.Println("Data:", hexutil.Encode(tx.Data()))
+		fmt.Println("Approver:", key.Address().Hex())
+		fmt.Println("Approvals:", len(req.Approvals))
+	})
+	return nil
+}
+
+// registerApprovals serves pending approval requests to approvers: GET
+// /approvals/{id} returns a request and POST /approvals/{id} adds an
+// approval to it, an EIP-191 signature over the ID by a listed approver.
+func (s *ethService) registerApprovals(mux *http.ServeMux) {
+	parseID := func(w http.ResponseWriter, r *http.Request) (common.Hash, bool) {
+		idBytes, err := hexutil.Decode(r.PathValue("id"))
+		if err != nil || len(idBytes) != common.HashLength {
+			http.Error(w, "invalid request ID", http.StatusBadRequest)
+			return common.Hash{}, false
+		}
+		return common.BytesToHash(idBytes), true
+	}
+	mux.HandleFunc("GET /approvals/{id}", authorized(opView, s.Key.Address(), func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(w, r)
+		if !ok {
+			return
+		}
+		req, err := s.Store.ApprovalRequest(id)
+		if err != nil {
+			slog.Error("failed to load approval request", "request", id.Hex(), "err", err)
+			http.Error(w, "failed to load approval request", http.StatusInternalServerError)
+			return
+		}
+		if req == nil {
+			http.Error(w, "approval request not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+	}))
+	mux.HandleFunc("POST /approvals/{id}", authorized(opApprove, s.Key.Address(), func(w http.ResponseWriter, r *http.Request) {
+		ap := s.CurrentPolicy().Approvals
+		if ap == nil {
+			http.Error(w, "policy requires no approvals", http.StatusNotFound)
+			return
+		}
+		id, ok := parseID(w, r)
+		if !ok {
+			return
+		}
+		var entry signer.ApprovalEntry
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&entry); err != nil {
+			http.Error(w, "Bad request format", http.StatusBadRequest)
+			return
+		}
+		if !(&signer.ApprovalRequest{ID: id, Approvals: []signer.ApprovalEntry{entry}}).ApprovedBy(ap)[entry.Approver] {
+			http.Error(w, "signature is not a valid approval by a listed approver", http.StatusForbidden)
+			return
+		}
+		var req *signer.ApprovalRequest
+		err := s.Store.UpdateApprovalRequest(id, func(cur *signer.ApprovalRequest) (*signer.ApprovalRequest, error) {
+			if cur == nil {
+				return nil, errApprovalNotFound
+			}
+			req = cur.AddApproval(entry)
+			return req, nil
+		})
+		switch {
+		case errors.Is(err, errApprovalNotFound):
+			http.Error(w, "approval request not found", http.StatusNotFound)
+			return
+		case err != nil:
+			slog.Error("failed to save approval", "request", id.Hex(), "err", err)
+			http.Error(w, "failed to save approval", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("approval added", "request", req.ID.Hex(), "approver", entry.Approver.Hex(), "client", callerName(r.Context()))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Request   string `json:"request"`
+			Approvals int    `json:"approvals"`
+			Quorum    int    `json:"quorum"`
+		}{req.ID.Hex(), len(req.ApprovedBy(ap)), ap.Quorum})
+	}))
+}
+
+var errApprovalNotFound = errors.New("approval request not found")
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// auditFlags are the audit flags shared by the signing commands.
+type auditFlags struct {
+	path         string
+	operator     string
+	keyFile      string
+	syslog       string
+	syslogFormat string
+}
+
+// auditInStore is the -audit-log and -log value that keeps the audit log
+// in the state store, so that daemon replicas sharing a store also share
+// one audit chain.
+const auditInStore = "store"
+
+func addAuditFlags(fs *flag.FlagSet) *auditFlags {
+	a := new(auditFlags)
+	fs.StringVar(&a.path, "audit-log", signer.DefaultAuditLog, "Append-only audit log of signing attempts: a JSONL file, store to keep it in the state store, or empty to disable")
+	fs.StringVar(&a.operator, "operator", currentUser(), "Operator name recorded in the audit log")
+	fs.StringVar(&a.keyFile, "audit-key", "", "File holding a hex secp256k1 key that signs every audit entry; use a key that signs nothing else")
+	fs.StringVar(&a.syslog, "audit-syslog", "", "Also send every audit entry to this syslog collector: udp://, tcp:// or tls://host:port, with optional facility (default authpriv) and, for tls, ca parameters")
+	fs.StringVar(&a.syslogFormat, "audit-syslog-format", signer.SyslogJSON, "Format of the audit entries sent to syslog: json or cef")
+	return a
+}
+
+// open returns the audit log selected by the flags, kept in store with
+// -audit-log store.
+func (a *auditFlags) open(store signer.Store) (*signer.AuditLog, error) {
+	if a.path == "" {
+		if a.keyFile != "" {
+			return nil, errors.New("audit-key requires audit-log")
+		}
+		if a.syslog != "" {
+			return nil, errors.New("audit-syslog requires audit-log")
+		}
+		return nil, nil
+	}
+	l := &signer.AuditLog{Entries: signer.OpenAuditFile(a.path), Operator: a.operator}
+	if a.path == auditInStore {
+		l.Entries = store
+	}
+	if a.syslog != "" {
+		var err error
+		if l.Syslog, err = signer.NewSyslogSink(a.syslog, a.syslogFormat, version); err != nil {
+			return nil, err
+		}
+	}
+	if a.keyFile != "" {
+		f, err := os.Open(a.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit key: %v", err)
+		}
+		defer f.Close()
+		if l.Key, err = readPrivateKey(f); err != nil {
+			return nil, fmt.Errorf("failed to load audit key: %v", err)
+		}
+	}
+	return l, nil
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func runAudit(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secure-signer audit <verify|export> [flags]")
+	}
+	switch args[0] {
+	case "verify":
+		return runAuditVerify(args[1:])
+	case "export":
+		return runAuditExport(args[1:])
+	}
+	return fmt.Errorf("unknown audit command %q", args[0])
+}
+
+// auditSignerFlag adds the -audit-signer flag of the audit commands.
+func auditSignerFlag(fs *flag.FlagSet) *string {
+	return fs.String("audit-signer", "", "Address of the audit key every entry must be signed by")
+}
+
+// parseAuditSigner parses the -audit-signer flag; nil means entries are not
+// checked for signatures.
+func parseAuditSigner(value string) (*common.Address, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !common.IsHexAddress(value) {
+		return nil, fmt.Errorf("invalid audit-signer %q", value)
+	}
+	addr := common.HexToAddress(value)
+	return &addr, nil
+}
+
+// auditSourceFlags select the audit log read by the audit commands.
+type auditSourceFlags struct {
+	log   *string
+	store *string
+}
+
+func addAuditSourceFlags(fs *flag.FlagSet, usage string) *auditSourceFlags {
+	return &auditSourceFlags{
+		log:   fs.String("log", signer.DefaultAuditLog, usage+": a JSONL file, or store for the log kept in -store"),
+		store: fs.String("store", signer.DefaultStore, "State store holding the audit log with -log store"),
+	}
+}
+
+// open returns the selected audit log and a function releasing it.
+func (a *auditSourceFlags) open() (signer.AuditStore, func(), error) {
+	if *a.log != auditInStore {
+		return signer.OpenAuditFile(*a.log), func() {}, nil
+	}
+	store, err := signer.OpenStore(*a.store)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open store: %v", err)
+	}
+	return store, func() { store.Close() }, nil
+}
+
+func runAuditVerify(args []string) error {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	source := addAuditSourceFlags(fs, "Audit log to verify")
+	headFile := fs.String("head", "", "Head file to check the log against (default <log>.head for a file)")
+	signerAddr := auditSignerFlag(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *headFile == "" && *source.log != auditInStore {
+		*headFile = *source.log + ".head"
+	}
+	txSigner, err := parseAuditSigner(*signerAddr)
+	if err != nil {
+		return err
+	}
+	log, release, err := source.open()
+	if err != nil {
+		return err
+	}
+	defer release()
+	last, err := signer.VerifyAuditLog(log, txSigner, nil)
+	if err != nil {
+		return err
+	}
+
+	var (
+		warning string
+		data    []byte
+	)
+	err = os.ErrNotExist
+	if *headFile != "" {
+		data, err = os.ReadFile(*headFile)
+	}
+	switch {
+	case err == nil:
+		var head signer.AuditHead
+		if err := json.Unmarshal(data, &head); err != nil {
+			return fmt.Errorf("invalid head file: %v", err)
+		}
+		if head.Seq > last.Seq {
+			return fmt.Errorf("audit log verification failed: log ends at entry %d but the head is at entry %d; the log was truncated", last.Seq, head.Seq)
+		}
+		if head != last {
+			return fmt.Errorf("audit log verification failed: log ends at entry %d (%s) but the head is entry %d (%s)", last.Seq, last.Hash, head.Seq, head.Hash)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		warning = "no head file; entries removed from the end of the log cannot be detected"
+	default:
+		return err
+	}
+	var signedBy string
+	if txSigner != nil {
+		signedBy = txSigner.Hex()
+	}
+	output.print(struct {
+		Entries  uint64 `json:"entries"`
+		Head     string `json:"head"`
+		SignedBy string `json:"signedBy,omitempty"`
+		Warning  string `json:"warning,omitempty"`
+	}{last.Seq, last.Hash, signedBy, warning}, func() {
+		if warning != "" {
+			fmt.Fprintln(os.Stderr, "warning:", warning)
+		}
+		fmt.Println("Entries:", last.Seq)
+		fmt.Println("Head:", last.Hash)
+		if signedBy != "" {
+			fmt.Println("SignedBy:", signedBy)
+		}
+	})
+	return nil
+}
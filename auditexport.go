@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// assetNative names the chain's native currency in audit exports; tokens
+// are named by their contract address.
+const assetNative = "native"
+
+// auditTransfer is a value movement requested by a transaction: its native
+// value, or a decoded ERC-20 or NFT transfer. Amounts are in base units.
+type auditTransfer struct {
+	recipient string
+	asset     string
+	amount    *big.Int
+}
+
+// entryTransfers returns the value movements of a transaction entry, none
+// for other kinds.
+func entryTransfers(e *signer.AuditEntry) ([]auditTransfer, error) {
+	if e.Kind != signer.AuditTransaction || len(e.Request) == 0 {
+		return nil, nil
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalJSON(e.Request); err != nil {
+		return nil, fmt.Errorf("entry %d: invalid transaction: %v", e.Seq, err)
+	}
+	if tx.To() == nil {
+		return nil, nil
+	}
+	var transfers []auditTransfer
+	call := txbuilder.DecodeCall(tx.Data())
+	if call == nil || tx.Value().Sign() > 0 {
+		transfers = append(transfers, auditTransfer{recipient: tx.To().Hex(), asset: assetNative, amount: tx.Value()})
+	}
+	if call != nil {
+		amount, _ := new(big.Int).SetString(call.Amount, 10)
+		transfers = append(transfers, auditTransfer{recipient: call.Recipient, asset: tx.To().Hex(), amount: amount})
+	}
+	return transfers, nil
+}
+
+// auditExportRow is one transfer of an exported entry. Entries that move no
+// value get a single row without the transfer columns.
+type auditExportRow struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Operator  string    `json:"operator"`
+	Kind      string    `json:"kind"`
+	Account   string    `json:"account,omitempty"`
+	ChainID   string    `json:"chainId,omitempty"`
+	Recipient string    `json:"recipient,omitempty"`
+	Asset     string    `json:"asset,omitempty"`
+	Amount    string    `json:"amount,omitempty"`
+	Allowed   bool      `json:"allowed"`
+	TxHash    string    `json:"txHash,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// auditTotal sums the signed transfers of one asset to a recipient or in a
+// period.
+type auditTotal struct {
+	Recipient string `json:"recipient,omitempty"`
+	Period    string `json:"period,omitempty"`
+	ChainID   string `json:"chainId"`
+	Asset     string `json:"asset"`
+	Count     int    `json:"count"`
+	Amount    string `json:"amount"`
+	sum       *big.Int
+}
+
+// auditTotals accumulates totals keyed by recipient or period, chain and
+// asset.
+type auditTotals map[[3]string]*auditTotal
+
+func (t auditTotals) add(key, chainID, asset string, amount *big.Int, byPeriod bool) {
+	k := [3]string{key, chainID, asset}
+	total, ok := t[k]
+	if !ok {
+		total = &auditTotal{ChainID: chainID, Asset: asset, sum: new(big.Int)}
+		if byPeriod {
+			total.Period = key
+		} else {
+			total.Recipient = key
+		}
+		t[k] = total
+	}
+	total.Count++
+	total.sum.Add(total.sum, amount)
+}
+
+// sorted returns the totals ordered by key, chain and asset.
+func (t auditTotals) sorted() []*auditTotal {
+	keys := make([][3]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for n := range keys[i] {
+			if keys[i][n] != keys[j][n] {
+				return keys[i][n] < keys[j][n]
+			}
+		}
+		return false
+	})
+	totals := make([]*auditTotal, len(keys))
+	for i, k := range keys {
+		totals[i] = t[k]
+		totals[i].Amount = totals[i].sum.String()
+	}
+	return totals
+}
+
+// auditPeriod returns the label of the period of length unit holding t.
+func auditPeriod(t time.Time, unit string) string {
+	switch unit {
+	case "day":
+		return t.Format("2006-01-02")
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return t.Format("2006-01")
+}
+
+// parseExportTime parses an RFC 3339 time or a date. A date given as the end
+// of the range includes the whole day.
+func parseExportTime(name, value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: use YYYY-MM-DD or RFC 3339", name, value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func runAuditExport(args []string) error {
+	fs := flag.NewFlagSet("audit export", flag.ExitOnError)
+	source := addAuditSourceFlags(fs, "Audit log to export")
+	fromTime := fs.String("from", "", "Export entries at or after this time (YYYY-MM-DD or RFC 3339)")
+	toTime := fs.String("to", "", "Export entries before this time; a date includes the whole day")
+	format := fs.String("format", "json", "Export format: csv or json")
+	period := fs.String("period", "month", "Period of the per-period totals: day, week or month")
+	out := fs.String("out", "", "File to write the export to (stdout when omitted)")
+	signerAddr := auditSignerFlag(fs)
+	parseFlags(fs, args)
+
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("invalid format %q: use csv or json", *format)
+	}
+	switch *period {
+	case "day", "week", "month":
+	default:
+		return fmt.Errorf("invalid period %q: use day, week or month", *period)
+	}
+	from, err := parseExportTime("from", *fromTime, false)
+	if err != nil {
+		return err
+	}
+	to, err := parseExportTime("to", *toTime, true)
+	if err != nil {
+		return err
+	}
+	if !to.IsZero() && !to.After(from) {
+		return errors.New("to must be after from")
+	}
+	txSigner, err := parseAuditSigner(*signerAddr)
+	if err != nil {
+		return err
+	}
+
+	log, release, err := source.open()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The whole log is verified, not just the exported range, so a report
+	// is never built from a log that has been tampered with.
+	rows := []auditExportRow{}
+	byRecipient, byPeriod := make(auditTotals), make(auditTotals)
+	_, err = signer.VerifyAuditLog(log, txSigner, func(e *signer.AuditEntry) error {
+		if e.Time.Before(from) || (!to.IsZero() && !e.Time.Before(to)) {
+			return nil
+		}
+		transfers, err := entryTransfers(e)
+		if err != nil {
+			return err
+		}
+		row := auditExportRow{
+			Seq:      e.Seq,
+			Time:     e.Time,
+			Operator: e.Operator,
+			Kind:     e.Kind,
+			Account:  e.Account,
+			ChainID:  e.ChainID,
+			Allowed:  e.Allowed,
+			TxHash:   e.TxHash,
+			Error:    e.Error,
+		}
+		if len(transfers) == 0 {
+			rows = append(rows, row)
+		}
+		for _, t := range transfers {
+			row.Recipient, row.Asset, row.Amount = t.recipient, t.asset, t.amount.String()
+			rows = append(rows, row)
+			// Only transactions that were signed count towards the totals.
+			if e.TxHash != "" {
+				byRecipient.add(t.recipient, e.ChainID, t.asset, t.amount, false)
+				byPeriod.add(auditPeriod(e.Time, *period), e.ChainID, t.asset, t.amount, true)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	recipients, periods := byRecipient.sorted(), byPeriod.sorted()
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Entries     []auditExportRow `json:"entries"`
+			ByRecipient []*auditTotal    `json:"totalsByRecipient"`
+			ByPeriod    []*auditTotal    `json:"totalsByPeriod"`
+		}{rows, recipients, periods})
+	}
+	return writeAuditCSV(w, rows, recipients, periods)
+}
+
+// writeAuditCSV writes the entries, the totals per recipient and the totals
+// per period as three tables separated by blank lines.
+func writeAuditCSV(w io.Writer, rows []auditExportRow, recipients, periods []*auditTotal) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"seq", "time", "operator", "kind", "account", "chain_id", "recipient", "asset", "amount", "allowed", "tx_hash", "error"})
+	for _, r := range rows {
+		cw.Write([]string{
+			strconv.FormatUint(r.Seq, 10), r.Time.Format(time.RFC3339), r.Operator, r.Kind, r.Account, r.ChainID,
+			r.Recipient, r.Asset, r.Amount, strconv.FormatBool(r.Allowed), r.TxHash, r.Error,
+		})
+	}
+	cw.Write(nil)
+	cw.Write([]string{"recipient", "chain_id", "asset", "count", "amount"})
+	for _, t := range recipients {
+		cw.Write([]string{t.Recipient, t.ChainID, t.Asset, strconv.Itoa(t.Count), t.Amount})
+	}
+	cw.Write(nil)
+	cw.Write([]string{"period", "chain_id", "asset", "count", "amount"})
+	for _, t := range periods {
+		cw.Write([]string{t.Period, t.ChainID, t.Asset, strconv.Itoa(t.Count), t.Amount})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"golang.org/x/time/rate"
+)
+
+// clientConfig is one entry of the clients file given to serve -clients. A
+// client is identified by a TLS client certificate whose common name or a
+// DNS name is listed in cert_names, by the API key in api_key_file, or by
+// both when both are set.
+type clientConfig struct {
+	Name       string   `json:"name"`
+	CertNames  []string `json:"cert_names"`
+	APIKeyFile string   `json:"api_key_file"`
+	// Roles limits what the client may do (see roleOps); without roles it
+	// may do everything.
+	Roles []string `json:"roles"`
+	// RatePerMinute limits the requests of the client, with bursts of up
+	// to Burst requests (defaulting to RatePerMinute). Zero is unlimited.
+	RatePerMinute int `json:"rate_per_minute"`
+	Burst         int `json:"burst"`
+}
+
+type apiClient struct {
+	name      string
+	certNames []string
+	keyHash   []byte
+	roles     []string
+	limiter   *rate.Limiter
+}
+
+// clientAuth authenticates the callers of the daemon. With a clients file,
+// only the listed clients are served, and with a JWT config bearer tokens
+// from the identity provider are accepted as well; with only a client CA,
+// any certificate it signed is accepted, with the certRoles. A nil
+// *clientAuth lets every request through.
+type clientAuth struct {
+	clients []*apiClient
+	jwt     *jwtVerifier
+	rbac    *rbacConfig
+	// anyCert accepts any certificate the client CA signed. It is only set
+	// for a single-key daemon with neither clients nor a JWT config; tenants
+	// and the clients file name the certificates they accept.
+	anyCert bool
+}
+
+// certRoles are the roles of a caller accepted by the client CA alone. It
+// may sign but not approve or unlock keys; list it in a clients file to
+// give it other roles.
+var certRoles = []string{"signer"}
+
+// loadClients reads the clients file.
+func loadClients(file string) (*clientAuth, error) {
+	var configs []clientConfig
+	if err := jsonfile.Read(file, &configs); err != nil {
+		return nil, fmt.Errorf("failed to read clients: %v", err)
+	}
+	a := new(clientAuth)
+	seen := make(map[string]bool)
+	for i, c := range configs {
+		if c.Name == "" || seen[c.Name] {
+			return nil, fmt.Errorf("client %d: name is required and must be unique", i)
+		}
+		seen[c.Name] = true
+		if len(c.CertNames) == 0 && c.APIKeyFile == "" {
+			return nil, fmt.Errorf("client %s: set cert_names, api_key_file or both", c.Name)
+		}
+		if c.RatePerMinute < 0 || c.Burst < 0 {
+			return nil, fmt.Errorf("client %s: rate_per_minute and burst must not be negative", c.Name)
+		}
+		for _, role := range c.Roles {
+			if roleOps[role] == nil {
+				return nil, fmt.Errorf("client %s: unknown role %q", c.Name, role)
+			}
+		}
+		client := &apiClient{name: c.Name, certNames: c.CertNames, roles: c.Roles}
+		if c.APIKeyFile != "" {
+			key, err := os.ReadFile(c.APIKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("client %s: failed to read api key: %v", c.Name, err)
+			}
+			key = bytes.TrimSpace(key)
+			if len(key) < 16 {
+				return nil, fmt.Errorf("client %s: api key must be at least 16 bytes", c.Name)
+			}
+			sum := sha256.Sum256(key)
+			client.keyHash = sum[:]
+		}
+		if c.RatePerMinute > 0 {
+			burst := c.Burst
+			if burst == 0 {
+				burst = c.RatePerMinute
+			}
+			client.limiter = rate.NewLimiter(rate.Limit(float64(c.RatePerMinute)/60), burst)
+		}
+		a.clients = append(a.clients, client)
+	}
+	if len(a.clients) == 0 {
+		return nil, errors.New("clients file lists no clients")
+	}
+	return a, nil
+}
+
+// requestAPIKey returns the API key of a request, sent as a bearer token or
+// in X-API-Key.
+func requestAPIKey(r *http.Request) []byte {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return []byte(strings.TrimSpace(key))
+	}
+	return []byte(r.Header.Get("X-API-Key"))
+}
+
+// certNames returns the common name and DNS names of the verified client
+// certificate of a request.
+func certNames(r *http.Request) []string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	names := append([]string{}, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return names
+}
+
+// authenticate returns the client making r, or nil.
+func (a *clientAuth) authenticate(r *http.Request) *apiClient {
+	presented := certNames(r)
+	var keyHash []byte
+	if key := requestAPIKey(r); len(key) > 0 {
+		sum := sha256.Sum256(key)
+		keyHash = sum[:]
+	}
+	for _, c := range a.clients {
+		if len(c.certNames) > 0 && !namesOverlap(c.certNames, presented) {
+			continue
+		}
+		if c.keyHash != nil && (keyHash == nil || subtle.ConstantTimeCompare(c.keyHash, keyHash) != 1) {
+			continue
+		}
+		return c
+	}
+	return nil
+}
+
+func namesOverlap(want, have []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if strings.EqualFold(w, h) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Errors of identify.
+var (
+	errInvalidToken    = errors.New("invalid token")
+	errUnauthenticated = errors.New("unauthorized")
+	errRateLimited     = errors.New("rate limit exceeded")
+)
+
+// identify returns the caller making r: errInvalidToken for a bad JWT,
+// errUnauthenticated for an unknown client and errRateLimited for a client
+// over its rate limit.
+func (a *clientAuth) identify(r *http.Request) (*caller, error) {
+	var c *caller
+	switch token, isJWT := bearerJWT(r); {
+	case isJWT && a.jwt != nil:
+		var err error
+		if c, err = a.jwt.verify(r.Context(), token); err != nil {
+			slog.Warn("invalid token", "remote", r.RemoteAddr, "path", r.URL.Path, "err", err)
+			return nil, errInvalidToken
+		}
+	case a.anyCert && len(certNames(r)) > 0:
+		// No client list: the TLS handshake already verified the
+		// certificate against the client CA.
+		names := certNames(r)
+		c = &caller{name: names[len(names)-1], id: "cert:" + names[len(names)-1], roles: certRoles}
+	default:
+		client := a.authenticate(r)
+		if client == nil {
+			slog.Warn("unauthenticated request", "remote", r.RemoteAddr, "path", r.URL.Path)
+			return nil, errUnauthenticated
+		}
+		if client.limiter != nil && !client.limiter.Allow() {
+			slog.Warn("client rate limited", "client", client.name, "remote", r.RemoteAddr, "path", r.URL.Path)
+			return nil, errRateLimited
+		}
+		c = &caller{name: client.name, id: "client:" + client.name, roles: client.roles, limiter: client.limiter}
+	}
+	if a.rbac != nil {
+		c.rbac, c.grants = true, a.rbac.grantsFor(c.id)
+	}
+	slog.Debug("authenticated request", "client", c.name, "roles", c.roles, "remote", r.RemoteAddr, "path", r.URL.Path)
+	return c, nil
+}
+
+// middleware refuses requests from unknown clients with 401 and over their
+// rate limit with 429, and passes the caller on in the request context.
+func (a *clientAuth) middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/upcheck" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c, err := a.identify(r)
+		switch err {
+		case nil:
+		case errInvalidToken:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="secure-signer", error="invalid_token"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		case errRateLimited:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="secure-signer"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+// authFlags are serve's TLS and client authentication flags.
+type authFlags struct {
+	certFile      string
+	keyFile       string
+	clientCAFile  string
+	clientsFile   string
+	jwtConfigFile string
+	rbacFile      string
+}
+
+func addAuthFlags(fs *flag.FlagSet) *authFlags {
+	t := new(authFlags)
+	fs.StringVar(&t.certFile, "tls-cert", "", "PEM certificate to serve HTTPS with")
+	fs.StringVar(&t.keyFile, "tls-key", "", "PEM private key of -tls-cert")
+	fs.StringVar(&t.clientCAFile, "tls-client-ca", "", "PEM CA bundle; clients must present a certificate signed by it (mTLS)")
+	fs.StringVar(&t.clientsFile, "clients", "", "JSON file of the clients allowed to call the daemon, with their API keys, certificate names, roles and rate limits")
+	fs.StringVar(&t.jwtConfigFile, "jwt-config", "", "JSON file configuring JWT bearer authentication: JWKS URL, issuer, audience and claim-to-role mapping")
+	fs.StringVar(&t.rbacFile, "rbac", "", "JSON file granting identities the keys, chains and maximum amounts they may sign for")
+	return t
+}
+
+// open returns the TLS config, nil for plain HTTP, and the client
+// authentication, nil when every caller is trusted. A daemon serving
+// tenants authenticates per tenant, so it only sets up TLS here.
+func (t *authFlags) open(listen string, tenants bool) (*tls.Config, *clientAuth, error) {
+	if (t.certFile == "") != (t.keyFile == "") {
+		return nil, nil, errors.New("tls-cert and tls-key must be given together")
+	}
+	if t.clientCAFile != "" && t.certFile == "" {
+		return nil, nil, errors.New("tls-client-ca requires tls-cert")
+	}
+	var auth *clientAuth
+	if !tenants {
+		var err error
+		if auth, err = loadClientAuth(t.clientsFile, t.jwtConfigFile, t.rbacFile, t.clientCAFile != ""); err != nil {
+			return nil, nil, err
+		}
+	}
+	if t.certFile == "" {
+		if (auth != nil || tenants) && !isLoopback(listen) {
+			slog.Warn("API keys are accepted over plain HTTP; use -tls-cert", "listen", listen)
+		}
+		return nil, auth, nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if t.clientCAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(t.clientCAFile); err != nil {
+			return nil, nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if auth == nil && !tenants {
+			auth = new(clientAuth)
+		}
+		if !tenants && len(auth.clients) == 0 && auth.jwt == nil {
+			auth.anyCert = true
+		}
+		if tenants || auth.jwt != nil {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		for _, c := range auth.clientList() {
+			if len(c.certNames) == 0 {
+				// API-key clients may connect without a certificate.
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+	}
+	return cfg, auth, nil
+}
+
+// loadClientAuth reads the clients, JWT and RBAC files, any of which may
+// be empty. It returns nil when all are. clientCA tells whether the TLS
+// handshake verifies client certificates.
+func loadClientAuth(clientsFile, jwtConfigFile, rbacFile string, clientCA bool) (*clientAuth, error) {
+	var auth *clientAuth
+	if clientsFile != "" {
+		var err error
+		if auth, err = loadClients(clientsFile); err != nil {
+			return nil, err
+		}
+		for _, c := range auth.clients {
+			if len(c.certNames) > 0 && !clientCA {
+				return nil, fmt.Errorf("client %s: cert_names require tls-client-ca", c.name)
+			}
+		}
+	}
+	if jwtConfigFile != "" {
+		if auth == nil {
+			auth = new(clientAuth)
+		}
+		var err error
+		if auth.jwt, err = loadJWTVerifier(jwtConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if rbacFile != "" {
+		if auth == nil && !clientCA {
+			return nil, errRBACUnauthenticated
+		}
+		if auth == nil {
+			auth = new(clientAuth)
+		}
+		var err error
+		if auth.rbac, err = loadRBAC(rbacFile); err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
+
+// clientList returns the clients of a, which may be nil.
+func (a *clientAuth) clientList() []*apiClient {
+	if a == nil {
+		return nil
+	}
+	return a.clients
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", file)
+	}
+	return pool, nil
+}
+
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// daemonFlags are the flags of commands that call a running daemon.
+type daemonFlags struct {
+	url        string
+	apiKeyFile string
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+func addDaemonFlags(fs *flag.FlagSet, usage string) *daemonFlags {
+	d := new(daemonFlags)
+	fs.StringVar(&d.url, "daemon", "http://127.0.0.1:8550", usage)
+	fs.StringVar(&d.apiKeyFile, "api-key-file", "", "File holding the API key to authenticate to the daemon with")
+	fs.StringVar(&d.caFile, "tls-ca", "", "PEM CA bundle to verify the daemon's certificate with (default: system roots)")
+	fs.StringVar(&d.certFile, "tls-cert", "", "PEM client certificate for daemons that require mTLS")
+	fs.StringVar(&d.keyFile, "tls-key", "", "PEM private key of -tls-cert")
+	return d
+}
+
+// daemonClient sends authenticated requests to the daemon.
+type daemonClient struct {
+	base   string
+	apiKey string
+	http   *http.Client
+}
+
+func (d *daemonFlags) open() (*daemonClient, error) {
+	c := &daemonClient{base: strings.TrimSuffix(d.url, "/"), http: new(http.Client)}
+	if d.apiKeyFile != "" {
+		key, err := os.ReadFile(d.apiKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read api key: %v", err)
+		}
+		c.apiKey = string(bytes.TrimSpace(key))
+	}
+	if (d.certFile == "") != (d.keyFile == "") {
+		return nil, errors.New("tls-cert and tls-key must be given together")
+	}
+	if d.caFile != "" || d.certFile != "" {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if d.caFile != "" {
+			var err error
+			if cfg.RootCAs, err = loadCertPool(d.caFile); err != nil {
+				return nil, err
+			}
+		}
+		if d.certFile != "" {
+			cert, err := tls.LoadX509KeyPair(d.certFile, d.keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		c.http.Transport = &http.Transport{TLSClientConfig: cfg}
+	}
+	return c, nil
+}
+
+func (c *daemonClient) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return c.http.Do(req)
+}
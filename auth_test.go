@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	testIssuer   = "https://idp.example"
+	testAudience = "secure-signer"
+	testKeyHex   = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	testAPIKey   = "0123456789abcdef0123456789abcdef"
+)
+
+var (
+	testKeyA = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testKeyB = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+// testIdP serves the JWKS of a signing key and issues tokens with it.
+type testIdP struct {
+	key *ecdsa.PrivateKey
+	srv *httptest.Server
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "k1", Algorithm: string(jose.ES256), Use: "sig"}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return &testIdP{key: key, srv: srv}
+}
+
+// token returns a token for subject with the given roles claim, from issuer.
+func (idp *testIdP) token(t *testing.T, issuer, subject string, roles ...string) string {
+	t.Helper()
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: idp.key, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.Claims{
+		Issuer:   issuer,
+		Subject:  subject,
+		Audience: jwt.Audience{testAudience},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token, err := jwt.Signed(sig).Claims(claims).Claims(map[string]any{"roles": roles}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// config writes a JWT config trusting idp and returns its path.
+func (idp *testIdP) config(t *testing.T, dir string, roleKeys map[string][]string) string {
+	t.Helper()
+	return writeTestJSON(t, filepath.Join(dir, "jwt.json"), jwtConfig{
+		JWKSURL:  idp.srv.URL,
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		RoleKeys: roleKeys,
+	})
+}
+
+func writeTestJSON(t *testing.T, file string, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writeServerCert writes a self-signed server certificate and key and
+// returns their paths; it doubles as the client CA bundle.
+func writeServerCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// request is a call to the daemon presenting a client certificate verified
+// by the client CA when cert is set and a bearer token when token is set.
+func request(cert, token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	if cert != "" {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cert}}}}}
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthorization(t *testing.T) {
+	dir := t.TempDir()
+	idp := newTestIdP(t)
+	certFile, keyFile := writeServerCert(t, dir)
+	apiKeyFile := filepath.Join(dir, "api.key")
+	if err := os.WriteFile(apiKeyFile, []byte(testAPIKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two tenants behind one client CA: a lists a certificate client, b
+	// only accepts tokens.
+	tenantsFile := writeTestJSON(t, filepath.Join(dir, "tenants.json"), []tenantConfig{
+		{
+			Name: "a", Dir: filepath.Join(dir, "a"), Key: testKeyHex,
+			Clients: writeTestJSON(t, filepath.Join(dir, "a", "clients.json"), []clientConfig{
+				{Name: "ops", CertNames: []string{"ops.a"}, Roles: []string{"signer"}},
+				{Name: "root", CertNames: []string{"root.a"}},
+				{Name: "bot", APIKeyFile: apiKeyFile, Roles: []string{"viewer"}},
+			}),
+		},
+		{Name: "b", Dir: filepath.Join(dir, "b"), Key: testKeyHex, JWTConfig: idp.config(t, filepath.Join(dir, "b"), map[string][]string{"signer": {testKeyB.Hex()}})},
+	})
+	tlsOpts := &authFlags{certFile: certFile, keyFile: keyFile, clientCAFile: certFile}
+	tenants, err := loadTenants(tenantsFile, tlsOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantAuth := map[string]*clientAuth{}
+	for _, tn := range tenants {
+		tenantAuth[tn.name] = tn.auth
+	}
+	if _, _, err := tlsOpts.open("127.0.0.1:0", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single-key daemon trusting the client CA alone, one with a JWT
+	// config as well, and one granting certificates by RBAC.
+	_, caOnly, err := tlsOpts.open("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withJWT := *tlsOpts
+	withJWT.jwtConfigFile = idp.config(t, filepath.Join(dir, "single"), nil)
+	_, caJWT, err := withJWT.open("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRBAC := *tlsOpts
+	withRBAC.rbacFile = writeTestJSON(t, filepath.Join(dir, "rbac.json"), rbacConfig{Grants: []rbacGrant{
+		{Identities: []string{"cert:ops.a"}, Keys: []string{testKeyA.Hex()}, MaxValueWei: big.NewInt(100)},
+	}})
+	_, caRBAC, err := withRBAC.open("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daemons := map[string]*clientAuth{"ca-only": caOnly, "ca+jwt": caJWT, "ca+rbac": caRBAC, "tenant-a": tenantAuth["a"], "tenant-b": tenantAuth["b"]}
+	tests := []struct {
+		name, daemon string
+		r            *http.Request
+		wantErr      error
+		wantID       string
+		// allowed and denied are op@key pairs checked with authorize.
+		allowed, denied []string
+	}{
+		{"ca-only cert signs", "ca-only", request("ops.a", ""), nil, "cert:ops.a",
+			[]string{"view@a", "sign@a", "sign@b"}, []string{"approve@a", "admin@a"}},
+		{"ca-only no cert", "ca-only", request("", ""), errUnauthenticated, "", nil, nil},
+		{"ca+jwt unlisted cert", "ca+jwt", request("ops.a", ""), errUnauthenticated, "", nil, nil},
+		{"ca+jwt token", "ca+jwt", request("", idp.token(t, testIssuer, "alice", "approver")), nil, "jwt:alice",
+			[]string{"approve@a"}, []string{"sign@a", "admin@a"}},
+		{"ca+rbac cert", "ca+rbac", request("ops.a", ""), nil, "cert:ops.a", []string{"sign@a"}, []string{"admin@a"}},
+
+		{"tenant a listed cert", "tenant-a", request("ops.a", ""), nil, "client:ops",
+			[]string{"sign@a", "sign@b"}, []string{"approve@a", "admin@a"}},
+		{"tenant a cert without roles", "tenant-a", request("root.a", ""), nil, "client:root",
+			[]string{"sign@a", "approve@a", "admin@a"}, nil},
+		{"tenant a unlisted cert", "tenant-a", request("ops.b", ""), errUnauthenticated, "", nil, nil},
+		{"tenant a api key", "tenant-a", request("", testAPIKey), nil, "client:bot", []string{"view@a"}, []string{"sign@a"}},
+		{"tenant a token", "tenant-a", request("", idp.token(t, testIssuer, "alice", "admin")), errUnauthenticated, "", nil, nil},
+
+		{"tenant b rejects tenant a cert", "tenant-b", request("ops.a", ""), errUnauthenticated, "", nil, nil},
+		{"tenant b rejects tenant a cert without roles", "tenant-b", request("root.a", ""), errUnauthenticated, "", nil, nil},
+		{"tenant b signer token", "tenant-b", request("", idp.token(t, testIssuer, "bob", "signer")), nil, "jwt:bob",
+			[]string{"sign@b", "view@b"}, []string{"sign@a", "approve@b"}},
+		{"tenant b token without roles", "tenant-b", request("", idp.token(t, testIssuer, "carol")), nil, "jwt:carol",
+			nil, []string{"view@a", "sign@b"}},
+		{"tenant b cert and token", "tenant-b", request("ops.a", idp.token(t, testIssuer, "bob", "signer")), nil, "jwt:bob", []string{"sign@b"}, nil},
+		{"tenant b foreign issuer", "tenant-b", request("", idp.token(t, "https://evil.example", "bob", "admin")), errInvalidToken, "", nil, nil},
+		{"tenant b api key", "tenant-b", request("", testAPIKey), errUnauthenticated, "", nil, nil},
+	}
+	keys := map[string]common.Address{"a": testKeyA, "b": testKeyB}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := daemons[tt.daemon].identify(tt.r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("identify error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if c.id != tt.wantID {
+				t.Errorf("id = %q, want %q", c.id, tt.wantID)
+			}
+			if strings.HasPrefix(c.id, "cert:") && c.roles == nil {
+				t.Error("certificate caller has unrestricted roles")
+			}
+			ctx := context.WithValue(context.Background(), callerKey{}, c)
+			for _, want := range []struct {
+				ops []string
+				ok  bool
+			}{{tt.allowed, true}, {tt.denied, false}} {
+				for _, opKey := range want.ops {
+					op, key, _ := strings.Cut(opKey, "@")
+					if err := authorize(ctx, op, keys[key]); (err == nil) != want.ok {
+						t.Errorf("authorize %s = %v, want allowed %v", opKey, err, want.ok)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCheckGrants(t *testing.T) {
+	cfg := &rbacConfig{Grants: []rbacGrant{
+		{Identities: []string{"cert:ops.a"}, Keys: []string{testKeyA.Hex()}, ChainIDs: []uint64{1}, MaxValueWei: big.NewInt(100)},
+		{Identities: []string{"jwt:alice", "client:ops"}, Keys: []string{testKeyB.Hex()}},
+	}}
+	tests := []struct {
+		id      string
+		key     common.Address
+		chainID *big.Int
+		value   *big.Int
+		ok      bool
+	}{
+		{"cert:ops.a", testKeyA, big.NewInt(1), big.NewInt(100), true},
+		{"cert:ops.a", testKeyA, big.NewInt(1), big.NewInt(101), false},
+		{"cert:ops.a", testKeyA, big.NewInt(5), big.NewInt(1), false},
+		{"cert:ops.a", testKeyA, nil, nil, false},
+		{"cert:ops.a", testKeyB, big.NewInt(1), big.NewInt(1), false},
+		{"jwt:alice", testKeyB, nil, nil, true},
+		{"jwt:alice", testKeyA, big.NewInt(1), big.NewInt(1), false},
+		{"client:ops", testKeyB, big.NewInt(5), big.NewInt(1e18), true},
+		{"cert:ops", testKeyB, big.NewInt(1), big.NewInt(1), false},
+		{"jwt:mallory", testKeyA, big.NewInt(1), big.NewInt(1), false},
+	}
+	for _, tt := range tests {
+		c := &caller{name: tt.id, id: tt.id, rbac: true, grants: cfg.grantsFor(tt.id)}
+		ctx := context.WithValue(context.Background(), callerKey{}, c)
+		if err := checkGrants(ctx, tt.key, tt.chainID, tt.value); (err == nil) != tt.ok {
+			t.Errorf("%s with %s on chain %v for %v: err = %v, want allowed %v", tt.id, tt.key.Hex(), tt.chainID, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestLoadClients(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api.key")
+	shortKeyFile := filepath.Join(dir, "short.key")
+	if err := os.WriteFile(keyFile, []byte(testAPIKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(shortKeyFile, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		clients []clientConfig
+		err     string
+	}{
+		{"valid", []clientConfig{{Name: "bot", APIKeyFile: keyFile, RatePerMinute: 60}, {Name: "ops", CertNames: []string{"ops"}}}, ""},
+		{"none", []clientConfig{}, "lists no clients"},
+		{"unnamed", []clientConfig{{CertNames: []string{"ops"}}}, "name is required"},
+		{"duplicate", []clientConfig{{Name: "ops", CertNames: []string{"a"}}, {Name: "ops", CertNames: []string{"b"}}}, "must be unique"},
+		{"no credentials", []clientConfig{{Name: "ops"}}, "set cert_names, api_key_file or both"},
+		{"short api key", []clientConfig{{Name: "bot", APIKeyFile: shortKeyFile}}, "at least 16 bytes"},
+		{"missing api key", []clientConfig{{Name: "bot", APIKeyFile: filepath.Join(dir, "missing.key")}}, "failed to read api key"},
+		{"unknown role", []clientConfig{{Name: "ops", CertNames: []string{"ops"}, Roles: []string{"root"}}}, `unknown role "root"`},
+		{"negative rate", []clientConfig{{Name: "ops", CertNames: []string{"ops"}, RatePerMinute: -1}}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadClients(writeTestJSON(t, filepath.Join(t.TempDir(), "clients.json"), tt.clients))
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("loadClients = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api.key")
+	if err := os.WriteFile(keyFile, []byte(testAPIKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := loadClients(writeTestJSON(t, filepath.Join(dir, "clients.json"), []clientConfig{
+		// Both a certificate and the key are required.
+		{Name: "ops", CertNames: []string{"ops.example"}, APIKeyFile: keyFile},
+		{Name: "bot", APIKeyFile: keyFile, RatePerMinute: 1, Burst: 2},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withHeader := func(r *http.Request, key, value string) *http.Request {
+		r.Header.Set(key, value)
+		return r
+	}
+	handler := auth.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(callerName(r.Context())))
+	}))
+	serve := func(r *http.Request) (int, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		code int
+		body string
+	}{
+		{"no credentials", request("", ""), http.StatusUnauthorized, "unauthorized"},
+		{"wrong key", request("", strings.Repeat("0", len(testAPIKey))), http.StatusUnauthorized, "unauthorized"},
+		{"unlisted cert", request("other.example", ""), http.StatusUnauthorized, "unauthorized"},
+		{"upcheck is open", httptest.NewRequest(http.MethodGet, "/upcheck", nil), http.StatusOK, ""},
+		{"cert and key", request("ops.example", testAPIKey), http.StatusOK, "ops"},
+		{"key as bearer token", request("", testAPIKey), http.StatusOK, "bot"},
+		{"key in X-API-Key", withHeader(request("", ""), "X-API-Key", testAPIKey), http.StatusOK, "bot"},
+		// bot's burst of 2 is used up by the two requests above.
+		{"rate limited", request("", testAPIKey), http.StatusTooManyRequests, errRateLimited.Error()},
+		{"other client not limited", request("ops.example", testAPIKey), http.StatusOK, "ops"},
+	}
+	for _, tt := range tests {
+		code, body := serve(tt.r)
+		if code != tt.code || body != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, code, body, tt.code, tt.body)
+		}
+	}
+}
+
+// signTestJWT returns a token with claims signed by key with alg, naming
+// the key kid.
+func signTestJWT(t *testing.T, alg jose.SignatureAlgorithm, key any, kid string, claims ...any) string {
+	t.Helper()
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: kid}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := jwt.Signed(sig)
+	for _, c := range claims {
+		b = b.Claims(c)
+	}
+	token, err := b.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWTVerify(t *testing.T) {
+	idp := newTestIdP(t)
+	dir := t.TempDir()
+	v, err := loadJWTVerifier(writeTestJSON(t, filepath.Join(dir, "jwt.json"), jwtConfig{
+		JWKSURL:       idp.srv.URL,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+		RolesClaim:    "realm_access.roles",
+		RoleMap:       map[string]string{"payments-team": "signer"},
+		LeewaySeconds: 30,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := func(edit func(*jwt.Claims)) jwt.Claims {
+		c := jwt.Claims{Issuer: testIssuer, Subject: "alice", Audience: jwt.Audience{"other", testAudience}, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}
+		if edit != nil {
+			edit(&c)
+		}
+		return c
+	}
+	roles := map[string]any{"realm_access": map[string]any{"roles": []string{"payments-team", "approver", "unknown"}}}
+	valid := func(edit func(*jwt.Claims)) string {
+		return signTestJWT(t, jose.ES256, idp.key, "k1", claims(edit), roles)
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`))
+	body, err := json.Marshal(claims(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(body) + "."
+
+	c, err := v.verify(t.Context(), valid(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.id != "jwt:alice" || strings.Join(c.roles, ",") != "signer,approver" {
+		t.Fatalf("caller = %+v, want jwt:alice with roles signer,approver", c)
+	}
+	// Within the leeway, a just-expired token is still accepted.
+	if _, err := v.verify(t.Context(), valid(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-10 * time.Second)) })); err != nil {
+		t.Fatalf("token inside the leeway refused: %v", err)
+	}
+
+	tests := []struct {
+		name, token, err string
+	}{
+		{"expired", valid(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-time.Minute)) }), "token is expired"},
+		{"no expiry", valid(func(c *jwt.Claims) { c.Expiry = nil }), "no expiry"},
+		{"not yet valid", valid(func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }), "not valid yet"},
+		{"wrong audience", valid(func(c *jwt.Claims) { c.Audience = jwt.Audience{"other"} }), "invalid audience"},
+		{"no audience", valid(func(c *jwt.Claims) { c.Audience = nil }), "invalid audience"},
+		{"wrong issuer", valid(func(c *jwt.Claims) { c.Issuer = "https://evil.example" }), "invalid issuer"},
+		{"no subject", valid(func(c *jwt.Claims) { c.Subject = "" }), "no subject"},
+		{"unsigned", unsigned, `unexpected signature algorithm "none"`},
+		{"symmetric algorithm", signTestJWT(t, jose.HS256, []byte(strings.Repeat("k", 32)), "k1", claims(nil)), `unexpected signature algorithm "HS256"`},
+		{"algorithm of another key type", signTestJWT(t, jose.RS256, rsaKey, "k1", claims(nil)), "does not match key k1"},
+		{"unknown key", signTestJWT(t, jose.ES256, idp.key, "k2", claims(nil)), errUnknownJWK.Error()},
+		{"forged signature", signTestJWT(t, jose.ES256, otherKey, "k1", claims(nil)), "cryptographic primitive"},
+		{"malformed", "a.b.c", "illegal base64"},
+	}
+	for _, tt := range tests {
+		if c, err := v.verify(t.Context(), tt.token); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: verify = %+v, %v; want an error containing %q", tt.name, c, err, tt.err)
+		}
+	}
+}
+
+func TestLoadJWTVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	tests := []struct {
+		name string
+		cfg  jwtConfig
+		err  string
+	}{
+		{"bad jwks url", jwtConfig{JWKSURL: "file:///etc/jwks.json", Issuer: testIssuer, Audience: testAudience}, "invalid jwks_url"},
+		{"no audience", jwtConfig{JWKSURL: idp.srv.URL, Issuer: testIssuer}, "issuer and audience are required"},
+		{"no issuer", jwtConfig{JWKSURL: idp.srv.URL, Audience: testAudience}, "issuer and audience are required"},
+		{"unknown mapped role", jwtConfig{JWKSURL: idp.srv.URL, Issuer: testIssuer, Audience: testAudience, RoleMap: map[string]string{"ops": "root"}}, `unknown role "root"`},
+		{"bad role key", jwtConfig{JWKSURL: idp.srv.URL, Issuer: testIssuer, Audience: testAudience, RoleKeys: map[string][]string{"signer": {"treasury"}}}, "invalid address"},
+	}
+	for _, tt := range tests {
+		_, err := loadJWTVerifier(writeTestJSON(t, filepath.Join(t.TempDir(), "jwt.json"), tt.cfg))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: loadJWTVerifier = %v, want an error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
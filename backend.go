@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+// openKeyBackend returns the backend selected by src, which refuses to sign
+// while store records a freeze.
+func openKeyBackend(ctx context.Context, src keySource, store signer.Store) (keys.Backend, error) {
+	key, err := openBackend(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return signer.GuardFrozen(key, store), nil
+}
+
+// openBackend returns the backend selected by src. -pkcs11-module or a
+// -key value with a URI scheme (e.g. awskms://...) selects a remote backend;
+// everything else is loaded into memory. A -from alias is first resolved to
+// the keyring's keystore file or backend URI for it.
+func openBackend(ctx context.Context, src keySource) (keys.Backend, error) {
+	if src.from != "" {
+		var err error
+		if src, err = resolveKeySource(src); err != nil {
+			return nil, err
+		}
+	}
+	if src.pkcs11Module != "" {
+		if src.hexKey != "" || src.keystoreFile != "" || src.fromStdin || src.mnemonic || src.mnemonicFile != "" {
+			return nil, errors.New("pkcs11-module cannot be combined with another key source")
+		}
+		return newPKCS11Backend(src)
+	}
+	if scheme, ref, ok := strings.Cut(src.hexKey, "://"); ok {
+		switch scheme {
+		case "awskms":
+			return keys.NewAWSKMSBackend(ctx, ref)
+		case "gcpkms":
+			return keys.NewGCPKMSBackend(ctx, ref)
+		case "azurekv":
+			return keys.NewAzureKVBackend(ctx, ref)
+		case "ledger":
+			return newLedgerBackend(ref)
+		case "trezor":
+			return newTrezorBackend(ref, src.passphraseFile)
+		case "vault":
+			return newVaultPluginBackend(ctx, src, ref)
+		case "vaultkv":
+			key, err := loadVaultKVKey(ctx, src, ref)
+			if err != nil {
+				return nil, err
+			}
+			return keys.NewLocalKey(key), nil
+		default:
+			return nil, fmt.Errorf("unknown key backend %q", scheme)
+		}
+	}
+	key, err := loadSigningKey(src)
+	if err != nil {
+		return nil, err
+	}
+	return keys.NewLocalKey(key), nil
+}
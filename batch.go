@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// batchRow is one transfer read from a sign-batch input file.
+type batchRow struct {
+	line     int
+	to       common.Address
+	amount   *big.Int
+	data     []byte
+	gasLimit uint64
+}
+
+// batchInputRow is the NDJSON form of a batch row. Amounts may be given as
+// JSON numbers or decimal strings.
+type batchInputRow struct {
+	To       string      `json:"to"`
+	Amount   json.Number `json:"amount"`
+	Data     string      `json:"data"`
+	GasLimit uint64      `json:"gasLimit"`
+}
+
+// batchResult is one line of the sign-batch output.
+type batchResult struct {
+	Line        int    `json:"line"`
+	To          string `json:"to"`
+	Amount      string `json:"amount"`
+	Nonce       uint64 `json:"nonce"`
+	TxHash      string `json:"txHash"`
+	RawTx       string `json:"rawTx"`
+	ExplorerURL string `json:"explorerUrl,omitempty"`
+}
+
+func runSignBatch(args []string) error {
+	fs := flag.NewFlagSet("sign-batch", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	input := fs.String("input", "", "Transfers to sign: CSV (to,amount[,data[,gas_limit]]) when the file ends in .csv, NDJSON otherwise")
+	out := fs.String("out", "", "File to write the signed transactions to as NDJSON (stdout when omitted)")
+	nonce := fs.Uint64("nonce", 0, "Nonce of the first transaction (allocated from -store, reconciled with the -rpc pending nonce, when omitted)")
+	chainID := addChainFlag(fs, 1, "Chain ID (default Ethereum mainnet, or fetched via -rpc when omitted)")
+	policyOpts := addPolicyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store for cumulative policy limits, approvals, time-delayed transactions and the freeze switch")
+	overrideReason := addOverrideFlag(fs)
+	confirmRecipient := addNewRecipientFlag(fs)
+	allowReplace := addAllowReplaceFlag(fs)
+	confirm := addConfirmFlag(fs)
+	approvalsDir := fs.String("approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements")
+	alertsConfig := addAlertFlags(fs)
+	postSignHooks := addPostSignHooksFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	skipDenied := fs.Bool("skip-denied", false, "Skip rows the policy denies instead of stopping; skipped rows do not use a nonce")
+	feeOpts := addFeeFlags(fs)
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of transactions to sign concurrently once the policy has passed them")
+	gasLimit := fs.Uint64("gas-limit", 0, "Gas limit for rows without one (default 21000 for plain transfers or estimated via -rpc)")
+	rpcOpts := addRPCFlags(fs)
+	parseFlags(fs, args)
+
+	if *input == "" {
+		return errors.New("input is required")
+	}
+	if *workers < 1 {
+		return errors.New("workers must be positive")
+	}
+	rows, err := readBatch(*input)
+	if err != nil {
+		return fmt.Errorf("failed to read batch: %v", err)
+	}
+
+	ctx := context.Background()
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	pol, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+
+	chain := chainID.big()
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+		var want *big.Int
+		if flagWasSet(fs, "chain") {
+			want = chain
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return err
+		}
+	}
+	fees, err := feeOpts.params(ctx, client, pol.Fees)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.gasLimit == 0 {
+			row.gasLimit = *gasLimit
+		}
+		if row.gasLimit == 0 && len(row.data) > 0 {
+			if client == nil {
+				return fmt.Errorf("line %d: gas-limit is required when data is set", row.line)
+			}
+			if row.gasLimit, err = txbuilder.EstimateGasLimit(ctx, client, pol.Fees, ethereum.CallMsg{From: key.Address(), To: &row.to, Value: row.amount, Data: row.data}); err != nil {
+				return fmt.Errorf("line %d: %v", row.line, err)
+			}
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		// Never overwrite the output of an earlier run: it may hold
+		// transactions that were already broadcast.
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	enc := json.NewEncoder(bw)
+
+	eng := signer.New(pol, key, store)
+	eng.ENS = policy.NewENSResolver(client)
+	eng.L1Fees = signer.NewL1FeeOracle(client)
+	eng.TokenDecimals = signer.NewTokenDecimals(client)
+	eng.OverrideReason = *overrideReason
+	eng.ConfirmRecipient = confirmRecipient
+	eng.AllowReplace = *allowReplace
+	if *confirm {
+		eng.Confirm = confirmTx
+	}
+	eng.ApprovalsDir = *approvalsDir
+	if eng.Alerts, err = signer.LoadAlerter(*alertsConfig); err != nil {
+		return err
+	}
+	if *postSignHooks != "" {
+		if eng.PostSign, err = signer.LoadPostSignHooks(*postSignHooks); err != nil {
+			return err
+		}
+		defer eng.PostSign.Wait()
+	}
+	if eng.Audit, err = auditOpts.open(store); err != nil {
+		return err
+	}
+	var nonces *signer.NonceManager
+	if !flagWasSet(fs, "nonce") {
+		nonces = &signer.NonceManager{Store: store, Client: client, From: key.Address(), OnGap: warnNonceGap}
+		if *nonce, err = nonces.PendingNonce(ctx); err != nil {
+			return err
+		}
+	}
+
+	// The policy and cumulative limits are applied in row order so nonces
+	// stay sequential and a denied row stops (or is skipped) at the same
+	// point as a one-by-one run. Only the signing itself runs in parallel.
+	var auths []*signer.Authorization
+	var authRows []*batchRow
+	var denyErr error
+	skipped := 0
+	next := *nonce
+	for _, row := range rows {
+		if nonces != nil {
+			if next, err = nonces.Allocate(chain, next); err != nil {
+				denyErr = err
+				break
+			}
+		}
+		tx, err := txbuilder.Build(next, row.to, row.amount, row.data, row.gasLimit, chain, fees)
+		var auth *signer.Authorization
+		if err != nil {
+			err = fmt.Errorf("failed to build tx: %v", err)
+		} else {
+			auth, err = eng.Authorize(ctx, tx, chain)
+		}
+		if err != nil {
+			if nonces != nil {
+				nonces.Release(chain, next)
+			}
+			var pe *policy.DeniedError
+			if *skipDenied && errors.As(err, &pe) {
+				fmt.Fprintf(os.Stderr, "line %d: skipped: %v\n", row.line, err)
+				skipped++
+				continue
+			}
+			denyErr = fmt.Errorf("line %d: %v", row.line, err)
+			break
+		}
+		auths = append(auths, auth)
+		authRows = append(authRows, row)
+		next++
+	}
+
+	signed := 0
+	err = signBatch(ctx, auths, *workers, func(i int, signedTx *types.Transaction) error {
+		raw, err := signedTx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		row := authRows[i]
+		if err := enc.Encode(batchResult{
+			Line:        row.line,
+			To:          row.to.Hex(),
+			Amount:      row.amount.String(),
+			Nonce:       signedTx.Nonce(),
+			TxHash:      signedTx.Hash().Hex(),
+			RawTx:       hexutil.Encode(raw),
+			ExplorerURL: chains.ExplorerTxURL(chain, signedTx.Hash().Hex()),
+		}); err != nil {
+			return err
+		}
+		signed++
+		return nil
+	})
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	if err == nil {
+		err = denyErr
+	}
+	if err != nil {
+		return fmt.Errorf("%v (%d transactions signed)", err, signed)
+	}
+	fmt.Fprintf(os.Stderr, "signed %d transactions, skipped %d\n", signed, skipped)
+	return nil
+}
+
+// signBatch signs auths on workers goroutines and passes the results to
+// emit in order. It stops at the first signing or emit error; transactions
+// after it are not emitted.
+//
+// Signing with a local key is CPU bound and shares its time with the store
+// write recording each nonce, which does not parallelize on sqlite; see
+// BenchmarkBatchSign and the README for measured throughput. Remote key
+// backends (KMS, Vault) are bound by round trips and benefit from more
+// workers than cores.
+func signBatch(ctx context.Context, auths []*signer.Authorization, workers int, emit func(int, *types.Transaction) error) error {
+	type result struct {
+		tx  *types.Transaction
+		err error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	results := make([]chan result, len(auths))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				tx, err := auths[i].Sign(ctx)
+				results[i] <- result{tx, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range auths {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for i := range auths {
+		r := <-results[i]
+		if r.err != nil {
+			return fmt.Errorf("nonce %d: %v", auths[i].Tx.Nonce(), r.err)
+		}
+		if err := emit(i, r.tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBatch reads and validates every row of file before anything is signed.
+func readBatch(file string) ([]*batchRow, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return readBatchCSV(f)
+	}
+	return readBatchNDJSON(f)
+}
+
+func readBatchCSV(r io.Reader) ([]*batchRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var rows []*batchRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if line == 1 && strings.EqualFold(record[0], "to") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: want to,amount[,data[,gas_limit]]", line)
+		}
+		in := batchInputRow{To: record[0], Amount: json.Number(record[1])}
+		if len(record) > 2 {
+			in.Data = record[2]
+		}
+		if len(record) > 3 && record[3] != "" {
+			if _, err := fmt.Sscan(record[3], &in.GasLimit); err != nil {
+				return nil, fmt.Errorf("line %d: invalid gas limit %q", line, record[3])
+			}
+		}
+		row, err := in.parse(line)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readBatchNDJSON(r io.Reader) ([]*batchRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var rows []*batchRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var in batchInputRow
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&in); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		row, err := in.parse(line)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func (in batchInputRow) parse(line int) (*batchRow, error) {
+	if !common.IsHexAddress(in.To) {
+		return nil, fmt.Errorf("line %d: invalid recipient %q", line, in.To)
+	}
+	amount, err := txbuilder.ParseWei("amount", in.Amount.String())
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", line, err)
+	}
+	data, err := txbuilder.ParseCalldata(in.Data)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", line, err)
+	}
+	return &batchRow{line: line, to: common.HexToAddress(in.To), amount: amount, data: data, gasLimit: in.GasLimit}, nil
+}
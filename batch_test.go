@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BenchmarkBatchSign measures sign batch throughput with a local key and a
+// sqlite store, the defaults, for several batch sizes and worker counts. It
+// reports the rate of the serial policy phase and of the parallel signing
+// phase separately, as well as overall.
+func BenchmarkBatchSign(b *testing.B) {
+	workers := []int{1, 2, 4, 8}
+	if n := runtime.NumCPU(); n > 8 {
+		workers = append(workers, n)
+	}
+	for _, size := range []int{100, 1000, 10000} {
+		for _, w := range workers {
+			b.Run(fmt.Sprintf("rows=%d/workers=%d", size, w), func(b *testing.B) {
+				benchmarkBatchSign(b, size, w)
+			})
+		}
+	}
+}
+
+func benchmarkBatchSign(b *testing.B, size, workers int) {
+	ctx := context.Background()
+	to := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	pol, err := policy.Parse([]byte(`{"max_amount_wei": 1000000000000000000, "whitelist": ["` + to.Hex() + `"]}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	secret, err := keys.SecretKeyFromECDSA(priv)
+	if err != nil {
+		b.Fatal(err)
+	}
+	store, err := signer.OpenStore(filepath.Join(b.TempDir(), "state.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	eng := signer.New(pol, keys.NewLocalKey(secret), store)
+
+	chain := big.NewInt(1)
+	fees := txbuilder.FeeParams{TxType: txbuilder.TxTypeDynamic, MaxFee: big.NewInt(30e9), MaxPriorityFee: big.NewInt(1e9)}
+	var nonce uint64
+	var authorizing, signing time.Duration
+	for b.Loop() {
+		start := time.Now()
+		auths := make([]*signer.Authorization, size)
+		for i := range auths {
+			tx, err := txbuilder.Build(nonce, to, big.NewInt(1e15), nil, 0, chain, fees)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if auths[i], err = eng.Authorize(ctx, tx, chain); err != nil {
+				b.Fatal(err)
+			}
+			nonce++
+		}
+		signStart := time.Now()
+		if err := signBatch(ctx, auths, workers, func(int, *types.Transaction) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+		authorizing += signStart.Sub(start)
+		signing += time.Since(signStart)
+	}
+	rows := float64(size * b.N)
+	b.ReportMetric(rows/authorizing.Seconds(), "authorize-tx/s")
+	b.ReportMetric(rows/signing.Seconds(), "sign-tx/s")
+	b.ReportMetric(rows/(authorizing+signing).Seconds(), "tx/s")
+}
+
+func TestReadBatch(t *testing.T) {
+	const to = "0x1000000000000000000000000000000000000001"
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		rows    int
+		err     string
+	}{
+		{"csv", "batch.csv", "to,amount,data,gas_limit\n" + to + ",1\n" + to + ", 2, 0x01020304, 50000\n", 2, ""},
+		{"csv too few fields", "batch.csv", to + "\n", 0, "line 1: want to,amount[,data[,gas_limit]]"},
+		{"csv too many fields", "batch.csv", to + ",1,,1,x\n", 0, "line 1: want to,amount"},
+		{"csv bad recipient", "batch.csv", to + ",1\n0x1234,1\n", 0, `line 2: invalid recipient "0x1234"`},
+		{"csv bad amount", "batch.csv", to + ",1.5\n", 0, `line 1: invalid amount: "1.5"`},
+		{"csv negative amount", "batch.csv", to + ",-1\n", 0, `line 1: invalid amount: "-1"`},
+		{"csv bad data", "batch.csv", to + ",1,0xzz\n", 0, "line 1: invalid data"},
+		{"csv bad gas limit", "batch.csv", to + ",1,,many\n", 0, `line 1: invalid gas limit "many"`},
+		{"csv unterminated quote", "batch.csv", to + ",\"1\n", 0, "extraneous or missing \" in quoted-field"},
+		{"ndjson", "batch.ndjson", `{"to": "` + to + `", "amount": 1}` + "\n\n" + `{"to": "` + to + `", "amount": "2", "data": "0x01", "gasLimit": 50000}` + "\n", 2, ""},
+		{"ndjson unknown field", "batch.ndjson", `{"to": "` + to + `", "value": 1}` + "\n", 0, `line 1: json: unknown field "value"`},
+		{"ndjson malformed", "batch.ndjson", `{"to": "` + to + `", "amount": 1}` + "\n{\n", 0, "line 2: unexpected EOF"},
+		{"ndjson bad amount", "batch.ndjson", `{"to": "` + to + `", "amount": 1e18}` + "\n", 0, `line 1: invalid amount: "1e18"`},
+		{"ndjson missing recipient", "batch.ndjson", `{"amount": 1}` + "\n", 0, `line 1: invalid recipient ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := readBatch(file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readBatch = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != tt.rows {
+				t.Fatalf("read %d rows, want %d", len(rows), tt.rows)
+			}
+			last := rows[len(rows)-1]
+			if last.amount.Int64() != 2 || len(last.data) == 0 || last.gasLimit != 50000 || last.to != common.HexToAddress(to) {
+				t.Fatalf("last row = %+v", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// receiptPollInterval is how often the receipt is polled while waiting.
+const receiptPollInterval = 2 * time.Second
+
+// broadcastFlags control submission and receipt waiting, shared by broadcast
+// and sign -send.
+type broadcastFlags struct {
+	wait          bool
+	confirmations uint64
+	timeout       time.Duration
+}
+
+func addBroadcastFlags(fs *flag.FlagSet) *broadcastFlags {
+	b := new(broadcastFlags)
+	fs.BoolVar(&b.wait, "wait", false, "Wait for the transaction receipt")
+	fs.Uint64Var(&b.confirmations, "confirmations", 1, "Blocks (including the inclusion block) to wait for with -wait")
+	fs.DurationVar(&b.timeout, "timeout", 5*time.Minute, "Maximum time to wait for the receipt")
+	return b
+}
+
+// receiptOutput is the JSON form of a receipt.
+type receiptOutput struct {
+	BlockNumber string `json:"blockNumber"`
+	Status      string `json:"status"`
+}
+
+func newReceiptOutput(receipt *types.Receipt) *receiptOutput {
+	if receipt == nil {
+		return nil
+	}
+	status := "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		status = "failed"
+	}
+	return &receiptOutput{BlockNumber: receipt.BlockNumber.String(), Status: status}
+}
+
+// sendTx submits tx via eth_sendRawTransaction and, if requested, waits for
+// its receipt, reporting progress to w. A mined but reverted transaction is
+// returned with an error.
+func sendTx(ctx context.Context, client *ethclient.Client, tx *types.Transaction, opts *broadcastFlags, w io.Writer) (*types.Receipt, error) {
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to send tx: %v", err)
+	}
+	fmt.Fprintln(w, "TxHash:", tx.Hash().Hex())
+	if !opts.wait {
+		return nil, nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+	receipt, err := waitForReceipt(waitCtx, client, tx.Hash(), opts.confirmations)
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for receipt: %v", err)
+	}
+	out := newReceiptOutput(receipt)
+	fmt.Fprintln(w, "BlockNumber:", out.BlockNumber)
+	fmt.Fprintln(w, "Status:", out.Status)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, errors.New("transaction reverted")
+	}
+	return receipt, nil
+}
+
+// waitForReceipt polls until hash is mined with the given number of
+// confirmations. The receipt is re-fetched on every poll so a reorg that
+// drops or moves the transaction is noticed.
+func waitForReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash, confirmations uint64) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		switch {
+		case err == nil:
+			if confirmations <= 1 {
+				return receipt, nil
+			}
+			head, err := client.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if head+1 >= receipt.BlockNumber.Uint64()+confirmations {
+				return receipt, nil
+			}
+		case !errors.Is(err, ethereum.NotFound):
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func runBroadcast(args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "JSON-RPC endpoint URL")
+	rawHex := fs.String("raw", "", "Signed raw transaction in hex")
+	signedFile := fs.String("signed-file", "", "Signed transaction file written by sign -signed-file (instead of -raw)")
+	opts := addBroadcastFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *rpcURL == "" || (*rawHex == "") == (*signedFile == "") {
+		return errors.New("rpc and one of raw and signed-file are required")
+	}
+	var (
+		tx  *types.Transaction
+		err error
+	)
+	if *signedFile != "" {
+		tx, err = readSignedTx(*signedFile)
+	} else {
+		tx, err = txbuilder.DecodeRawTx(*rawHex)
+	}
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := ethclient.DialContext(ctx, *rpcURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rpc: %v", err)
+	}
+	defer client.Close()
+	receipt, err := sendTx(ctx, client, tx, opts, output.progress())
+	if *output == outputJSON && (err == nil || receipt != nil) {
+		writeJSON(struct {
+			TxHash  string         `json:"txHash"`
+			Receipt *receiptOutput `json:"receipt,omitempty"`
+		}{tx.Hash().Hex(), newReceiptOutput(receipt)})
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+)
+
+// chainFlag is a -chain value: a chain ID, or the name of a chain in the
+// registry.
+type chainFlag struct {
+	id     uint64
+	preset *chains.Preset
+}
+
+func addChainFlag(fs *flag.FlagSet, def uint64, usage string) *chainFlag {
+	c := &chainFlag{id: def}
+	if p, ok := chains.ByID(new(big.Int).SetUint64(def)); ok && def != 0 {
+		c.preset = &p
+	}
+	fs.Var(c, "chain", usage+"; a chain ID or a name such as mainnet, sepolia, arbitrum, optimism, base or polygon")
+	return c
+}
+
+func (c *chainFlag) String() string {
+	if c == nil {
+		return ""
+	}
+	return strconv.FormatUint(c.id, 10)
+}
+
+func (c *chainFlag) Set(s string) error {
+	if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+		c.id, c.preset = id, nil
+		if p, ok := chains.ByID(new(big.Int).SetUint64(id)); ok {
+			c.preset = &p
+		}
+		return nil
+	}
+	registry, err := chains.Load()
+	if err != nil {
+		return err
+	}
+	p, ok := registry[strings.ToLower(s)]
+	if !ok {
+		names := make([]string, 0, len(registry))
+		for name := range registry {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown chain %q (known chains: %s)", s, strings.Join(names, ", "))
+	}
+	c.id, c.preset = p.ChainID, &p
+	return nil
+}
+
+// big returns the chain ID.
+func (c *chainFlag) big() *big.Int {
+	return new(big.Int).SetUint64(c.id)
+}
+
+// defaultRPC returns the first public endpoint of the selected chain.
+func (c *chainFlag) defaultRPC() (string, error) {
+	if c.preset == nil || len(c.preset.RPC) == 0 {
+		return "", fmt.Errorf("chain %d has no default rpc endpoint", c.id)
+	}
+	return c.preset.RPC[0], nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Environment variables that select the config file and profile when
+// -config and -profile are not given.
+const (
+	envConfig  = "SIGNER_CONFIG"
+	envProfile = "SIGNER_PROFILE"
+)
+
+// signerConfig is a -config file, YAML or TOML by its extension, of named
+// profiles. A profile maps flag names to values, a list for a repeatable
+// flag, and changes the defaults of those flags for every command that
+// has them, so they need not be repeated; flags given on the command line
+// still win. Profile is the profile used when -profile is not given.
+//
+//	profile: staging
+//	profiles:
+//	  prod:
+//	    rpc: https://eth.example.com
+//	    chain: 1
+//	    policy: /etc/signer/prod-policy.json
+//	    key: awskms://alias/signer-prod
+//	    output: json
+type signerConfig struct {
+	Profile  string                    `json:"profile" toml:"profile"`
+	Profiles map[string]map[string]any `json:"profiles" toml:"profiles"`
+}
+
+// profileExclusive are groups of flags that exclude one another: a profile
+// value for one is not applied when the command line gives another, so
+// that, e.g., -keystore overrides the profile's key and -offline its rpc.
+var profileExclusive = [][]string{
+	{"key", "keystore", "key-stdin", "mnemonic", "mnemonic-file", "from", "pkcs11-module"},
+	{"rpc", "offline"},
+}
+
+// configFlags select the config file and profile. They are global flags,
+// given before the command name, and are also accepted by every command.
+type configFlags struct {
+	file    string
+	profile string
+}
+
+// globalConfig holds the global -config and -profile.
+var globalConfig configFlags
+
+// addConfigFlags registers -config and -profile on fs with defaults def.
+func addConfigFlags(fs *flag.FlagSet, def configFlags) *configFlags {
+	c := new(configFlags)
+	fs.StringVar(&c.file, "config", def.file, "YAML or TOML file of named profiles of flag defaults (default $"+envConfig+")")
+	fs.StringVar(&c.profile, "profile", def.profile, "Profile of -config to use (default $"+envProfile+" or the file's profile)")
+	return c
+}
+
+// parseFlags parses the command flags in args and applies the selected
+// config profile to the flags left unset. It exits like fs.Parse when the
+// profile cannot be applied.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	config := addConfigFlags(fs, globalConfig)
+	fs.Parse(args)
+	if err := config.apply(fs); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		os.Exit(2)
+	}
+}
+
+// apply sets the flags of fs named by the selected profile that were not
+// given on the command line. Names the command has no flag for are
+// skipped, since a profile is shared by all commands.
+func (c *configFlags) apply(fs *flag.FlagSet) error {
+	if c.file == "" {
+		if c.profile != "" {
+			return errors.New("profile requires config")
+		}
+		return nil
+	}
+	name, profile, err := loadProfile(c.file, c.profile)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, group := range profileExclusive {
+		if slices.ContainsFunc(group, func(flagName string) bool { return set[flagName] }) {
+			for _, flagName := range group {
+				set[flagName] = true
+			}
+		}
+	}
+	flagNames := make([]string, 0, len(profile))
+	for flagName := range profile {
+		flagNames = append(flagNames, flagName)
+	}
+	sort.Strings(flagNames)
+	for _, flagName := range flagNames {
+		f := fs.Lookup(flagName)
+		if f == nil || set[flagName] {
+			continue
+		}
+		values, err := profileValues(profile[flagName])
+		if err != nil {
+			return fmt.Errorf("profile %s: %s: %v", name, flagName, err)
+		}
+		// Set the value without marking the flag as given, so it acts as
+		// a default: checks that flags are not combined still apply only
+		// to the command line.
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("profile %s: invalid value %q for %s: %v", name, v, flagName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadProfile reads file and returns the profile called name, or the
+// file's default profile when name is empty.
+func loadProfile(file, name string) (string, map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	var config signerConfig
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml", ".json":
+		if data, err = yaml.YAMLToJSON(data); err == nil {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			dec.UseNumber()
+			err = dec.Decode(&config)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&config)
+	default:
+		return "", nil, fmt.Errorf("unknown config format %q: want .yaml, .yml, .json or .toml", ext)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse config %s: %v", file, err)
+	}
+	if name == "" {
+		name = config.Profile
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("config %s has no default profile; use -profile", file)
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return "", nil, fmt.Errorf("config %s has no profile %q", file, name)
+	}
+	for flagName, value := range profile {
+		switch flagName {
+		case "config", "profile",
+			// Escape hatches from a safety check must be asked for on the
+			// command line, for the transaction at hand.
+			"override-reason", "allow-replace", "confirm-new-recipient", "no-checksum":
+			return "", nil, fmt.Errorf("profile %s: %s cannot be set in a profile", name, flagName)
+		case "key":
+			// A profile is an ordinary file; it may name a key, not hold one.
+			if s, ok := value.(string); !ok || !strings.Contains(s, "://") {
+				return "", nil, fmt.Errorf("profile %s: key must be a key backend URI; use keystore or from for local keys", name)
+			}
+		}
+	}
+	return name, profile, nil
+}
+
+// profileValues formats a profile value as the flag values to set: one for
+// a string, number or bool, one per element for a list.
+func profileValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []any:
+		var values []string
+		for _, elem := range v {
+			if _, ok := elem.([]any); ok {
+				return nil, errors.New("nested lists are not flag values")
+			}
+			elemValues, err := profileValues(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, elemValues...)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
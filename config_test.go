@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadProfileRejects(t *testing.T) {
+	tests := []struct {
+		name, setting, err string
+	}{
+		{"override reason", `override-reason: "routine"`, "override-reason cannot be set in a profile"},
+		{"allow replace", `allow-replace: true`, "allow-replace cannot be set in a profile"},
+		{"confirm new recipient", `confirm-new-recipient: true`, "confirm-new-recipient cannot be set in a profile"},
+		{"no checksum", `no-checksum: true`, "no-checksum cannot be set in a profile"},
+		{"nested profile", `profile: other`, "profile cannot be set in a profile"},
+		{"raw key", `key: "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"`, "key must be a key backend URI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeConfig(t, "signer.yaml", "profiles:\n  prod:\n    chain: 1\n    "+tt.setting+"\n")
+			if _, _, err := loadProfile(file, "prod"); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("loadProfile = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+	file := writeConfig(t, "signer.toml", "[profiles.prod]\nchain = 1\nallow-replace = true\n")
+	if _, _, err := loadProfile(file, "prod"); err == nil || !strings.Contains(err.Error(), "allow-replace cannot be set in a profile") {
+		t.Fatalf("loadProfile of TOML = %v, want allow-replace rejected", err)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	file := writeConfig(t, "signer.yaml", `profile: prod
+profiles:
+  prod:
+    rpc: https://eth.example.com
+    chain: 1
+    output: json
+`)
+	newFlags := func() (fs *flag.FlagSet, rpc, output, chain *string) {
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Bool("offline", false, "")
+		return fs, fs.String("rpc", "", ""), fs.String("output", "text", ""), fs.String("chain", "", "")
+	}
+
+	fs, rpc, output, chain := newFlags()
+	if err := fs.Parse([]string{"-output", "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&configFlags{file: file}).apply(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *rpc != "https://eth.example.com" || *chain != "1" || *output != "text" {
+		t.Fatalf("rpc %q, chain %q, output %q; want the profile's rpc and chain and the command line's output", *rpc, *chain, *output)
+	}
+
+	// -offline on the command line drops the profile's rpc.
+	fs, rpc, _, _ = newFlags()
+	if err := fs.Parse([]string{"-offline"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&configFlags{file: file}).apply(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *rpc != "" {
+		t.Fatalf("rpc = %q with -offline, want none", *rpc)
+	}
+
+	fs, _, _, _ = newFlags()
+	if err := (&configFlags{file: file, profile: "staging"}).apply(fs); err == nil {
+		t.Fatal("missing profile applied")
+	}
+	if err := (&configFlags{profile: "prod"}).apply(fs); err == nil {
+		t.Fatal("profile without config applied")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+)
+
+// updateAddressBook runs fn on the address book in file under its lock and
+// saves it if fn succeeds.
+func updateAddressBook(file string, fn func(*policy.AddressBook) error) error {
+	file = policy.ContactsFile(file)
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	unlock, err := fileutil.Lock(file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b, err := policy.OpenAddressBook(file)
+	if err != nil {
+		return err
+	}
+	if err := fn(b); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(file, data)
+}
+
+func runContacts(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secure-signer contacts <list|add|remove> [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runContactsList(args[1:])
+	case "add":
+		return runContactsAdd(args[1:])
+	case "remove":
+		return runContactsRemove(args[1:])
+	}
+	return fmt.Errorf("unknown contacts command %q", args[0])
+}
+
+func addContactsFlag(fs *flag.FlagSet) *string {
+	return fs.String("contacts", "", "Address book file (default $"+policy.EnvContactsFile+" or "+policy.DefaultContactsFile+")")
+}
+
+func runContactsList(args []string) error {
+	fs := flag.NewFlagSet("contacts list", flag.ExitOnError)
+	file := addContactsFlag(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	b, err := policy.OpenAddressBook(*file)
+	if err != nil {
+		return err
+	}
+	type listed struct {
+		Name string `json:"name"`
+		*policy.Contact
+	}
+	contacts := []listed{}
+	for name, c := range b.Contacts {
+		contacts = append(contacts, listed{name, c})
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].Name < contacts[j].Name })
+	output.print(contacts, func() {
+		for _, c := range contacts {
+			fmt.Printf("%-20s %s %s\n", c.Name, c.Address.Hex(), c.Note)
+		}
+	})
+	return nil
+}
+
+func runContactsAdd(args []string) error {
+	fs := flag.NewFlagSet("contacts add", flag.ExitOnError)
+	file := addContactsFlag(fs)
+	name := fs.String("name", "", "Name to address the contact by, as in -to <name>")
+	address := fs.String("address", "", "Contact address")
+	note := fs.String("note", "", "Free-form description of the contact")
+	parseFlags(fs, args)
+
+	if !aliasPattern.MatchString(*name) || policy.IsENSName(*name) {
+		return fmt.Errorf("invalid contact name %q: use lowercase letters, digits, '_' and '-'", *name)
+	}
+	addr, err := policy.ParseChecksummedAddress("contact", *address)
+	if err != nil {
+		return err
+	}
+	err = updateAddressBook(*file, func(b *policy.AddressBook) error {
+		if _, ok := b.Contacts[*name]; ok {
+			return fmt.Errorf("contact %q already exists", *name)
+		}
+		for other, c := range b.Contacts {
+			if c.Address == addr {
+				fmt.Fprintf(os.Stderr, "warning: %s is also saved as %q\n", addr.Hex(), other)
+			}
+		}
+		b.Contacts[*name] = &policy.Contact{Address: addr, Note: *note, Added: time.Now().UTC()}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add contact: %v", err)
+	}
+	fmt.Printf("Added %s as %s\n", addr.Hex(), *name)
+	return nil
+}
+
+func runContactsRemove(args []string) error {
+	fs := flag.NewFlagSet("contacts remove", flag.ExitOnError)
+	file := addContactsFlag(fs)
+	name := fs.String("name", "", "Name of the contact to remove")
+	parseFlags(fs, args)
+
+	if *name == "" {
+		return errors.New("name is required")
+	}
+	err := updateAddressBook(*file, func(b *policy.AddressBook) error {
+		if _, ok := b.Contacts[*name]; !ok {
+			return fmt.Errorf("no contact %q in %s", *name, b.File)
+		}
+		delete(b.Contacts, *name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove contact: %v", err)
+	}
+	fmt.Println("Removed", *name)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: secure-signer decode <rawTxHex>")
+		fs.PrintDefaults()
+	}
+	abis := fs.String("abi-dir", "", "ABI registry used to decode calldata, a directory of <address>.json ABI files (default $"+policy.EnvABIDir+" or "+policy.DefaultABIDir+")")
+	lookup := fs.Bool("4byte-lookup", false, "Look up selectors without a known signature at 4byte.directory and cache the results in $"+envSignatureCache+" or "+defaultSignatureCache)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one raw transaction is required")
+	}
+	tx, err := txbuilder.DecodeRawTx(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	from, err := txbuilder.TxSender(tx)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %v", err)
+	}
+
+	out, err := newTxOutput(tx, from)
+	if err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		writeJSON(out)
+		return nil
+	}
+	fmt.Println("Hash:", tx.Hash().Hex())
+	fmt.Println("Type:", tx.Type())
+	fmt.Println("ChainID:", tx.ChainId())
+	fmt.Println("From:", from.Hex())
+	if tx.To() != nil {
+		fmt.Println("To:", tx.To().Hex())
+	} else {
+		fmt.Println("To: (contract creation)")
+	}
+	fmt.Println("Nonce:", tx.Nonce())
+	fmt.Println("Value:", txbuilder.FormatWei(tx.Value()))
+	fmt.Println("Gas:", tx.Gas())
+	fmt.Println("GasPrice:", tx.GasPrice())
+	fmt.Println("GasTipCap:", tx.GasTipCap())
+	fmt.Println("GasFeeCap:", tx.GasFeeCap())
+	if tx.Type() == types.BlobTxType {
+		fmt.Println("BlobFeeCap:", out.BlobFeeCap)
+		for _, h := range out.BlobHashes {
+			fmt.Println("BlobHash:", h.Hex())
+		}
+		if out.Blobs > 0 {
+			fmt.Println("Blobs:", out.Blobs)
+		}
+	}
+	for _, tuple := range out.AccessList {
+		fmt.Println("AccessList:", tuple.Address.Hex(), tuple.StorageKeys)
+	}
+	fmt.Println("Data:", "0x"+hex.EncodeToString(tx.Data()))
+	if c := out.Call; c != nil {
+		if c.TokenID != "" {
+			fmt.Printf("Call: %s to %s token %s amount %s\n", c.Kind, c.Recipient, c.TokenID, c.Amount)
+		} else {
+			fmt.Printf("Call: %s to %s amount %s\n", c.Kind, c.Recipient, c.Amount)
+		}
+	} else if tx.To() != nil && len(tx.Data()) >= 4 {
+		call, guessed := describeCall(*abis, *tx.To(), tx.Data())
+		if call == nil && *lookup {
+			if _, err := fetchSignatures(context.Background(), tx.Data()[:4]); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: failed to look up selector at 4byte.directory:", err)
+			}
+			call, guessed = describeCall(*abis, *tx.To(), tx.Data())
+		}
+		if call != nil {
+			printCall(os.Stdout, call, guessed)
+		}
+	}
+	if s := out.Signature; s != nil {
+		fmt.Println("V:", s.V)
+		fmt.Println("R:", s.R)
+		fmt.Println("S:", s.S)
+	}
+	return nil
+}
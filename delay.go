@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func runDelayed(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secure-signer delayed <list|release|cancel> [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runDelayedList(args[1:])
+	case "release":
+		return runDelayedRelease(args[1:])
+	case "cancel":
+		return runDelayedCancel(args[1:])
+	}
+	return fmt.Errorf("unknown delayed command %q", args[0])
+}
+
+func runDelayedList(args []string) error {
+	fs := flag.NewFlagSet("delayed list", flag.ExitOnError)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the time-delayed transactions")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	held, err := store.HeldTxs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if *output == outputJSON {
+		type entry struct {
+			*signer.HeldTx
+			Status string `json:"status"`
+		}
+		list := make([]entry, 0, len(held))
+		for _, h := range held {
+			list = append(list, entry{h, h.Status(now)})
+		}
+		writeJSON(list)
+		return nil
+	}
+	for _, h := range held {
+		fmt.Printf("%s %s chain=%s from=%s to=%s nonce=%d value=%s release=%s\n", h.TxHash.Hex(), h.Status(now), h.ChainID, h.From.Hex(), h.To, h.Nonce, txbuilder.FormatWei(h.Value), h.ReleaseAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runDelayedRelease(args []string) error {
+	fs := flag.NewFlagSet("delayed release", flag.ExitOnError)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the time-delayed transactions")
+	rpcURL := fs.String("rpc", "", "JSON-RPC endpoint to broadcast released transactions to; without it they are printed")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: secure-signer delayed release [flags] [tx-hash...]")
+		fmt.Fprintln(fs.Output(), "Releases the given transactions, or every one whose delay has passed.")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	var held []*signer.HeldTx
+	if fs.NArg() == 0 {
+		all, err := store.HeldTxs()
+		if err != nil {
+			return err
+		}
+		for _, h := range all {
+			if h.Status(time.Now()) == "due" {
+				held = append(held, h)
+			}
+		}
+	}
+	for _, arg := range fs.Args() {
+		hash, err := parseHeldHash(arg)
+		if err != nil {
+			return err
+		}
+		h, err := store.HeldTx(hash)
+		if err != nil {
+			return fmt.Errorf("failed to load held transaction: %v", err)
+		}
+		if h == nil {
+			return fmt.Errorf("no held transaction %s", hash.Hex())
+		}
+		switch h.Status(time.Now()) {
+		case "cancelled":
+			return fmt.Errorf("transaction %s was cancelled by %s", hash.Hex(), h.CancelledBy)
+		case "held":
+			return fmt.Errorf("transaction %s is held until %s", hash.Hex(), h.ReleaseAt.Format(time.RFC3339))
+		}
+		held = append(held, h)
+	}
+
+	ctx := context.Background()
+	var client *ethclient.Client
+	if *rpcURL != "" {
+		var err error
+		if client, err = ethclient.DialContext(ctx, *rpcURL); err != nil {
+			return fmt.Errorf("failed to connect to rpc: %v", err)
+		}
+		defer client.Close()
+	}
+	for _, h := range held {
+		// Take the transaction out of the store first, so that it is
+		// released once and never after a concurrent cancel.
+		err := store.UpdateHeldTx(h.TxHash, func(cur *signer.HeldTx) (*signer.HeldTx, error) {
+			if cur == nil || cur.Status(time.Now()) != "due" {
+				return nil, fmt.Errorf("transaction %s is no longer due", h.TxHash.Hex())
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to release transaction: %v", err)
+		}
+		if client == nil {
+			fmt.Println("TxHash:", h.TxHash.Hex())
+			fmt.Println("RawTxHex:", strings.TrimPrefix(h.Raw.String(), "0x"))
+			continue
+		}
+		var sent common.Hash
+		if err := client.Client().CallContext(ctx, &sent, "eth_sendRawTransaction", h.Raw); err != nil {
+			if rerr := store.UpdateHeldTx(h.TxHash, func(*signer.HeldTx) (*signer.HeldTx, error) { return h, nil }); rerr != nil {
+				return fmt.Errorf("failed to send tx %s: %v; failed to put it back: %v; raw transaction: %s", h.TxHash.Hex(), err, rerr, h.Raw)
+			}
+			return fmt.Errorf("failed to send tx %s: %v", h.TxHash.Hex(), err)
+		}
+		fmt.Println("Sent:", h.TxHash.Hex())
+	}
+	return nil
+}
+
+func runDelayedCancel(args []string) error {
+	fs := flag.NewFlagSet("delayed cancel", flag.ExitOnError)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the time-delayed transactions")
+	operator := fs.String("operator", currentUser(), "Operator name recorded with the cancellation")
+	reason := fs.String("reason", "", "Reason recorded with the cancellation")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: secure-signer delayed cancel [flags] <tx-hash>")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one transaction hash is required")
+	}
+	hash, err := parseHeldHash(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	var h *signer.HeldTx
+	err = store.UpdateHeldTx(hash, func(cur *signer.HeldTx) (*signer.HeldTx, error) {
+		switch {
+		case cur == nil:
+			return nil, fmt.Errorf("no held transaction %s", hash.Hex())
+		case cur.CancelledAt != nil:
+			return nil, fmt.Errorf("transaction %s was already cancelled by %s", hash.Hex(), cur.CancelledBy)
+		}
+		now := time.Now().UTC()
+		next := *cur
+		next.Raw, next.CancelledAt, next.CancelledBy, next.CancelReason = nil, &now, *operator, *reason
+		h = &next
+		return h, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel held transaction: %v", err)
+	}
+	fmt.Println("Cancelled:", hash.Hex())
+	fmt.Printf("Nonce %d of %s stays used; sign a replacement with -allow-replace to reuse it\n", h.Nonce, h.From.Hex())
+	return nil
+}
+
+func parseHeldHash(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid transaction hash %q", s)
+	}
+	return common.BytesToHash(b), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+)
+
+func runDevices(args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	ledger, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return fmt.Errorf("failed to open Ledger hub: %v", err)
+	}
+	trezors, err := trezorHubs()
+	if err != nil {
+		return err
+	}
+	urls := []string{}
+	for _, hub := range append([]*usbwallet.Hub{ledger}, trezors...) {
+		for _, w := range hub.Wallets() {
+			urls = append(urls, w.URL().String())
+		}
+	}
+	output.print(urls, func() {
+		for _, u := range urls {
+			fmt.Println(u)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// resolveTo replaces a contact name or ENS name given as -to with its
+// address. ENS names are checked against the pins in policy when one is
+// given.
+func (t *txFlags) resolveTo(ctx context.Context, client *ethclient.Client, pol *policy.Policy) error {
+	if policy.IsContactName(t.to) {
+		b, err := policy.OpenAddressBook(*t.contacts)
+		if err != nil {
+			return err
+		}
+		addr, err := b.Lookup(t.to)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Resolved %s to %s\n", t.to, addr.Hex())
+		t.to = addr.Hex()
+		return nil
+	}
+	if !policy.IsENSName(t.to) {
+		return nil
+	}
+	if client == nil {
+		return fmt.Errorf("resolving %s requires rpc", t.to)
+	}
+	addr, err := policy.NewENSResolver(client).ResolvePinned(ctx, pol, t.to)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Resolved %s to %s\n", t.to, addr.Hex())
+	t.to = addr.Hex()
+	return nil
+}
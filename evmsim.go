@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// prestate is a state snapshot for offline simulation. Alloc uses the same
+// format as a genesis alloc or the prestateTracer output, so it can be
+// exported from any node on the online machine.
+type prestate struct {
+	Env struct {
+		Number    uint64         `json:"number"`
+		Timestamp uint64         `json:"timestamp"`
+		BaseFee   *big.Int       `json:"baseFee"`
+		GasLimit  uint64         `json:"gasLimit"`
+		Coinbase  common.Address `json:"coinbase"`
+	} `json:"env"`
+	Alloc types.GenesisAlloc `json:"alloc"`
+}
+
+// transferEventTopic is keccak256("Transfer(address,address,uint256)"),
+// shared by ERC-20 and ERC-721.
+var transferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// simulationResult is the outcome of a local EVM run.
+type simulationResult struct {
+	gasUsed  uint64
+	reverted error
+	balances map[common.Address][2]*big.Int // before, after
+	logs     []*types.Log
+}
+
+func loadPrestate(file string) (*prestate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var pre prestate
+	if err := json.Unmarshal(data, &pre); err != nil {
+		return nil, fmt.Errorf("invalid prestate: %v", err)
+	}
+	return &pre, nil
+}
+
+// simulateLocal executes tx from from on top of pre with go-ethereum's EVM,
+// with every fork up to the latest active.
+func simulateLocal(pre *prestate, from common.Address, tx *types.Transaction, chainID *big.Int) (*simulationResult, error) {
+	db := state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil), nil)
+	statedb, err := state.New(types.EmptyRootHash, db)
+	if err != nil {
+		return nil, err
+	}
+	for addr, account := range pre.Alloc {
+		if account.Balance != nil {
+			statedb.SetBalance(addr, uint256.MustFromBig(account.Balance), tracing.BalanceChangeUnspecified)
+		}
+		statedb.SetNonce(addr, account.Nonce, tracing.NonceChangeUnspecified)
+		statedb.SetCode(addr, account.Code)
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+
+	watched := map[common.Address]bool{from: true, pre.Env.Coinbase: true}
+	if tx.To() != nil {
+		watched[*tx.To()] = true
+	}
+	for addr := range pre.Alloc {
+		watched[addr] = true
+	}
+	before := make(map[common.Address]*big.Int, len(watched))
+	for addr := range watched {
+		before[addr] = statedb.GetBalance(addr).ToBig()
+	}
+
+	config := *params.AllDevChainProtocolChanges
+	config.ChainID = chainID
+	timestamp := pre.Env.Timestamp
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().Unix())
+	}
+	gasLimit := pre.Env.GasLimit
+	if gasLimit == 0 {
+		gasLimit = 30_000_000
+	}
+	baseFee := pre.Env.BaseFee
+	if baseFee == nil {
+		baseFee = new(big.Int)
+	}
+	random := common.Hash{}
+	blockCtx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Coinbase:    pre.Env.Coinbase,
+		BlockNumber: new(big.Int).SetUint64(pre.Env.Number),
+		Time:        timestamp,
+		Difficulty:  new(big.Int),
+		BaseFee:     baseFee,
+		BlobBaseFee: new(big.Int),
+		GasLimit:    gasLimit,
+		Random:      &random,
+	}
+
+	msg := &core.Message{
+		From:      from,
+		To:        tx.To(),
+		Nonce:     tx.Nonce(),
+		Value:     tx.Value(),
+		GasLimit:  tx.Gas(),
+		GasPrice:  tx.GasPrice(),
+		GasFeeCap: tx.GasFeeCap(),
+		GasTipCap: tx.GasTipCap(),
+		Data:      tx.Data(),
+		// The snapshot may predate the nonce the operator chose.
+		SkipNonceChecks: true,
+	}
+	if tx.Type() != types.LegacyTxType {
+		// Effective gas price as the network would charge it.
+		price := new(big.Int).Add(baseFee, tx.GasTipCap())
+		if price.Cmp(tx.GasFeeCap()) > 0 {
+			price = tx.GasFeeCap()
+		}
+		msg.GasPrice = price
+	}
+
+	evm := vm.NewEVM(blockCtx, statedb, &config, vm.Config{NoBaseFee: baseFee.Sign() == 0})
+	evm.SetTxContext(core.NewEVMTxContext(msg))
+	statedb.SetTxContext(tx.Hash(), 0)
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(gasLimit))
+	if err != nil {
+		return nil, fmt.Errorf("transaction is invalid on prestate: %v", err)
+	}
+
+	sim := &simulationResult{
+		gasUsed:  result.UsedGas,
+		balances: make(map[common.Address][2]*big.Int),
+		logs:     statedb.GetLogs(tx.Hash(), pre.Env.Number, common.Hash{}),
+	}
+	if result.Err != nil {
+		sim.reverted = fmt.Errorf("%w: %s", errSimulationReverted, revertReason(fmt.Sprintf("%#x", result.Revert())))
+	}
+	for addr := range watched {
+		after := statedb.GetBalance(addr).ToBig()
+		if after.Cmp(before[addr]) != 0 {
+			sim.balances[addr] = [2]*big.Int{before[addr], after}
+		}
+	}
+	return sim, nil
+}
+
+// printSimulation writes a human-readable preview of sim to w.
+func printSimulation(w io.Writer, sim *simulationResult) {
+	status := "success"
+	if sim.reverted != nil {
+		status = "reverted"
+	}
+	fmt.Fprintln(w, "Simulation:", status)
+	fmt.Fprintln(w, "SimulatedGasUsed:", sim.gasUsed)
+	for addr, change := range sim.balances {
+		delta := new(big.Int).Sub(change[1], change[0])
+		fmt.Fprintf(w, "BalanceChange: %s %s -> %s (%+d wei)\n", addr.Hex(), change[0], change[1], delta)
+	}
+	for _, log := range sim.logs {
+		if len(log.Topics) == 3 && log.Topics[0] == transferEventTopic {
+			fmt.Fprintf(w, "Event: %s Transfer %s -> %s amount 0x%x\n", log.Address.Hex(),
+				common.BytesToAddress(log.Topics[1].Bytes()).Hex(),
+				common.BytesToAddress(log.Topics[2].Bytes()).Hex(), log.Data)
+			continue
+		}
+		fmt.Fprintf(w, "Event: %s topics %v data 0x%x\n", log.Address.Hex(), log.Topics, log.Data)
+	}
+}
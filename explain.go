@@ -0,0 +1,16 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+)
+
+// printDecision writes d as indented JSON to stderr.
+func printDecision(d *policy.Decision) {
+	enc := json.NewEncoder(os.Stderr)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(d)
+}
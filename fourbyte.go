@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// embeddedSignatures is the built-in selector database: common function
+// signatures, one per line.
+//
+//go:embed signatures.txt
+var embeddedSignatures string
+
+// envSignatureCache names the environment variable that overrides the
+// default signature cache file.
+const envSignatureCache = "SIGNER_4BYTE_CACHE"
+
+// defaultSignatureCache holds the signatures fetched from 4byte.directory.
+const defaultSignatureCache = "signatures.json"
+
+// fourByteURL is the 4byte.directory signature search endpoint.
+const fourByteURL = "https://www.4byte.directory/api/v1/signatures/?hex_signature="
+
+func signatureCacheFile() string {
+	if env := os.Getenv(envSignatureCache); env != "" {
+		return env
+	}
+	return defaultSignatureCache
+}
+
+// selectorOf returns the 4-byte selector of a function signature, as hex.
+func selectorOf(signature string) string {
+	return hexutil.Encode(crypto.Keccak256([]byte(signature))[:4])
+}
+
+// signatureDB maps hex selectors to the function signatures known for them.
+// Several signatures may share a selector.
+type signatureDB map[string][]string
+
+func (db signatureDB) add(signature string) {
+	sel := selectorOf(signature)
+	if !slices.Contains(db[sel], signature) {
+		db[sel] = append(db[sel], signature)
+	}
+}
+
+// loadSignatures returns the built-in signatures and those cached from
+// 4byte.directory. A missing cache is not an error.
+func loadSignatures() (signatureDB, error) {
+	db := make(signatureDB)
+	for _, line := range strings.Split(embeddedSignatures, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			db.add(line)
+		}
+	}
+	data, err := os.ReadFile(signatureCacheFile())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return db, nil
+	case err != nil:
+		return db, fmt.Errorf("failed to read signature cache: %v", err)
+	}
+	var cached map[string][]string
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return db, fmt.Errorf("invalid signature cache: %v", err)
+	}
+	for _, signatures := range cached {
+		for _, signature := range signatures {
+			db.add(signature)
+		}
+	}
+	return db, nil
+}
+
+// guessCall decodes data using the signatures known for its selector. A
+// signature counts only if the arguments decode and re-encode to exactly
+// data, which rules out most selector collisions; the first that does is
+// returned.
+func (db signatureDB) guessCall(data []byte) (*policy.DecodedCall, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	for _, signature := range db[hexutil.Encode(data[:4])] {
+		method, err := methodFromSignature(signature)
+		if err != nil {
+			continue
+		}
+		call, err := policy.UnpackCall(method, data)
+		if err != nil {
+			continue
+		}
+		values := make([]any, len(call.Args))
+		for i, arg := range call.Args {
+			values[i] = arg.Raw
+		}
+		if packed, err := method.Inputs.Pack(values...); err != nil || !bytes.Equal(packed, data[4:]) {
+			continue
+		}
+		for i := range call.Args {
+			call.Args[i].Name = fmt.Sprintf("arg%d", i)
+		}
+		return call, true
+	}
+	return nil, false
+}
+
+// methodFromSignature builds an ABI method from a function signature such
+// as "transfer(address,uint256)".
+func methodFromSignature(signature string) (*abi.Method, error) {
+	sel, err := abi.ParseSelector(signature)
+	if err != nil {
+		return nil, err
+	}
+	sel.Type = "function"
+	data, err := json.Marshal([]abi.SelectorMarshaling{sel})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	method, ok := parsed.Methods[sel.Name]
+	if !ok {
+		return nil, fmt.Errorf("invalid signature %q", signature)
+	}
+	return &method, nil
+}
+
+// fetchSignatures looks up selector at 4byte.directory and adds the
+// signatures found to the cache. Signatures whose hash does not match the
+// selector are dropped.
+func fetchSignatures(ctx context.Context, selector []byte) ([]string, error) {
+	sel := hexutil.Encode(selector)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fourByteURL+sel, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+	var result struct {
+		Results []struct {
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	var found []string
+	for _, r := range result.Results {
+		if selectorOf(r.TextSignature) == sel {
+			found = append(found, r.TextSignature)
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found, cacheSignatures(sel, found)
+}
+
+// cacheSignatures adds signatures for sel to the signature cache.
+func cacheSignatures(sel string, signatures []string) error {
+	file := signatureCacheFile()
+	cached := make(map[string][]string)
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return fmt.Errorf("invalid signature cache: %v", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read signature cache: %v", err)
+	}
+	for _, signature := range signatures {
+		if !slices.Contains(cached[sel], signature) {
+			cached[sel] = append(cached[sel], signature)
+		}
+	}
+	if data, err = json.MarshalIndent(cached, "", "  "); err != nil {
+		return err
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	if err := fileutil.WriteAtomic(file, data); err != nil {
+		return fmt.Errorf("failed to write signature cache: %v", err)
+	}
+	return nil
+}
+
+// describeCall decodes data sent to contract with its registered ABI or,
+// failing that, with the signature database. guessed reports that the
+// function is only the probable one, matched by selector.
+func describeCall(abiDir string, contract common.Address, data []byte) (call *policy.DecodedCall, guessed bool) {
+	if call, err := policy.DecodeCalldata(abiDir, contract, data); err == nil {
+		return call, false
+	}
+	db, err := loadSignatures()
+	if err != nil {
+		slog.Warn("ignoring signature cache", "err", err)
+	}
+	if call, ok := db.guessCall(data); ok {
+		return call, true
+	}
+	return nil, false
+}
+
+// printCall writes a decoded call and its arguments to w.
+func printCall(w io.Writer, call *policy.DecodedCall, guessed bool) {
+	if guessed {
+		fmt.Fprintf(w, "Call: probably %s (matched by selector)\n", call.Signature)
+	} else {
+		fmt.Fprintln(w, "Call:", call.Signature)
+	}
+	for _, arg := range call.Args {
+		fmt.Fprintf(w, "  %s %s: %s\n", arg.Type, arg.Name, arg.Value)
+	}
+}
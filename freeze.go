@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+func runFreeze(args []string) error {
+	fs := flag.NewFlagSet("freeze", flag.ExitOnError)
+	policyOpts := addPolicyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	operator := fs.String("operator", currentUser(), "Operator name recorded with the freeze")
+	reason := fs.String("reason", "", "Reason for the freeze (required)")
+	parseFlags(fs, args)
+
+	if *reason == "" {
+		return errors.New("reason is required")
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	ap := policy.Unfreeze
+	if ap == nil {
+		ap = policy.Approvals
+	}
+	if ap == nil || ap.Quorum <= 0 || ap.Quorum > len(ap.Approvers) {
+		return errors.New("policy has no unfreeze approvers: set unfreeze or approvals with a valid quorum")
+	}
+	f := &signer.FreezeState{FrozenAt: time.Now().UTC(), FrozenBy: *operator, Reason: *reason, Approvers: ap.Approvers, Quorum: ap.Quorum}
+	if _, err := rand.Read(f.ID[:]); err != nil {
+		return err
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	err = store.UpdateFreeze(func(cur *signer.FreezeState) (*signer.FreezeState, error) {
+		if cur != nil {
+			return nil, fmt.Errorf("signing is already frozen since %s by %s", cur.FrozenAt.Format(time.RFC3339), cur.FrozenBy)
+		}
+		return f, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to freeze: %v", err)
+	}
+	fmt.Println("Frozen:", f.ID.Hex())
+	fmt.Printf("All signing is refused until %d of %s approve with unfreeze\n", f.Quorum, strings.Join(f.Approvers, ", "))
+	return nil
+}
+
+func runUnfreeze(args []string) error {
+	fs := flag.NewFlagSet("unfreeze", flag.ExitOnError)
+	keyOpts := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	parseFlags(fs, args)
+
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	f, err := store.Freeze()
+	if err != nil {
+		return fmt.Errorf("failed to load freeze: %v", err)
+	}
+	if f == nil {
+		return errors.New("signing is not frozen")
+	}
+	ctx := context.Background()
+	// Approvers must be able to sign while frozen.
+	key, err := openBackend(ctx, *keyOpts)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	listed := false
+	for _, entry := range f.Approvers {
+		listed = listed || strings.EqualFold(entry, key.Address().Hex())
+	}
+	if !listed {
+		return fmt.Errorf("%s is not an unfreeze approver", key.Address().Hex())
+	}
+	sig, err := keys.SignPersonalMessage(ctx, key, f.ID.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to sign approval: %v", err)
+	}
+
+	// Approvals given meanwhile by others are kept, and one for an earlier
+	// freeze is never applied to a new one.
+	var n int
+	err = store.UpdateFreeze(func(cur *signer.FreezeState) (*signer.FreezeState, error) {
+		if cur == nil || cur.ID != f.ID {
+			return nil, errors.New("the freeze changed meanwhile; run unfreeze again")
+		}
+		approvals := []signer.ApprovalEntry{{Approver: key.Address(), Signature: sig}}
+		for _, a := range cur.Approvals {
+			if a.Approver != key.Address() {
+				approvals = append(approvals, a)
+			}
+		}
+		next := *cur
+		next.Approvals = approvals
+		if n = len(next.ApprovedBy()); n < next.Quorum {
+			return &next, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save approval: %v", err)
+	}
+	if n < f.Quorum {
+		fmt.Printf("Approved: %d of %d approvals to unfreeze %s\n", n, f.Quorum, f.ID.Hex())
+		return nil
+	}
+	fmt.Println("Unfrozen:", f.ID.Hex())
+	return nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcTarget is a service reachable over gRPC and the authentication of its
+// clients, nil when every caller is trusted.
+type grpcTarget struct {
+	service *ethService
+	auth    *clientAuth
+}
+
+type grpcTargetKey struct{}
+
+// grpcSigner implements the Signer service of signer.proto. It signs
+// through the same eth_signTransaction code as the JSON-RPC endpoint, so
+// requests get the same policy checks, nonces, audit and metrics, without
+// the HTTP and JSON overhead.
+type grpcSigner struct {
+	UnimplementedSignerServer
+	// targets holds the service of each tenant by name, or the daemon's
+	// only service under "".
+	targets map[string]grpcTarget
+	tenants bool
+}
+
+// newGRPCServer returns a gRPC server for signer, serving TLS with
+// tlsConfig unless it is nil.
+func newGRPCServer(tlsConfig *tls.Config, signer *grpcSigner) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := signer.authenticate(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := signer.authenticate(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
+	RegisterSignerServer(srv, signer)
+	return srv
+}
+
+// grpcStream is a server stream with the context set by authenticate.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context { return s.ctx }
+
+// authenticate picks the target of a call and authenticates its caller as
+// the HTTP middleware does, returning the context to handle it in.
+func (g *grpcSigner) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	name := ""
+	if g.tenants {
+		if v := md.Get("x-tenant"); len(v) > 0 {
+			name = v[0]
+		}
+		if name == "" {
+			return nil, status.Error(codes.InvalidArgument, "x-tenant metadata is required")
+		}
+	}
+	t, ok := g.targets[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown tenant %q", name)
+	}
+	ctx = context.WithValue(ctx, grpcTargetKey{}, t)
+	if t.auth == nil {
+		return ctx, nil
+	}
+	// identify works on HTTP requests; give it one carrying the call's
+	// credentials.
+	r := (&http.Request{Header: make(http.Header), URL: &url.URL{Path: method}}).WithContext(ctx)
+	for _, key := range []string{"authorization", "x-api-key"} {
+		if v := md.Get(key); len(v) > 0 {
+			r.Header.Set(key, v[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+	c, err := t.auth.identify(r)
+	switch err {
+	case nil:
+	case errRateLimited:
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, callerKey{}, c), nil
+}
+
+func (g *grpcSigner) Sign(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	t := ctx.Value(grpcTargetKey{}).(grpcTarget)
+	args, err := signTxArgsFromProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp, err := g.sign(ctx, t.service, req, args)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp, nil
+}
+
+func (g *grpcSigner) BatchSign(stream grpc.BidiStreamingServer[SignRequest, SignResponse]) error {
+	ctx := stream.Context()
+	t := ctx.Value(grpcTargetKey{}).(grpcTarget)
+	for i := 0; ; i++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// authenticate took the first request off the client's rate limit;
+		// later ones wait for it rather than fail.
+		if c := callerFrom(ctx); i > 0 && c != nil && c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return status.FromContextError(err).Err()
+			}
+		}
+		var resp *SignResponse
+		args, err := signTxArgsFromProto(req)
+		if err == nil {
+			resp, err = g.sign(ctx, t.service, req, args)
+		}
+		if err != nil {
+			resp = &SignResponse{Id: req.GetId(), Error: err.Error()}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *grpcSigner) sign(ctx context.Context, s *ethService, req *SignRequest, args signTxArgs) (*SignResponse, error) {
+	if req.GetIdempotencyKey() != "" {
+		ctx = signer.ContextWithIdempotencyKey(ctx, req.GetIdempotencyKey())
+	}
+	res, err := s.SignTransaction(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &SignResponse{Id: req.GetId(), Raw: res.Raw, TxHash: res.Tx.Hash().Hex(), Nonce: res.Tx.Nonce()}, nil
+}
+
+// signTxArgsFromProto converts req to eth_signTransaction parameters.
+func signTxArgsFromProto(req *SignRequest) (signTxArgs, error) {
+	var args signTxArgs
+	from, err := common.NewMixedcaseAddressFromString(req.GetFrom())
+	if err != nil {
+		return args, fmt.Errorf("invalid from address %q", req.GetFrom())
+	}
+	args.From = *from
+	if req.GetTo() != "" {
+		if !common.IsHexAddress(req.GetTo()) {
+			return args, fmt.Errorf("invalid to address %q", req.GetTo())
+		}
+		to := common.HexToAddress(req.GetTo())
+		args.To = &to
+	}
+	if req.GetGas() != 0 {
+		gas := hexutil.Uint64(req.GetGas())
+		args.Gas = &gas
+	}
+	if req.Nonce != nil {
+		nonce := hexutil.Uint64(req.GetNonce())
+		args.Nonce = &nonce
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   **hexutil.Big
+	}{
+		{"value", req.GetValue(), &args.Value},
+		{"gas_price", req.GetGasPrice(), &args.GasPrice},
+		{"max_fee_per_gas", req.GetMaxFeePerGas(), &args.MaxFeePerGas},
+		{"max_priority_fee_per_gas", req.GetMaxPriorityFeePerGas(), &args.MaxPriorityFeePerGas},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, ok := new(big.Int).SetString(f.value, 10)
+		if !ok || v.Sign() < 0 {
+			return args, fmt.Errorf("invalid %s %q: want a decimal amount in wei", f.name, f.value)
+		}
+		*f.dst = (*hexutil.Big)(v)
+	}
+	if len(req.GetData()) > 0 {
+		data := hexutil.Bytes(req.GetData())
+		args.Data = &data
+	}
+	if req.GetChainId() != 0 {
+		args.ChainID = (*hexutil.Big)(new(big.Int).SetUint64(req.GetChainId()))
+	}
+	if len(req.GetAccessList()) > 0 {
+		list := make(types.AccessList, 0, len(req.GetAccessList()))
+		for _, tuple := range req.GetAccessList() {
+			if !common.IsHexAddress(tuple.GetAddress()) {
+				return args, fmt.Errorf("invalid access list address %q", tuple.GetAddress())
+			}
+			entry := types.AccessTuple{Address: common.HexToAddress(tuple.GetAddress())}
+			for _, key := range tuple.GetStorageKeys() {
+				b, err := hexutil.Decode(key)
+				if err != nil || len(b) != common.HashLength {
+					return args, fmt.Errorf("invalid access list storage key %q", key)
+				}
+				entry.StorageKeys = append(entry.StorageKeys, common.BytesToHash(b))
+			}
+			list = append(list, entry)
+		}
+		args.AccessList = &list
+	}
+	return args, nil
+}
+
+// grpcError maps a signing error to a gRPC status.
+func grpcError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "forbidden"):
+		return status.Error(codes.PermissionDenied, msg)
+	case strings.HasPrefix(msg, "policy check failed"), signer.IsHeld(err):
+		return status.Error(codes.FailedPrecondition, msg)
+	}
+	return status.Error(codes.Unknown, msg)
+}
+
+// serveGRPC starts serving signer on listen in the background. shutdown
+// stops accepting calls and waits for those in flight until ctx is done.
+func serveGRPC(listen string, tlsConfig *tls.Config, signer *grpcSigner) (shutdown func(ctx context.Context), err error) {
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", listen, err)
+	}
+	srv := newGRPCServer(tlsConfig, signer)
+	scheme := "grpc"
+	if tlsConfig != nil {
+		scheme = "grpcs"
+	} else if !isLoopback(listen) {
+		for _, t := range signer.targets {
+			if t.auth != nil {
+				slog.Warn("API keys are accepted over plain gRPC; use -tls-cert", "listen", listen)
+				break
+			}
+		}
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("gRPC server failed", "err", err)
+		}
+	}()
+	slog.Info("serving gRPC", "listen", scheme+"://"+listen)
+	return func(ctx context.Context) {
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			slog.Warn("gRPC drain timed out; abandoning calls in flight")
+			srv.Stop()
+		}
+	}, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+// version is the release version, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// readyTimeout bounds the checks of one readiness probe.
+const readyTimeout = 5 * time.Second
+
+// healthChecker is implemented by key backends outside the process, which
+// check that they can still reach the key.
+type healthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// versionInfo is the /version response.
+type versionInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	GoVersion  string `json:"go_version"`
+}
+
+func buildVersion() versionInfo {
+	v := versionInfo{Version: version, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Commit = s.Value
+		case "vcs.time":
+			v.CommitTime = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
+
+// readiness checks that s can sign: that its key backend is reachable, its
+// policy was loaded and its last reload accepted, and its store is usable.
+// It returns "ok" for each check that passed, or what went wrong.
+func (s *ethService) readiness(ctx context.Context) (checks map[string]string, ready bool) {
+	checks = make(map[string]string)
+	ready = true
+	result := func(name string, err error) {
+		checks[name] = "ok"
+		if err != nil {
+			checks[name], ready = err.Error(), false
+		}
+	}
+	result("key", s.keyHealth(ctx))
+	if s.Session != nil && s.Session.Check() != nil {
+		// A locked key is ready to be unlocked.
+		checks["key"] = "locked"
+	}
+	var policyErr error
+	if s.CurrentPolicy() == nil {
+		policyErr = errors.New("no policy loaded")
+	} else if err := s.PolicyErr.Load(); err != nil {
+		policyErr = errors.New("last policy reload rejected: " + (*err).Error())
+	}
+	result("policy", policyErr)
+	result("store", s.Store.Ping())
+	return checks, ready
+}
+
+func (s *ethService) keyHealth(ctx context.Context) error {
+	if hc, ok := signer.UnwrapKey(s.Key).(healthChecker); ok {
+		return hc.CheckHealth(ctx)
+	}
+	return nil
+}
+
+// registerHealth serves the unauthenticated probes of the daemon:
+// /healthz, which answers as long as it runs, /readyz, which answers 503
+// unless every service passes its readiness checks, and /version. The
+// services are keyed by tenant name, or "" for a single-key daemon.
+func registerHealth(mux *http.ServeMux, services map[string]*ethService) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+		resp := struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}{Status: "ready", Checks: make(map[string]string)}
+		for name, service := range services {
+			checks, ready := service.readiness(ctx)
+			if !ready {
+				resp.Status = "not ready"
+			}
+			for check, result := range checks {
+				if name != "" {
+					check = name + "/" + check
+				}
+				resp.Checks[check] = result
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status != "ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildVersion())
+	})
+}
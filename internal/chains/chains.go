@@ -0,0 +1,115 @@
+// Package chains is the registry of chains that can be selected by name.
+package chains
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// envChains names the environment variable that overrides the default
+// chain registry file.
+const envChains = "SIGNER_CHAINS"
+
+// defaultChainsFile extends the built-in chain registry when present.
+const defaultChainsFile = "chains.json"
+
+// Preset describes a chain that can be selected by name with -chain.
+type Preset struct {
+	// Name is the display name, such as "Ethereum Mainnet".
+	Name    string `json:"name"`
+	ChainID uint64 `json:"chain_id"`
+	// RPC lists public endpoints, the first of which is used by -rpc
+	// default.
+	RPC []string `json:"rpc"`
+	// Explorer is the block explorer base URL; transactions are linked as
+	// <explorer>/tx/<hash>.
+	Explorer string `json:"explorer"`
+	// Symbol is the native currency symbol.
+	Symbol string `json:"symbol"`
+	// OPStack marks OP Stack chains, which charge an L1 data fee.
+	OPStack bool `json:"op_stack"`
+}
+
+// builtinChains is the chain registry, keyed by the name given to -chain.
+var builtinChains = map[string]Preset{
+	"mainnet":          {Name: "Ethereum Mainnet", ChainID: 1, RPC: []string{"https://ethereum-rpc.publicnode.com"}, Explorer: "https://etherscan.io", Symbol: "ETH"},
+	"sepolia":          {Name: "Sepolia", ChainID: 11155111, RPC: []string{"https://ethereum-sepolia-rpc.publicnode.com"}, Explorer: "https://sepolia.etherscan.io", Symbol: "ETH"},
+	"holesky":          {Name: "Holesky", ChainID: 17000, RPC: []string{"https://ethereum-holesky-rpc.publicnode.com"}, Explorer: "https://holesky.etherscan.io", Symbol: "ETH"},
+	"optimism":         {Name: "OP Mainnet", ChainID: 10, RPC: []string{"https://mainnet.optimism.io"}, Explorer: "https://optimistic.etherscan.io", Symbol: "ETH", OPStack: true},
+	"optimism-sepolia": {Name: "OP Sepolia", ChainID: 11155420, RPC: []string{"https://sepolia.optimism.io"}, Explorer: "https://sepolia-optimism.etherscan.io", Symbol: "ETH", OPStack: true},
+	"arbitrum":         {Name: "Arbitrum One", ChainID: 42161, RPC: []string{"https://arb1.arbitrum.io/rpc"}, Explorer: "https://arbiscan.io", Symbol: "ETH"},
+	"arbitrum-sepolia": {Name: "Arbitrum Sepolia", ChainID: 421614, RPC: []string{"https://sepolia-rollup.arbitrum.io/rpc"}, Explorer: "https://sepolia.arbiscan.io", Symbol: "ETH"},
+	"base":             {Name: "Base", ChainID: 8453, RPC: []string{"https://mainnet.base.org"}, Explorer: "https://basescan.org", Symbol: "ETH", OPStack: true},
+	"base-sepolia":     {Name: "Base Sepolia", ChainID: 84532, RPC: []string{"https://sepolia.base.org"}, Explorer: "https://sepolia.basescan.org", Symbol: "ETH", OPStack: true},
+	"polygon":          {Name: "Polygon", ChainID: 137, RPC: []string{"https://polygon-rpc.com"}, Explorer: "https://polygonscan.com", Symbol: "POL"},
+	"bsc":              {Name: "BNB Smart Chain", ChainID: 56, RPC: []string{"https://bsc-dataseed.bnbchain.org"}, Explorer: "https://bscscan.com", Symbol: "BNB"},
+	"gnosis":           {Name: "Gnosis", ChainID: 100, RPC: []string{"https://rpc.gnosischain.com"}, Explorer: "https://gnosisscan.io", Symbol: "xDAI"},
+	"avalanche":        {Name: "Avalanche C-Chain", ChainID: 43114, RPC: []string{"https://api.avax.network/ext/bc/C/rpc"}, Explorer: "https://snowtrace.io", Symbol: "AVAX"},
+	"zksync":           {Name: "zkSync Era", ChainID: 324, RPC: []string{"https://mainnet.era.zksync.io"}, Explorer: "https://explorer.zksync.io", Symbol: "ETH"},
+	"linea":            {Name: "Linea", ChainID: 59144, RPC: []string{"https://rpc.linea.build"}, Explorer: "https://lineascan.build", Symbol: "ETH"},
+	"scroll":           {Name: "Scroll", ChainID: 534352, RPC: []string{"https://rpc.scroll.io"}, Explorer: "https://scrollscan.com", Symbol: "ETH"},
+}
+
+// Load returns the built-in chains together with those from the chain
+// registry file, which may add chains or override built-in ones by name.
+// A missing file is not an error.
+func Load() (map[string]Preset, error) {
+	chains := make(map[string]Preset, len(builtinChains))
+	for name, c := range builtinChains {
+		chains[name] = c
+	}
+	file := defaultChainsFile
+	if env := os.Getenv(envChains); env != "" {
+		file = env
+	}
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return chains, nil
+	case err != nil:
+		return chains, fmt.Errorf("failed to read chain registry: %v", err)
+	}
+	var extra map[string]Preset
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return chains, fmt.Errorf("invalid chain registry %s: %v", file, err)
+	}
+	for name, c := range extra {
+		if c.ChainID == 0 {
+			return chains, fmt.Errorf("invalid chain registry %s: %s has no chain_id", file, name)
+		}
+		if c.Name == "" {
+			c.Name = name
+		}
+		chains[strings.ToLower(name)] = c
+	}
+	return chains, nil
+}
+
+// ByID returns the registered chain with the given ID, if any. Errors
+// reading the registry file fall back to the built-in chains.
+func ByID(chainID *big.Int) (Preset, bool) {
+	if chainID == nil || !chainID.IsUint64() {
+		return Preset{}, false
+	}
+	chains, _ := Load()
+	for _, c := range chains {
+		if c.ChainID == chainID.Uint64() {
+			return c, true
+		}
+	}
+	return Preset{}, false
+}
+
+// ExplorerTxURL returns the block explorer link for the transaction hash
+// on chainID, or "" if the chain has no known explorer.
+func ExplorerTxURL(chainID *big.Int, hash string) string {
+	c, ok := ByID(chainID)
+	if !ok || c.Explorer == "" {
+		return ""
+	}
+	return strings.TrimSuffix(c.Explorer, "/") + "/tx/" + hash
+}
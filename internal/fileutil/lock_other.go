@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fileutil
+
+// Lock is a no-op where flock is unavailable; the file store's mutex still
+// serializes updates within one process.
+func Lock(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
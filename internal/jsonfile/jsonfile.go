@@ -0,0 +1,48 @@
+// Package jsonfile reads and writes the JSON files handed between machines.
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// Write writes v to a new file; an existing file is never
+// overwritten.
+func Write(file string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read decodes the single JSON value in file. Files saved by QR
+// scanner apps are accepted as long as they hold the scanned text.
+func Read(file string, v any) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return Decode(TrimScanned(data), v)
+}
+
+func Decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// TrimScanned strips the byte order mark and surrounding whitespace that
+// scanner apps tend to add.
+func TrimScanned(data []byte) []byte {
+	return bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+}
@@ -0,0 +1,81 @@
+// Package tracing records the OpenTelemetry spans of sign requests.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the instrumentation scope of the signer's spans.
+const tracerName = "secure-signer"
+
+// tracer starts the signer's own spans. It records nothing until
+// Setup installs an exporter.
+var tracer = otel.Tracer(tracerName)
+
+// Setup exports spans over OTLP/HTTP when an endpoint is configured
+// with the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables, which also set its headers,
+// TLS and sampling as usual, and propagates W3C trace context in any case.
+// serviceVersion is reported as the service.version resource attribute.
+// shutdown flushes the spans still buffered.
+func Setup(ctx context.Context, serviceVersion string) (shutdown func(ctx context.Context), err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) {}, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+	// The environment, e.g. OTEL_SERVICE_NAME, overrides the defaults.
+	res, err := resource.Merge(resource.NewSchemaless(
+		semconv.ServiceName(tracerName),
+		semconv.ServiceVersion(serviceVersion),
+	), resource.Environment())
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) { provider.Shutdown(ctx) }, nil
+}
+
+// StartSpan starts a span of the signer as a child of any span in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends span, marking it failed with err if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StoreOp runs the store operation op in a span.
+func StoreOp(ctx context.Context, op string, fn func() error) error {
+	_, span := StartSpan(ctx, "store."+op)
+	err := fn()
+	EndSpan(span, err)
+	return err
+}
+
+// HTTPClient makes outgoing requests, such as JSON-RPC calls, in
+// client spans carrying the trace context to the server.
+var HTTPClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
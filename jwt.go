@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"golang.org/x/time/rate"
+)
+
+// Operations a role may be allowed to perform.
+const (
+	opView    = "view"    // list accounts and public keys, read session and approval state, metrics
+	opSign    = "sign"    // eth_signTransaction, eth_sign and Web3Signer signing
+	opApprove = "approve" // add approvals to pending approval requests
+	opAdmin   = "admin"   // unlock and lock the session key
+)
+
+// roleOps are the operations of each role.
+var roleOps = map[string]map[string]bool{
+	"viewer":   {opView: true},
+	"signer":   {opView: true, opSign: true},
+	"approver": {opView: true, opApprove: true},
+	"admin":    {opView: true, opSign: true, opApprove: true, opAdmin: true},
+}
+
+// jwksRefresh is how often the JWKS is refetched: at most every minute when
+// a token names an unknown key, and at least every hour.
+const (
+	jwksMinRefresh = time.Minute
+	jwksMaxAge     = time.Hour
+)
+
+// jwtAlgorithms are the signature algorithms accepted in tokens; symmetric
+// algorithms are not, as the daemon only knows the IdP's public keys.
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// caller is the authenticated identity behind a request.
+type caller struct {
+	name string
+	// id identifies the caller to the RBAC grants (see rbacGrant).
+	id string
+	// roles limits the caller to the operations of its roles; nil means
+	// no limit.
+	roles []string
+	// keys restricts a role to the listed key addresses; a role without an
+	// entry may use every key.
+	keys map[string][]string
+	// rbac is set when the daemon enforces RBAC grants; the caller may then
+	// only sign what one of grants permits.
+	rbac   bool
+	grants []rbacGrant
+	// limiter is the rate limit of an API client, if it has one.
+	limiter *rate.Limiter
+}
+
+type callerKey struct{}
+
+// callerFrom returns the caller of a request, or nil when the daemon does
+// not authenticate clients.
+func callerFrom(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+	return c
+}
+
+// callerName returns the name of the caller of ctx, or "".
+func callerName(ctx context.Context) string {
+	if c := callerFrom(ctx); c != nil {
+		return c.name
+	}
+	return ""
+}
+
+// authorize returns an error unless the caller of ctx may perform op with
+// the key account.
+func authorize(ctx context.Context, op string, account common.Address) error {
+	c := callerFrom(ctx)
+	if c == nil || c.roles == nil {
+		return nil
+	}
+	for _, role := range c.roles {
+		if !roleOps[role][op] {
+			continue
+		}
+		keys, ok := c.keys[role]
+		if !ok {
+			return nil
+		}
+		for _, k := range keys {
+			if strings.EqualFold(k, account.Hex()) {
+				return nil
+			}
+		}
+	}
+	slog.Warn("request forbidden", "client", c.name, "roles", c.roles, "op", op, "account", account.Hex())
+	return fmt.Errorf("forbidden: %s may not %s with %s", c.name, op, account.Hex())
+}
+
+// authorized wraps an HTTP handler with authorize.
+func authorized(op string, account common.Address, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := authorize(r.Context(), op, account); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// jwtConfig is the file given to serve -jwt-config.
+type jwtConfig struct {
+	JWKSURL  string `json:"jwks_url"`
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// RolesClaim names the claim listing the caller's roles or groups, as a
+	// list or a space-separated string. A dotted name such as
+	// realm_access.roles reaches into nested claims. Default: roles.
+	RolesClaim string `json:"roles_claim"`
+	// RoleMap maps claim values to the roles viewer, signer, approver and
+	// admin. Values that already are role names need no entry; others are
+	// ignored.
+	RoleMap map[string]string `json:"role_map"`
+	// RoleKeys restricts roles to the listed key addresses.
+	RoleKeys      map[string][]string `json:"role_keys"`
+	LeewaySeconds int                 `json:"leeway_seconds"`
+}
+
+// jwtVerifier validates bearer tokens against the IdP's JWKS.
+type jwtVerifier struct {
+	cfg    jwtConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+func loadJWTVerifier(file string) (*jwtVerifier, error) {
+	var cfg jwtConfig
+	if err := jsonfile.Read(file, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to read jwt config: %v", err)
+	}
+	if !strings.HasPrefix(cfg.JWKSURL, "https://") && !strings.HasPrefix(cfg.JWKSURL, "http://") {
+		return nil, fmt.Errorf("jwt config: invalid jwks_url %q", cfg.JWKSURL)
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("jwt config: issuer and audience are required")
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	for value, role := range cfg.RoleMap {
+		if roleOps[role] == nil {
+			return nil, fmt.Errorf("jwt config: role_map.%s: unknown role %q", value, role)
+		}
+	}
+	for role, keys := range cfg.RoleKeys {
+		if roleOps[role] == nil {
+			return nil, fmt.Errorf("jwt config: role_keys: unknown role %q", role)
+		}
+		for _, k := range keys {
+			if !common.IsHexAddress(k) {
+				return nil, fmt.Errorf("jwt config: role_keys.%s: invalid address %q", role, k)
+			}
+		}
+	}
+	v := &jwtVerifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := v.key(ctx, ""); err != nil && !errors.Is(err, errUnknownJWK) {
+		slog.Warn("failed to fetch JWKS; retrying on first request", "url", cfg.JWKSURL, "err", err)
+	}
+	return v, nil
+}
+
+// bearerJWT returns the bearer token of r if it is shaped like a JWT.
+func bearerJWT(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && strings.Count(token, ".") == 2
+}
+
+var errUnknownJWK = errors.New("token signed by an unknown key")
+
+// key returns the JWKS signing key with the given ID, refetching the set
+// when it is stale or does not have it.
+func (v *jwtVerifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	k := v.find(kid)
+	since := time.Since(v.fetched)
+	if v.keys == nil || since > jwksMaxAge || (k == nil && since > jwksMinRefresh) {
+		if err := v.fetch(ctx); err != nil {
+			if k != nil {
+				slog.Warn("failed to refresh JWKS", "url", v.cfg.JWKSURL, "err", err)
+				return k, nil
+			}
+			return nil, err
+		}
+		k = v.find(kid)
+	}
+	if k == nil {
+		return nil, errUnknownJWK
+	}
+	return k, nil
+}
+
+func (v *jwtVerifier) find(kid string) *jose.JSONWebKey {
+	if v.keys == nil {
+		return nil
+	}
+	var found []jose.JSONWebKey
+	for _, k := range v.keys.Keys {
+		if (kid == "" || k.KeyID == kid) && (k.Use == "" || k.Use == "sig") {
+			found = append(found, k)
+		}
+	}
+	if len(found) != 1 {
+		return nil
+	}
+	return &found[0]
+}
+
+func (v *jwtVerifier) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	keys := new(jose.JSONWebKeySet)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(keys); err != nil {
+		return fmt.Errorf("invalid JWKS: %v", err)
+	}
+	v.keys, v.fetched = keys, time.Now()
+	return nil
+}
+
+// verify validates the token's signature, issuer, audience and lifetime and
+// returns its subject with the roles mapped from its claims.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (*caller, error) {
+	tok, err := jwt.ParseSigned(token, jwtAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+	header := tok.Headers[0]
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, fmt.Errorf("token algorithm %s does not match key %s", header.Algorithm, key.KeyID)
+	}
+	var claims jwt.Claims
+	var custom map[string]any
+	if err := tok.Claims(key.Key, &claims, &custom); err != nil {
+		return nil, err
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	expected := jwt.Expected{Issuer: v.cfg.Issuer, AnyAudience: jwt.Audience{v.cfg.Audience}, Time: time.Now()}
+	if err := claims.ValidateWithLeeway(expected, time.Duration(v.cfg.LeewaySeconds)*time.Second); err != nil {
+		return nil, err
+	}
+	return &caller{name: claims.Subject, id: "jwt:" + claims.Subject, roles: v.roles(custom), keys: v.cfg.RoleKeys}, nil
+}
+
+// roles maps the roles claim to roles. The result is never nil, so that a
+// token without roles may do nothing.
+func (v *jwtVerifier) roles(claims map[string]any) []string {
+	var value any = claims
+	for _, part := range strings.Split(v.cfg.RolesClaim, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			value = nil
+			break
+		}
+		value = m[part]
+	}
+	var values []string
+	switch value := value.(type) {
+	case string:
+		values = strings.Fields(value)
+	case []any:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	roles := []string{}
+	for _, s := range values {
+		role, ok := v.cfg.RoleMap[s]
+		if !ok && roleOps[s] != nil {
+			role, ok = s, true
+		}
+		if ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "", "File to write the new hex private key to (created with mode 0600)")
+	keystoreFile := fs.String("keystore", "", "File to write the new key to as an encrypted keystore v3 JSON file (instead of -out)")
+	passphraseFile := fs.String("passphrase-file", "", "File containing the keystore passphrase (prompted when omitted)")
+	scryptN := fs.Int("scrypt-n", keystore.StandardScryptN, "Keystore scrypt CPU/memory cost parameter N")
+	scryptP := fs.Int("scrypt-p", keystore.StandardScryptP, "Keystore scrypt parallelization parameter P")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if (*out == "") == (*keystoreFile == "") {
+		return errors.New("exactly one of out and keystore is required")
+	}
+	if *scryptN < 2 || *scryptN&(*scryptN-1) != 0 {
+		return errors.New("scrypt-n must be a power of two greater than 1")
+	}
+	if *scryptP < 1 {
+		return errors.New("scrypt-p must be positive")
+	}
+	var passphrase string
+	if *keystoreFile != "" {
+		var err error
+		if passphrase, err = newPassphrase(*passphraseFile); err != nil {
+			return err
+		}
+	}
+	key, err := generateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %v", err)
+	}
+	defer key.Destroy()
+	var data []byte
+	file := *out
+	if *keystoreFile != "" {
+		file = *keystoreFile
+		if data, err = encryptKeystore(key, passphrase, *scryptN, *scryptP); err != nil {
+			return fmt.Errorf("failed to encrypt key: %v", err)
+		}
+	} else {
+		err = key.Use(func(priv *ecdsa.PrivateKey) error {
+			raw := crypto.FromECDSA(priv)
+			defer clear(raw)
+			data = hex.AppendEncode(nil, raw)
+			data = append(data, '\n')
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		defer clear(data)
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	address := key.Address().Hex()
+	output.print(struct {
+		Address string `json:"address"`
+		File    string `json:"file"`
+	}{address, file}, func() {
+		fmt.Println("Address:", address)
+	})
+	return nil
+}
+
+// encryptKeystore encodes key as a keystore v3 JSON file protected by
+// passphrase with the given scrypt parameters.
+func encryptKeystore(key *keys.SecretKey, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = key.Use(func(priv *ecdsa.PrivateKey) error {
+		var err error
+		data, err = keystore.EncryptKey(&keystore.Key{
+			Id:         id,
+			Address:    key.Address(),
+			PrivateKey: priv,
+		}, passphrase, scryptN, scryptP)
+		return err
+	})
+	return data, err
+}
+
+// generateKey returns a new random key.
+func generateKey() (*keys.SecretKey, error) {
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return keys.SecretKeyFromECDSA(priv)
+}
+
+// newPassphrase reads a passphrase for a new keystore from file, or prompts
+// for it twice on the terminal.
+func newPassphrase(file string) (string, error) {
+	passphrase, err := readPassphrase(file, "New keystore passphrase: ")
+	if err != nil || file != "" {
+		return passphrase, err
+	}
+	confirm, err := readPassphrase("", "Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// envKeysDir names the environment variable that overrides the default
+// keyring directory.
+const envKeysDir = "SIGNER_KEYS_DIR"
+
+// defaultKeysDir is the keyring directory used when neither -keys-dir nor
+// SIGNER_KEYS_DIR is set.
+const defaultKeysDir = "signer-keys"
+
+// keyringIndex is the file in the keyring directory listing its keys.
+const keyringIndex = "index.json"
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// keyringEntry is a key in the keyring. Local keys are stored as keystore
+// v3 files in the keyring directory, named by File; other keys are
+// referenced by their backend URI, such as awskms://<key-id>.
+type keyringEntry struct {
+	Address common.Address `json:"address"`
+	File    string         `json:"file,omitempty"`
+	URI     string         `json:"uri,omitempty"`
+	Label   string         `json:"label,omitempty"`
+	Added   time.Time      `json:"added"`
+}
+
+// keyring is a directory of keys addressable by alias.
+type keyring struct {
+	dir  string
+	Keys map[string]*keyringEntry `json:"keys"`
+}
+
+// keyringDir returns dir, or the directory from SIGNER_KEYS_DIR or the
+// default.
+func keyringDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if env := os.Getenv(envKeysDir); env != "" {
+		return env
+	}
+	return defaultKeysDir
+}
+
+// openKeyring reads the keyring index in dir. A missing index is an empty
+// keyring.
+func openKeyring(dir string) (*keyring, error) {
+	k := &keyring{dir: keyringDir(dir), Keys: make(map[string]*keyringEntry)}
+	data, err := os.ReadFile(filepath.Join(k.dir, keyringIndex))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return k, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read keyring: %v", err)
+	}
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, fmt.Errorf("invalid keyring index: %v", err)
+	}
+	if k.Keys == nil {
+		k.Keys = make(map[string]*keyringEntry)
+	}
+	return k, nil
+}
+
+// updateKeyring runs fn on the keyring in dir under its lock and saves the
+// index if fn succeeds.
+func updateKeyring(dir string, fn func(*keyring) error) error {
+	dir = keyringDir(dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	unlock, err := fileutil.Lock(filepath.Join(dir, keyringIndex+".lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	k, err := openKeyring(dir)
+	if err != nil {
+		return err
+	}
+	if err := fn(k); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(filepath.Join(dir, keyringIndex), data)
+}
+
+// entry returns the key with alias.
+func (k *keyring) entry(alias string) (*keyringEntry, error) {
+	e, ok := k.Keys[alias]
+	if !ok {
+		return nil, fmt.Errorf("no key %q in keyring %s", alias, k.dir)
+	}
+	return e, nil
+}
+
+// add stores e under alias, refusing duplicate aliases and addresses.
+func (k *keyring) add(alias string, e *keyringEntry) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("invalid alias %q: use lowercase letters, digits, '.', '_' and '-'", alias)
+	}
+	if _, ok := k.Keys[alias]; ok {
+		return fmt.Errorf("alias %q is already in use", alias)
+	}
+	for other, existing := range k.Keys {
+		if existing.Address == e.Address {
+			return fmt.Errorf("%s is already in the keyring as %q", e.Address.Hex(), other)
+		}
+	}
+	k.Keys[alias] = e
+	return nil
+}
+
+// resolveKeySource turns src.from into the key source of the aliased key.
+func resolveKeySource(src keySource) (keySource, error) {
+	if src.hexKey != "" || src.keystoreFile != "" || src.fromStdin || src.mnemonic || src.mnemonicFile != "" || src.pkcs11Module != "" {
+		return src, errors.New("from cannot be combined with another key source")
+	}
+	k, err := openKeyring(src.keysDir)
+	if err != nil {
+		return src, err
+	}
+	e, err := k.entry(src.from)
+	if err != nil {
+		return src, err
+	}
+	if e.URI != "" {
+		src.hexKey = e.URI
+	} else {
+		src.keystoreFile = filepath.Join(k.dir, e.File)
+	}
+	src.from = ""
+	return src, nil
+}
+
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secure-signer keys <list|add|remove|label> [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runKeysList(args[1:])
+	case "add":
+		return runKeysAdd(args[1:])
+	case "remove":
+		return runKeysRemove(args[1:])
+	case "label":
+		return runKeysLabel(args[1:])
+	}
+	return fmt.Errorf("unknown keys command %q", args[0])
+}
+
+func addKeysDirFlag(fs *flag.FlagSet) *string {
+	return fs.String("keys-dir", "", "Keyring directory (default $"+envKeysDir+" or "+defaultKeysDir+")")
+}
+
+func runKeysList(args []string) error {
+	fs := flag.NewFlagSet("keys list", flag.ExitOnError)
+	dir := addKeysDirFlag(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	k, err := openKeyring(*dir)
+	if err != nil {
+		return err
+	}
+	type listed struct {
+		Alias string `json:"alias"`
+		*keyringEntry
+	}
+	keys := []listed{}
+	for alias, e := range k.Keys {
+		keys = append(keys, listed{alias, e})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Alias < keys[j].Alias })
+	output.print(keys, func() {
+		for _, key := range keys {
+			source := "keystore"
+			if key.URI != "" {
+				source, _, _ = strings.Cut(key.URI, "://")
+			}
+			fmt.Printf("%-20s %s %-9s %s\n", key.Alias, key.Address.Hex(), source, key.Label)
+		}
+	})
+	return nil
+}
+
+func runKeysAdd(args []string) error {
+	fs := flag.NewFlagSet("keys add", flag.ExitOnError)
+	dir := addKeysDirFlag(fs)
+	alias := fs.String("alias", "", "Alias to address the key by, as in -from <alias>")
+	label := fs.String("label", "", "Free-form description of the key")
+	generate := fs.Bool("generate", false, "Generate a new key")
+	fromStdin := fs.Bool("key-stdin", false, "Import a hex private key read from stdin")
+	importFile := fs.String("import-keystore", "", "Import a keystore v3 JSON file, keeping its passphrase")
+	uri := fs.String("uri", "", "Reference a key backend URI (awskms://, gcpkms://, azurekv://, vault://, ledger://, trezor://) instead of storing a key")
+	passphraseFile := fs.String("passphrase-file", "", "File containing the passphrase to encrypt a generated or imported hex key with (prompted when omitted)")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	sources := 0
+	for _, set := range []bool{*generate, *fromStdin, *importFile != "", *uri != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("exactly one of generate, key-stdin, import-keystore and uri is required")
+	}
+	if *alias == "" {
+		return errors.New("alias is required")
+	}
+	e := &keyringEntry{Label: *label, Added: time.Now().UTC()}
+	var data []byte
+	switch {
+	case *uri != "":
+		if _, _, ok := strings.Cut(*uri, "://"); !ok {
+			return fmt.Errorf("invalid key backend URI %q", *uri)
+		}
+		key, err := openBackend(context.Background(), keySource{hexKey: *uri, passphraseFile: *passphraseFile, vaultAuth: vaultAuthToken})
+		if err != nil {
+			return fmt.Errorf("failed to open key backend: %v", err)
+		}
+		e.URI, e.Address = *uri, key.Address()
+	case *importFile != "":
+		var err error
+		if data, err = os.ReadFile(*importFile); err != nil {
+			return err
+		}
+		var ks struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(data, &ks); err != nil || !common.IsHexAddress(ks.Address) {
+			return errors.New("keystore file has no valid address")
+		}
+		e.Address = common.HexToAddress(ks.Address)
+	default:
+		key, err := generateKey()
+		if *fromStdin {
+			key, err = readPrivateKey(os.Stdin)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load private key: %v", err)
+		}
+		defer key.Destroy()
+		passphrase, err := newPassphrase(*passphraseFile)
+		if err != nil {
+			return err
+		}
+		if data, err = encryptKeystore(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP); err != nil {
+			return fmt.Errorf("failed to encrypt key: %v", err)
+		}
+		e.Address = key.Address()
+	}
+	err := updateKeyring(*dir, func(k *keyring) error {
+		if data != nil {
+			e.File = strings.ToLower(e.Address.Hex()[2:]) + ".json"
+		}
+		if err := k.add(*alias, e); err != nil {
+			return err
+		}
+		if data == nil {
+			return nil
+		}
+		f, err := os.OpenFile(filepath.Join(k.dir, e.File), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add key: %v", err)
+	}
+	output.print(struct {
+		Alias   string `json:"alias"`
+		Address string `json:"address"`
+	}{*alias, e.Address.Hex()}, func() {
+		fmt.Printf("Added %s as %s\n", e.Address.Hex(), *alias)
+	})
+	return nil
+}
+
+func runKeysRemove(args []string) error {
+	fs := flag.NewFlagSet("keys remove", flag.ExitOnError)
+	dir := addKeysDirFlag(fs)
+	alias := fs.String("alias", "", "Alias of the key to remove")
+	parseFlags(fs, args)
+
+	if *alias == "" {
+		return errors.New("alias is required")
+	}
+	var removed string
+	err := updateKeyring(*dir, func(k *keyring) error {
+		e, err := k.entry(*alias)
+		if err != nil {
+			return err
+		}
+		if e.File != "" {
+			// Keep the encrypted key so a mistaken removal can be undone
+			// by importing it again.
+			removed = filepath.Join(k.dir, e.File+".removed-"+time.Now().UTC().Format("20060102T150405Z"))
+			if err := os.Rename(filepath.Join(k.dir, e.File), removed); err != nil {
+				return err
+			}
+		}
+		delete(k.Keys, *alias)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove key: %v", err)
+	}
+	fmt.Println("Removed", *alias)
+	if removed != "" {
+		fmt.Println("Keystore moved to", removed)
+	}
+	return nil
+}
+
+func runKeysLabel(args []string) error {
+	fs := flag.NewFlagSet("keys label", flag.ExitOnError)
+	dir := addKeysDirFlag(fs)
+	alias := fs.String("alias", "", "Alias of the key to label")
+	label := fs.String("label", "", "New label (empty clears it)")
+	parseFlags(fs, args)
+
+	if *alias == "" {
+		return errors.New("alias is required")
+	}
+	err := updateKeyring(*dir, func(k *keyring) error {
+		e, err := k.entry(*alias)
+		if err != nil {
+			return err
+		}
+		e.Label = *label
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to label key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/term"
+)
+
+// envPrivateKey names the environment variable consulted when no explicit key
+// source flag is given.
+const envPrivateKey = "SIGNER_PRIVATE_KEY"
+
+// keySource describes where the signing key should be read from.
+type keySource struct {
+	hexKey         string
+	keystoreFile   string
+	passphraseFile string
+	fromStdin      bool
+	mnemonic       bool
+	mnemonicFile   string
+	derivationPath string
+	// from is the alias of a key in the keyring in keysDir.
+	from    string
+	keysDir string
+
+	vaultAuth         string
+	vaultRoleID       string
+	vaultSecretIDFile string
+
+	pkcs11Module   string
+	pkcs11Slot     uint
+	pkcs11KeyLabel string
+	pkcs11PINFile  string
+}
+
+// addKeyFlags registers the key source flags on fs.
+func addKeyFlags(fs *flag.FlagSet) *keySource {
+	src := new(keySource)
+	fs.StringVar(&src.hexKey, "key", "", "Private key in hex (prefer -key-stdin or "+envPrivateKey+"), or a key backend URI (awskms://<key-id>, gcpkms://projects/..., azurekv://<vault>/<key>, vault://<path>, vaultkv://<path>, ledger://<path>, trezor://<path>)")
+	fs.StringVar(&src.keystoreFile, "keystore", "", "Path to an encrypted keystore v3 JSON file (instead of -key)")
+	fs.StringVar(&src.passphraseFile, "passphrase-file", "", "File containing the keystore or Trezor passphrase (prompted when omitted)")
+	fs.BoolVar(&src.fromStdin, "key-stdin", false, "Read the hex private key from stdin")
+	fs.BoolVar(&src.mnemonic, "mnemonic", false, "Derive the key from a BIP-39 mnemonic (prompted unless -mnemonic-file is set)")
+	fs.StringVar(&src.mnemonicFile, "mnemonic-file", "", "File containing the BIP-39 mnemonic (implies -mnemonic)")
+	fs.StringVar(&src.derivationPath, "derivation-path", keys.DefaultDerivationPath, "BIP-44 derivation path used with -mnemonic")
+	fs.StringVar(&src.from, "from", "", "Alias of a key in the keyring (see the keys command) to sign with instead of -key")
+	fs.StringVar(&src.keysDir, "keys-dir", "", "Keyring directory for -from (default $"+envKeysDir+" or "+defaultKeysDir+")")
+	fs.StringVar(&src.vaultAuth, "vault-auth", vaultAuthToken, "Vault auth method for vault:// keys: token (VAULT_TOKEN) or approle")
+	fs.StringVar(&src.vaultRoleID, "vault-role-id", "", "Vault AppRole role ID (default $VAULT_ROLE_ID)")
+	fs.StringVar(&src.vaultSecretIDFile, "vault-secret-id-file", "", "File containing the Vault AppRole secret ID (default $VAULT_SECRET_ID)")
+	fs.StringVar(&src.pkcs11Module, "pkcs11-module", "", "Path to a PKCS#11 module; signs with an HSM key instead of -key")
+	fs.UintVar(&src.pkcs11Slot, "slot", 0, "PKCS#11 slot ID")
+	fs.StringVar(&src.pkcs11KeyLabel, "key-label", "", "CKA_LABEL of the PKCS#11 key pair")
+	fs.StringVar(&src.pkcs11PINFile, "pin-file", "", "File containing the PKCS#11 user PIN (prompted when omitted)")
+	return src
+}
+
+// loadSigningKey resolves the key from exactly one explicit source (-key,
+// -keystore, -key-stdin or -mnemonic). When none is given it falls back to
+// the SIGNER_PRIVATE_KEY environment variable.
+func loadSigningKey(src keySource) (*keys.SecretKey, error) {
+	useMnemonic := src.mnemonic || src.mnemonicFile != ""
+	explicit := 0
+	for _, set := range []bool{src.hexKey != "", src.keystoreFile != "", src.fromStdin, useMnemonic} {
+		if set {
+			explicit++
+		}
+	}
+	if explicit > 1 {
+		return nil, errors.New("key, keystore, key-stdin and mnemonic are mutually exclusive")
+	}
+	switch {
+	case useMnemonic:
+		mnemonic, err := readPassphrase(src.mnemonicFile, "Mnemonic: ")
+		if err != nil {
+			return nil, err
+		}
+		return keys.DeriveKey(mnemonic, src.derivationPath)
+	case src.keystoreFile != "":
+		return loadKeystore(src.keystoreFile, src.passphraseFile)
+	case src.fromStdin:
+		return readPrivateKey(os.Stdin)
+	case src.hexKey != "":
+		return loadPrivateKey(src.hexKey)
+	}
+	if hexKey := os.Getenv(envPrivateKey); hexKey != "" {
+		return loadPrivateKey(hexKey)
+	}
+	return nil, fmt.Errorf("no key given: use -key, -keystore, -key-stdin, -mnemonic or %s", envPrivateKey)
+}
+
+// readPrivateKey reads a hex private key from the first line of r.
+// The line is read into a byte buffer that is wiped afterwards.
+func readPrivateKey(r io.Reader) (*keys.SecretKey, error) {
+	line, err := bufio.NewReader(r).ReadSlice('\n')
+	defer clear(line)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return keys.ParseHexKey(line)
+}
+
+// loadPrivateKey parses a hex private key given as a string, from a flag,
+// the environment or Vault. Go strings cannot be wiped, so the key keeps a
+// copy on the heap there; -key-stdin and keystores avoid that.
+func loadPrivateKey(hexKey string) (*keys.SecretKey, error) {
+	b := []byte(hexKey)
+	defer clear(b)
+	return keys.ParseHexKey(b)
+}
+
+// loadKeystore decrypts a keystore v3 JSON file. The passphrase is read from
+// passphraseFile when set, otherwise the operator is prompted on the terminal.
+func loadKeystore(file, passphraseFile string) (*keys.SecretKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := readPassphrase(passphraseFile, "Keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return keys.SecretKeyFromECDSA(key.PrivateKey)
+}
+
+// readPassphrase returns the first line of file, or prompts for a passphrase
+// without echo when file is empty.
+func readPassphrase(file, prompt string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no passphrase file given and stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
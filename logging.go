@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+)
+
+// redactedKeys are attribute keys whose values are never logged, whatever
+// their type. Matching ignores case and "-" versus "_".
+var redactedKeys = map[string]bool{
+	"key":           true,
+	"private_key":   true,
+	"privkey":       true,
+	"passphrase":    true,
+	"password":      true,
+	"mnemonic":      true,
+	"seed":          true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"authorization": true,
+}
+
+const redacted = "[REDACTED]"
+
+// redact is the slog ReplaceAttr hook that keeps secrets out of the logs:
+// it blanks attributes with a secret-sounding key and any private key
+// value, however it is labelled.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ReplaceAll(strings.ToLower(a.Key), "-", "_")] {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		switch a.Value.Any().(type) {
+		case *ecdsa.PrivateKey, ecdsa.PrivateKey, *keys.SecretKey:
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+// logFlags are the global logging flags, given before the command name.
+type logFlags struct {
+	level  slog.Level
+	format string
+	file   string
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	l := &logFlags{level: slog.LevelInfo}
+	fs.TextVar(&l.level, "log-level", l.level, "Log level: debug, info, warn or error")
+	fs.StringVar(&l.format, "log-format", "text", "Log format: text or json")
+	fs.StringVar(&l.file, "log-file", "", "Append logs to this file instead of stderr")
+	return l
+}
+
+// setup installs the default slog logger. The returned function closes the
+// log file, if any.
+func (l *logFlags) setup() (func(), error) {
+	var w io.Writer = os.Stderr
+	closeLog := func() {}
+	if l.file != "" {
+		f, err := os.OpenFile(l.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		w = f
+		closeLog = func() { f.Close() }
+	}
+	opts := &slog.HandlerOptions{Level: l.level, ReplaceAttr: redact}
+	var h slog.Handler
+	switch l.format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		closeLog()
+		return nil, fmt.Errorf("invalid log-format %q: use text or json", l.format)
+	}
+	slog.SetDefault(slog.New(h))
+	return closeLog, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// messageFlags are the payload flags shared by sign-message and
+// verify-message. Exactly one of them must be set.
+type messageFlags struct {
+	text string
+	hex  string
+}
+
+func addMessageFlags(fs *flag.FlagSet) *messageFlags {
+	m := new(messageFlags)
+	fs.StringVar(&m.text, "message", "", "Message as UTF-8 text")
+	fs.StringVar(&m.hex, "hex", "", "Message as 0x-prefixed hex bytes")
+	return m
+}
+
+func (m *messageFlags) payload() ([]byte, error) {
+	switch {
+	case m.text != "" && m.hex != "":
+		return nil, errors.New("message and hex are mutually exclusive")
+	case m.hex != "":
+		data, err := hexutil.Decode(m.hex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex message: %v", err)
+		}
+		return data, nil
+	case m.text != "":
+		return []byte(m.text), nil
+	}
+	return nil, errors.New("message or hex is required")
+}
+
+// messageRequest is the audit log form of a personal message.
+func messageRequest(data []byte) any {
+	return struct {
+		Message hexutil.Bytes `json:"message"`
+	}{data}
+}
+
+func runSignMessage(args []string) error {
+	fs := flag.NewFlagSet("sign-message", flag.ExitOnError)
+	keyOpts := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	msg := addMessageFlags(fs)
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	data, err := msg.payload()
+	if err != nil {
+		return err
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key, err := openKeyBackend(ctx, *keyOpts, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy = policy.ForSender(key.Address())
+	request := messageRequest(data)
+	hash := accounts.TextHash(data)
+	if !policy.AllowEthSign {
+		return audit.Denied(signer.AuditMessage, request, hash, errors.New("policy check failed: message signing is not allowed"))
+	}
+	if err := policy.CheckSigningWindow(time.Now(), *overrideReason); err != nil {
+		return audit.Denied(signer.AuditMessage, request, hash, fmt.Errorf("policy check failed: %v", err))
+	}
+	sig, err := keys.SignPersonalMessage(ctx, key, data)
+	if err != nil {
+		err = fmt.Errorf("failed to sign message: %v", err)
+	}
+	if err := audit.Signed(signer.AuditMessage, key.Address(), request, hash, err); err != nil {
+		return err
+	}
+	out := struct {
+		Address string `json:"address"`
+		signatureOutput
+	}{key.Address().Hex(), newSignatureOutput(sig)}
+	output.print(out, func() {
+		fmt.Println("Address:", out.Address)
+		out.printText()
+	})
+	return nil
+}
+
+func runVerifyMessage(args []string) error {
+	fs := flag.NewFlagSet("verify-message", flag.ExitOnError)
+	msg := addMessageFlags(fs)
+	address := fs.String("address", "", "Expected signer address")
+	sigHex := fs.String("signature", "", "65-byte signature in hex")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *address == "" || *sigHex == "" {
+		return errors.New("address and signature are required")
+	}
+	if !common.IsHexAddress(*address) {
+		return fmt.Errorf("invalid address %q", *address)
+	}
+	data, err := msg.payload()
+	if err != nil {
+		return err
+	}
+	sig, err := hexutil.Decode(*sigHex)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	signer, err := keys.RecoverPersonalMessage(data, sig)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %v", err)
+	}
+	if signer != common.HexToAddress(*address) {
+		return fmt.Errorf("signature mismatch: signed by %s", signer.Hex())
+	}
+	output.print(struct {
+		Valid  bool   `json:"valid"`
+		Signer string `json:"signer"`
+	}{true, signer.Hex()}, func() {
+		fmt.Println("Valid signature from", signer.Hex())
+	})
+	return nil
+}
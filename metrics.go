@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of a signing request in the metrics.
+const (
+	resultSigned = "signed"
+	resultDenied = "denied"
+	resultError  = "error"
+)
+
+// signerMetrics are the daemon's Prometheus metrics. Request kinds are the
+// audit log kinds. A nil *signerMetrics records nothing.
+type signerMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	denials  *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	keyUsage *prometheus.CounterVec
+}
+
+func newSignerMetrics() *signerMetrics {
+	m := &signerMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "secure_signer_sign_requests_total",
+			Help: "Signing requests by kind and result (signed, denied or error).",
+		}, []string{"kind", "result"}),
+		denials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "secure_signer_policy_denials_total",
+			Help: "Signing requests denied by the policy, by kind and the rule that denied them.",
+		}, []string{"kind", "rule"}),
+		// Local keys sign in well under a millisecond; KMS and hardware
+		// backends take up to seconds.
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "secure_signer_sign_duration_seconds",
+			Help:    "Time taken to policy-check and sign a request, by kind.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 15),
+		}, []string{"kind"}),
+		keyUsage: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "secure_signer_key_signatures_total",
+			Help: "Signatures produced, by account and kind.",
+		}, []string{"account", "kind"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.denials, m.latency, m.keyUsage,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+func (m *signerMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observe records a request of kind for account that started at start and
+// ended with err. rule names the policy rule that denied it, if any.
+func (m *signerMetrics) observe(kind string, account common.Address, start time.Time, rule string, err error) {
+	if m == nil {
+		return
+	}
+	m.latency.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+	switch {
+	case err == nil:
+		m.requests.WithLabelValues(kind, resultSigned).Inc()
+		m.keyUsage.WithLabelValues(account.Hex(), kind).Inc()
+	case rule != "":
+		m.requests.WithLabelValues(kind, resultDenied).Inc()
+		m.denials.WithLabelValues(kind, rule).Inc()
+	default:
+		m.requests.WithLabelValues(kind, resultError).Inc()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// warnNonceGap tells the operator that count nonces from floor were handed
+// out but are not pending on the node, and how to reclaim them.
+func warnNonceGap(floor, count uint64) {
+	fmt.Fprintf(os.Stderr, "warning: nonce gap: %d handed-out nonces from %d are not pending on the node; run 'secure-signer nonce -reset' if they were never broadcast\n", count, floor)
+}
+
+func runNonce(args []string) error {
+	fs := flag.NewFlagSet("nonce", flag.ExitOnError)
+	address := fs.String("address", "", "Account address")
+	chainID := addChainFlag(fs, 1, "Chain ID (default Ethereum mainnet, or fetched via -rpc when omitted)")
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the nonces")
+	reset := fs.Bool("reset", false, "Set the stored next nonce to the node's pending nonce (requires -rpc)")
+	set := fs.Int64("set", -1, "Set the stored next nonce to this value")
+	rpcOpts := addRPCFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if !common.IsHexAddress(*address) {
+		return errors.New("address is required")
+	}
+	if *reset && *set >= 0 {
+		return errors.New("reset and set are mutually exclusive")
+	}
+	from := common.HexToAddress(*address)
+	ctx := context.Background()
+	chain := chainID.big()
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	var pending *uint64
+	if client != nil {
+		defer client.Close()
+		var want *big.Int
+		if flagWasSet(fs, "chain") {
+			want = chain
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return err
+		}
+		n, err := txbuilder.FetchNonce(ctx, client, from)
+		if err != nil {
+			return err
+		}
+		pending = &n
+	}
+	if *reset && pending == nil {
+		return errors.New("reset requires rpc")
+	}
+
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key := signer.NonceKey(chain, from)
+	switch {
+	case *reset:
+		err = store.SetNonce(key, *pending)
+	case *set >= 0:
+		err = store.SetNonce(key, uint64(*set))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set nonce: %v", err)
+	}
+	next, ok, err := store.Nonce(key)
+	if err != nil {
+		return fmt.Errorf("failed to read nonce: %v", err)
+	}
+
+	var out struct {
+		Address string  `json:"address"`
+		ChainID string  `json:"chainId"`
+		Next    *uint64 `json:"next,omitempty"`
+		Pending *uint64 `json:"pending,omitempty"`
+		Gap     uint64  `json:"gap,omitempty"`
+	}
+	out.Address = from.Hex()
+	out.ChainID = chain.String()
+	out.Pending = pending
+	if ok {
+		out.Next = &next
+		if pending != nil && next > *pending {
+			out.Gap = next - *pending
+		}
+	}
+	output.print(out, func() {
+		fmt.Println("Address:", out.Address)
+		fmt.Println("ChainID:", out.ChainID)
+		if out.Next != nil {
+			fmt.Println("Next:", *out.Next)
+		} else {
+			fmt.Println("Next: none stored")
+		}
+		if out.Pending != nil {
+			fmt.Println("Pending:", *out.Pending)
+		}
+		if out.Gap > 0 {
+			fmt.Printf("Gap: %d nonces handed out but not pending on the node\n", out.Gap)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// interchangeVersion is the version of the unsigned and signed transaction
+// files. Readers reject other versions rather than guess at their meaning.
+const interchangeVersion = 1
+
+// unsignedTxFile is written by prepare on an online machine and signed by
+// sign -unsigned-file on an air-gapped one. Amounts are decimal strings.
+// SigningHash lets both machines show the operator the same fingerprint and
+// is checked when the file is read.
+type unsignedTxFile struct {
+	Version              int                          `json:"version"`
+	ChainID              string                       `json:"chainId"`
+	From                 string                       `json:"from"`
+	Type                 string                       `json:"type"`
+	To                   string                       `json:"to"`
+	Nonce                uint64                       `json:"nonce"`
+	Value                string                       `json:"value"`
+	Gas                  uint64                       `json:"gas"`
+	GasPrice             string                       `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string                       `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string                       `json:"maxPriorityFeePerGas,omitempty"`
+	Data                 string                       `json:"data"`
+	AccessList           types.AccessList             `json:"accessList,omitempty"`
+	AuthorizationList    []types.SetCodeAuthorization `json:"authorizationList,omitempty"`
+	SigningHash          string                       `json:"signingHash"`
+}
+
+// signedTxFile is written by sign -signed-file and read by broadcast
+// -signed-file.
+type signedTxFile struct {
+	Version int    `json:"version"`
+	ChainID string `json:"chainId"`
+	From    string `json:"from"`
+	TxHash  string `json:"txHash"`
+	RawTx   string `json:"rawTx"`
+}
+
+func newUnsignedTxFile(tx *types.Transaction, from common.Address, chainID *big.Int) (*unsignedTxFile, error) {
+	f := &unsignedTxFile{
+		Version:           interchangeVersion,
+		ChainID:           chainID.String(),
+		From:              from.Hex(),
+		To:                tx.To().Hex(),
+		Nonce:             tx.Nonce(),
+		Value:             tx.Value().String(),
+		Gas:               tx.Gas(),
+		Data:              hexutil.Encode(tx.Data()),
+		AccessList:        tx.AccessList(),
+		AuthorizationList: tx.SetCodeAuthorizations(),
+		SigningHash:       types.LatestSignerForChainID(chainID).Hash(tx).Hex(),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		f.Type = txbuilder.TxTypeLegacy
+		f.GasPrice = tx.GasPrice().String()
+	case types.AccessListTxType:
+		f.Type = txbuilder.TxTypeAccessList
+		f.GasPrice = tx.GasPrice().String()
+	case types.DynamicFeeTxType, types.SetCodeTxType:
+		f.Type = txbuilder.TxTypeDynamic
+		if tx.Type() == types.SetCodeTxType {
+			f.Type = txbuilder.TxTypeSetCode
+		}
+		f.MaxFeePerGas = tx.GasFeeCap().String()
+		f.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	default:
+		return nil, fmt.Errorf("cannot export transactions of type %d", tx.Type())
+	}
+	return f, nil
+}
+
+// readUnsignedTx reads an unsignedTxFile and rebuilds its transaction,
+// which must be sent by from.
+func readUnsignedTx(file string, from common.Address) (*types.Transaction, *big.Int, error) {
+	var f unsignedTxFile
+	if err := jsonfile.Read(file, &f); err != nil {
+		return nil, nil, fmt.Errorf("failed to read unsigned tx: %v", err)
+	}
+	if f.Version != interchangeVersion {
+		return nil, nil, fmt.Errorf("unsupported unsigned tx version %d", f.Version)
+	}
+	if !common.IsHexAddress(f.From) || common.HexToAddress(f.From) != from {
+		return nil, nil, fmt.Errorf("unsigned tx is from %s, not %s", f.From, from.Hex())
+	}
+	if !common.IsHexAddress(f.To) {
+		return nil, nil, fmt.Errorf("invalid recipient %q", f.To)
+	}
+	chainID, ok := new(big.Int).SetString(f.ChainID, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid chain ID %q", f.ChainID)
+	}
+	value, err := txbuilder.ParseWei("value", f.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := txbuilder.ParseCalldata(f.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	fees := txbuilder.FeeParams{TxType: f.Type, AccessList: f.AccessList, AuthList: f.AuthorizationList}
+	switch f.Type {
+	case txbuilder.TxTypeLegacy, txbuilder.TxTypeAccessList:
+		if fees.GasPrice, err = txbuilder.ParseWei("gasPrice", f.GasPrice); err != nil {
+			return nil, nil, err
+		}
+	case txbuilder.TxTypeDynamic, txbuilder.TxTypeSetCode:
+		if fees.MaxFee, err = txbuilder.ParseWei("maxFeePerGas", f.MaxFeePerGas); err != nil {
+			return nil, nil, err
+		}
+		if fees.MaxPriorityFee, err = txbuilder.ParseWei("maxPriorityFeePerGas", f.MaxPriorityFeePerGas); err != nil {
+			return nil, nil, err
+		}
+	}
+	if f.Gas == 0 {
+		return nil, nil, errors.New("unsigned tx has no gas limit")
+	}
+	tx, err := txbuilder.Build(f.Nonce, common.HexToAddress(f.To), value, data, f.Gas, chainID, fees)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid unsigned tx: %v", err)
+	}
+	if hash := types.LatestSignerForChainID(chainID).Hash(tx); hash != common.HexToHash(f.SigningHash) {
+		return nil, nil, fmt.Errorf("unsigned tx signing hash %s does not match its contents (%s)", f.SigningHash, hash.Hex())
+	}
+	return tx, chainID, nil
+}
+
+func newSignedTxFile(tx *types.Transaction, from common.Address) (*signedTxFile, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &signedTxFile{
+		Version: interchangeVersion,
+		ChainID: tx.ChainId().String(),
+		From:    from.Hex(),
+		TxHash:  tx.Hash().Hex(),
+		RawTx:   hexutil.Encode(raw),
+	}, nil
+}
+
+// readSignedTx reads a signedTxFile and decodes its transaction, checking
+// that it matches the recorded sender and hash. A file holding just the raw
+// transaction hex, as scanned from a wallet's QR code, is accepted too.
+func readSignedTx(file string) (*types.Transaction, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signed tx: %v", err)
+	}
+	text := jsonfile.TrimScanned(data)
+	if len(text) > 0 && text[0] != '{' {
+		return txbuilder.DecodeRawTx(string(text))
+	}
+	var f signedTxFile
+	if err := jsonfile.Decode(text, &f); err != nil {
+		return nil, fmt.Errorf("failed to read signed tx: %v", err)
+	}
+	if f.Version != interchangeVersion {
+		return nil, fmt.Errorf("unsupported signed tx version %d", f.Version)
+	}
+	tx, err := txbuilder.DecodeRawTx(f.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Hash() != common.HexToHash(f.TxHash) {
+		return nil, fmt.Errorf("signed tx hash %s does not match its raw transaction (%s)", f.TxHash, tx.Hash().Hex())
+	}
+	if from, err := txbuilder.TxSender(tx); err != nil || from != common.HexToAddress(f.From) {
+		return nil, fmt.Errorf("signed tx is not signed by %s", f.From)
+	}
+	return tx, nil
+}
+
+func runPrepare(args []string) error {
+	fs := flag.NewFlagSet("prepare", flag.ExitOnError)
+	fromAddr := fs.String("from", "", "Address of the key that will sign the transaction")
+	txOpts := addTxFlags(fs)
+	out := fs.String("out", "", "File to write the unsigned transaction to (stdout when omitted)")
+	qr := addQRFlags(fs, "unsigned transaction")
+	rpcOpts := addRPCFlags(fs)
+	parseFlags(fs, args)
+
+	if !common.IsHexAddress(*fromAddr) {
+		return errors.New("from is required")
+	}
+	from := common.HexToAddress(*fromAddr)
+	ctx := context.Background()
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	if err := txOpts.resolveTo(ctx, client, nil); err != nil {
+		return err
+	}
+	// The signing machine enforces the policy, fee caps included.
+	tx, chainID, err := txOpts.build(ctx, client, from, txbuilder.FeeSettings{}, func(*big.Int) (uint64, error) {
+		if client == nil {
+			return 0, errors.New("nonce is required without rpc")
+		}
+		return txbuilder.FetchNonce(ctx, client, from)
+	})
+	if err != nil {
+		return err
+	}
+	f, err := newUnsignedTxFile(tx, from, chainID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := qr.render(payload); err != nil {
+		return err
+	}
+	if *out == "" {
+		writeJSON(f)
+		return nil
+	}
+	if err := jsonfile.Write(*out, f); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "SigningHash:", f.SigningHash)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// outputFormat is the -output flag shared by all commands: text prints the
+// classic "Key: value" lines, json prints a single JSON object on stdout.
+type outputFormat string
+
+const (
+	outputText outputFormat = "text"
+	outputJSON outputFormat = "json"
+)
+
+func addOutputFlag(fs *flag.FlagSet) *outputFormat {
+	o := outputText
+	fs.Var(&o, "output", "Output format: text or json")
+	return &o
+}
+
+func (o *outputFormat) String() string { return string(*o) }
+
+func (o *outputFormat) Set(value string) error {
+	switch outputFormat(value) {
+	case outputText, outputJSON:
+		*o = outputFormat(value)
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want text or json)", value)
+}
+
+// print writes v as JSON in json mode and otherwise calls text.
+func (o outputFormat) print(v any, text func()) {
+	if o == outputJSON {
+		writeJSON(v)
+		return
+	}
+	text()
+}
+
+// writeJSON writes v as indented JSON to stdout.
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// progress returns where informational output such as simulation previews
+// goes: stdout in text mode, stderr in json mode so stdout stays parseable.
+func (o outputFormat) progress() io.Writer {
+	if o == outputJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// txOutput is the JSON form of a signed transaction.
+type txOutput struct {
+	RawTx          string                `json:"rawTx"`
+	TxHash         string                `json:"txHash"`
+	Type           uint8                 `json:"type"`
+	ChainID        string                `json:"chainId"`
+	From           string                `json:"from"`
+	To             string                `json:"to,omitempty"`
+	Nonce          uint64                `json:"nonce"`
+	Value          string                `json:"value"`
+	Data           string                `json:"data"`
+	Fees           feesOutput            `json:"fees"`
+	AccessList     types.AccessList      `json:"accessList,omitempty"`
+	BlobFeeCap     string                `json:"maxFeePerBlobGas,omitempty"`
+	BlobHashes     []common.Hash         `json:"blobVersionedHashes,omitempty"`
+	Blobs          int                   `json:"blobs,omitempty"`
+	Call           *txbuilder.CallOutput `json:"call,omitempty"`
+	Signature      *txSigOutput          `json:"signature,omitempty"`
+	PolicyDecision *policy.Decision      `json:"policyDecision,omitempty"`
+	Receipt        *receiptOutput        `json:"receipt,omitempty"`
+	ExplorerURL    string                `json:"explorerUrl,omitempty"`
+}
+
+type txSigOutput struct {
+	V string `json:"v"`
+	R string `json:"r"`
+	S string `json:"s"`
+}
+
+type feesOutput struct {
+	Gas                  uint64 `json:"gas"`
+	GasPrice             string `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+}
+
+func newTxOutput(tx *types.Transaction, from common.Address) (*txOutput, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize tx: %v", err)
+	}
+	out := &txOutput{
+		RawTx:   hexutil.Encode(raw),
+		TxHash:  tx.Hash().Hex(),
+		Type:    tx.Type(),
+		ChainID: tx.ChainId().String(),
+		From:    from.Hex(),
+		Nonce:   tx.Nonce(),
+		Value:   tx.Value().String(),
+		Data:    hexutil.Encode(tx.Data()),
+		Fees:    feesOutput{Gas: tx.Gas()},
+	}
+	if tx.ChainId().Sign() > 0 {
+		out.ExplorerURL = chains.ExplorerTxURL(tx.ChainId(), out.TxHash)
+	}
+	if tx.To() != nil {
+		out.To = tx.To().Hex()
+	}
+	out.AccessList = tx.AccessList()
+	if tx.Type() == types.BlobTxType {
+		out.BlobFeeCap = tx.BlobGasFeeCap().String()
+		out.BlobHashes = tx.BlobHashes()
+		if sidecar := tx.BlobTxSidecar(); sidecar != nil {
+			out.Blobs = len(sidecar.Blobs)
+		}
+	}
+	out.Call = txbuilder.DecodeCall(tx.Data())
+	if v, r, s := tx.RawSignatureValues(); r.Sign() != 0 {
+		out.Signature = &txSigOutput{V: v.String(), R: hexutil.EncodeBig(r), S: hexutil.EncodeBig(s)}
+	}
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		out.Fees.GasPrice = tx.GasPrice().String()
+	} else {
+		out.Fees.MaxFeePerGas = tx.GasFeeCap().String()
+		out.Fees.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	}
+	return out, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func runSignPermit(args []string) error {
+	fs := flag.NewFlagSet("sign-permit", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	token := fs.String("token", "", "EIP-2612 token address")
+	spender := fs.String("spender", "", "Address allowed to spend the tokens")
+	value := fs.String("value", "", "Allowance in token base units")
+	deadline := fs.String("deadline", "1h", "Permit expiry as a Unix timestamp or a duration from now")
+	name := fs.String("name", "", "Token EIP-712 domain name (fetched via -rpc when omitted)")
+	version := fs.String("version", "", "Token EIP-712 domain version (fetched via -rpc when omitted, default 1)")
+	nonce := fs.String("nonce", "", "Owner's permit nonce (fetched via -rpc when omitted)")
+	chainID := addChainFlag(fs, 1, "Chain ID (fetched via -rpc when omitted)")
+	rpcOpts := addRPCFlags(fs)
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if !common.IsHexAddress(*token) {
+		return fmt.Errorf("invalid token address %q", *token)
+	}
+	if !common.IsHexAddress(*spender) {
+		return fmt.Errorf("invalid spender address %q", *spender)
+	}
+	now := time.Now()
+	p := &txbuilder.Permit{Token: common.HexToAddress(*token), Spender: common.HexToAddress(*spender), Name: *name, Version: *version, ChainID: chainID.big()}
+	var err error
+	if p.Value, err = txbuilder.ParseWei("value", *value); err != nil {
+		return err
+	}
+	if p.Deadline, err = txbuilder.ParseDeadline(*deadline, now); err != nil {
+		return err
+	}
+	if *nonce != "" {
+		if p.Nonce, err = txbuilder.ParseWei("nonce", *nonce); err != nil {
+			return err
+		}
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy = policy.ForSender(key.Address())
+	p.Owner = key.Address()
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		var want *big.Int
+		if flagWasSet(fs, "chain") {
+			want = p.ChainID
+		}
+		if p.ChainID, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return err
+		}
+		if err := p.Fetch(ctx, client, *name != "", *version != "", *nonce != ""); err != nil {
+			return err
+		}
+	} else if *name == "" || *version == "" || *nonce == "" {
+		return errors.New("name, version and nonce are required offline")
+	}
+
+	typed := p.TypedData()
+	if err := policy.CheckChainID(p.ChainID); err != nil {
+		return audit.Denied(signer.AuditTypedData, typed, nil, fmt.Errorf("policy check failed: %v", err))
+	}
+	if err := policy.CheckPermit(p, now); err != nil {
+		return audit.Denied(signer.AuditTypedData, typed, nil, fmt.Errorf("policy check failed: %v", err))
+	}
+	if err := policy.CheckSigningWindow(now, *overrideReason); err != nil {
+		return audit.Denied(signer.AuditTypedData, typed, nil, fmt.Errorf("policy check failed: %v", err))
+	}
+	hash, sig, err := signTypedData(ctx, key, typed)
+	if err != nil {
+		err = fmt.Errorf("failed to sign permit: %v", err)
+	}
+	if err := audit.Signed(signer.AuditTypedData, key.Address(), typed, hash, err); err != nil {
+		return err
+	}
+	out := struct {
+		Owner    string `json:"owner"`
+		Spender  string `json:"spender"`
+		Value    string `json:"value"`
+		Nonce    string `json:"nonce"`
+		Deadline string `json:"deadline"`
+		Hash     string `json:"hash"`
+		signatureOutput
+	}{p.Owner.Hex(), p.Spender.Hex(), p.Value.String(), p.Nonce.String(), p.Deadline.String(), hexutil.Encode(hash), newSignatureOutput(sig)}
+	output.print(out, func() {
+		fmt.Println("Owner:", out.Owner)
+		fmt.Println("Spender:", out.Spender)
+		fmt.Println("Value:", out.Value)
+		fmt.Println("Deadline:", out.Deadline)
+		fmt.Println("Hash:", out.Hash)
+		out.printText()
+	})
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+)
+
+// oidSecp256k1 is the named curve OID carried in CKA_EC_PARAMS.
+var oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+// pkcs11Backend signs with a secp256k1 key pair stored on a PKCS#11 token
+// (Thales Luna, SoftHSM, ...). The private and public key objects are found
+// by CKA_LABEL. A PKCS#11 session is not safe for concurrent use, so signing
+// is serialized.
+type pkcs11Backend struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	priv    pkcs11.ObjectHandle
+	pub     *ecdsa.PublicKey
+	address common.Address
+}
+
+func newPKCS11Backend(src keySource) (*pkcs11Backend, error) {
+	if src.pkcs11KeyLabel == "" {
+		return nil, errors.New("key-label is required with pkcs11-module")
+	}
+	p := pkcs11.New(src.pkcs11Module)
+	if p == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", src.pkcs11Module)
+	}
+	if err := p.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %v", err)
+	}
+	b := &pkcs11Backend{ctx: p}
+	if err := b.open(src); err != nil {
+		p.Finalize()
+		p.Destroy()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *pkcs11Backend) open(src keySource) error {
+	session, err := b.ctx.OpenSession(src.pkcs11Slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to open PKCS#11 session on slot %d: %v", src.pkcs11Slot, err)
+	}
+	b.session = session
+	pin, err := readPassphrase(src.pkcs11PINFile, "PKCS#11 PIN: ")
+	if err != nil {
+		return err
+	}
+	if err := b.ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+		return fmt.Errorf("PKCS#11 login failed: %v", err)
+	}
+	if b.priv, err = b.findObject(pkcs11.CKO_PRIVATE_KEY, src.pkcs11KeyLabel); err != nil {
+		return err
+	}
+	pubHandle, err := b.findObject(pkcs11.CKO_PUBLIC_KEY, src.pkcs11KeyLabel)
+	if err != nil {
+		return err
+	}
+	attrs, err := b.ctx.GetAttributeValue(session, pubHandle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read PKCS#11 public key: %v", err)
+	}
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(attrs[0].Value, &curve); err != nil || !curve.Equal(oidSecp256k1) {
+		return fmt.Errorf("PKCS#11 key %q is not a secp256k1 key", src.pkcs11KeyLabel)
+	}
+	// CKA_EC_POINT is a DER OCTET STRING holding the uncompressed point,
+	// though some tokens return the raw point.
+	point := attrs[1].Value
+	var wrapped []byte
+	if _, err := asn1.Unmarshal(point, &wrapped); err == nil {
+		point = wrapped
+	}
+	if b.pub, err = crypto.UnmarshalPubkey(point); err != nil {
+		return fmt.Errorf("invalid PKCS#11 public key: %v", err)
+	}
+	b.address = crypto.PubkeyToAddress(*b.pub)
+	return nil
+}
+
+func (b *pkcs11Backend) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := b.ctx.FindObjectsInit(b.session, template); err != nil {
+		return 0, err
+	}
+	defer b.ctx.FindObjectsFinal(b.session)
+	handles, _, err := b.ctx.FindObjects(b.session, 2)
+	if err != nil {
+		return 0, err
+	}
+	switch len(handles) {
+	case 0:
+		return 0, fmt.Errorf("no PKCS#11 object labelled %q", label)
+	case 1:
+		return handles[0], nil
+	default:
+		return 0, fmt.Errorf("multiple PKCS#11 objects labelled %q", label)
+	}
+}
+
+func (b *pkcs11Backend) Address() common.Address     { return b.address }
+func (b *pkcs11Backend) PublicKey() *ecdsa.PublicKey { return b.pub }
+
+// CheckHealth checks that the token session is still open.
+func (b *pkcs11Backend) CheckHealth(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.ctx.GetSessionInfo(b.session); err != nil {
+		return fmt.Errorf("PKCS#11 session failed: %v", err)
+	}
+	return nil
+}
+
+func (b *pkcs11Backend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := b.ctx.SignInit(b.session, mech, b.priv); err != nil {
+		return nil, fmt.Errorf("PKCS#11 sign failed: %v", err)
+	}
+	raw, err := b.ctx.Sign(b.session, hash)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 sign failed: %v", err)
+	}
+	if len(raw) != 64 {
+		return nil, fmt.Errorf("unexpected PKCS#11 signature length %d", len(raw))
+	}
+	r := new(big.Int).SetBytes(raw[:32])
+	s := new(big.Int).SetBytes(raw[32:])
+	return keys.RecoverableSignature(hash, r, s, b.pub)
+}
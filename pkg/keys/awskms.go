@@ -0,0 +1,84 @@
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AWSKMSBackend signs with an ECC_SECG_P256K1 key held in AWS KMS. The key
+// is referenced as awskms://<key-id|key-arn|alias/name>; credentials and the
+// default region come from the standard AWS configuration chain.
+type AWSKMSBackend struct {
+	client  *kms.Client
+	keyID   string
+	pub     *ecdsa.PublicKey
+	address common.Address
+}
+
+func NewAWSKMSBackend(ctx context.Context, keyID string) (*AWSKMSBackend, error) {
+	var opts []func(*config.LoadOptions) error
+	// arn:aws:kms:<region>:<account>:key/<id>
+	if parts := strings.Split(keyID, ":"); len(parts) >= 6 && parts[0] == "arn" {
+		opts = append(opts, config.WithRegion(parts[3]))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	client := kms.NewFromConfig(cfg)
+
+	out, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: &keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch KMS public key: %v", err)
+	}
+	if out.KeySpec != kmstypes.KeySpecEccSecgP256k1 {
+		return nil, fmt.Errorf("KMS key %s has spec %s, want %s", keyID, out.KeySpec, kmstypes.KeySpecEccSecgP256k1)
+	}
+	pub, err := parseSPKIPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &AWSKMSBackend{
+		client:  client,
+		keyID:   keyID,
+		pub:     pub,
+		address: crypto.PubkeyToAddress(*pub),
+	}, nil
+}
+
+func (b *AWSKMSBackend) Address() common.Address     { return b.address }
+func (b *AWSKMSBackend) PublicKey() *ecdsa.PublicKey { return b.pub }
+
+// CheckHealth fetches the public key again to check that KMS is reachable
+// and the key usable.
+func (b *AWSKMSBackend) CheckHealth(ctx context.Context) error {
+	if _, err := b.client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: &b.keyID}); err != nil {
+		return fmt.Errorf("failed to fetch KMS public key: %v", err)
+	}
+	return nil
+}
+
+func (b *AWSKMSBackend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	out, err := b.client.Sign(ctx, &kms.SignInput{
+		KeyId:            &b.keyID,
+		Message:          hash,
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: kmstypes.SigningAlgorithmSpecEcdsaSha256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS sign failed: %v", err)
+	}
+	r, s, err := parseDERSignature(out.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return RecoverableSignature(hash, r, s, b.pub)
+}
@@ -0,0 +1,100 @@
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AzureKVBackend signs with a P-256K key held in Azure Key Vault or Managed
+// HSM, referenced as azurekv://<vault-host>/<key-name>[/<version>], e.g.
+// azurekv://treasury.managedhsm.azure.net/eth-hot. Credentials come from
+// azidentity's default credential chain.
+type AzureKVBackend struct {
+	client  *azkeys.Client
+	name    string
+	version string
+	pub     *ecdsa.PublicKey
+	address common.Address
+}
+
+func NewAzureKVBackend(ctx context.Context, ref string) (*AzureKVBackend, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid Azure key reference %q (want azurekv://<vault-host>/<key-name>[/<version>])", ref)
+	}
+	var version string
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Azure credentials: %v", err)
+	}
+	client, err := azkeys.NewClient("https://"+parts[0], cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Key Vault client: %v", err)
+	}
+
+	resp, err := client.GetKey(ctx, parts[1], version, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Azure key: %v", err)
+	}
+	jwk := resp.Key
+	if jwk == nil || jwk.Crv == nil || *jwk.Crv != azkeys.CurveNameP256K {
+		return nil, fmt.Errorf("Azure key %s is not a P-256K key", parts[1])
+	}
+	pub := &ecdsa.PublicKey{
+		Curve: crypto.S256(),
+		X:     new(big.Int).SetBytes(jwk.X),
+		Y:     new(big.Int).SetBytes(jwk.Y),
+	}
+	// Pin the version we fetched so a key rotation cannot silently change
+	// the signing address.
+	if jwk.KID != nil {
+		version = jwk.KID.Version()
+	}
+	return &AzureKVBackend{
+		client:  client,
+		name:    parts[1],
+		version: version,
+		pub:     pub,
+		address: crypto.PubkeyToAddress(*pub),
+	}, nil
+}
+
+func (b *AzureKVBackend) Address() common.Address     { return b.address }
+func (b *AzureKVBackend) PublicKey() *ecdsa.PublicKey { return b.pub }
+
+// CheckHealth fetches the key again to check that Key Vault is reachable
+// and the key usable.
+func (b *AzureKVBackend) CheckHealth(ctx context.Context) error {
+	if _, err := b.client.GetKey(ctx, b.name, b.version, nil); err != nil {
+		return fmt.Errorf("failed to fetch Key Vault key: %v", err)
+	}
+	return nil
+}
+
+func (b *AzureKVBackend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	resp, err := b.client.Sign(ctx, b.name, b.version, azkeys.SignParameters{
+		Algorithm: to.Ptr(azkeys.SignatureAlgorithmES256K),
+		Value:     hash,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Azure sign failed: %v", err)
+	}
+	if len(resp.Result) != 64 {
+		return nil, fmt.Errorf("unexpected Azure signature length %d", len(resp.Result))
+	}
+	r := new(big.Int).SetBytes(resp.Result[:32])
+	s := new(big.Int).SetBytes(resp.Result[32:])
+	return RecoverableSignature(hash, r, s, b.pub)
+}
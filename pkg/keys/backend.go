@@ -0,0 +1,97 @@
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Backend signs with a secp256k1 key that may live outside this process.
+type Backend interface {
+	Address() common.Address
+	PublicKey() *ecdsa.PublicKey
+	// SignHash signs a 32-byte digest and returns a 65-byte [R || S || V]
+	// signature with V in {0, 1}.
+	SignHash(ctx context.Context, hash []byte) ([]byte, error)
+}
+
+// TxSigningBackend is implemented by backends that must see the whole
+// transaction rather than its signing hash, such as hardware wallets that
+// display it for confirmation.
+type TxSigningBackend interface {
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// LocalKey is a Backend holding the private key in this process's
+// locked memory.
+type LocalKey struct {
+	Key *SecretKey
+}
+
+func NewLocalKey(key *SecretKey) *LocalKey {
+	return &LocalKey{Key: key}
+}
+
+func (k *LocalKey) Address() common.Address     { return k.Key.Address() }
+func (k *LocalKey) PublicKey() *ecdsa.PublicKey { return k.Key.PublicKey() }
+
+func (k *LocalKey) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return k.Key.Sign(hash)
+}
+
+// parseSPKIPublicKey decodes a DER SubjectPublicKeyInfo holding a secp256k1
+// key, as returned by cloud KMS services. crypto/x509 does not know the curve.
+func parseSPKIPublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	return crypto.UnmarshalPubkey(spki.PublicKey.Bytes)
+}
+
+// parseDERSignature decodes an ASN.1 ECDSA-Sig-Value.
+func parseDERSignature(der []byte) (r, s *big.Int, err error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	return sig.R, sig.S, nil
+}
+
+// RecoverableSignature converts a plain ECDSA signature into Ethereum's
+// [R || S || V] form. Remote signers do not normalize s or report the
+// recovery id, so s is moved to the lower half of the curve order and V is
+// found by trial recovery against the known public key. r and s come from
+// the remote signer's response and are checked to be in [1, N-1] first.
+func RecoverableSignature(hash []byte, r, s *big.Int, pub *ecdsa.PublicKey) ([]byte, error) {
+	n := crypto.S256().Params().N
+	if r == nil || s == nil || r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, errors.New("signature values are out of range")
+	}
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	want := crypto.FromECDSAPub(pub)
+	for v := byte(0); v < 2; v++ {
+		sig[crypto.RecoveryIDOffset] = v
+		got, err := crypto.Ecrecover(hash, sig)
+		if err == nil && string(got) == string(want) {
+			return sig, nil
+		}
+	}
+	return nil, errors.New("signature does not recover to the backend public key")
+}
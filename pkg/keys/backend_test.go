@@ -0,0 +1,48 @@
+package keys
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRecoverableSignature(t *testing.T) {
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.Keccak256([]byte("message"))
+	sig, err := crypto.Sign(hash, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	n := crypto.S256().Params().N
+
+	// A remote signer may return the high-s form of the same signature.
+	got, err := RecoverableSignature(hash, r, new(big.Int).Sub(n, s), &priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(sig) {
+		t.Fatalf("RecoverableSignature = %x, want %x", got, sig)
+	}
+
+	tooLong := new(big.Int).Lsh(big.NewInt(1), 300)
+	for _, tt := range []struct {
+		name string
+		r, s *big.Int
+	}{
+		{"zero r", new(big.Int), s},
+		{"zero s", r, new(big.Int)},
+		{"negative s", r, new(big.Int).Neg(s)},
+		{"r equal to N", n, s},
+		{"r longer than 32 bytes", tooLong, s},
+		{"s longer than 32 bytes", r, tooLong},
+	} {
+		if _, err := RecoverableSignature(hash, tt.r, tt.s, &priv.PublicKey); err == nil {
+			t.Errorf("%s: RecoverableSignature accepted an invalid signature", tt.name)
+		}
+	}
+}
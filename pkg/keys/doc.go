@@ -0,0 +1,5 @@
+// Package keys holds the signing keys of the signer: a Backend signs
+// hashes with a key it may never reveal, whether the key is held in memory
+// as a SecretKey, derived from a mnemonic or kept in AWS KMS, Google Cloud
+// KMS or Azure Key Vault.
+package keys
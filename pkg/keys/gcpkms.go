@@ -0,0 +1,93 @@
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// GCPKMSBackend signs with an EC_SIGN_SECP256K1_SHA256 key version held in
+// Google Cloud KMS, referenced as
+// gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>/cryptoKeyVersions/<v>.
+// Credentials come from Application Default Credentials.
+type GCPKMSBackend struct {
+	client  *kms.KeyManagementClient
+	name    string
+	pub     *ecdsa.PublicKey
+	address common.Address
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+func NewGCPKMSBackend(ctx context.Context, name string) (*GCPKMSBackend, error) {
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP KMS client: %v", err)
+	}
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: name})
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to fetch KMS public key: %v", err)
+	}
+	if resp.Algorithm != kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256 {
+		client.Close()
+		return nil, fmt.Errorf("KMS key %s has algorithm %s, want EC_SIGN_SECP256K1_SHA256", name, resp.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		client.Close()
+		return nil, errors.New("invalid KMS public key PEM")
+	}
+	pub, err := parseSPKIPublicKey(block.Bytes)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &GCPKMSBackend{
+		client:  client,
+		name:    name,
+		pub:     pub,
+		address: crypto.PubkeyToAddress(*pub),
+	}, nil
+}
+
+func (b *GCPKMSBackend) Address() common.Address     { return b.address }
+func (b *GCPKMSBackend) PublicKey() *ecdsa.PublicKey { return b.pub }
+
+// CheckHealth fetches the public key again to check that KMS is reachable
+// and the key usable.
+func (b *GCPKMSBackend) CheckHealth(ctx context.Context) error {
+	if _, err := b.client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: b.name}); err != nil {
+		return fmt.Errorf("failed to fetch KMS public key: %v", err)
+	}
+	return nil
+}
+
+func (b *GCPKMSBackend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	resp, err := b.client.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{
+		Name:         b.name,
+		Digest:       &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: hash}},
+		DigestCrc32C: wrapperspb.Int64(int64(crc32.Checksum(hash, crc32c))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS sign failed: %v", err)
+	}
+	if !resp.VerifiedDigestCrc32C || resp.SignatureCrc32C == nil ||
+		resp.SignatureCrc32C.Value != int64(crc32.Checksum(resp.Signature, crc32c)) {
+		return nil, errors.New("KMS sign response failed integrity check")
+	}
+	r, s, err := parseDERSignature(resp.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return RecoverableSignature(hash, r, s, b.pub)
+}
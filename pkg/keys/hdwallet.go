@@ -0,0 +1,111 @@
+package keys
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultDerivationPath is the first account on the standard BIP-44 Ethereum
+// path.
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// HDKey is an extended private key as defined by BIP-32.
+type HDKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// MnemonicToMaster validates a BIP-39 mnemonic and returns the BIP-32 master
+// key for its seed.
+func MnemonicToMaster(mnemonic string) (*HDKey, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("invalid mnemonic")
+	}
+	seed := bip39.NewSeed(mnemonic, "")
+	defer clear(seed)
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return &HDKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Wipe zeroes the private key.
+func (k *HDKey) Wipe() {
+	clear(k.key)
+}
+
+// child derives the BIP-32 child key at index.
+func (k *HDKey) child(index uint32) (*HDKey, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, k.key...)
+		defer clear(data)
+	} else {
+		priv, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = crypto.CompressPubkey(&priv.PublicKey)
+		wipeECDSA(priv)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	clear(sum[:32])
+	parent := new(big.Int).SetBytes(k.key)
+	defer clear(parent.Bits())
+	defer clear(il.Bits())
+	if il.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	childKey := il.Add(il, parent)
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	return &HDKey{key: childKey.FillBytes(make([]byte, 32)), chainCode: sum[32:]}, nil
+}
+
+// Derive walks path from the master key, wiping the intermediate keys on
+// the way. k itself is left intact.
+func (k *HDKey) Derive(path accounts.DerivationPath) (*SecretKey, error) {
+	key := &HDKey{key: append([]byte(nil), k.key...), chainCode: k.chainCode}
+	for _, index := range path {
+		child, err := key.child(index)
+		key.Wipe()
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return newSecretKey(key.key)
+}
+
+// DeriveKey derives the private key at path from mnemonic.
+func DeriveKey(mnemonic, path string) (*SecretKey, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	master, err := MnemonicToMaster(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	defer master.Wipe()
+	return master.Derive(derivationPath)
+}
@@ -0,0 +1,33 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignPersonalMessage signs data under the EIP-191 "\x19Ethereum Signed
+// Message:\n" prefix. The returned signature has V in {0, 1}.
+func SignPersonalMessage(ctx context.Context, key Backend, data []byte) ([]byte, error) {
+	return key.SignHash(ctx, accounts.TextHash(data))
+}
+
+// RecoverPersonalMessage returns the address that produced sig over data.
+// V may be given as 0/1 or 27/28.
+func RecoverPersonalMessage(data, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature must be %d bytes", crypto.SignatureLength)
+	}
+	sig = append([]byte{}, sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(data), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,131 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrKeyDestroyed is returned when signing with a key after Destroy.
+var ErrKeyDestroyed = errors.New("private key has been destroyed")
+
+// SecretKey is a secp256k1 private key kept out of the Go heap. Its 32
+// bytes live in a buffer allocated outside the garbage collector and, where
+// the platform allows, locked against swapping. The scalar is only expanded
+// into an *ecdsa.PrivateKey for the duration of a single use, after which
+// that copy is wiped as well. Destroy wipes the key; a key that is dropped
+// without Destroy is wiped when it is collected.
+type SecretKey struct {
+	mu      sync.Mutex
+	buf     []byte
+	free    func()
+	pub     ecdsa.PublicKey
+	address common.Address
+}
+
+// newSecretKey moves the 32-byte scalar b into locked memory. b is zeroed,
+// whether or not it is a valid key.
+func newSecretKey(b []byte) (*SecretKey, error) {
+	defer clear(b)
+	priv, err := crypto.ToECDSA(b)
+	if err != nil {
+		return nil, err
+	}
+	k := &SecretKey{pub: priv.PublicKey, address: crypto.PubkeyToAddress(priv.PublicKey)}
+	wipeECDSA(priv)
+	if k.buf, k.free, err = allocSecret(len(b)); err != nil {
+		return nil, err
+	}
+	copy(k.buf, b)
+	runtime.SetFinalizer(k, (*SecretKey).Destroy)
+	return k, nil
+}
+
+// SecretKeyFromECDSA moves priv into a SecretKey and wipes priv, which
+// must not be used afterwards.
+func SecretKeyFromECDSA(priv *ecdsa.PrivateKey) (*SecretKey, error) {
+	b := crypto.FromECDSA(priv)
+	wipeECDSA(priv)
+	return newSecretKey(b)
+}
+
+// ParseHexKey decodes a hex private key, optionally 0x-prefixed and
+// surrounded by whitespace, without copying it into a string. The caller
+// still owns and must wipe s.
+func ParseHexKey(s []byte) (*SecretKey, error) {
+	s = bytes.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	if len(s) != 64 {
+		return nil, errors.New("invalid private key: want 64 hex digits")
+	}
+	b := make([]byte, 32)
+	if _, err := hex.Decode(b, s); err != nil {
+		clear(b)
+		return nil, errors.New("invalid private key: not hex")
+	}
+	return newSecretKey(b)
+}
+
+func (k *SecretKey) Address() common.Address     { return k.address }
+func (k *SecretKey) PublicKey() *ecdsa.PublicKey { return &k.pub }
+
+// Sign signs a 32-byte digest, returning a 65-byte [R || S || V] signature.
+func (k *SecretKey) Sign(hash []byte) ([]byte, error) {
+	var sig []byte
+	err := k.Use(func(priv *ecdsa.PrivateKey) error {
+		var err error
+		sig, err = crypto.Sign(hash, priv)
+		return err
+	})
+	return sig, err
+}
+
+// Use calls fn with a transient *ecdsa.PrivateKey for the key, which is
+// wiped when fn returns. fn must not retain it.
+func (k *SecretKey) Use(fn func(*ecdsa.PrivateKey) error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.buf == nil {
+		return ErrKeyDestroyed
+	}
+	priv, err := crypto.ToECDSA(k.buf)
+	if err != nil {
+		return err
+	}
+	defer wipeECDSA(priv)
+	return fn(priv)
+}
+
+// Destroy wipes and releases the key. It is safe to call more than once;
+// signing afterwards fails.
+func (k *SecretKey) Destroy() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.buf == nil {
+		return
+	}
+	clear(k.buf)
+	k.free()
+	k.buf, k.free = nil, nil
+	runtime.SetFinalizer(k, nil)
+}
+
+// String and GoString keep the key material out of formatted output.
+func (k *SecretKey) String() string   { return "SecretKey(" + k.address.Hex() + ")" }
+func (k *SecretKey) GoString() string { return k.String() }
+
+// wipeECDSA zeroes the scalar of priv.
+func wipeECDSA(priv *ecdsa.PrivateKey) {
+	if priv != nil && priv.D != nil {
+		clear(priv.D.Bits())
+		priv.D.SetInt64(0)
+	}
+}
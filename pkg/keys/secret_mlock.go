@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package keys
+
+import (
+	"log/slog"
+	"syscall"
+)
+
+// allocSecret maps n bytes of anonymous memory outside the Go heap and
+// locks them so they are never written to swap. A key is still usable when
+// the lock is refused, for example over RLIMIT_MEMLOCK, but that is logged.
+func allocSecret(n int) ([]byte, func(), error) {
+	buf, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, nil, err
+	}
+	locked := syscall.Mlock(buf) == nil
+	if !locked {
+		slog.Warn("failed to lock key memory; it may be swapped out")
+	}
+	return buf, func() {
+		if locked {
+			syscall.Munlock(buf)
+		}
+		syscall.Munmap(buf)
+	}, nil
+}
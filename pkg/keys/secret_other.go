@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package keys
+
+// allocSecret returns an ordinary buffer on platforms without mlock; the
+// key is still wiped after use but may be swapped out.
+func allocSecret(n int) ([]byte, func(), error) {
+	return make([]byte, n), func() {}, nil
+}
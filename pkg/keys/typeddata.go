@@ -0,0 +1,12 @@
+package keys
+
+import (
+	"context"
+)
+
+// TypedDataSigningBackend is implemented by backends that sign EIP-712 data
+// from its domain separator and message hash, so hardware wallets can show
+// what is being signed.
+type TypedDataSigningBackend interface {
+	SignTypedData(ctx context.Context, domainSeparator, messageHash []byte) ([]byte, error)
+}
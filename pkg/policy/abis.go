@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// EnvABIDir names the environment variable that overrides the default ABI
+// registry directory.
+const EnvABIDir = "SIGNER_ABI_DIR"
+
+// DefaultABIDir is the ABI registry used when neither the policy abi_dir nor
+// SIGNER_ABI_DIR is set.
+const DefaultABIDir = "abis"
+
+// abiDir returns dir, or the directory from SIGNER_ABI_DIR or the default.
+func abiDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if env := os.Getenv(EnvABIDir); env != "" {
+		return env
+	}
+	return DefaultABIDir
+}
+
+// LoadABI reads the ABI registered for contract: <dir>/<address>.json,
+// holding either a plain ABI or a build artifact with an "abi" field. The
+// file name may use any case.
+func LoadABI(dir string, contract common.Address) (*abi.ABI, error) {
+	dir = abiDir(dir)
+	var data []byte
+	var err error
+	for _, name := range []string{contract.Hex(), strings.ToLower(contract.Hex())} {
+		if data, err = os.ReadFile(filepath.Join(dir, name+".json")); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return nil, fmt.Errorf("invalid ABI file for %s: %v", contract.Hex(), err)
+		}
+		data = artifact.ABI
+	}
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI for %s: %v", contract.Hex(), err)
+	}
+	return &parsed, nil
+}
+
+// DecodedCall is calldata decoded against a registered ABI.
+type DecodedCall struct {
+	Method    string         `json:"method"`
+	Signature string         `json:"signature"`
+	Args      []decodedValue `json:"args"`
+}
+
+// decodedValue is a decoded argument, formatted for display and matching.
+type decodedValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Raw   any    `json:"-"`
+}
+
+// DecodeCalldata decodes data as a call to contract, using its ABI from dir.
+func DecodeCalldata(dir string, contract common.Address, data []byte) (*DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, errors.New("calldata has no selector")
+	}
+	parsed, err := LoadABI(dir, contract)
+	if err != nil {
+		return nil, err
+	}
+	method, err := parsed.MethodById(data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("selector %s not in the ABI of %s", hexutil.Encode(data[:4]), contract.Hex())
+	}
+	return UnpackCall(method, data)
+}
+
+// UnpackCall decodes the arguments of data as a call to method.
+func UnpackCall(method *abi.Method, data []byte) (*DecodedCall, error) {
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s arguments: %v", method.Name, err)
+	}
+	call := &DecodedCall{Method: method.Name, Signature: method.Sig}
+	for i, input := range method.Inputs {
+		call.Args = append(call.Args, decodedValue{Name: input.Name, Type: input.Type.String(), Value: formatABIValue(values[i]), Raw: values[i]})
+	}
+	return call, nil
+}
+
+// formatABIValue formats a decoded argument: integers in decimal, addresses
+// checksummed and byte values in hex.
+func formatABIValue(v any) string {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = formatABIValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
+
+// abiInteger returns a decoded integer argument as a big.Int.
+func abiInteger(v any) (*big.Int, bool) {
+	if n, ok := v.(*big.Int); ok {
+		return n, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	}
+	return nil, false
+}
+
+// ArgumentRule constrains an argument of calls to one function, decoded
+// with the contract's ABI from the registry.
+type ArgumentRule struct {
+	// Function is the function name or signature ("approve" or
+	// "approve(address,uint256)").
+	Function string `json:"function"`
+	// Argument is the argument name, or its zero-based position.
+	Argument string `json:"argument"`
+	// Max caps an integer argument. OneOf, when non-empty, lists the
+	// values allowed, and NotOneOf the values rejected, compared in the
+	// decoded form: decimal integers, checksummed addresses, hex bytes.
+	Max      *big.Int `json:"max"`
+	OneOf    []string `json:"one_of"`
+	NotOneOf []string `json:"not_one_of"`
+}
+
+// matches reports whether the rule applies to call.
+func (r ArgumentRule) matches(call *DecodedCall) bool {
+	return r.Function == call.Method || strings.ReplaceAll(r.Function, " ", "") == call.Signature
+}
+
+// argument returns the argument the rule constrains.
+func (r ArgumentRule) argument(call *DecodedCall) (decodedValue, error) {
+	for _, arg := range call.Args {
+		if arg.Name != "" && arg.Name == r.Argument {
+			return arg, nil
+		}
+	}
+	if i, err := strconv.Atoi(r.Argument); err == nil && i >= 0 && i < len(call.Args) {
+		return call.Args[i], nil
+	}
+	return decodedValue{}, fmt.Errorf("%s has no argument %q", call.Signature, r.Argument)
+}
+
+// check applies the rule to call.
+func (r ArgumentRule) check(call *DecodedCall) error {
+	arg, err := r.argument(call)
+	if err != nil {
+		return err
+	}
+	if r.Max != nil {
+		n, ok := abiInteger(arg.Raw)
+		if !ok {
+			return fmt.Errorf("%s argument %s is not an integer", call.Method, r.Argument)
+		}
+		if n.Cmp(r.Max) > 0 {
+			return fmt.Errorf("%s argument %s is %s, above the cap %s", call.Method, r.Argument, n, r.Max)
+		}
+	}
+	if len(r.OneOf) > 0 && !containsFold(r.OneOf, arg.Value) {
+		return fmt.Errorf("%s argument %s is %s, not one of the allowed values", call.Method, r.Argument, arg.Value)
+	}
+	if containsFold(r.NotOneOf, arg.Value) {
+		return fmt.Errorf("%s argument %s must not be %s", call.Method, r.Argument, arg.Value)
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, entry := range list {
+		if strings.EqualFold(entry, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArguments applies the argument rules of cp to a call of contract
+// with data. The contract's ABI must be registered; calls to functions
+// without rules are left to the selectors.
+func (p *Policy) checkArguments(contract common.Address, cp ContractPolicy, data []byte) error {
+	call, err := DecodeCalldata(p.ABIDir, contract, data)
+	if err != nil {
+		return err
+	}
+	for _, rule := range cp.Arguments {
+		if !rule.matches(call) {
+			continue
+		}
+		if err := rule.check(call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
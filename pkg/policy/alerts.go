@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultAckTimeout is how long a transaction waits for its alert to be
+// acknowledged when the policy does not say.
+const defaultAckTimeout = 10 * time.Minute
+
+// AlertPolicy sends a chat alert for high-value transactions, configured
+// with -alerts-config, and can hold the signature until the alert is
+// acknowledged.
+type AlertPolicy struct {
+	// MinValueWei alerts on transactions sending at least this much ether.
+	MinValueWei *big.Int `json:"min_value_wei"`
+	// Tokens alerts on ERC-20 transfers of at least the given amount, in
+	// base units, keyed by token address.
+	Tokens map[string]*big.Int `json:"tokens"`
+	// RequireAck withholds the signature until the alert is acknowledged,
+	// with the Telegram button or the ack command, and denies the
+	// transaction if that does not happen within AckTimeoutMinutes.
+	RequireAck        bool `json:"require_ack"`
+	AckTimeoutMinutes int  `json:"ack_timeout_minutes"`
+}
+
+// Triggers reports whether tx crosses one of the alert thresholds.
+func (p *AlertPolicy) Triggers(tx *types.Transaction) bool {
+	return crossesThreshold(tx, p.MinValueWei, p.Tokens)
+}
+
+// crossesThreshold reports whether tx sends at least minValue ether, or
+// transfers at least the amount given in tokens of an ERC-20 token.
+func crossesThreshold(tx *types.Transaction, minValue *big.Int, tokens map[string]*big.Int) bool {
+	if minValue != nil && tx.Value().Cmp(minValue) >= 0 {
+		return true
+	}
+	if tx.To() == nil {
+		return false
+	}
+	if _, amount, ok := txbuilder.DecodeERC20Transfer(tx.Data()); ok {
+		for token, min := range tokens {
+			if strings.EqualFold(token, tx.To().Hex()) && min != nil && amount.Cmp(min) >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *AlertPolicy) AckTimeout() time.Duration {
+	if p.AckTimeoutMinutes > 0 {
+		return time.Duration(p.AckTimeoutMinutes) * time.Minute
+	}
+	return defaultAckTimeout
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Selectors of the ERC-20 calls that grant an allowance.
+var (
+	selectorERC20Approve           = []byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+	selectorERC20IncreaseAllowance = []byte{0x39, 0x50, 0x93, 0x51} // increaseAllowance(address,uint256)
+)
+
+// unlimitedAllowance is the smallest allowance treated as unlimited. Wallets
+// and dapps request 2^256-1 or similar; no real token supply comes close
+// to 2^128.
+var unlimitedAllowance = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// Allowance actions.
+const (
+	AllowanceDeny = "deny"
+	AllowanceOff  = "off"
+)
+
+// AllowancePolicy controls the check on ERC-20 approve and
+// increaseAllowance calls. Unlimited allowances are rejected by default;
+// limits for a token, and for a spender of that token, take precedence
+// over the policy-wide ones.
+type AllowancePolicy struct {
+	// Action is "deny" (the default) or "off".
+	Action string `json:"action"`
+	// AllowUnlimited allows unlimited allowances. MaxAmount, when set,
+	// caps every allowance, in token base units.
+	AllowUnlimited bool     `json:"allow_unlimited"`
+	MaxAmount      *big.Int `json:"max_amount"`
+	// Tokens holds the limits for single tokens, keyed by token address.
+	Tokens map[string]TokenAllowancePolicy `json:"tokens"`
+}
+
+// TokenAllowancePolicy holds the allowance limits for a single token.
+type TokenAllowancePolicy struct {
+	AllowUnlimited *bool    `json:"allow_unlimited"`
+	MaxAmount      *big.Int `json:"max_amount"`
+	// Spenders holds the limits for single spenders of the token, keyed by
+	// spender address.
+	Spenders map[string]SpenderAllowancePolicy `json:"spenders"`
+}
+
+// SpenderAllowancePolicy holds the allowance limits for a single spender
+// of a token.
+type SpenderAllowancePolicy struct {
+	AllowUnlimited *bool    `json:"allow_unlimited"`
+	MaxAmount      *big.Int `json:"max_amount"`
+}
+
+// decodeERC20Allowance reports whether data is an approve or
+// increaseAllowance call and returns its arguments. Like the token
+// contracts, it ignores trailing bytes and takes the spender from the low
+// 20 bytes of its word. method is set, with ok false, for such a call whose
+// arguments do not decode.
+func decodeERC20Allowance(data []byte) (method string, spender common.Address, amount *big.Int, ok bool) {
+	if len(data) < 4 {
+		return "", common.Address{}, nil, false
+	}
+	switch {
+	case bytes.Equal(data[:4], selectorERC20Approve):
+		method = "approve"
+	case bytes.Equal(data[:4], selectorERC20IncreaseAllowance):
+		method = "increaseAllowance"
+	default:
+		return "", common.Address{}, nil, false
+	}
+	if len(data) < 4+2*32 {
+		return method, common.Address{}, nil, false
+	}
+	return method, common.BytesToAddress(data[16:36]), new(big.Int).SetBytes(data[36:68]), true
+}
+
+// allowanceLimits returns whether unlimited allowances of token to spender
+// are allowed and the cap on them, if any. A token or spender entry that
+// allows unlimited allowances also lifts the caps it would inherit.
+func (ap *AllowancePolicy) allowanceLimits(token, spender common.Address) (allowUnlimited bool, maxAmount *big.Int) {
+	if ap == nil {
+		return false, nil
+	}
+	allowUnlimited, maxAmount = ap.AllowUnlimited, ap.MaxAmount
+	for addr, tp := range ap.Tokens {
+		if !strings.EqualFold(addr, token.Hex()) {
+			continue
+		}
+		if tp.AllowUnlimited != nil {
+			allowUnlimited = *tp.AllowUnlimited
+		}
+		if tp.MaxAmount != nil || (tp.AllowUnlimited != nil && *tp.AllowUnlimited) {
+			maxAmount = tp.MaxAmount
+		}
+		for addr, sp := range tp.Spenders {
+			if !strings.EqualFold(addr, spender.Hex()) {
+				continue
+			}
+			if sp.AllowUnlimited != nil {
+				allowUnlimited = *sp.AllowUnlimited
+			}
+			if sp.MaxAmount != nil || (sp.AllowUnlimited != nil && *sp.AllowUnlimited) {
+				maxAmount = sp.MaxAmount
+			}
+		}
+	}
+	return allowUnlimited, maxAmount
+}
+
+// checkAllowance rejects approve and increaseAllowance calls that grant an
+// unlimited allowance, unless allowed, or an allowance above the cap. It
+// applies to every transaction, whether or not the token is listed in the
+// policy tokens.
+func (p *Policy) checkAllowance(tx *types.Transaction, d *Decision) error {
+	if tx.To() == nil || (p.Allowances != nil && p.Allowances.Action == AllowanceOff) {
+		return nil
+	}
+	method, spender, amount, ok := decodeERC20Allowance(tx.Data())
+	if method == "" {
+		return nil
+	}
+	if !ok {
+		return d.Fail("allowances", nil, nil, fmt.Sprintf("malformed %s calldata", method))
+	}
+	token := *tx.To()
+	allowUnlimited, maxAmount := p.Allowances.allowanceLimits(token, spender)
+	if amount.Cmp(unlimitedAllowance) >= 0 && !allowUnlimited {
+		return d.Fail("allowances", maxAmount, amount, fmt.Sprintf("%s of an unlimited allowance to %s is not allowed", method, spender.Hex()))
+	}
+	if maxAmount != nil && amount.Cmp(maxAmount) > 0 {
+		return d.Fail("allowances", maxAmount, amount, fmt.Sprintf("%s to %s exceeds the allowance cap", method, spender.Hex()))
+	}
+	d.Pass("allowances", maxAmount, amount)
+	return nil
+}
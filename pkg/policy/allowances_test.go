@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func approveCall(selector []byte, spender common.Address, amount *big.Int) []byte {
+	data := append([]byte{}, selector...)
+	data = append(data, common.LeftPadBytes(spender.Bytes(), 32)...)
+	return append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+}
+
+func TestDecodeERC20Allowance(t *testing.T) {
+	spender := testRecipient
+	amount := big.NewInt(500)
+	approve := approveCall(selectorERC20Approve, spender, amount)
+	dirty := append([]byte{}, approve...)
+	copy(dirty[4:16], bytes.Repeat([]byte{0xff}, 12))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		method string
+		ok     bool
+	}{
+		{"approve", approve, "approve", true},
+		{"increaseAllowance", approveCall(selectorERC20IncreaseAllowance, spender, amount), "increaseAllowance", true},
+		{"padded", append(append([]byte{}, approve...), make([]byte, 32)...), "approve", true},
+		{"dirty address", dirty, "approve", true},
+		{"short", approve[:67], "approve", false},
+		{"selector only", selectorERC20IncreaseAllowance, "increaseAllowance", false},
+		{"other selector", approveCall([]byte{0xa9, 0x05, 0x9c, 0xbb}, spender, amount), "", false},
+		{"empty", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, gotSpender, gotAmount, ok := decodeERC20Allowance(tt.data)
+			if method != tt.method || ok != tt.ok {
+				t.Fatalf("method, ok = %q, %v, want %q, %v", method, ok, tt.method, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if gotSpender != spender {
+				t.Errorf("spender = %s, want %s", gotSpender.Hex(), spender.Hex())
+			}
+			if gotAmount.Cmp(amount) != 0 {
+				t.Errorf("amount = %s, want %s", gotAmount, amount)
+			}
+		})
+	}
+}
+
+func TestEvaluateAllowance(t *testing.T) {
+	doc := `{
+		"max_amount_wei": 0,
+		"allow_calldata": true,
+		"whitelist": ["` + testToken.Hex() + `"],
+		"allowances": {"max_amount": 1000}
+	}`
+	unlimited := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	dirty := approveCall(selectorERC20Approve, testRecipient, unlimited)
+	dirty[4] = 0x01
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"within cap", approveCall(selectorERC20Approve, testRecipient, big.NewInt(1000)), ""},
+		{"over cap", approveCall(selectorERC20Approve, testRecipient, big.NewInt(1001)), "allowances"},
+		{"unlimited", approveCall(selectorERC20IncreaseAllowance, testRecipient, unlimited), "allowances"},
+		{"padded unlimited", append(approveCall(selectorERC20Approve, testRecipient, unlimited), 0x00), "allowances"},
+		{"dirty address unlimited", dirty, "allowances"},
+		{"truncated", approveCall(selectorERC20Approve, testRecipient, unlimited)[:50], "allowances"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluate(t, doc, testToken, tt.data); got != tt.want {
+				t.Errorf("failed check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
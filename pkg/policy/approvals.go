@@ -0,0 +1,10 @@
+package policy
+
+// ApprovalPolicy requires a quorum of approvers to sign off on every
+// transaction before it is signed.
+type ApprovalPolicy struct {
+	// Approvers lists the addresses whose approvals count.
+	Approvers []string `json:"approvers"`
+	// Quorum is the number of distinct approvers required.
+	Quorum int `json:"quorum"`
+}
@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// checkBlacklist rejects the transaction if any of addrs is on the policy
+// blacklist. The blacklist file, if configured, is re-read on every check so
+// edits take effect immediately, including in a running daemon; a file that
+// cannot be read fails the check.
+func (p *Policy) checkBlacklist(addrs ...common.Address) error {
+	denied := p.Blacklist
+	if p.BlacklistFile != "" {
+		entries, err := ReadAddressFile(p.BlacklistFile)
+		if err != nil {
+			return fmt.Errorf("failed to read blacklist file: %v", err)
+		}
+		denied = append(entries, denied...)
+	}
+	for _, addr := range addrs {
+		for _, entry := range denied {
+			if strings.EqualFold(entry, addr.Hex()) {
+				return errors.New("address is blacklisted")
+			}
+		}
+	}
+	return nil
+}
+
+// recordBlacklist runs checkBlacklist and records it in d, if the policy
+// has a blacklist.
+func (p *Policy) recordBlacklist(d *Decision, addrs ...common.Address) error {
+	if len(p.Blacklist) == 0 && p.BlacklistFile == "" {
+		return nil
+	}
+	return d.Record("blacklist", p.checkBlacklist(addrs...))
+}
+
+// ReadAddressFile reads one address per line. Blank lines and lines
+// starting with # are ignored.
+func ReadAddressFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var addrs []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("line %d: invalid address %q", line, entry)
+		}
+		addrs = append(addrs, entry)
+	}
+	return addrs, scanner.Err()
+}
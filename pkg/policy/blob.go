@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// checkBlobs applies the blob limits of the fee settings to tx. Blob
+// transactions are rejected unless the policy sets max_blobs_per_tx.
+func checkBlobs(settings txbuilder.FeeSettings, tx *types.Transaction, d *Decision) error {
+	if tx.Type() != types.BlobTxType {
+		return nil
+	}
+	n := len(tx.BlobHashes())
+	if n > settings.MaxBlobsPerTx {
+		if settings.MaxBlobsPerTx == 0 {
+			return d.Fail("fees.max_blobs_per_tx", nil, n, "blob transactions are not allowed")
+		}
+		return d.Fail("fees.max_blobs_per_tx", settings.MaxBlobsPerTx, n, fmt.Sprintf("%d blobs exceed policy cap %d", n, settings.MaxBlobsPerTx))
+	}
+	d.Pass("fees.max_blobs_per_tx", settings.MaxBlobsPerTx, n)
+	if settings.MaxBlobFeePerGasWei != nil {
+		if tx.BlobGasFeeCap().Cmp(settings.MaxBlobFeePerGasWei) > 0 {
+			return d.Fail("fees.max_blob_fee_per_gas_wei", settings.MaxBlobFeePerGasWei, tx.BlobGasFeeCap(), fmt.Sprintf("max blob fee per gas %s exceeds policy cap %s", tx.BlobGasFeeCap(), settings.MaxBlobFeePerGasWei))
+		}
+		d.Pass("fees.max_blob_fee_per_gas_wei", settings.MaxBlobFeePerGasWei, tx.BlobGasFeeCap())
+	}
+	return nil
+}
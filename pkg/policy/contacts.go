@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EnvContactsFile names the environment variable that overrides the default
+// address book.
+const EnvContactsFile = "SIGNER_CONTACTS"
+
+// DefaultContactsFile is the address book used when neither -contacts nor
+// SIGNER_CONTACTS is set.
+const DefaultContactsFile = "contacts.json"
+
+// Contact is a named recipient in the address book.
+type Contact struct {
+	Address common.Address `json:"address"`
+	Note    string         `json:"note,omitempty"`
+	Added   time.Time      `json:"added"`
+}
+
+// AddressBook maps contact names to addresses, so recipients can be given
+// by name with -to and in policy whitelists.
+type AddressBook struct {
+	File     string              `json:"-"`
+	Contacts map[string]*Contact `json:"contacts"`
+}
+
+// ContactsFile returns file, or the file from SIGNER_CONTACTS or the
+// default.
+func ContactsFile(file string) string {
+	if file != "" {
+		return file
+	}
+	if env := os.Getenv(EnvContactsFile); env != "" {
+		return env
+	}
+	return DefaultContactsFile
+}
+
+// OpenAddressBook reads the address book in file. A missing file is an
+// empty address book.
+func OpenAddressBook(file string) (*AddressBook, error) {
+	b, _, err := readAddressBook(ContactsFile(file))
+	return b, err
+}
+
+// readAddressBook reads the address book in file and returns it with the
+// hex SHA-256 of the file, which is that of no bytes for a missing file.
+func readAddressBook(file string) (*AddressBook, string, error) {
+	b := &AddressBook{File: file, Contacts: make(map[string]*Contact)}
+	data, err := os.ReadFile(file)
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return b, digest, nil
+	case err != nil:
+		return nil, "", fmt.Errorf("failed to read address book: %v", err)
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, "", fmt.Errorf("invalid address book: %v", err)
+	}
+	if b.Contacts == nil {
+		b.Contacts = make(map[string]*Contact)
+	}
+	return b, digest, nil
+}
+
+// addressBook opens the policy's address book, ContactsFile or the file
+// from SIGNER_CONTACTS or the default, and returns it with its SHA-256. A
+// signed policy ignores SIGNER_CONTACTS and must pin the book in
+// ContactsSHA256, so neither the environment nor an edit to the book can
+// change what it allows.
+func (p *Policy) addressBook() (*AddressBook, string, error) {
+	file := ContactsFile(p.ContactsFile)
+	if p.signed && p.ContactsFile == "" {
+		file = DefaultContactsFile
+	}
+	b, digest, err := readAddressBook(file)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case p.ContactsSHA256 != "":
+		if !strings.EqualFold(p.ContactsSHA256, digest) {
+			return nil, "", fmt.Errorf("address book %s has sha256 %s, but the policy pins %s", file, digest, p.ContactsSHA256)
+		}
+	case p.signed:
+		return nil, "", fmt.Errorf("a signed policy must pin its address book: set contacts_sha256 to %s", digest)
+	}
+	return b, digest, nil
+}
+
+// LoadedContactsSHA256 returns the SHA-256 of the address book the policy
+// resolved its contacts from, the value to pin in ContactsSHA256, or ""
+// if it has not read one.
+func (p *Policy) LoadedContactsSHA256() string {
+	return p.loadedContactsSHA256
+}
+
+// Lookup returns the address of the contact name.
+func (b *AddressBook) Lookup(name string) (common.Address, error) {
+	c, ok := b.Contacts[strings.ToLower(name)]
+	if !ok {
+		return common.Address{}, fmt.Errorf("no contact %q in %s", name, b.File)
+	}
+	return c.Address, nil
+}
+
+// IsContactName reports whether s is a contact name rather than an address
+// or ENS name.
+func IsContactName(s string) bool {
+	return s != "" && !common.IsHexAddress(s) && !IsENSName(s)
+}
+
+// resolveContacts replaces the contact names in the policy whitelist with
+// their addresses from ContactsFile.
+func (p *Policy) resolveContacts() error {
+	var names []string
+	for entry := range p.Whitelist {
+		if IsContactName(entry) {
+			names = append(names, entry)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if p.ContactsFile == "" {
+		return fmt.Errorf("whitelist: %q is not an address and no contacts_file is configured", names[0])
+	}
+	b, digest, err := p.addressBook()
+	if err != nil {
+		return err
+	}
+	p.loadedContactsSHA256 = digest
+	for _, name := range names {
+		addr, err := b.Lookup(name)
+		if err != nil {
+			return fmt.Errorf("whitelist: %v", err)
+		}
+		if _, ok := p.Recipient(addr); ok {
+			return fmt.Errorf("whitelist: contact %q is %s, which is already listed", name, addr.Hex())
+		}
+		p.Whitelist[addr.Hex()] = p.Whitelist[name]
+		delete(p.Whitelist, name)
+	}
+	return nil
+}
+
+// ParseChecksummedAddress parses s, rejecting mixed-case addresses whose
+// EIP-55 checksum does not match.
+func ParseChecksummedAddress(name, s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid %s address %q", name, s)
+	}
+	addr := common.HexToAddress(s)
+	body := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) && body != addr.Hex()[2:] {
+		return common.Address{}, fmt.Errorf("%s address %s has an invalid checksum", name, s)
+	}
+	return addr, nil
+}
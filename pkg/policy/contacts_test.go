@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContactsPinning(t *testing.T) {
+	book := filepath.Join(t.TempDir(), "contacts.json")
+	writeBook := func(addr string) {
+		t.Helper()
+		if err := os.WriteFile(book, []byte(`{"contacts": {"treasury": {"address": "`+addr+`"}}}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeBook(testRecipient.Hex())
+	doc := func(pin string) []byte {
+		return []byte(`{"max_amount_wei": 1, "whitelist": ["treasury"], "contacts_file": ` + quote(book) + `, "contacts_sha256": "` + pin + `"}`)
+	}
+
+	p, err := Parse(doc(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Recipient(testRecipient); !ok {
+		t.Fatal("contact name not resolved")
+	}
+	digest := p.LoadedContactsSHA256()
+	if _, err := ParseSigned(doc("")); err == nil || !strings.Contains(err.Error(), digest) {
+		t.Fatalf("ParseSigned without a pin = %v, want an error naming %s", err, digest)
+	}
+	if _, err := ParseSigned(doc(digest)); err != nil {
+		t.Fatalf("pinned address book refused: %v", err)
+	}
+
+	// Pointing the contact at another address changes the whitelist, so
+	// the pinned policy no longer loads, signed or not.
+	writeBook(testSender.Hex())
+	for _, parse := range []func([]byte) (*Policy, error){Parse, ParseSigned} {
+		if _, err := parse(doc(digest)); err == nil || !strings.Contains(err.Error(), "but the policy pins") {
+			t.Fatalf("tampered address book: err = %v", err)
+		}
+	}
+
+	// Sender policies inherit the book and its pin.
+	writeBook(testRecipient.Hex())
+	sender := []byte(`{"contacts_file": ` + quote(book) + `, "contacts_sha256": "` + digest + `", "senders": {"` + testSender.Hex() + `": {"max_amount_wei": 1, "whitelist": ["treasury"]}}}`)
+	if _, err := ParseSigned(sender); err != nil {
+		t.Fatalf("sender policy with the pinned book refused: %v", err)
+	}
+	writeBook(testSender.Hex())
+	if _, err := ParseSigned(sender); err == nil {
+		t.Fatal("sender policy with a tampered address book accepted")
+	}
+}
+
+func TestParseChecksummedAddress(t *testing.T) {
+	const checksummed = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{checksummed, true},
+		{"0X" + checksummed[2:], true},
+		{strings.ToLower(checksummed), true},
+		{"0x" + strings.ToUpper(checksummed[2:]), true},
+		{strings.ToLower(checksummed)[2:], true},
+		{"0xFB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", false},
+		{"0X" + "FB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", false},
+		{"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d35", false},
+		{"treasury", false},
+	}
+	for _, tt := range tests {
+		addr, err := ParseChecksummedAddress("test", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseChecksummedAddress(%s) = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && addr.Hex() != checksummed {
+			t.Errorf("ParseChecksummedAddress(%s) = %s", tt.in, addr.Hex())
+		}
+	}
+}
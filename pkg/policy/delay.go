@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TimeDelayPolicy holds transactions above its thresholds for a cooling-off
+// period. They are signed, but the signed transaction is kept in a queue
+// instead of being returned, and only released with the delayed command
+// once DelayMinutes has passed. Until then any operator can cancel it.
+// Transactions with later nonces from the same key cannot be mined before
+// a held one is released, or replaced after it is cancelled.
+type TimeDelayPolicy struct {
+	// MinValueWei holds transactions sending at least this much ether.
+	MinValueWei *big.Int `json:"min_value_wei"`
+	// Tokens holds ERC-20 transfers of at least the given amount, in base
+	// units, keyed by token address.
+	Tokens       map[string]*big.Int `json:"tokens"`
+	DelayMinutes int                 `json:"delay_minutes"`
+}
+
+// Applies reports whether tx crosses one of the thresholds.
+func (p *TimeDelayPolicy) Applies(tx *types.Transaction) bool {
+	return crossesThreshold(tx, p.MinValueWei, p.Tokens)
+}
+
+func (p *TimeDelayPolicy) Delay() time.Duration {
+	return time.Duration(p.DelayMinutes) * time.Minute
+}
@@ -0,0 +1,9 @@
+// Package policy parses and evaluates signing policies: the JSON documents,
+// optionally with a Rego module, that decide which transactions a key may
+// sign.
+//
+// Parse validates a policy and Policy.Evaluate runs its stateless checks
+// for a transaction, recording each in a Decision that explains the
+// outcome. The checks that need state, such as cumulative spend limits,
+// approvals and replay protection, are run by package signer.
+package policy
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ensRegistry is the ENS registry, deployed at the same address on mainnet
+// and the public testnets.
+var ensRegistry = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+var (
+	// resolver(bytes32)
+	selectorENSResolver = []byte{0x01, 0x78, 0xb8, 0xbf}
+	// addr(bytes32)
+	selectorENSAddr = []byte{0x3b, 0x3b, 0x57, 0xde}
+)
+
+// IsENSName reports whether s looks like an ENS name rather than an
+// address.
+func IsENSName(s string) bool {
+	return strings.Contains(s, ".") && !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X")
+}
+
+// NormalizeENSName lowercases name. Full ENSIP-15 normalization is not
+// applied, so names outside ASCII are rejected rather than risk resolving
+// a lookalike.
+func NormalizeENSName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", fmt.Errorf("invalid ENS name %q", name)
+		}
+		for _, c := range label {
+			if c > 0x7f {
+				return "", fmt.Errorf("ENS name %q is not ASCII", name)
+			}
+		}
+	}
+	return name, nil
+}
+
+// namehash computes the EIP-137 node of a normalized name.
+func namehash(name string) common.Hash {
+	var node common.Hash
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// ENSResolver resolves ENS names over RPC at sign time, checking them
+// against the policy's pinned resolutions.
+type ENSResolver struct {
+	client *ethclient.Client
+}
+
+// NewENSResolver returns a resolver using client, or nil without one.
+func NewENSResolver(client *ethclient.Client) *ENSResolver {
+	if client == nil {
+		return nil
+	}
+	return &ENSResolver{client: client}
+}
+
+// resolve returns the address name resolves to.
+func (r *ENSResolver) resolve(ctx context.Context, name string) (common.Address, error) {
+	name, err := NormalizeENSName(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	node := namehash(name)
+	out, err := txbuilder.CallToken(ctx, r.client, ensRegistry, append(append([]byte{}, selectorENSResolver...), node.Bytes()...))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to look up resolver of %s: %v", name, err)
+	}
+	if len(out) != 32 {
+		return common.Address{}, fmt.Errorf("ENS registry returned an invalid resolver for %s", name)
+	}
+	resolver := common.BytesToAddress(out)
+	if resolver == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("ENS name %s has no resolver", name)
+	}
+	out, err = txbuilder.CallToken(ctx, r.client, resolver, append(append([]byte{}, selectorENSAddr...), node.Bytes()...))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to resolve %s: %v", name, err)
+	}
+	if len(out) != 32 {
+		return common.Address{}, fmt.Errorf("resolver returned an invalid address for %s", name)
+	}
+	addr := common.BytesToAddress(out)
+	if addr == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("ENS name %s has no address", name)
+	}
+	return addr, nil
+}
+
+// ResolvePinned resolves name and checks the result against the address
+// pinned for it in policy, if any.
+func (r *ENSResolver) ResolvePinned(ctx context.Context, policy *Policy, name string) (common.Address, error) {
+	addr, err := r.resolve(ctx, name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if policy == nil {
+		return addr, nil
+	}
+	for pinned, want := range policy.ENSPins {
+		if strings.EqualFold(strings.TrimSuffix(pinned, "."), strings.TrimSuffix(name, ".")) && !strings.EqualFold(want, addr.Hex()) {
+			return common.Address{}, fmt.Errorf("ENS name %s resolves to %s, but is pinned to %s", name, addr.Hex(), want)
+		}
+	}
+	return addr, nil
+}
+
+// ResolvePolicy returns a copy of policy with the ENS names in its whitelist
+// replaced by the addresses they currently resolve to. Without a resolver
+// the policy is returned as is, and whitelisted names match no recipient.
+func (r *ENSResolver) ResolvePolicy(ctx context.Context, policy *Policy) (*Policy, error) {
+	if r == nil || !policy.HasENSNames() {
+		return policy, nil
+	}
+	resolved := *policy
+	resolved.Whitelist = make(Whitelist, len(policy.Whitelist))
+	for entry, rp := range policy.Whitelist {
+		if !IsENSName(entry) {
+			resolved.Whitelist[entry] = rp
+			continue
+		}
+		addr, err := r.ResolvePinned(ctx, policy, entry)
+		if err != nil {
+			return nil, fmt.Errorf("whitelist: %v", err)
+		}
+		resolved.Whitelist[addr.Hex()] = rp
+	}
+	return &resolved, nil
+}
+
+// HasENSNames reports whether the whitelist lists any ENS names.
+func (p *Policy) HasENSNames() bool {
+	for entry := range p.Whitelist {
+		if IsENSName(entry) {
+			return true
+		}
+	}
+	return false
+}
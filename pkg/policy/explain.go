@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Decision explains a policy evaluation: every check that ran, in order,
+// with the configured limit and the requested value where there is one.
+// A nil *Decision records nothing, for callers that only want the error.
+type Decision struct {
+	Allowed bool          `json:"allowed"`
+	Checks  []checkResult `json:"checks"`
+}
+
+type checkResult struct {
+	Check     string `json:"check"`
+	Passed    bool   `json:"passed"`
+	Limit     string `json:"limit,omitempty"`
+	Requested string `json:"requested,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func explainValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case *big.Int:
+		if v == nil {
+			return ""
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func (d *Decision) add(check string, passed bool, limit, requested any, reason string) {
+	if d == nil {
+		return
+	}
+	d.Checks = append(d.Checks, checkResult{
+		Check:     check,
+		Passed:    passed,
+		Limit:     explainValue(limit),
+		Requested: explainValue(requested),
+		Reason:    reason,
+	})
+}
+
+// Pass records a check that passed.
+func (d *Decision) Pass(check string, limit, requested any) {
+	d.add(check, true, limit, requested, "")
+}
+
+// Fail records a check that failed and returns reason as the error.
+func (d *Decision) Fail(check string, limit, requested any, reason string) error {
+	d.add(check, false, limit, requested, reason)
+	return errors.New(reason)
+}
+
+// Record records the outcome of a check that only reports an error.
+func (d *Decision) Record(check string, err error) error {
+	if err != nil {
+		d.add(check, false, nil, nil, err.Error())
+	} else {
+		d.add(check, true, nil, nil, "")
+	}
+	return err
+}
+
+// DeniedError is a policy denial carrying its explanation, which the
+// JSON-RPC server returns as the error data.
+type DeniedError struct {
+	Err      error
+	Decision *Decision
+}
+
+func (e *DeniedError) Error() string { return e.Err.Error() }
+
+func (e *DeniedError) Unwrap() error { return e.Err }
+
+func (e *DeniedError) ErrorData() interface{} { return e.Decision }
+
+// DeniedRule names the policy rule that denied a transaction SignTx
+// answered with d and err, or is empty if the policy did not deny it.
+func DeniedRule(d *Decision, err error) string {
+	var perr *DeniedError
+	if !errors.As(err, &perr) {
+		return ""
+	}
+	if failed := d.FailedCheck(); failed != "" {
+		return failed
+	}
+	return "policy"
+}
+
+// FailedCheck names the first check of d that failed, or is empty if none
+// did.
+func (d *Decision) FailedCheck() string {
+	if d == nil {
+		return ""
+	}
+	for _, c := range d.Checks {
+		if !c.Passed {
+			return c.Check
+		}
+	}
+	return ""
+}
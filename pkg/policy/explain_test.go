@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecisionExplainsDenial(t *testing.T) {
+	p, err := Parse([]byte(`{"max_amount_wei": 100, "allowed_chain_ids": [1], "whitelist": ["` + testRecipient.Hex() + `"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &testRecipient, Gas: 21000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: big.NewInt(250)})
+	var d Decision
+	err = p.Evaluate(t.Context(), tx, testSender, big.NewInt(1), time.Now(), "", &d)
+	if err == nil {
+		t.Fatal("transaction over the limit allowed")
+	}
+
+	// The checks that ran before the denial are listed as passed, and
+	// evaluation stops at the failed one.
+	data, err := json.Marshal(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Allowed bool `json:"allowed"`
+		Checks  []map[string]any
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"check": "max_amount_wei", "passed": false, "limit": "100", "requested": "250", "reason": "amount exceeds max policy limit"}
+	last := got.Checks[len(got.Checks)-1]
+	if got.Allowed || len(last) != len(want) {
+		t.Fatalf("decision = %s", data)
+	}
+	for k, v := range want {
+		if last[k] != v {
+			t.Errorf("failed check %s = %v, want %v", k, last[k], v)
+		}
+	}
+	passed := make(map[string]bool)
+	for _, c := range got.Checks[:len(got.Checks)-1] {
+		if c["passed"] != true {
+			t.Errorf("check %v before the denial did not pass", c)
+		}
+		passed[c["check"].(string)] = true
+	}
+	for _, check := range []string{"allowed_chain_ids", "whitelist"} {
+		if !passed[check] {
+			t.Errorf("decision %s does not list the passed %s check", data, check)
+		}
+	}
+}
+
+func TestDecisionHelpers(t *testing.T) {
+	// A nil decision records nothing but still reports the error.
+	var nilDecision *Decision
+	if err := nilDecision.Fail("whitelist", nil, nil, "denied"); err == nil || err.Error() != "denied" {
+		t.Fatalf("Fail on nil decision = %v", err)
+	}
+	if nilDecision.FailedCheck() != "" {
+		t.Fatal("nil decision has a failed check")
+	}
+
+	d := new(Decision)
+	d.Pass("whitelist", nil, "0xabc")
+	d.Record("limits", nil)
+	err := &DeniedError{Err: d.Record("replay", errors.New("nonce reused")), Decision: d}
+	if got := d.FailedCheck(); got != "replay" {
+		t.Fatalf("FailedCheck = %q, want replay", got)
+	}
+	if got := DeniedRule(d, err); got != "replay" {
+		t.Fatalf("DeniedRule = %q, want replay", got)
+	}
+	if got := DeniedRule(new(Decision), &DeniedError{Err: errors.New("denied")}); got != "policy" {
+		t.Fatalf("DeniedRule without a failed check = %q, want policy", got)
+	}
+	if got := DeniedRule(d, errors.New("rpc failed")); got != "" {
+		t.Fatalf("DeniedRule for a non-policy error = %q, want none", got)
+	}
+	if d.Checks[0].Limit != "" || d.Checks[0].Requested != "0xabc" {
+		t.Fatalf("passed check = %+v", d.Checks[0])
+	}
+}
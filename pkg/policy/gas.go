@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// checkFees applies the fee caps to tx, recording each in d. The max fee
+// cap also bounds the gas price of legacy transactions.
+func checkFees(settings txbuilder.FeeSettings, tx *types.Transaction, d *Decision) error {
+	if settings.MaxGasLimit > 0 {
+		if tx.Gas() > settings.MaxGasLimit {
+			return d.Fail("fees.max_gas_limit", settings.MaxGasLimit, tx.Gas(), fmt.Sprintf("gas limit %d exceeds policy cap %d", tx.Gas(), settings.MaxGasLimit))
+		}
+		d.Pass("fees.max_gas_limit", settings.MaxGasLimit, tx.Gas())
+	}
+	if settings.MaxFeePerGasWei != nil {
+		if tx.GasFeeCap().Cmp(settings.MaxFeePerGasWei) > 0 {
+			return d.Fail("fees.max_fee_per_gas_wei", settings.MaxFeePerGasWei, tx.GasFeeCap(), fmt.Sprintf("max fee per gas %s exceeds policy cap %s", tx.GasFeeCap(), settings.MaxFeePerGasWei))
+		}
+		d.Pass("fees.max_fee_per_gas_wei", settings.MaxFeePerGasWei, tx.GasFeeCap())
+	}
+	if settings.MaxPriorityFeeWei != nil && tx.Type() != types.LegacyTxType {
+		if tx.GasTipCap().Cmp(settings.MaxPriorityFeeWei) > 0 {
+			return d.Fail("fees.max_priority_fee_wei", settings.MaxPriorityFeeWei, tx.GasTipCap(), fmt.Sprintf("max priority fee %s exceeds policy cap %s", tx.GasTipCap(), settings.MaxPriorityFeeWei))
+		}
+		d.Pass("fees.max_priority_fee_wei", settings.MaxPriorityFeeWei, tx.GasTipCap())
+	}
+	if err := checkBlobs(settings, tx, d); err != nil {
+		return err
+	}
+	if settings.MaxTotalCostWei != nil {
+		if tx.Cost().Cmp(settings.MaxTotalCostWei) > 0 {
+			return d.Fail("fees.max_total_cost_wei", settings.MaxTotalCostWei, tx.Cost(), fmt.Sprintf("total cost %s exceeds policy cap %s", tx.Cost(), settings.MaxTotalCostWei))
+		}
+		d.Pass("fees.max_total_cost_wei", settings.MaxTotalCostWei, tx.Cost())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCheckFees(t *testing.T) {
+	settings := txbuilder.FeeSettings{
+		MaxGasLimit:       100000,
+		MaxFeePerGasWei:   big.NewInt(50),
+		MaxPriorityFeeWei: big.NewInt(2),
+		MaxTotalCostWei:   big.NewInt(3000000),
+	}
+	dynamic := func(gas uint64, feeCap, tipCap, value int64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			To:        &testRecipient,
+			Gas:       gas,
+			GasFeeCap: big.NewInt(feeCap),
+			GasTipCap: big.NewInt(tipCap),
+			Value:     big.NewInt(value),
+		})
+	}
+	legacy := func(gasPrice int64) *types.Transaction {
+		return types.NewTransaction(0, testRecipient, new(big.Int), 21000, big.NewInt(gasPrice), nil)
+	}
+	tests := []struct {
+		name string
+		tx   *types.Transaction
+		want string
+	}{
+		{"within caps", dynamic(21000, 50, 2, 0), ""},
+		{"at gas limit cap", dynamic(100000, 10, 2, 0), ""},
+		{"over gas limit cap", dynamic(100001, 10, 2, 0), "fees.max_gas_limit"},
+		{"over max fee", dynamic(21000, 51, 2, 0), "fees.max_fee_per_gas_wei"},
+		{"over priority fee", dynamic(21000, 50, 3, 0), "fees.max_priority_fee_wei"},
+		// 60000 gas at 50 wei is exactly the total cost cap; any value
+		// on top exceeds it.
+		{"at total cost cap", dynamic(60000, 50, 2, 0), ""},
+		{"over total cost cap", dynamic(60000, 50, 2, 1), "fees.max_total_cost_wei"},
+		// Legacy gas prices are capped by the max fee; they have no
+		// separate priority fee.
+		{"legacy within caps", legacy(50), ""},
+		{"legacy over max fee", legacy(51), "fees.max_fee_per_gas_wei"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decision
+			err := checkFees(settings, tt.tx, &d)
+			if got := d.FailedCheck(); got != tt.want || (err != nil) != (tt.want != "") {
+				t.Errorf("checkFees: failed check = %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	var d Decision
+	if err := checkFees(txbuilder.FeeSettings{}, dynamic(30000000, 1e12, 1e12, 0), &d); err != nil {
+		t.Errorf("checkFees without caps = %v, want no error", err)
+	}
+}
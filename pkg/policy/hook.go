@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultHookTimeout is how long a hook may take when its config does not
+// say.
+const defaultHookTimeout = 5 * time.Second
+
+// HookTarget is an external program the signer hands a JSON document to:
+// an HTTP endpoint it POSTs the document to, or a local command it runs
+// with the document on its standard input. Requests to an endpoint carry
+// the HMAC-SHA256 of the body keyed by the secret in SecretFile, if any, in
+// the X-Signer-Signature header, like webhook deliveries.
+type HookTarget struct {
+	URL            string   `json:"url"`
+	Command        []string `json:"command"`
+	SecretFile     string   `json:"secret_file"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+
+	secret []byte
+}
+
+// Prepare validates the target and reads its secret.
+func (h *HookTarget) Prepare() error {
+	switch {
+	case h.URL != "" && len(h.Command) > 0:
+		return errors.New("url and command are mutually exclusive")
+	case h.URL != "":
+		if !strings.HasPrefix(h.URL, "https://") && !strings.HasPrefix(h.URL, "http://") {
+			return fmt.Errorf("invalid url %q", h.URL)
+		}
+	case len(h.Command) > 0:
+		if h.SecretFile != "" {
+			return errors.New("secret_file requires url")
+		}
+	default:
+		return errors.New("url or command is required")
+	}
+	if h.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid timeout_seconds %d", h.TimeoutSeconds)
+	}
+	if h.SecretFile != "" {
+		secret, err := os.ReadFile(h.SecretFile)
+		if err != nil {
+			return fmt.Errorf("failed to read secret: %v", err)
+		}
+		if h.secret = bytes.TrimSpace(secret); len(h.secret) == 0 {
+			return errors.New("secret is empty")
+		}
+	}
+	return nil
+}
+
+func (h *HookTarget) Timeout() time.Duration {
+	if h.TimeoutSeconds > 0 {
+		return time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+// Name identifies the target in logs and errors.
+func (h *HookTarget) Name() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return h.Command[0]
+}
+
+// Signature returns the X-Signer-Signature value of body, if h has a
+// secret.
+func (h *HookTarget) Signature(body []byte) (string, bool) {
+	if h.secret == nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil)), true
+}
+
+// PreSignHook asks an external service, such as a risk engine, to approve
+// every transaction once it passed the other checks. The hook gets the
+// transaction as the Rego policy input, with its signing hash and the
+// policy digest added, and must answer "approve" within the timeout, as
+// plain text or as {"decision": "approve"}; anything else, including no
+// answer, denies the transaction. A denial may give a reason, as
+// {"decision": "deny", "reason": "..."}.
+type PreSignHook struct {
+	HookTarget
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+)
+
+// PermitPolicy controls EIP-2612 permits for one token. Spenders lists who
+// may be approved and MaxValue caps the allowance, in the token's base
+// units; MaxDeadlineSeconds, when set, caps how far in the future the
+// permit may expire.
+type PermitPolicy struct {
+	Spenders           []string `json:"spenders"`
+	MaxValue           *big.Int `json:"max_value"`
+	MaxDeadlineSeconds int64    `json:"max_deadline_seconds"`
+}
+
+// CheckPermit applies the token's permit policy to pm at now.
+func (p *Policy) CheckPermit(pm *txbuilder.Permit, now time.Time) error {
+	var pp *PermitPolicy
+	for addr, entry := range p.Permits {
+		if strings.EqualFold(addr, pm.Token.Hex()) {
+			pp = &entry
+			break
+		}
+	}
+	if pp == nil {
+		return fmt.Errorf("token %s not in permits", pm.Token.Hex())
+	}
+	if !containsAddress(pp.Spenders, pm.Spender) {
+		return fmt.Errorf("spender %s not in permits.%s.spenders", pm.Spender.Hex(), pm.Token.Hex())
+	}
+	if pp.MaxValue == nil {
+		return errors.New("no max_value configured")
+	}
+	if pm.Value.Cmp(pp.MaxValue) > 0 {
+		return fmt.Errorf("permit value %s exceeds policy cap %s", pm.Value, pp.MaxValue)
+	}
+	if pm.Deadline.Cmp(big.NewInt(now.Unix())) <= 0 {
+		return errors.New("permit deadline has passed")
+	}
+	if pp.MaxDeadlineSeconds > 0 && pm.Deadline.Cmp(big.NewInt(now.Unix()+pp.MaxDeadlineSeconds)) > 0 {
+		return fmt.Errorf("permit deadline is more than %d seconds away", pp.MaxDeadlineSeconds)
+	}
+	return nil
+}
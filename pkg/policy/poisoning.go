@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Address poisoning actions.
+const (
+	PoisoningDeny = "deny"
+	PoisoningWarn = "warn"
+	PoisoningOff  = "off"
+)
+
+// defaultPoisoningChars is how many leading and trailing hex digits two
+// addresses must share to count as lookalikes. Wallets commonly shorten
+// addresses to this many characters on each side.
+const defaultPoisoningChars = 4
+
+// PoisoningPolicy controls the check for recipients that look like a known
+// address without being it: addresses that share the leading and trailing
+// hex digits of a whitelisted address or contact, as generated for address
+// poisoning attacks.
+type PoisoningPolicy struct {
+	// Action is "deny" (the default), "warn" or "off".
+	Action string `json:"action"`
+	// PrefixChars and SuffixChars are the number of leading and trailing
+	// hex digits that must match. Both default to 4.
+	PrefixChars int `json:"prefix_chars"`
+	SuffixChars int `json:"suffix_chars"`
+}
+
+// lookalike reports whether a and b differ but share prefix leading and
+// suffix trailing hex digits.
+func lookalike(a, b common.Address, prefix, suffix int) bool {
+	if a == b {
+		return false
+	}
+	x, y := strings.ToLower(a.Hex()[2:]), strings.ToLower(b.Hex()[2:])
+	return x[:prefix] == y[:prefix] && x[len(x)-suffix:] == y[len(y)-suffix:]
+}
+
+// knownAddresses returns the whitelisted addresses and the contacts in the
+// policy's address book, the same book its whitelist names resolve from. A
+// signed policy that does not pin the book leaves it out.
+func (p *Policy) knownAddresses() ([]common.Address, error) {
+	var known []common.Address
+	for entry := range p.Whitelist {
+		if common.IsHexAddress(entry) {
+			known = append(known, common.HexToAddress(entry))
+		}
+	}
+	if !p.signed || p.ContactsSHA256 != "" {
+		b, _, err := p.addressBook()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range b.Contacts {
+			known = append(known, c.Address)
+		}
+	}
+	return known, nil
+}
+
+// checkPoisoning rejects tx, or warns with the poisoning action "warn", if
+// its recipient is a lookalike of a known address.
+func (p *Policy) checkPoisoning(tx *types.Transaction, d *Decision) error {
+	settings := PoisoningPolicy{Action: PoisoningDeny, PrefixChars: defaultPoisoningChars, SuffixChars: defaultPoisoningChars}
+	if p.Poisoning != nil {
+		if p.Poisoning.Action != "" {
+			settings.Action = p.Poisoning.Action
+		}
+		if p.Poisoning.PrefixChars > 0 {
+			settings.PrefixChars = min(p.Poisoning.PrefixChars, 20)
+		}
+		if p.Poisoning.SuffixChars > 0 {
+			settings.SuffixChars = min(p.Poisoning.SuffixChars, 20)
+		}
+	}
+	if settings.Action == PoisoningOff {
+		return nil
+	}
+	to, ok := TxRecipient(tx)
+	if !ok || p.whitelisted(to) {
+		return nil
+	}
+	known, err := p.knownAddresses()
+	if err != nil {
+		return d.Record("address_poisoning", fmt.Errorf("failed to read known addresses: %v", err))
+	}
+	for _, addr := range known {
+		if !lookalike(to, addr, settings.PrefixChars, settings.SuffixChars) {
+			continue
+		}
+		msg := fmt.Sprintf("recipient %s looks like known address %s but is a different address", to.Hex(), addr.Hex())
+		if settings.Action == PoisoningWarn {
+			slog.Warn("possible address poisoning", "recipient", to.Hex(), "known", addr.Hex())
+			break
+		}
+		return d.Fail("address_poisoning", addr.Hex(), to.Hex(), msg)
+	}
+	d.Pass("address_poisoning", nil, to.Hex())
+	return nil
+}
@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPoisoningContacts(t *testing.T) {
+	contact := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	lookalike := common.HexToAddress("0x4000000000000000000000000000000011110004")
+	book := filepath.Join(t.TempDir(), "contacts.json")
+	writeBook := func(addr common.Address) {
+		t.Helper()
+		if err := os.WriteFile(book, []byte(`{"contacts": {"exchange": {"address": "`+addr.Hex()+`"}}}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeBook(contact)
+	// Run from an empty directory so no default contacts.json is found.
+	t.Chdir(t.TempDir())
+	t.Setenv(EnvContactsFile, book)
+
+	failed := func(p *Policy) string {
+		t.Helper()
+		tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &lookalike, Gas: 21000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: new(big.Int)})
+		var d Decision
+		p.Evaluate(t.Context(), tx, testSender, big.NewInt(1), time.Now(), "", &d)
+		return d.FailedCheck()
+	}
+	load := func(parse func([]byte) (*Policy, error), extra string) *Policy {
+		t.Helper()
+		p, err := parse([]byte(`{"max_amount_wei": 1, "whitelist": ["` + testRecipient.Hex() + `"]` + extra + `}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// The book the contacts commands use is the one checked for
+	// lookalikes, whether or not the policy names a contacts_file.
+	if got := failed(load(Parse, "")); got != "address_poisoning" {
+		t.Errorf("book from %s: failed check = %q, want address_poisoning", EnvContactsFile, got)
+	}
+	if got := failed(load(Parse, `, "contacts_file": `+quote(book))); got != "address_poisoning" {
+		t.Errorf("contacts_file: failed check = %q, want address_poisoning", got)
+	}
+
+	// A signed policy ignores the environment and an unpinned book.
+	if got := failed(load(ParseSigned, "")); got != "whitelist" {
+		t.Errorf("signed policy: failed check = %q, want whitelist", got)
+	}
+	_, digest, err := readAddressBook(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := load(ParseSigned, `, "contacts_file": `+quote(book)+`, "contacts_sha256": "`+digest+`"`)
+	if got := failed(pinned); got != "address_poisoning" {
+		t.Errorf("signed policy with a pinned book: failed check = %q, want address_poisoning", got)
+	}
+	// Removing the contact after loading does not hide the lookalike; the
+	// book no longer matches its pin, so the check fails closed.
+	writeBook(testRecipient)
+	if got := failed(pinned); got != "address_poisoning" {
+		t.Errorf("tampered book: failed check = %q, want address_poisoning", got)
+	}
+}
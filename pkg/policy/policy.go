@@ -0,0 +1,583 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+type Policy struct {
+	MaxAmountWei   *big.Int  `json:"max_amount_wei"`
+	Whitelist      Whitelist `json:"whitelist"`
+	AllowCalldata  bool      `json:"allow_calldata"`
+	AllowEthSign   bool      `json:"allow_eth_sign"`
+	AllowTypedData bool      `json:"allow_typed_data"`
+	// AllowRawSign enables the Web3Signer eth1 sign endpoint, which signs
+	// arbitrary data without transaction policy checks.
+	AllowRawSign bool `json:"allow_raw_sign"`
+	// DailyLimitWei and WeeklyLimitWei cap the total value signed per sender
+	// and chain over rolling 24 hour and 7 day windows.
+	DailyLimitWei  *big.Int `json:"daily_limit_wei"`
+	WeeklyLimitWei *big.Int `json:"weekly_limit_wei"`
+	// MaxTxPerHour and MaxTxPerDay cap the number of transactions signed
+	// per sender and chain over rolling windows.
+	MaxTxPerHour uint64 `json:"max_tx_per_hour"`
+	MaxTxPerDay  uint64 `json:"max_tx_per_day"`
+	// AllowedChainIDs, when non-empty, limits signing to these chains.
+	AllowedChainIDs []*big.Int `json:"allowed_chain_ids"`
+	// AllowedDelegates lists the contracts EIP-7702 authorizations may
+	// delegate an account to, for sign-authorization and for set-code
+	// transactions. Authorizations valid on every chain (chain ID 0) are
+	// also rejected unless AllowAnyChainAuthorizations is set.
+	AllowedDelegates            []string `json:"allowed_delegates"`
+	AllowAnyChainAuthorizations bool     `json:"allow_any_chain_authorizations"`
+	// UserOps, when set, allows sign-userop to sign ERC-4337 user
+	// operations within its limits.
+	UserOps *UserOpPolicy `json:"user_operations"`
+	// Safe, when set, allows sign-safe to sign transactions of the listed
+	// Safes.
+	Safe *SafePolicy `json:"safe"`
+	// Permits allows sign-permit to sign EIP-2612 permits for the listed
+	// tokens, keyed by token address.
+	Permits map[string]PermitPolicy `json:"permits"`
+	// Approvals, when set, holds every transaction until a quorum of
+	// approvers has signed off on it with the approve command.
+	Approvals *ApprovalPolicy `json:"approvals"`
+	// Unfreeze lists the approvers a quorum of whom must lift a freeze. It
+	// defaults to Approvals.
+	Unfreeze *ApprovalPolicy `json:"unfreeze"`
+	// TimeDelay, when set, holds signed transactions above its thresholds
+	// for a cooling-off period before they are released.
+	TimeDelay *TimeDelayPolicy `json:"time_delay"`
+	// Alerts, when set, sends a chat alert for transactions above its
+	// thresholds and can hold them until the alert is acknowledged.
+	Alerts *AlertPolicy `json:"alerts"`
+	// PreSignHook, when set, must approve every transaction before it is
+	// signed.
+	PreSignHook *PreSignHook `json:"pre_sign_hook"`
+	// Rules are CEL expressions that must all hold for every transaction.
+	// Lists defines named string lists, such as address groups, that the
+	// rules can refer to.
+	Rules []Rule              `json:"rules"`
+	Lists map[string][]string `json:"lists"`
+	// Rego, when set, evaluates transactions against a Rego policy instead
+	// of the whitelist, amount, calldata, token, NFT and contract rules.
+	Rego *RegoPolicy `json:"rego"`
+	// SigningWindow, when set, rejects signing outside the given days and
+	// hours.
+	SigningWindow *SigningWindow `json:"signing_window"`
+
+	// Blacklist and the addresses listed in BlacklistFile are always
+	// rejected, even if whitelisted.
+	Blacklist     []string `json:"blacklist"`
+	BlacklistFile string   `json:"blacklist_file"`
+	// Tokens lists the ERC-20 contracts that may be transferred, keyed by
+	// token address. Transfers of any other token are rejected.
+	Tokens map[string]TokenPolicy `json:"tokens"`
+	// NFTs lists the ERC-721/ERC-1155 collections that may be transferred,
+	// keyed by collection address.
+	NFTs map[string]NFTPolicy `json:"nfts"`
+	// Contracts restricts calls to the listed contracts, keyed by contract
+	// address, to the allowed function selectors.
+	Contracts map[string]ContractPolicy `json:"contracts"`
+	// ABIDir is the ABI registry used to decode calldata for contract
+	// argument rules, a directory of <address>.json ABI files. It
+	// defaults to $SIGNER_ABI_DIR or ./abis.
+	ABIDir string `json:"abi_dir"`
+	// Fees controls gas and fee suggestions fetched over RPC and caps the
+	// fees of every transaction.
+	Fees txbuilder.FeeSettings `json:"fees"`
+	// ContactsFile, when set, is the address book whose contact names may
+	// be used in the whitelist in place of addresses. Names are looked up
+	// when the policy is loaded.
+	ContactsFile string `json:"contacts_file"`
+	// ContactsSHA256, when set, is the hex SHA-256 of the address book file,
+	// and the policy fails to load if it differs. A signed policy that
+	// uses contact names requires it.
+	ContactsSHA256 string `json:"contacts_sha256"`
+	// Poisoning controls the check for recipients that look like, but are
+	// not, a whitelisted address or contact. It denies them by default.
+	Poisoning *PoisoningPolicy `json:"address_poisoning"`
+	// Allowances controls the check on ERC-20 approvals. Unlimited
+	// allowances are rejected by default, for every token.
+	Allowances *AllowancePolicy `json:"allowances"`
+	// ENSPins maps ENS names to the address they must resolve to. Whitelist
+	// entries may be ENS names, resolved over RPC each time a transaction
+	// is signed; the pins apply to them and to names given as -to, so a
+	// hijacked name is refused.
+	ENSPins map[string]string `json:"ens_pins"`
+	// MaxAmountUSD caps the value of each transaction in US dollars, with
+	// the native currency priced when it is signed from PriceSource.
+	MaxAmountUSD float64      `json:"max_amount_usd"`
+	PriceSource  *PriceSource `json:"price_source"`
+	// ZkSync controls signing zkSync Era EIP-712 transactions with
+	// sign-zksync, which are otherwise checked like any transaction.
+	ZkSync *ZkSyncPolicy `json:"zksync"`
+	// Senders binds policies to signing keys, keyed by sender address.
+	// Everything a listed key signs is checked against its own policy
+	// instead of this one; other keys use the rules above.
+	Senders map[string]*Policy `json:"senders"`
+
+	// Digest is the SHA-256 of the policy file, identifying it in logs.
+	Digest string `json:"-"`
+	// signed is set for a policy parsed with ParseSigned.
+	signed bool
+	// loadedContactsSHA256 is the SHA-256 of the address book last read.
+	loadedContactsSHA256 string
+	rules                []compiledRule
+	rego                 *rego.PreparedEvalQuery
+}
+
+// Whitelist maps each allowed recipient address to its limits. In JSON it
+// may be given either as a plain list of addresses, which then fall back to
+// the policy-wide limits, or as an object keyed by address.
+type Whitelist map[string]RecipientPolicy
+
+// RecipientPolicy holds the limits for ether sent to a single recipient.
+// Unset fields fall back to the policy-wide limits.
+type RecipientPolicy struct {
+	MaxAmountWei  *big.Int `json:"max_amount_wei"`
+	DailyLimitWei *big.Int `json:"daily_limit_wei"`
+}
+
+func (w *Whitelist) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*w = make(Whitelist, len(list))
+		for _, addr := range list {
+			(*w)[addr] = RecipientPolicy{}
+		}
+		return nil
+	}
+	var m map[string]RecipientPolicy
+	if err := json.Unmarshal(data, &m); err != nil {
+		return errors.New("whitelist must be a list of addresses or an object of address to limits")
+	}
+	*w = m
+	return nil
+}
+
+// TokenPolicy holds the limits for a single ERC-20 token. MaxAmount is in
+// the token's base units; with Decimals set, the limits may instead be
+// given in whole tokens, such as "2500.5", with MaxPerTx and DailyLimit.
+type TokenPolicy struct {
+	Symbol string `json:"symbol"`
+	// Decimals is checked against the token contract when an rpc endpoint
+	// is available, and transactions are rejected if they differ.
+	Decimals   *int     `json:"decimals"`
+	MaxAmount  *big.Int `json:"max_amount"`
+	MaxPerTx   string   `json:"max_per_tx"`
+	DailyLimit string   `json:"daily_limit"`
+
+	// dailyLimit is DailyLimit in base units.
+	dailyLimit *big.Int
+}
+
+// NFTPolicy restricts transfers out of a single NFT collection.
+type NFTPolicy struct {
+	// TokenIDs, when non-empty, limits transfers to these token IDs.
+	TokenIDs []*big.Int `json:"token_ids"`
+	// MaxAmount caps the quantity of an ERC-1155 transfer. It defaults to 1.
+	MaxAmount *big.Int `json:"max_amount"`
+}
+
+// ContractPolicy lists the functions that may be called on a contract.
+type ContractPolicy struct {
+	// Selectors holds 4-byte selectors ("0xa9059cbb") or function
+	// signatures ("transfer(address,uint256)"). Calls with any other
+	// selector, and calls without one, are rejected.
+	Selectors []string `json:"selectors"`
+	// Arguments constrain the decoded arguments of calls, using the
+	// contract's ABI from the registry in ABIDir.
+	Arguments []ArgumentRule `json:"arguments"`
+}
+
+// Parse decodes the JSON policy document data and prepares it for
+// evaluation, resolving its contacts and compiling its rules.
+func Parse(data []byte) (*Policy, error) {
+	return parse(data, false)
+}
+
+// ParseSigned is Parse for a policy document whose signature has been
+// verified. The files it loads rules from must be pinned in it by SHA-256,
+// so they cannot be changed without signing the policy again.
+func ParseSigned(data []byte) (*Policy, error) {
+	return parse(data, true)
+}
+
+func parse(data []byte, signed bool) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	policy.signed = signed
+	sum := sha256.Sum256(data)
+	policy.Digest = hex.EncodeToString(sum[:])
+	if err := policy.resolveContacts(); err != nil {
+		return nil, err
+	}
+	if err := policy.prepare(); err != nil {
+		return nil, err
+	}
+	for addr, sp := range policy.Senders {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("invalid sender address %q", addr)
+		}
+		if sp == nil {
+			return nil, fmt.Errorf("senders.%s: policy is empty", addr)
+		}
+		if len(sp.Senders) > 0 {
+			return nil, fmt.Errorf("senders.%s: sender policies cannot be nested", addr)
+		}
+		sp.Digest, sp.signed = policy.Digest, signed
+		if sp.ContactsFile == "" {
+			sp.ContactsFile, sp.ContactsSHA256 = policy.ContactsFile, policy.ContactsSHA256
+		}
+		if err := sp.resolveContacts(); err != nil {
+			return nil, fmt.Errorf("senders.%s: %v", addr, err)
+		}
+		if err := sp.prepare(); err != nil {
+			return nil, fmt.Errorf("senders.%s: %v", addr, err)
+		}
+	}
+	return &policy, nil
+}
+
+// prepare validates the whitelist addresses, converts the token limits to
+// base units and compiles the policy's rules and Rego module.
+func (p *Policy) prepare() error {
+	for entry := range p.Whitelist {
+		if IsENSName(entry) {
+			continue
+		}
+		if _, err := ParseChecksummedAddress("whitelist", entry); err != nil {
+			return fmt.Errorf("%v (use all lowercase to skip the checksum)", err)
+		}
+	}
+	if err := p.prepareTokens(); err != nil {
+		return err
+	}
+	if err := p.compileRules(); err != nil {
+		return fmt.Errorf("invalid policy rule: %v", err)
+	}
+	if err := p.prepareRego(); err != nil {
+		return fmt.Errorf("invalid rego policy: %v", err)
+	}
+	if p.PreSignHook != nil {
+		if err := p.PreSignHook.Prepare(); err != nil {
+			return fmt.Errorf("invalid pre_sign_hook: %v", err)
+		}
+	}
+	return nil
+}
+
+// ForSender returns the policy bound to from, or p if from has none.
+func (p *Policy) ForSender(from common.Address) *Policy {
+	for addr, sp := range p.Senders {
+		if strings.EqualFold(addr, from.Hex()) {
+			return sp
+		}
+	}
+	return p
+}
+
+// checkPolicy applies the static transaction rules to tx, recording each
+// check in d.
+func checkPolicy(policy *Policy, tx *types.Transaction, d *Decision) error {
+	if tx.To() == nil {
+		return d.Fail("contract_creation", nil, nil, "contract creation is not allowed")
+	}
+	if err := checkFees(policy.Fees, tx, d); err != nil {
+		return err
+	}
+	to := *tx.To()
+	amount := tx.Value()
+	contract, hasContract := policy.contractPolicy(to)
+	if hasContract {
+		if err := d.Record("contracts", contract.checkSelector(tx.Data())); err != nil {
+			return err
+		}
+		if len(contract.Arguments) > 0 {
+			if err := d.Record("contracts.arguments", policy.checkArguments(to, contract, tx.Data())); err != nil {
+				return err
+			}
+		}
+	}
+	// transferFrom is shared by ERC-20 and ERC-721; on a collection listed
+	// in nfts it is checked as an NFT transfer.
+	_, isCollection := policy.nftPolicy(to)
+	if recipient, tokenAmount, ok := txbuilder.DecodeERC20Transfer(tx.Data()); ok && !(isCollection && txbuilder.IsNFTTransfer(tx.Data())) {
+		if err := policy.recordBlacklist(d, to, recipient); err != nil {
+			return err
+		}
+		return checkTokenTransfer(policy, to, recipient, tokenAmount, amount, d)
+	}
+	if transfer, ok := txbuilder.DecodeNFTTransfer(tx.Data()); ok {
+		if err := policy.recordBlacklist(d, to, transfer.To); err != nil {
+			return err
+		}
+		return checkNFTTransfer(policy, to, transfer, amount, d)
+	}
+	if err := policy.recordBlacklist(d, to); err != nil {
+		return err
+	}
+	// Check whitelist
+	rp, ok := policy.Recipient(to)
+	if !ok {
+		return d.Fail("whitelist", nil, to.Hex(), "recipient not in whitelist")
+	}
+	d.Pass("whitelist", nil, to.Hex())
+	// Check amount
+	maxAmount := policy.MaxAmountWei
+	check := "max_amount_wei"
+	if rp.MaxAmountWei != nil {
+		maxAmount = rp.MaxAmountWei
+		check = "whitelist.max_amount_wei"
+	}
+	if maxAmount == nil {
+		return d.Fail(check, nil, amount, "no max_amount_wei configured")
+	}
+	if amount.Cmp(maxAmount) > 0 {
+		return d.Fail(check, maxAmount, amount, "amount exceeds max policy limit")
+	}
+	d.Pass(check, maxAmount, amount)
+	// Check calldata
+	if len(tx.Data()) > 0 && !hasContract {
+		if !policy.AllowCalldata {
+			return d.Fail("allow_calldata", nil, nil, "transactions with calldata are not allowed")
+		}
+		d.Pass("allow_calldata", nil, nil)
+	}
+	return nil
+}
+
+// CheckChainID rejects chainID if the policy limits signing to other chains.
+func (p *Policy) CheckChainID(chainID *big.Int) error {
+	if len(p.AllowedChainIDs) == 0 {
+		return nil
+	}
+	for _, id := range p.AllowedChainIDs {
+		if id.Cmp(chainID) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("chain ID %s not allowed", chainID)
+}
+
+func (p *Policy) whitelisted(addr common.Address) bool {
+	_, ok := p.Recipient(addr)
+	return ok
+}
+
+// Recipient returns the whitelist entry for addr, if it is listed.
+func (p *Policy) Recipient(addr common.Address) (RecipientPolicy, bool) {
+	for entry, rp := range p.Whitelist {
+		if strings.EqualFold(entry, addr.Hex()) {
+			return rp, true
+		}
+	}
+	return RecipientPolicy{}, false
+}
+
+// TokenPolicy returns the policy for token, if it is listed.
+func (p *Policy) TokenPolicy(token common.Address) (TokenPolicy, bool) {
+	for addr, tp := range p.Tokens {
+		if strings.EqualFold(addr, token.Hex()) {
+			return tp, true
+		}
+	}
+	return TokenPolicy{}, false
+}
+
+// checkTokenTransfer applies the token rules to an ERC-20 transfer of amount
+// to recipient. The recipient whitelist applies to the token recipient, not
+// the token contract.
+func checkTokenTransfer(policy *Policy, token, recipient common.Address, amount, value *big.Int, d *Decision) error {
+	tp, ok := policy.TokenPolicy(token)
+	if !ok {
+		return d.Fail("tokens", nil, token.Hex(), "token not in policy tokens")
+	}
+	d.Pass("tokens", nil, token.Hex())
+	if value.Sign() != 0 {
+		return d.Fail("tokens.value", 0, value, "token transfer must not carry ether value")
+	}
+	if !policy.whitelisted(recipient) {
+		return d.Fail("whitelist", nil, recipient.Hex(), "recipient not in whitelist")
+	}
+	d.Pass("whitelist", nil, recipient.Hex())
+	if tp.MaxAmount == nil {
+		return d.Fail("tokens.max_amount", nil, amount, "no max_amount configured for token")
+	}
+	if amount.Cmp(tp.MaxAmount) > 0 {
+		return d.Fail("tokens.max_amount", tp.MaxAmount, amount, fmt.Sprintf("token amount %s exceeds max policy limit %s", tp.format(amount), tp.format(tp.MaxAmount)))
+	}
+	d.Pass("tokens.max_amount", tp.MaxAmount, amount)
+	return nil
+}
+
+// contractPolicy returns the policy for contract, if it is listed.
+func (p *Policy) contractPolicy(contract common.Address) (ContractPolicy, bool) {
+	for addr, cp := range p.Contracts {
+		if strings.EqualFold(addr, contract.Hex()) {
+			return cp, true
+		}
+	}
+	return ContractPolicy{}, false
+}
+
+// checkSelector rejects calldata whose selector is not listed.
+func (cp ContractPolicy) checkSelector(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("call without a function selector is not allowed")
+	}
+	for _, entry := range cp.Selectors {
+		sel, err := ParseSelector(entry)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(sel, data[:4]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("function selector %s not allowed for contract", hexutil.Encode(data[:4]))
+}
+
+// ParseSelector accepts a hex selector or a function signature.
+func ParseSelector(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "0x") {
+		sel, err := hexutil.Decode(s)
+		if err != nil || len(sel) != 4 {
+			return nil, fmt.Errorf("invalid selector %q", s)
+		}
+		return sel, nil
+	}
+	if !strings.HasSuffix(s, ")") || !strings.Contains(s, "(") || strings.Contains(s, " ") {
+		return nil, fmt.Errorf("invalid function signature %q", s)
+	}
+	return crypto.Keccak256([]byte(s))[:4], nil
+}
+
+// nftPolicy returns the policy for collection, if it is listed.
+func (p *Policy) nftPolicy(collection common.Address) (NFTPolicy, bool) {
+	for addr, np := range p.NFTs {
+		if strings.EqualFold(addr, collection.Hex()) {
+			return np, true
+		}
+	}
+	return NFTPolicy{}, false
+}
+
+// checkNFTTransfer applies the collection rules to a safeTransferFrom call.
+func checkNFTTransfer(policy *Policy, collection common.Address, t *txbuilder.NFTTransfer, value *big.Int, d *Decision) error {
+	np, ok := policy.nftPolicy(collection)
+	if !ok {
+		return d.Fail("nfts", nil, collection.Hex(), "collection not in policy nfts")
+	}
+	d.Pass("nfts", nil, collection.Hex())
+	if value.Sign() != 0 {
+		return d.Fail("nfts.value", 0, value, "NFT transfer must not carry ether value")
+	}
+	if !policy.whitelisted(t.To) {
+		return d.Fail("whitelist", nil, t.To.Hex(), "recipient not in whitelist")
+	}
+	d.Pass("whitelist", nil, t.To.Hex())
+	if len(np.TokenIDs) > 0 {
+		allowed := false
+		for _, id := range np.TokenIDs {
+			if id.Cmp(t.TokenID) == 0 {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return d.Fail("nfts.token_ids", np.TokenIDs, t.TokenID, "token ID not allowed for collection")
+		}
+		d.Pass("nfts.token_ids", np.TokenIDs, t.TokenID)
+	}
+	maxAmount := np.MaxAmount
+	if maxAmount == nil {
+		maxAmount = big.NewInt(1)
+	}
+	if t.Amount.Cmp(maxAmount) > 0 {
+		return d.Fail("nfts.max_amount", maxAmount, t.Amount, "NFT amount exceeds max policy limit")
+	}
+	d.Pass("nfts.max_amount", maxAmount, t.Amount)
+	return nil
+}
+
+// checkTransferCalldata rejects calls to the token transfer functions whose
+// arguments do not decode, rather than checking them as plain contract
+// calls.
+func checkTransferCalldata(tx *types.Transaction, d *Decision) error {
+	data := tx.Data()
+	if tx.To() == nil {
+		return nil
+	}
+	if txbuilder.IsERC20Transfer(data) {
+		if _, _, ok := txbuilder.DecodeERC20Transfer(data); !ok {
+			return d.Fail("transfer_calldata", nil, hexutil.Encode(data[:4]), "malformed token transfer calldata")
+		}
+	}
+	if txbuilder.IsNFTTransfer(data) {
+		if _, ok := txbuilder.DecodeNFTTransfer(data); !ok {
+			return d.Fail("transfer_calldata", nil, hexutil.Encode(data[:4]), "malformed or unsupported NFT transfer calldata")
+		}
+	}
+	return nil
+}
+
+// Evaluate runs the stateless policy checks for tx, sent by from on chainID
+// at now, recording each in d. Approvals and cumulative limits are left to
+// the engine.
+func (p *Policy) Evaluate(ctx context.Context, tx *types.Transaction, from common.Address, chainID *big.Int, now time.Time, overrideReason string, d *Decision) error {
+	if len(p.AllowedChainIDs) > 0 {
+		if err := p.CheckChainID(chainID); err != nil {
+			return d.Fail("allowed_chain_ids", p.AllowedChainIDs, chainID, err.Error())
+		}
+		d.Pass("allowed_chain_ids", p.AllowedChainIDs, chainID)
+	}
+	if err := p.checkAuthorizations(tx, d); err != nil {
+		return err
+	}
+	if err := p.checkPoisoning(tx, d); err != nil {
+		return err
+	}
+	if err := p.checkAllowance(tx, d); err != nil {
+		return err
+	}
+	if err := checkTransferCalldata(tx, d); err != nil {
+		return err
+	}
+	var err error
+	if p.rego != nil {
+		err = p.checkRego(ctx, tx, from, chainID, now, d)
+	} else {
+		err = checkPolicy(p, tx, d)
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.checkRules(tx, from, chainID, now, d); err != nil {
+		return err
+	}
+	if p.SigningWindow != nil {
+		if err := d.Record("signing_window", p.CheckSigningWindow(now, overrideReason)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
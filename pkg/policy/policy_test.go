@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testToken     = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testRecipient = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testSender    = common.HexToAddress("0x3000000000000000000000000000000000000003")
+)
+
+// evaluate parses doc and runs Evaluate on a call to to with data,
+// returning the first failed check, or "" if the call is allowed.
+func evaluate(t *testing.T, doc string, to common.Address, data []byte) string {
+	t.Helper()
+	p, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		To:        &to,
+		Gas:       100000,
+		GasFeeCap: big.NewInt(1),
+		GasTipCap: big.NewInt(1),
+		Value:     new(big.Int),
+		Data:      data,
+	})
+	var d Decision
+	err = p.Evaluate(context.Background(), tx, testSender, big.NewInt(1), time.Now(), "", &d)
+	if err == nil {
+		return ""
+	}
+	if check := d.FailedCheck(); check != "" {
+		return check
+	}
+	t.Fatalf("Evaluate failed without a failed check: %v", err)
+	return ""
+}
+
+func TestEvaluateTokenTransfer(t *testing.T) {
+	// The token contract itself is whitelisted for plain calls, so a
+	// transfer that is not recognised would slip through as one.
+	doc := `{
+		"max_amount_wei": 0,
+		"allow_calldata": true,
+		"whitelist": ["` + testToken.Hex() + `", "` + testRecipient.Hex() + `"],
+		"tokens": {"` + testToken.Hex() + `": {"max_amount": 100}}
+	}`
+	transfer := func(amount int64) []byte {
+		return txbuilder.EncodeERC20Transfer(testRecipient, big.NewInt(amount))
+	}
+	dirty := transfer(1000)
+	dirty[4] = 0xff
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"within limit", transfer(100), ""},
+		{"over limit", transfer(1000), "tokens.max_amount"},
+		{"padded over limit", append(transfer(1000), 0x00), "tokens.max_amount"},
+		{"dirty address over limit", dirty, "tokens.max_amount"},
+		{"truncated", transfer(1000)[:40], "transfer_calldata"},
+		{"selector only", transfer(1000)[:4], "transfer_calldata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluate(t, doc, testToken, tt.data); got != tt.want {
+				t.Errorf("failed check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateNFTTransfer(t *testing.T) {
+	doc := `{
+		"max_amount_wei": 0,
+		"allow_calldata": true,
+		"whitelist": ["` + testToken.Hex() + `", "` + testRecipient.Hex() + `"],
+		"nfts": {"` + testToken.Hex() + `": {"token_ids": [7]}}
+	}`
+	erc721 := func(id int64) []byte {
+		return txbuilder.EncodeERC721Transfer(testSender, testRecipient, big.NewInt(id))
+	}
+	transferFrom := func(id int64) []byte {
+		return append([]byte{0x23, 0xb8, 0x72, 0xdd}, erc721(id)[4:]...)
+	}
+	batch := append([]byte{0x2e, 0xb2, 0xc2, 0xd6}, txbuilder.EncodeERC1155Transfer(testSender, testRecipient, big.NewInt(7), big.NewInt(1))[4:]...)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"allowed token", erc721(7), ""},
+		{"other token", erc721(8), "nfts.token_ids"},
+		{"padded other token", append(erc721(8), 0x00), "nfts.token_ids"},
+		{"transferFrom allowed token", transferFrom(7), ""},
+		{"transferFrom other token", transferFrom(8), "nfts.token_ids"},
+		{"truncated", erc721(8)[:90], "transfer_calldata"},
+		{"batch", batch, "transfer_calldata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluate(t, doc, testToken, tt.data); got != tt.want {
+				t.Errorf("failed check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"time"
+)
+
+const (
+	DefaultCoinGeckoURL  = "https://api.coingecko.com/api/v3/simple/price"
+	defaultPriceMaxAge   = 60 // minutes
+	PriceCacheTTL        = time.Minute
+	PriceRequestTimeout  = 10 * time.Second
+	NativeCurrencyScale  = 18
+	DefaultNativeSymbol  = "ETH"
+	PriceSourceChainlink = "chainlink"
+	PriceSourceCoinGecko = "coingecko"
+)
+
+// PriceSource configures where USD prices for the USD policy limits come
+// from. Chainlink feeds are read over RPC; CoinGecko is used when no feed
+// is configured for a currency or the feed fails. Prices older than
+// MaxAgeMinutes are rejected. Without a fresh price, transactions are
+// rejected unless FailOpen is set.
+type PriceSource struct {
+	// RPC is the endpoint Chainlink feeds are read from, which need not be
+	// on the chain being signed for. Without it only CoinGecko is used.
+	RPC string `json:"rpc"`
+	// ChainlinkFeeds maps native currency symbols, as in the chain
+	// registry, to their USD aggregator on the RPC chain. It defaults to
+	// the ETH/USD feed on Ethereum mainnet.
+	ChainlinkFeeds map[string]string `json:"chainlink_feeds"`
+	// CoinGeckoIDs maps native currency symbols to CoinGecko coin IDs.
+	// CoinGeckoURL overrides the simple price endpoint; set it to "off"
+	// to disable the fallback.
+	CoinGeckoIDs  map[string]string `json:"coingecko_ids"`
+	CoinGeckoURL  string            `json:"coingecko_url"`
+	MaxAgeMinutes int               `json:"max_age_minutes"`
+	FailOpen      bool              `json:"fail_open"`
+}
+
+func (s *PriceSource) MaxAge() time.Duration {
+	if s == nil || s.MaxAgeMinutes <= 0 {
+		return defaultPriceMaxAge * time.Minute
+	}
+	return time.Duration(s.MaxAgeMinutes) * time.Minute
+}
@@ -0,0 +1,22 @@
+package policy
+
+import (
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxRecipient returns who tx pays: the recipient of a token or NFT
+// transfer, or else the address tx is sent to.
+func TxRecipient(tx *types.Transaction) (common.Address, bool) {
+	if tx.To() == nil {
+		return common.Address{}, false
+	}
+	if to, _, ok := txbuilder.DecodeERC20Transfer(tx.Data()); ok {
+		return to, true
+	}
+	if t, ok := txbuilder.DecodeNFTTransfer(tx.Data()); ok {
+		return t.To, true
+	}
+	return *tx.To(), true
+}
@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/open-policy-agent/opa/v1/loader"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+)
+
+// defaultRegoQuery is evaluated when the policy does not name a query.
+const defaultRegoQuery = "data.signer.allow"
+
+// RegoPolicy delegates the transaction checks to an embedded Open Policy
+// Agent evaluation of a Rego policy.
+type RegoPolicy struct {
+	// Path is a .rego file or a directory (bundle) of them.
+	Path string `json:"path"`
+	// Query must evaluate to true to allow the transaction. It defaults to
+	// data.signer.allow.
+	Query string `json:"query"`
+	// SHA256, when set, is the hex SHA-256 of the modules and data loaded
+	// from Path, and the policy fails to load if they differ. A signed
+	// policy requires it, so the Rego policy cannot be changed without
+	// signing the policy again.
+	SHA256 string `json:"sha256"`
+
+	// loadedSHA256 is the SHA-256 of what was loaded from Path.
+	loadedSHA256 string
+}
+
+// LoadedSHA256 returns the SHA-256 of the modules and data loaded from Path,
+// the value to pin in SHA256.
+func (r *RegoPolicy) LoadedSHA256() string {
+	return r.loadedSHA256
+}
+
+// prepareRego loads and compiles the Rego policy, if any. The modules are
+// checked against the pinned digest and compiled from the same copy, so
+// they cannot change in between.
+func (p *Policy) prepareRego() error {
+	if p.Rego == nil {
+		return nil
+	}
+	if p.Rego.Path == "" {
+		return errors.New("rego path is required")
+	}
+	query := p.Rego.Query
+	if query == "" {
+		query = defaultRegoQuery
+	}
+	loaded, err := loader.NewFileLoader().WithProcessAnnotation(true).All([]string{p.Rego.Path})
+	if err != nil {
+		return err
+	}
+	if p.Rego.loadedSHA256, err = regoDigest(p.Rego.Path, loaded); err != nil {
+		return err
+	}
+	switch {
+	case p.Rego.SHA256 != "":
+		if !strings.EqualFold(p.Rego.SHA256, p.Rego.loadedSHA256) {
+			return fmt.Errorf("%s has sha256 %s, but the policy pins %s", p.Rego.Path, p.Rego.loadedSHA256, p.Rego.SHA256)
+		}
+	case p.signed:
+		return fmt.Errorf("a signed policy must pin its rego modules: set rego.sha256 to %s", p.Rego.loadedSHA256)
+	}
+	opts := []func(*rego.Rego){rego.Query(query), rego.Store(inmem.NewFromObject(loaded.Documents))}
+	for _, module := range loaded.Modules {
+		opts = append(opts, rego.ParsedModule(module.Parsed))
+	}
+	prepared, err := rego.New(opts...).PrepareForEval(context.Background())
+	if err != nil {
+		return err
+	}
+	p.rego = &prepared
+	return nil
+}
+
+// regoDigest hashes the modules and data loaded from path, by their names
+// relative to path, so the digest does not depend on where they live.
+func regoDigest(path string, loaded *loader.Result) (string, error) {
+	names := make([]string, 0, len(loaded.Modules))
+	files := make(map[string][]byte, len(loaded.Modules))
+	for _, module := range loaded.Modules {
+		name, err := filepath.Rel(path, module.Name)
+		if err != nil {
+			return "", err
+		}
+		name = filepath.ToSlash(name)
+		names = append(names, name)
+		files[name] = module.Raw
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "module %q %d\n", name, len(files[name]))
+		h.Write(files[name])
+	}
+	data, err := json.Marshal(loaded.Documents)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "data %d\n", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RegoInput is the input document a Rego policy sees. Amounts are decimal
+// strings so they survive JSON without losing precision.
+func RegoInput(tx *types.Transaction, from common.Address, chainID *big.Int, now time.Time) map[string]any {
+	input := map[string]any{
+		"from":      strings.ToLower(from.Hex()),
+		"to":        strings.ToLower(tx.To().Hex()),
+		"value":     tx.Value().String(),
+		"data":      hexutil.Encode(tx.Data()),
+		"chain_id":  chainID.String(),
+		"nonce":     tx.Nonce(),
+		"gas":       tx.Gas(),
+		"type":      tx.Type(),
+		"gas_price": tx.GasPrice().String(),
+		"max_fee":   tx.GasFeeCap().String(),
+		"tip":       tx.GasTipCap().String(),
+		"time":      now.UTC().Format(time.RFC3339),
+	}
+	if len(tx.Data()) >= 4 {
+		input["selector"] = hexutil.Encode(tx.Data()[:4])
+	}
+	if recipient, amount, ok := txbuilder.DecodeERC20Transfer(tx.Data()); ok {
+		input["erc20"] = map[string]any{
+			"recipient": strings.ToLower(recipient.Hex()),
+			"amount":    amount.String(),
+		}
+	}
+	if t, ok := txbuilder.DecodeNFTTransfer(tx.Data()); ok {
+		input["nft"] = map[string]any{
+			"from":     strings.ToLower(t.From.Hex()),
+			"to":       strings.ToLower(t.To.Hex()),
+			"token_id": t.TokenID.String(),
+			"amount":   t.Amount.String(),
+		}
+	}
+	return input
+}
+
+// checkRego evaluates the Rego policy in place of the static transaction
+// checks. Contract creation, the blacklist and the fee caps are still
+// enforced.
+func (p *Policy) checkRego(ctx context.Context, tx *types.Transaction, from common.Address, chainID *big.Int, now time.Time, d *Decision) error {
+	if tx.To() == nil {
+		return d.Fail("contract_creation", nil, nil, "contract creation is not allowed")
+	}
+	if err := checkFees(p.Fees, tx, d); err != nil {
+		return err
+	}
+	parties := []common.Address{*tx.To()}
+	if recipient, _, ok := txbuilder.DecodeERC20Transfer(tx.Data()); ok {
+		parties = append(parties, recipient)
+	}
+	if t, ok := txbuilder.DecodeNFTTransfer(tx.Data()); ok {
+		parties = append(parties, t.To)
+	}
+	if err := p.recordBlacklist(d, parties...); err != nil {
+		return err
+	}
+	rs, err := p.rego.Eval(ctx, rego.EvalInput(RegoInput(tx, from, chainID, now)))
+	if err != nil {
+		return d.Record("rego", fmt.Errorf("rego evaluation failed: %v", err))
+	}
+	if !rs.Allowed() {
+		return d.Fail("rego", nil, nil, "rego policy denied the transaction")
+	}
+	d.Pass("rego", nil, nil)
+	return nil
+}
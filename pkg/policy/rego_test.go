@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const testRegoModule = `package signer
+
+allow if {
+	input.to == data.treasury
+	to_number(input.value) <= 100
+}
+`
+
+func TestRegoPinning(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("allow.rego", testRegoModule)
+	write("data.json", `{"treasury": "`+strings.ToLower(testRecipient.Hex())+`"}`)
+	doc := func(pin string) []byte {
+		return []byte(`{"rego": {"path": ` + quote(dir) + `, "sha256": "` + pin + `"}}`)
+	}
+
+	p, err := Parse(doc(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := p.Rego.LoadedSHA256()
+	if _, err := ParseSigned(doc("")); err == nil || !strings.Contains(err.Error(), digest) {
+		t.Fatalf("ParseSigned without a pin = %v, want an error naming %s", err, digest)
+	}
+	p, err = ParseSigned(doc(digest))
+	if err != nil {
+		t.Fatalf("pinned rego policy refused: %v", err)
+	}
+	for _, tt := range []struct {
+		value int64
+		want  string
+	}{{100, ""}, {101, "rego"}} {
+		tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &testRecipient, Gas: 21000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: big.NewInt(tt.value)})
+		var d Decision
+		p.Evaluate(t.Context(), tx, testSender, big.NewInt(1), time.Now(), "", &d)
+		if got := d.FailedCheck(); got != tt.want {
+			t.Errorf("value %d: failed check = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	// Loosening the module, changing its data or adding a module must all
+	// be caught.
+	for _, tamper := range []func(){
+		func() { write("allow.rego", strings.Replace(testRegoModule, "<= 100", "<= 1000000", 1)) },
+		func() { write("data.json", `{"treasury": "0x0000000000000000000000000000000000000bad"}`) },
+		func() { write("extra.rego", "package signer\n\nallow if true\n") },
+	} {
+		tamper()
+		if _, err := ParseSigned(doc(digest)); err == nil {
+			t.Fatal("tampered rego policy accepted")
+		}
+		write("allow.rego", testRegoModule)
+		write("data.json", `{"treasury": "`+strings.ToLower(testRecipient.Hex())+`"}`)
+		os.Remove(filepath.Join(dir, "extra.rego"))
+		if _, err := ParseSigned(doc(digest)); err != nil {
+			t.Fatalf("restored rego policy refused: %v", err)
+		}
+	}
+
+	// The digest does not depend on where the modules live.
+	moved := filepath.Join(t.TempDir(), "policy")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	dir = moved
+	if _, err := ParseSigned(doc(digest)); err != nil {
+		t.Fatalf("moved rego policy refused: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package policy
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// Rule is a CEL expression that must evaluate to true for a
+// transaction to be signed. Expressions see:
+//
+//	to, from   lowercase 0x-prefixed addresses
+//	value      the value in wei, as a wei amount
+//	data       the calldata as bytes
+//	selector   the 0x-prefixed 4-byte selector, or "" without calldata
+//	chain_id   the chain ID as an int
+//	gas, nonce ints
+//	now        the signing time as a timestamp
+//
+// and every named list in the policy's lists as a list of strings, with
+// addresses lowercased. Wei amounts compare exactly, however large, with
+// each other and with wei(amount), where amount is an int or a string such
+// as "1000000000000000000", "1.5eth" or "2500gwei":
+//
+//	value <= wei("1eth")
+type Rule struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// compiledRule is a Rule ready to evaluate.
+type compiledRule struct {
+	name    string
+	expr    string
+	program cel.Program
+}
+
+// compileRules type-checks the policy rules against the transaction
+// variables and its lists. Each rule must produce a bool.
+func (p *Policy) compileRules() error {
+	if len(p.Rules) == 0 {
+		return nil
+	}
+	opts := []cel.EnvOption{
+		cel.Variable("to", cel.StringType),
+		cel.Variable("from", cel.StringType),
+		cel.Variable("value", weiType),
+		cel.Variable("data", cel.BytesType),
+		cel.Variable("selector", cel.StringType),
+		cel.Variable("chain_id", cel.IntType),
+		cel.Variable("gas", cel.IntType),
+		cel.Variable("nonce", cel.IntType),
+		cel.Variable("now", cel.TimestampType),
+	}
+	for name := range p.Lists {
+		opts = append(opts, cel.Variable(name, cel.ListType(cel.StringType)))
+	}
+	opts = append(opts, weiFunctions...)
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return fmt.Errorf("invalid policy lists: %v", err)
+	}
+	p.rules = nil
+	for i, r := range p.Rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		ast, iss := env.Compile(r.Expr)
+		if iss.Err() != nil {
+			return fmt.Errorf("%s: %v", name, iss.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return fmt.Errorf("%s: expression must evaluate to a bool", name)
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		p.rules = append(p.rules, compiledRule{name: name, expr: r.Expr, program: prg})
+	}
+	return nil
+}
+
+// checkRules evaluates the policy rules for tx, sent by from on chainID at
+// now, recording each in d. An expression that fails to evaluate rejects the
+// transaction.
+func (p *Policy) checkRules(tx *types.Transaction, from common.Address, chainID *big.Int, now time.Time, d *Decision) error {
+	if len(p.rules) == 0 {
+		return nil
+	}
+	selector := ""
+	if len(tx.Data()) >= 4 {
+		selector = hexutil.Encode(tx.Data()[:4])
+	}
+	vars := map[string]any{
+		"to":       strings.ToLower(tx.To().Hex()),
+		"from":     strings.ToLower(from.Hex()),
+		"value":    weiValue{tx.Value()},
+		"data":     tx.Data(),
+		"selector": selector,
+		"chain_id": chainID.Int64(),
+		"gas":      int64(tx.Gas()),
+		"nonce":    int64(tx.Nonce()),
+		"now":      now,
+	}
+	for name, list := range p.Lists {
+		entries := make([]string, len(list))
+		for i, entry := range list {
+			if common.IsHexAddress(entry) {
+				entry = strings.ToLower(entry)
+			}
+			entries[i] = entry
+		}
+		vars[name] = entries
+	}
+	for _, r := range p.rules {
+		check := "rules." + r.name
+		out, _, err := r.program.Eval(vars)
+		if err != nil {
+			return d.Record(check, fmt.Errorf("%s: %v", r.name, err))
+		}
+		if ok, _ := out.Value().(bool); !ok {
+			return d.Fail(check, r.expr, nil, fmt.Sprintf("%s: rule not satisfied", r.name))
+		}
+		d.Pass(check, r.expr, nil)
+	}
+	return nil
+}
+
+// weiType is the CEL type of amounts of wei. Unlike CEL's numbers it holds
+// any amount a transaction can carry exactly.
+var weiType = cel.OpaqueType("wei")
+
+// weiRuntimeType is weiType as seen by the interpreter, where wei amounts
+// can be ordered.
+type weiRuntimeType struct{}
+
+func (weiRuntimeType) HasTrait(trait int) bool { return trait == traits.ComparerType }
+func (weiRuntimeType) TypeName() string        { return weiType.TypeName() }
+
+// weiValue is a CEL value of weiType.
+type weiValue struct{ *big.Int }
+
+func (w weiValue) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	if reflect.TypeOf(w.Int).AssignableTo(typeDesc) {
+		return w.Int, nil
+	}
+	return nil, fmt.Errorf("unsupported conversion of wei to %v", typeDesc)
+}
+
+func (w weiValue) ConvertToType(typeVal ref.Type) ref.Val {
+	switch typeVal.TypeName() {
+	case weiType.TypeName():
+		return w
+	case celtypes.StringType.TypeName():
+		return celtypes.String(w.String())
+	case celtypes.TypeType.TypeName():
+		return weiType
+	}
+	return celtypes.NewErr("unsupported conversion of wei to %s", typeVal.TypeName())
+}
+
+func (w weiValue) Equal(other ref.Val) ref.Val {
+	o, ok := other.(weiValue)
+	return celtypes.Bool(ok && w.Cmp(o.Int) == 0)
+}
+
+func (w weiValue) Compare(other ref.Val) ref.Val {
+	o, ok := other.(weiValue)
+	if !ok {
+		return celtypes.MaybeNoSuchOverloadErr(other)
+	}
+	return celtypes.Int(w.Cmp(o.Int))
+}
+
+func (w weiValue) Type() ref.Type { return weiRuntimeType{} }
+func (w weiValue) Value() any     { return w.Int }
+
+// weiFunctions declares wei() and the ordering of wei amounts, which the
+// standard comparison operators evaluate through weiValue.Compare.
+var weiFunctions = []cel.EnvOption{
+	cel.Function("wei",
+		cel.Overload("wei_string", []*cel.Type{cel.StringType}, weiType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+			wei, err := txbuilder.ParseEther("wei amount", string(v.(celtypes.String)))
+			if err != nil {
+				return celtypes.WrapErr(err)
+			}
+			return weiValue{wei}
+		})),
+		cel.Overload("wei_int", []*cel.Type{cel.IntType}, weiType, cel.UnaryBinding(func(v ref.Val) ref.Val {
+			if v.(celtypes.Int) < 0 {
+				return celtypes.NewErr("negative wei amount %d", v)
+			}
+			return weiValue{big.NewInt(int64(v.(celtypes.Int)))}
+		})),
+	),
+	cel.Function(operators.Less, cel.Overload("less_wei", []*cel.Type{weiType, weiType}, cel.BoolType)),
+	cel.Function(operators.LessEquals, cel.Overload("less_equals_wei", []*cel.Type{weiType, weiType}, cel.BoolType)),
+	cel.Function(operators.Greater, cel.Overload("greater_wei", []*cel.Type{weiType, weiType}, cel.BoolType)),
+	cel.Function(operators.GreaterEquals, cel.Overload("greater_equals_wei", []*cel.Type{weiType, weiType}, cel.BoolType)),
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRules(t *testing.T) {
+	oneEther := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	plus := func(x *big.Int, n int64) *big.Int { return new(big.Int).Add(x, big.NewInt(n)) }
+	doc := `{
+		"max_amount_wei": 10000000000000000000000000,
+		"allow_calldata": true,
+		"whitelist": ["` + testRecipient.Hex() + `"],
+		"lists": {"treasury": ["` + testRecipient.Hex() + `"]},
+		"rules": [
+			{"name": "cap", "expr": "value <= wei(\"1eth\") || to in treasury && value <= wei(\"1000000eth\")"},
+			{"name": "no_calls_with_value", "expr": "selector == \"\" || value == wei(0)"},
+			{"name": "low_nonce", "expr": "nonce < 1000"}
+		]
+	}`
+	p, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without the treasury list the cap is 1 ether, exactly.
+	strict, err := Parse([]byte(strings.Replace(doc, `"treasury": ["`+testRecipient.Hex()+`"]`, `"treasury": []`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		policy *Policy
+		value  *big.Int
+		data   []byte
+		nonce  uint64
+		want   string
+	}{
+		{"at cap", strict, oneEther, nil, 0, ""},
+		// A double rounds 1 ether + 1 wei to 1 ether.
+		{"one wei over cap", strict, plus(oneEther, 1), nil, 0, "rules.cap"},
+		{"treasury", p, new(big.Int).Mul(oneEther, big.NewInt(500000)), nil, 0, ""},
+		{"treasury over 2^64 wei", p, plus(new(big.Int).Lsh(big.NewInt(1), 70), 1), nil, 0, ""},
+		{"over treasury cap", p, plus(new(big.Int).Mul(oneEther, big.NewInt(1000000)), 1), nil, 0, "rules.cap"},
+		{"call without value", p, new(big.Int), []byte{1, 2, 3, 4}, 0, ""},
+		{"call with value", p, big.NewInt(1), []byte{1, 2, 3, 4}, 0, "rules.no_calls_with_value"},
+		{"high nonce", p, new(big.Int), nil, 1000, "rules.low_nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := types.NewTx(&types.DynamicFeeTx{
+				ChainID:   big.NewInt(1),
+				Nonce:     tt.nonce,
+				To:        &testRecipient,
+				Gas:       100000,
+				GasFeeCap: big.NewInt(1),
+				GasTipCap: big.NewInt(1),
+				Value:     tt.value,
+				Data:      tt.data,
+			})
+			var d Decision
+			err := tt.policy.Evaluate(t.Context(), tx, testSender, big.NewInt(1), time.Now(), "", &d)
+			if got := d.FailedCheck(); got != tt.want || (err != nil) != (tt.want != "") {
+				t.Errorf("failed check = %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesInvalid(t *testing.T) {
+	for _, expr := range []string{
+		// value is not a number, so a lossy comparison does not compile.
+		`value <= 1e18`,
+		`value`,
+		`unknown_list.size() > 0`,
+	} {
+		doc := `{"rules": [{"expr": ` + quote(expr) + `}]}`
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("rule %s compiled", expr)
+		}
+	}
+
+	// An amount that does not parse fails closed when evaluated.
+	p, err := Parse([]byte(`{"max_amount_wei": 1, "whitelist": ["` + testRecipient.Hex() + `"], "rules": [{"name": "bad", "expr": "value <= wei(\"1.5\")"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &testRecipient, Gas: 21000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: new(big.Int)})
+	var d Decision
+	if err := p.Evaluate(t.Context(), tx, testSender, big.NewInt(1), time.Now(), "", &d); err == nil || d.FailedCheck() != "rules.bad" {
+		t.Errorf("Evaluate = %v, failed check %q; want rules.bad", err, d.FailedCheck())
+	}
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SafePolicy controls signing Safe transactions. Safes lists the Safes the
+// key may sign for. The call a Safe transaction makes is also checked
+// against the transaction policy, as if the Safe sent it. Delegate calls,
+// which run the target's code in the Safe's context, and gas refunds paid
+// from the Safe are rejected unless allowed.
+type SafePolicy struct {
+	Safes             []string `json:"safes"`
+	AllowDelegateCall bool     `json:"allow_delegate_call"`
+	AllowRefunds      bool     `json:"allow_refunds"`
+}
+
+// CheckSafeTx applies the Safe policy to tx on safe, and the transaction
+// policy to the call it makes, recording the checks in d.
+func (p *Policy) CheckSafeTx(ctx context.Context, safe common.Address, tx *txbuilder.SafeTx, chainID *big.Int, now time.Time, overrideReason string, d *Decision) error {
+	sp := p.Safe
+	if sp == nil {
+		return d.Fail("safe", nil, safe.Hex(), "safe transaction signing is not allowed")
+	}
+	if !containsAddress(sp.Safes, safe) {
+		return d.Fail("safe.safes", sp.Safes, safe.Hex(), "safe not in safe.safes")
+	}
+	d.Pass("safe.safes", sp.Safes, safe.Hex())
+	switch tx.Operation {
+	case txbuilder.SafeOperationCall:
+	case txbuilder.SafeOperationDelegateCall:
+		if !sp.AllowDelegateCall {
+			return d.Fail("safe.allow_delegate_call", false, tx.Operation, "delegate calls are not allowed")
+		}
+		d.Pass("safe.allow_delegate_call", true, tx.Operation)
+	default:
+		return d.Fail("safe.operation", nil, tx.Operation, fmt.Sprintf("unknown operation %d", tx.Operation))
+	}
+	if tx.GasPrice.Sign() > 0 {
+		if !sp.AllowRefunds {
+			return d.Fail("safe.allow_refunds", false, tx.GasPrice, "gas refunds from the safe are not allowed")
+		}
+		d.Pass("safe.allow_refunds", true, tx.GasPrice)
+	}
+	call := types.NewTx(&types.DynamicFeeTx{
+		ChainID: chainID,
+		To:      &tx.To,
+		Value:   tx.Value,
+		Data:    tx.Data,
+	})
+	return p.Evaluate(ctx, call, safe, chainID, now, overrideReason, d)
+}
@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// CheckDelegate rejects an authorization the policy does not allow:
+// delegating to a contract outside allowed_delegates, or, unless
+// allow_any_chain_authorizations is set, one valid on every chain.
+// Clearing a delegation, by authorizing the zero address, is always
+// allowed.
+func (p *Policy) CheckDelegate(auth types.SetCodeAuthorization) error {
+	if auth.ChainID.IsZero() && !p.AllowAnyChainAuthorizations {
+		return errors.New("authorizations valid on every chain are not allowed")
+	}
+	if auth.Address == (common.Address{}) {
+		return nil
+	}
+	for _, addr := range p.AllowedDelegates {
+		if strings.EqualFold(addr, auth.Address.Hex()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("delegate %s not in allowed_delegates", auth.Address.Hex())
+}
+
+// checkAuthorizations applies CheckDelegate to every authorization of a
+// set-code transaction, recording each in d.
+func (p *Policy) checkAuthorizations(tx *types.Transaction, d *Decision) error {
+	for _, auth := range tx.SetCodeAuthorizations() {
+		if err := p.CheckDelegate(auth); err != nil {
+			return d.Fail("allowed_delegates", p.AllowedDelegates, auth.Address.Hex(), err.Error())
+		}
+		d.Pass("allowed_delegates", p.AllowedDelegates, auth.Address.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SigningWindow restricts signing to certain days and hours.
+type SigningWindow struct {
+	// Days lists the allowed weekdays as three-letter names ("mon" to
+	// "sun"). Empty allows every day.
+	Days []string `json:"days"`
+	// StartHour and EndHour bound the allowed hours as [start, end). Both
+	// zero allows every hour.
+	StartHour int `json:"start_hour"`
+	EndHour   int `json:"end_hour"`
+	// Timezone is the IANA zone the window is evaluated in. It defaults to
+	// UTC.
+	Timezone string `json:"timezone"`
+}
+
+// Contains reports whether t falls inside the window. A malformed window is
+// an error so that it fails closed.
+func (w *SigningWindow) Contains(t time.Time) (bool, error) {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false, fmt.Errorf("invalid signing window timezone: %v", err)
+		}
+	}
+	t = t.In(loc)
+	if len(w.Days) > 0 {
+		day := strings.ToLower(t.Weekday().String()[:3])
+		allowed := false
+		for _, d := range w.Days {
+			d = strings.ToLower(d)
+			if !validWeekday(d) {
+				return false, fmt.Errorf("invalid signing window day %q", d)
+			}
+			if d == day {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false, nil
+		}
+	}
+	if w.StartHour == 0 && w.EndHour == 0 {
+		return true, nil
+	}
+	if w.StartHour < 0 || w.EndHour > 24 || w.StartHour >= w.EndHour {
+		return false, errors.New("invalid signing window hours")
+	}
+	return t.Hour() >= w.StartHour && t.Hour() < w.EndHour, nil
+}
+
+func validWeekday(d string) bool {
+	switch d {
+	case "mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return true
+	}
+	return false
+}
+
+// CheckSigningWindow rejects signing at now if it falls outside the policy
+// signing window, unless overrideReason is given, in which case the override
+// is logged and signing proceeds.
+func (p *Policy) CheckSigningWindow(now time.Time, overrideReason string) error {
+	if p.SigningWindow == nil {
+		return nil
+	}
+	ok, err := p.SigningWindow.Contains(now)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	if overrideReason == "" {
+		return errors.New("outside the allowed signing window")
+	}
+	slog.Warn("signing window overridden", "at", now.UTC().Format(time.RFC3339), "reason", overrideReason)
+	return nil
+}
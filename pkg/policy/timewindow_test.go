@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSigningWindow(t *testing.T) {
+	// 2026-01-05 is a Monday.
+	monday := func(hour, min int) time.Time { return time.Date(2026, 1, 5, hour, min, 0, 0, time.UTC) }
+	weekdays := &SigningWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartHour: 9, EndHour: 17}
+	tests := []struct {
+		name     string
+		window   *SigningWindow
+		now      time.Time
+		override string
+		wantErr  bool
+	}{
+		{"no window", nil, monday(3, 0), "", false},
+		{"at start hour", weekdays, monday(9, 0), "", false},
+		{"last minute", weekdays, monday(16, 59), "", false},
+		{"at end hour", weekdays, monday(17, 0), "", true},
+		{"before start", weekdays, monday(8, 59), "", true},
+		{"weekend", weekdays, monday(12, 0).AddDate(0, 0, -1), "", true},
+		{"weekend overridden", weekdays, monday(12, 0).AddDate(0, 0, -1), "incident", false},
+		{"days only", &SigningWindow{Days: []string{"Mon"}}, monday(23, 59), "", false},
+		{"whole day", &SigningWindow{StartHour: 0, EndHour: 24}, monday(23, 59), "", false},
+		// 08:00 UTC is 17:00 in Tokyo, and 23:00 UTC on Sunday is Monday there.
+		{"timezone end hour", &SigningWindow{StartHour: 9, EndHour: 17, Timezone: "Asia/Tokyo"}, monday(8, 0), "", true},
+		{"timezone in hours", &SigningWindow{StartHour: 9, EndHour: 17, Timezone: "Asia/Tokyo"}, monday(7, 59), "", false},
+		{"timezone day", &SigningWindow{Days: []string{"mon"}, Timezone: "Asia/Tokyo"}, monday(23, 0).AddDate(0, 0, -1), "", false},
+		// Malformed windows fail closed, even with an override.
+		{"overnight hours", &SigningWindow{StartHour: 22, EndHour: 6}, monday(23, 0), "incident", true},
+		{"end past midnight", &SigningWindow{StartHour: 9, EndHour: 25}, monday(10, 0), "", true},
+		{"unknown day", &SigningWindow{Days: []string{"monday"}}, monday(10, 0), "incident", true},
+		{"unknown timezone", &SigningWindow{Timezone: "Mars/Olympus"}, monday(10, 0), "incident", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Policy{SigningWindow: tt.window}
+			err := p.CheckSigningWindow(tt.now, tt.override)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckSigningWindow(%s) = %v, want error %v", tt.now.Format(time.RFC3339), err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MaxTokenDecimals is the most decimals a uint256 amount can meaningfully
+// carry.
+const MaxTokenDecimals = 77
+
+// prepareTokens converts the whole-token limits of the token policies to
+// base units.
+func (p *Policy) prepareTokens() error {
+	for addr, tp := range p.Tokens {
+		if tp.Decimals != nil && (*tp.Decimals < 0 || *tp.Decimals > MaxTokenDecimals) {
+			return fmt.Errorf("tokens.%s.decimals: must be between 0 and %d", addr, MaxTokenDecimals)
+		}
+		if (tp.MaxPerTx != "" || tp.DailyLimit != "") && tp.Decimals == nil {
+			return fmt.Errorf("tokens.%s: max_per_tx and daily_limit need decimals", addr)
+		}
+		if tp.MaxPerTx != "" {
+			if tp.MaxAmount != nil {
+				return fmt.Errorf("tokens.%s: set either max_amount or max_per_tx", addr)
+			}
+			n, err := txbuilder.ParseDecimal(tp.MaxPerTx, *tp.Decimals)
+			if err != nil {
+				return fmt.Errorf("tokens.%s.max_per_tx: invalid amount %q: %v", addr, tp.MaxPerTx, err)
+			}
+			tp.MaxAmount = n
+		}
+		if tp.DailyLimit != "" {
+			n, err := txbuilder.ParseDecimal(tp.DailyLimit, *tp.Decimals)
+			if err != nil {
+				return fmt.Errorf("tokens.%s.daily_limit: invalid amount %q: %v", addr, tp.DailyLimit, err)
+			}
+			tp.dailyLimit = n
+		}
+		p.Tokens[addr] = tp
+	}
+	return nil
+}
+
+// format formats an amount of the token in whole tokens when its decimals
+// are known, and in base units otherwise.
+func (tp TokenPolicy) format(n *big.Int) string {
+	if tp.Decimals == nil {
+		return n.String()
+	}
+	s := txbuilder.FormatDecimal(n, *tp.Decimals)
+	if tp.Symbol != "" {
+		s += " " + tp.Symbol
+	}
+	return s
+}
+
+// TokenDailyLimit returns the daily limit of token in base units, if the
+// policy gives it one.
+func (p *Policy) TokenDailyLimit(token common.Address) (*big.Int, bool) {
+	tp, ok := p.TokenPolicy(token)
+	if !ok || tp.dailyLimit == nil {
+		return nil, false
+	}
+	return tp.dailyLimit, true
+}
@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// UserOpPolicy controls sign-userop. Senders lists the smart accounts whose
+// operations may be signed. Targets, when non-empty, limits the contracts
+// the account may call, which requires callData the signer can decode
+// (execute or executeBatch). MaxValueWei caps the ether each call sends.
+// MaxTotalGas caps the sum of the operation's gas limits, and the fee caps
+// its gas fees; unset caps are not applied.
+type UserOpPolicy struct {
+	EntryPoints             []string `json:"entry_points"`
+	Senders                 []string `json:"senders"`
+	Targets                 []string `json:"targets"`
+	MaxValueWei             *big.Int `json:"max_value_wei"`
+	MaxTotalGas             *big.Int `json:"max_total_gas"`
+	MaxFeePerGasWei         *big.Int `json:"max_fee_per_gas_wei"`
+	MaxPriorityFeePerGasWei *big.Int `json:"max_priority_fee_per_gas_wei"`
+}
+
+func containsAddress(list []string, addr common.Address) bool {
+	for _, entry := range list {
+		if strings.EqualFold(entry, addr.Hex()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check applies the policy to op sent through entryPoint. EntryPoints,
+// when empty, allows only the canonical deployments.
+func (up *UserOpPolicy) Check(op *txbuilder.UserOperation, entryPoint common.Address) error {
+	if len(up.EntryPoints) > 0 {
+		if !containsAddress(up.EntryPoints, entryPoint) {
+			return fmt.Errorf("entry point %s not in user_operations.entry_points", entryPoint.Hex())
+		}
+	} else if _, ok := txbuilder.KnownEntryPoints[entryPoint]; !ok {
+		return fmt.Errorf("entry point %s is not a canonical deployment; list it in user_operations.entry_points", entryPoint.Hex())
+	}
+	if !containsAddress(up.Senders, op.Sender) {
+		return fmt.Errorf("sender %s not in user_operations.senders", op.Sender.Hex())
+	}
+	if len(up.Targets) > 0 || up.MaxValueWei != nil {
+		calls, ok := txbuilder.DecodeUserOpCalls(op.CallData)
+		if !ok {
+			return errors.New("callData is not an execute or executeBatch call")
+		}
+		for _, c := range calls {
+			if len(up.Targets) > 0 && !containsAddress(up.Targets, c.Target) {
+				return fmt.Errorf("call target %s not in user_operations.targets", c.Target.Hex())
+			}
+			if up.MaxValueWei != nil && c.Value.Cmp(up.MaxValueWei) > 0 {
+				return fmt.Errorf("call value %s exceeds policy cap %s", c.Value, up.MaxValueWei)
+			}
+		}
+	}
+	if up.MaxTotalGas != nil {
+		if total := op.TotalGas(); total.Cmp(up.MaxTotalGas) > 0 {
+			return fmt.Errorf("total gas %s exceeds policy cap %s", total, up.MaxTotalGas)
+		}
+	}
+	if up.MaxFeePerGasWei != nil && op.MaxFeePerGas.ToInt().Cmp(up.MaxFeePerGasWei) > 0 {
+		return fmt.Errorf("max fee per gas %s exceeds policy cap %s", op.MaxFeePerGas.ToInt(), up.MaxFeePerGasWei)
+	}
+	if up.MaxPriorityFeePerGasWei != nil && op.MaxPriorityFeePerGas.ToInt().Cmp(up.MaxPriorityFeePerGasWei) > 0 {
+		return fmt.Errorf("max priority fee per gas %s exceeds policy cap %s", op.MaxPriorityFeePerGas.ToInt(), up.MaxPriorityFeePerGasWei)
+	}
+	return nil
+}
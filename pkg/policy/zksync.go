@@ -0,0 +1,29 @@
+package policy
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ZkSyncPolicy controls signing zkSync Era EIP-712 transactions.
+// Paymasters lists the paymasters a transaction may have its fees paid by;
+// transactions naming any other paymaster are rejected. General paymasters
+// are given nothing, but approval-based ones take tokens from the sender.
+type ZkSyncPolicy struct {
+	Paymasters []string `json:"paymasters"`
+}
+
+// CheckPaymaster rejects a paymaster not listed in the zkSync policy.
+func (p *Policy) CheckPaymaster(paymaster common.Address, d *Decision) error {
+	if paymaster == (common.Address{}) {
+		return nil
+	}
+	var allowed []string
+	if p.ZkSync != nil {
+		allowed = p.ZkSync.Paymasters
+	}
+	if !containsAddress(allowed, paymaster) {
+		return d.Fail("zksync.paymasters", allowed, paymaster.Hex(), "paymaster not in zksync.paymasters")
+	}
+	d.Pass("zksync.paymasters", allowed, paymaster.Hex())
+	return nil
+}
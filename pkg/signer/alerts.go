@@ -0,0 +1,365 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// alertConfig is the -alerts-config file. Credentials are read from files
+// so the config itself holds no secrets.
+type alertConfig struct {
+	Slack *struct {
+		// WebhookURLFile holds a Slack incoming webhook URL.
+		WebhookURLFile string `json:"webhook_url_file"`
+	} `json:"slack"`
+	Telegram *struct {
+		BotTokenFile string `json:"bot_token_file"`
+		ChatID       string `json:"chat_id"`
+		// AckUserIDs, when non-empty, limits who can acknowledge alerts
+		// with the button to these Telegram user IDs.
+		AckUserIDs []int64 `json:"ack_user_ids"`
+	} `json:"telegram"`
+}
+
+// Alerter sends alerts to Slack and Telegram and waits for them to be
+// acknowledged.
+type Alerter struct {
+	slackURL     string
+	telegram     string // bot API base URL, including the token
+	telegramChat string
+	ackUsers     map[int64]bool
+	client       *http.Client
+
+	mu      sync.Mutex
+	waiters map[string]chan string // alert ID to the name of who acked it
+	polling bool
+	offset  int64
+}
+
+// LoadAlerter reads the alerts config file; an empty file name configures
+// no channels.
+func LoadAlerter(file string) (*Alerter, error) {
+	if file == "" {
+		return nil, nil
+	}
+	var c alertConfig
+	if err := jsonfile.Read(file, &c); err != nil {
+		return nil, fmt.Errorf("failed to read alerts config: %v", err)
+	}
+	a := &Alerter{
+		ackUsers: make(map[int64]bool),
+		client:   &http.Client{Timeout: time.Minute},
+		waiters:  make(map[string]chan string),
+	}
+	if c.Slack != nil {
+		endpoint, err := readSecretFile(c.Slack.WebhookURLFile)
+		if err != nil {
+			return nil, fmt.Errorf("slack: %v", err)
+		}
+		a.slackURL = endpoint
+	}
+	if c.Telegram != nil {
+		token, err := readSecretFile(c.Telegram.BotTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("telegram: %v", err)
+		}
+		if c.Telegram.ChatID == "" {
+			return nil, errors.New("telegram: chat_id is required")
+		}
+		a.telegram = "https://api.telegram.org/bot" + token
+		a.telegramChat = c.Telegram.ChatID
+		for _, id := range c.Telegram.AckUserIDs {
+			a.ackUsers[id] = true
+		}
+	}
+	if a.slackURL == "" && a.telegram == "" {
+		return nil, errors.New("alerts config configures no channel")
+	}
+	return a, nil
+}
+
+// readSecretFile returns the trimmed contents of a file holding a
+// credential.
+func readSecretFile(file string) (string, error) {
+	if file == "" {
+		return "", errors.New("credential file is required")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", file)
+	}
+	return secret, nil
+}
+
+// checkAlert alerts on tx if it crosses a threshold of the policy and, if
+// the policy requires it, waits for the alert to be acknowledged. Alerts
+// that cannot be sent are logged, unless an acknowledgement is required, in
+// which case the transaction is denied.
+func (e *Engine) checkAlert(ctx context.Context, pol *policy.AlertPolicy, tx *types.Transaction, chainID *big.Int) error {
+	if !pol.Triggers(tx) {
+		return nil
+	}
+	id := types.LatestSignerForChainID(chainID).Hash(tx)
+	if e.Alerts == nil {
+		if pol.RequireAck {
+			return errors.New("alert requires acknowledgement but no alerts config is loaded")
+		}
+		slog.Warn("high-value transaction alert not sent: no alerts config", "alert", id.Hex())
+		return nil
+	}
+	text := alertText(tx, e.Key.Address(), chainID, id, pol.RequireAck)
+	if pol.RequireAck {
+		// A transaction acknowledged earlier, e.g. in a request that then
+		// timed out, goes through.
+		if by, ok := readAck(e.ApprovalsDir, id); ok {
+			slog.Info("alert acknowledged", "alert", id.Hex(), "by", by)
+			return nil
+		}
+	}
+	if err := e.Alerts.send(ctx, text, id, pol.RequireAck); err != nil {
+		if pol.RequireAck {
+			return fmt.Errorf("failed to send alert: %v", err)
+		}
+		slog.Warn("failed to send alert", "alert", id.Hex(), "err", err)
+		return nil
+	}
+	if !pol.RequireAck {
+		return nil
+	}
+	by, err := e.Alerts.waitAck(ctx, e.ApprovalsDir, id, pol.AckTimeout())
+	if err != nil {
+		return err
+	}
+	slog.Info("alert acknowledged", "alert", id.Hex(), "by", by)
+	return nil
+}
+
+func alertText(tx *types.Transaction, from common.Address, chainID *big.Int, id common.Hash, requireAck bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "High-value transaction on chain %s\n", chainID)
+	fmt.Fprintf(&b, "From: %s\n", from.Hex())
+	if tx.To() != nil {
+		fmt.Fprintf(&b, "To: %s\n", tx.To().Hex())
+	} else {
+		fmt.Fprintln(&b, "To: contract creation")
+	}
+	fmt.Fprintf(&b, "Value: %s\n", txbuilder.FormatWei(tx.Value()))
+	if call := txbuilder.DecodeCall(tx.Data()); call != nil && tx.To() != nil {
+		fmt.Fprintf(&b, "Transfer: %s of %s to %s\n", call.Amount, tx.To().Hex(), call.Recipient)
+	}
+	fmt.Fprintf(&b, "Nonce: %d\n", tx.Nonce())
+	fmt.Fprintf(&b, "Alert: %s\n", id.Hex())
+	if requireAck {
+		fmt.Fprintf(&b, "The signature is held until this alert is acknowledged: 'secure-signer ack %s'", id.Hex())
+	}
+	return b.String()
+}
+
+// send posts the alert to every configured channel. With ack, the Telegram
+// message carries an acknowledge button.
+func (a *Alerter) send(ctx context.Context, text string, id common.Hash, ack bool) error {
+	var errs []error
+	if a.slackURL != "" {
+		if err := a.post(ctx, a.slackURL, map[string]any{"text": text}, nil); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %v", err))
+		}
+	}
+	if a.telegram != "" {
+		msg := map[string]any{"chat_id": a.telegramChat, "text": text}
+		if ack {
+			msg["reply_markup"] = map[string]any{
+				"inline_keyboard": [][]map[string]string{{{"text": "Acknowledge", "callback_data": "ack:" + id.Hex()}}},
+			}
+		}
+		if err := a.post(ctx, a.telegram+"/sendMessage", msg, nil); err != nil {
+			errs = append(errs, fmt.Errorf("telegram: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends body as JSON to endpoint and decodes the response into result
+// if it is not nil.
+func (a *Alerter) post(ctx context.Context, endpoint string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		// Drop the URL, which holds the credential.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// waitAck waits until the alert id is acknowledged, with the ack command or
+// the Telegram button, and returns who acknowledged it.
+func (a *Alerter) waitAck(ctx context.Context, dir string, id common.Hash, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	acked := make(chan string, 1)
+	if a.telegram != "" {
+		a.mu.Lock()
+		a.waiters[id.Hex()] = acked
+		if !a.polling {
+			a.polling = true
+			go a.pollTelegram()
+		}
+		a.mu.Unlock()
+		defer func() {
+			a.mu.Lock()
+			delete(a.waiters, id.Hex())
+			a.mu.Unlock()
+		}()
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case by := <-acked:
+			return by, nil
+		case <-ticker.C:
+			if by, ok := readAck(dir, id); ok {
+				return by, nil
+			}
+		case <-ctx.Done():
+			return "", fmt.Errorf("alert %s was not acknowledged within %s", id.Hex(), timeout)
+		}
+	}
+}
+
+// pollTelegram long-polls the bot for button presses and hands them to the
+// waiting requests. Only one poller runs per bot, as Telegram requires; it
+// stops once nobody is waiting.
+func (a *Alerter) pollTelegram() {
+	for {
+		a.mu.Lock()
+		if len(a.waiters) == 0 {
+			a.polling = false
+			a.mu.Unlock()
+			return
+		}
+		offset := a.offset
+		a.mu.Unlock()
+
+		var resp struct {
+			OK     bool `json:"ok"`
+			Result []struct {
+				UpdateID      int64 `json:"update_id"`
+				CallbackQuery *struct {
+					ID   string `json:"id"`
+					Data string `json:"data"`
+					From struct {
+						ID       int64  `json:"id"`
+						Username string `json:"username"`
+					} `json:"from"`
+				} `json:"callback_query"`
+			} `json:"result"`
+		}
+		body := map[string]any{"offset": offset, "timeout": 20, "allowed_updates": []string{"callback_query"}}
+		if err := a.post(context.Background(), a.telegram+"/getUpdates", body, &resp); err != nil || !resp.OK {
+			slog.Warn("telegram poll failed", "err", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, u := range resp.Result {
+			a.mu.Lock()
+			a.offset = u.UpdateID + 1
+			q := u.CallbackQuery
+			var waiter chan string
+			if q != nil && (len(a.ackUsers) == 0 || a.ackUsers[q.From.ID]) {
+				waiter = a.waiters[strings.TrimPrefix(q.Data, "ack:")]
+			}
+			a.mu.Unlock()
+			if q == nil {
+				continue
+			}
+			answer := "Not authorized to acknowledge"
+			if waiter != nil {
+				answer = "Acknowledged"
+				select {
+				case waiter <- fmt.Sprintf("telegram:%d:%s", q.From.ID, q.From.Username):
+				default:
+				}
+			}
+			a.post(context.Background(), a.telegram+"/answerCallbackQuery", map[string]any{"callback_query_id": q.ID, "text": answer}, nil)
+		}
+	}
+}
+
+// completeAlert removes the acknowledgement of a transaction that has been
+// signed.
+func (e *Engine) completeAlert(pol *policy.Policy, tx *types.Transaction, chainID *big.Int) {
+	if pol.Alerts == nil || !pol.Alerts.RequireAck {
+		return
+	}
+	os.Remove(AckPath(e.ApprovalsDir, types.LatestSignerForChainID(chainID).Hash(tx)))
+}
+
+// AlertAck is an acknowledgement written by the ack command as <id>.ack in
+// the approvals directory.
+type AlertAck struct {
+	ID       common.Hash `json:"id"`
+	Operator string      `json:"operator"`
+	At       time.Time   `json:"at"`
+}
+
+func AckPath(dir string, id common.Hash) string {
+	if dir == "" {
+		dir = DefaultApprovalsDir
+	}
+	return filepath.Join(dir, id.Hex()+".ack")
+}
+
+// readAck reports whether the alert id was acknowledged with the ack
+// command, and by whom.
+func readAck(dir string, id common.Hash) (string, bool) {
+	data, err := os.ReadFile(AckPath(dir, id))
+	if err != nil {
+		return "", false
+	}
+	var ack AlertAck
+	if err := json.Unmarshal(data, &ack); err != nil || ack.ID != id {
+		return "", false
+	}
+	return ack.Operator, true
+}
@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultApprovalsDir holds alert acknowledgements when -approvals-dir is
+// not given.
+const DefaultApprovalsDir = "approvals"
+
+// ApprovalRequest is a pending transaction awaiting approval, kept in the
+// state store. The ID is the transaction's signing hash, so it commits to
+// the chain and every field of the transaction, and resubmitting the same
+// transaction finds the same request.
+type ApprovalRequest struct {
+	ID        common.Hash     `json:"id"`
+	ChainID   *big.Int        `json:"chain_id"`
+	From      common.Address  `json:"from"`
+	Tx        hexutil.Bytes   `json:"tx"`
+	CreatedAt time.Time       `json:"created_at"`
+	Approvals []ApprovalEntry `json:"approvals"`
+}
+
+// ApprovalEntry is an EIP-191 personal signature over the request ID.
+type ApprovalEntry struct {
+	Approver  common.Address `json:"approver"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// AddApproval returns a copy of req with entry replacing any approval by
+// the same approver.
+func (req *ApprovalRequest) AddApproval(entry ApprovalEntry) *ApprovalRequest {
+	next := *req
+	next.Approvals = []ApprovalEntry{entry}
+	for _, a := range req.Approvals {
+		if a.Approver != entry.Approver {
+			next.Approvals = append(next.Approvals, a)
+		}
+	}
+	return &next
+}
+
+// ApprovedBy returns the distinct listed approvers with a valid signature on
+// the request.
+func (req *ApprovalRequest) ApprovedBy(pol *policy.ApprovalPolicy) map[common.Address]bool {
+	valid := make(map[common.Address]bool)
+	for _, a := range req.Approvals {
+		signer, err := keys.RecoverPersonalMessage(req.ID.Bytes(), a.Signature)
+		if err != nil || signer != a.Approver {
+			continue
+		}
+		for _, entry := range pol.Approvers {
+			if strings.EqualFold(entry, signer.Hex()) {
+				valid[signer] = true
+			}
+		}
+	}
+	return valid
+}
+
+// checkApprovals returns nil if tx has reached the approval quorum. Otherwise
+// it records a pending request for the approvers and returns an error naming
+// it.
+func (e *Engine) checkApprovals(pol *policy.Policy, tx *types.Transaction, chainID *big.Int) error {
+	ap := pol.Approvals
+	if ap == nil {
+		return nil
+	}
+	if ap.Quorum <= 0 || ap.Quorum > len(ap.Approvers) {
+		return errors.New("invalid approval quorum")
+	}
+	id := types.LatestSignerForChainID(chainID).Hash(tx)
+	var req *ApprovalRequest
+	err := e.Store.UpdateApprovalRequest(id, func(cur *ApprovalRequest) (*ApprovalRequest, error) {
+		if req = cur; req != nil {
+			return cur, nil
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		req = &ApprovalRequest{ID: id, ChainID: chainID, From: e.Key.Address(), Tx: raw, CreatedAt: time.Now().UTC()}
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record approval request: %v", err)
+	}
+	if n := len(req.ApprovedBy(ap)); n < ap.Quorum {
+		return fmt.Errorf("approval required: request %s has %d of %d approvals", id.Hex(), n, ap.Quorum)
+	}
+	return nil
+}
+
+// completeApproval removes the request for a transaction that has been
+// signed, so its approvals cannot be reused.
+func (e *Engine) completeApproval(pol *policy.Policy, tx *types.Transaction, chainID *big.Int) {
+	if pol.Approvals == nil {
+		return
+	}
+	id := types.LatestSignerForChainID(chainID).Hash(tx)
+	err := e.Store.UpdateApprovalRequest(id, func(*ApprovalRequest) (*ApprovalRequest, error) { return nil, nil })
+	if err != nil {
+		slog.Warn("failed to remove approval request", "request", id.Hex(), "err", err)
+	}
+}
@@ -0,0 +1,113 @@
+package signer
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testApprover returns a local key for an approver.
+func testApprover(t *testing.T) *keys.LocalKey {
+	t.Helper()
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := keys.SecretKeyFromECDSA(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys.NewLocalKey(secret)
+}
+
+func TestApprovalQuorum(t *testing.T) {
+	approvers := []*keys.LocalKey{testApprover(t), testApprover(t), testApprover(t)}
+	outsider := testApprover(t)
+	var listed []string
+	for _, a := range approvers {
+		listed = append(listed, `"`+a.Address().Hex()+`"`)
+	}
+	e := testEngine(t, `{
+		"max_amount_wei": 100,
+		"whitelist": ["`+testRecipient.Hex()+`"],
+		"approvals": {"approvers": [`+strings.Join(listed, ", ")+`], "quorum": 2}
+	}`)
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &testRecipient, Gas: 21000, GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Value: big.NewInt(1)})
+	id := types.LatestSignerForChainID(big.NewInt(1)).Hash(tx)
+
+	sign := func() string {
+		t.Helper()
+		_, d, err := e.SignTx(t.Context(), tx, big.NewInt(1))
+		if err == nil {
+			return ""
+		}
+		return d.FailedCheck()
+	}
+	approve := func(as common.Address, by *keys.LocalKey, msg []byte) {
+		t.Helper()
+		sig, err := keys.SignPersonalMessage(t.Context(), by, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = e.Store.UpdateApprovalRequest(id, func(cur *ApprovalRequest) (*ApprovalRequest, error) {
+			return cur.AddApproval(ApprovalEntry{Approver: as, Signature: sig}), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := sign(); got != "approvals" {
+		t.Fatalf("unapproved transaction: failed check = %q, want approvals", got)
+	}
+	approve(approvers[0].Address(), approvers[0], id.Bytes())
+	// Approving twice, claiming another approver's signature, signing
+	// something else and approving without being listed must not make up
+	// the quorum.
+	approve(approvers[0].Address(), approvers[0], id.Bytes())
+	approve(approvers[1].Address(), approvers[0], id.Bytes())
+	approve(approvers[2].Address(), approvers[2], common.HexToHash("0x01").Bytes())
+	approve(outsider.Address(), outsider, id.Bytes())
+	if got := sign(); got != "approvals" {
+		t.Fatalf("transaction with one valid approval: failed check = %q, want approvals", got)
+	}
+
+	// Duplicate entries by one approver, as a store written by hand may
+	// hold, count once.
+	req, err := e.Store.ApprovalRequest(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Approvals = append(req.Approvals, req.Approvals...)
+	if n := len(req.ApprovedBy(e.CurrentPolicy().Approvals)); n != 1 {
+		t.Fatalf("ApprovedBy counts %d approvers, want 1", n)
+	}
+
+	approve(approvers[1].Address(), approvers[1], id.Bytes())
+	if got := sign(); got != "" {
+		t.Fatalf("approved transaction: failed check = %q", got)
+	}
+	// The approvals are used up once the transaction is signed.
+	if req, err := e.Store.ApprovalRequest(id); err != nil || req != nil {
+		t.Fatalf("approval request after signing = %v, %v; want none", req, err)
+	}
+}
+
+func TestApprovalQuorumInvalid(t *testing.T) {
+	approver := testApprover(t).Address().Hex()
+	for _, quorum := range []string{"0", "2"} {
+		e := testEngine(t, `{
+			"max_amount_wei": 100,
+			"whitelist": ["`+testRecipient.Hex()+`"],
+			"approvals": {"approvers": ["`+approver+`"], "quorum": `+quorum+`}
+		}`)
+		if got := signTransfer(t, e, 0, 1); got != "approvals" {
+			t.Errorf("quorum %s of 1: failed check = %q, want approvals", quorum, got)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package signer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// DefaultAuditLog is the audit log used when -audit-log is not given.
+const DefaultAuditLog = "signer-audit.jsonl"
+
+// Kinds of signing request recorded in the audit log.
+const (
+	AuditTransaction   = "transaction"
+	AuditMessage       = "message"
+	AuditTypedData     = "typed_data"
+	AuditRaw           = "raw"
+	AuditAuthorization = "authorization"
+	AuditUserOp        = "user_operation"
+	AuditSafe          = "safe_transaction"
+)
+
+// AuditEntry is one line of the audit log. Hash is the SHA-256 of the
+// entry's JSON encoding with Hash and Signature empty, and PrevHash the Hash
+// of the entry before it, so editing, reordering or removing an entry breaks
+// the chain. When the log has an audit key, Signature is its secp256k1
+// signature over Hash, so an attacker who can write the log but does not
+// hold the key cannot rebuild the chain either.
+type AuditEntry struct {
+	Seq         uint64           `json:"seq"`
+	Time        time.Time        `json:"time"`
+	Operator    string           `json:"operator"`
+	Kind        string           `json:"kind"`
+	Account     string           `json:"account,omitempty"`
+	ChainID     string           `json:"chainId,omitempty"`
+	Request     json.RawMessage  `json:"request,omitempty"`
+	SigningHash string           `json:"signingHash,omitempty"`
+	Allowed     bool             `json:"allowed"`
+	Decision    *policy.Decision `json:"decision,omitempty"`
+	TxHash      string           `json:"txHash,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	PrevHash    string           `json:"prevHash"`
+	Hash        string           `json:"hash"`
+	Signature   string           `json:"signature,omitempty"`
+}
+
+func (e *AuditEntry) computeHash() (string, error) {
+	c := *e
+	c.Hash = ""
+	c.Signature = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditHead is the position of the last entry of an audit log. An audit
+// file records it in a sibling .head file: the chain alone cannot reveal
+// entries cut off the end of the log; comparing it with the head can. Keep
+// a copy of the head elsewhere to catch an attacker who rewrites both.
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditStore keeps the entries of an audit log: a JSONL file opened with
+// OpenAuditFile, or the state store, so that daemon replicas sharing one
+// record a single chain.
+type AuditStore interface {
+	// AppendAudit atomically appends the entry seal returns for the
+	// position of the last entry, zero if there is none. Nothing is
+	// appended if seal fails. seal may be called more than once.
+	AppendAudit(seal func(head AuditHead) (*AuditEntry, error)) error
+	// ReadAudit calls fn for every entry, in order.
+	ReadAudit(fn func(*AuditEntry) error) error
+}
+
+// AuditLog records hash-chained entries in an AuditStore. A nil *AuditLog
+// records nothing.
+type AuditLog struct {
+	Entries  AuditStore
+	Operator string
+	Key      *keys.SecretKey
+	// Syslog, when set, receives a copy of every entry recorded.
+	Syslog *SyslogSink
+	mu     sync.Mutex
+	closed bool
+}
+
+// record fills in the sequence number, time, operator and chain hashes of
+// e and appends it to the log.
+func (l *AuditLog) record(e *AuditEntry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errors.New("audit log is closed")
+	}
+	err := l.Entries.AppendAudit(func(head AuditHead) (*AuditEntry, error) {
+		e.Seq = head.Seq + 1
+		e.Time = time.Now().UTC()
+		e.Operator = l.Operator
+		e.PrevHash = head.Hash
+		var err error
+		if e.Hash, err = e.computeHash(); err != nil {
+			return nil, err
+		}
+		if l.Key != nil {
+			sig, err := l.Key.Sign(common.FromHex(e.Hash))
+			if err != nil {
+				return nil, fmt.Errorf("failed to sign audit entry: %v", err)
+			}
+			e.Signature = hexutil.Encode(sig)
+		}
+		return e, nil
+	})
+	if err != nil {
+		return err
+	}
+	if l.Syslog != nil {
+		l.Syslog.send(e)
+	}
+	return nil
+}
+
+// Close waits for the entry being recorded, if any, and wipes the audit
+// key. Nothing can be recorded after it.
+func (l *AuditLog) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if l.Key != nil {
+		l.Key.Destroy()
+	}
+	if l.Syslog != nil {
+		l.Syslog.Close()
+	}
+}
+
+// auditFile is an AuditStore in a JSONL file. Like the file store, every
+// append holds an exclusive lock on a sibling .lock file so the CLI and the
+// daemon can share a log.
+type auditFile struct {
+	path string
+}
+
+// OpenAuditFile returns the audit log in the JSONL file at path, which is
+// created on the first append.
+func OpenAuditFile(path string) AuditStore {
+	return &auditFile{path: path}
+}
+
+func (f *auditFile) headPath() string { return f.path + ".head" }
+
+func (f *auditFile) AppendAudit(seal func(head AuditHead) (*AuditEntry, error)) error {
+	unlock, err := fileutil.Lock(f.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	head, err := f.readHead()
+	if err != nil {
+		return err
+	}
+	e, err := seal(head)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(append(line, '\n')); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(AuditHead{Seq: e.Seq, Hash: e.Hash})
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(f.headPath(), data)
+}
+
+// readHead returns the last entry's position, from the head file or, if
+// that is missing, by reading the log.
+func (f *auditFile) readHead() (AuditHead, error) {
+	var head AuditHead
+	data, err := os.ReadFile(f.headPath())
+	if err == nil {
+		err = json.Unmarshal(data, &head)
+		return head, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return head, err
+	}
+	err = f.ReadAudit(func(e *AuditEntry) error {
+		head = AuditHead{Seq: e.Seq, Hash: e.Hash}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return head, err
+}
+
+// ReadAudit calls fn for every line of the log, in order.
+func (f *auditFile) ReadAudit(fn func(*AuditEntry) error) error {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		e := new(AuditEntry)
+		if err := jsonfile.Decode(scanner.Bytes(), e); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(e); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// auditSignature records an attempt to sign something other than a
+// transaction.
+func (l *AuditLog) auditSignature(kind string, account common.Address, request any, signingHash []byte, err error) error {
+	if l == nil {
+		return nil
+	}
+	e := &AuditEntry{Kind: kind, Allowed: err == nil}
+	if account != (common.Address{}) {
+		e.Account = account.Hex()
+	}
+	if request != nil {
+		data, merr := json.Marshal(request)
+		if merr != nil {
+			return merr
+		}
+		e.Request = data
+	}
+	if signingHash != nil {
+		e.SigningHash = hexutil.Encode(signingHash)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return l.record(e)
+}
+
+// Denied records a request refused by the policy and returns err.
+func (l *AuditLog) Denied(kind string, request any, signingHash []byte, err error) error {
+	if aerr := l.auditSignature(kind, common.Address{}, request, signingHash, err); aerr != nil {
+		slog.Warn("failed to write audit log", "err", aerr)
+	}
+	return err
+}
+
+// Signed records the outcome of signing a request for account and returns
+// err. A signature that could not be recorded is withheld.
+func (l *AuditLog) Signed(kind string, account common.Address, request any, signingHash []byte, err error) error {
+	if aerr := l.auditSignature(kind, account, request, signingHash, err); aerr != nil && err == nil {
+		return AuditRecordFailed(aerr)
+	}
+	return err
+}
+
+// AuditRecordFailed wraps a failure to write the audit log.
+func AuditRecordFailed(err error) error {
+	return fmt.Errorf("failed to write audit log: %v", err)
+}
+
+// VerifyAuditLog checks the chain of log, and with signer the signature of
+// every entry, calling fn for each entry that checks out. It returns the
+// last entry's position.
+func VerifyAuditLog(log AuditStore, signer *common.Address, fn func(*AuditEntry) error) (AuditHead, error) {
+	var last AuditHead
+	err := log.ReadAudit(func(e *AuditEntry) error {
+		if e.Seq != last.Seq+1 {
+			return fmt.Errorf("entry %d follows entry %d", e.Seq, last.Seq)
+		}
+		if e.PrevHash != last.Hash {
+			return fmt.Errorf("entry %d does not chain to the entry before it", e.Seq)
+		}
+		hash, err := e.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("entry %d has been modified", e.Seq)
+		}
+		if signer != nil {
+			if err := e.checkSignature(*signer); err != nil {
+				return fmt.Errorf("entry %d: %v", e.Seq, err)
+			}
+		}
+		last = AuditHead{Seq: e.Seq, Hash: e.Hash}
+		if fn != nil {
+			return fn(e)
+		}
+		return nil
+	})
+	if err != nil {
+		return last, fmt.Errorf("audit log verification failed: %v", err)
+	}
+	return last, nil
+}
+
+// checkSignature checks that e is signed by the audit key at signer.
+func (e *AuditEntry) checkSignature(signer common.Address) error {
+	if e.Signature == "" {
+		return errors.New("entry is not signed")
+	}
+	sig, err := hexutil.Decode(e.Signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return errors.New("invalid entry signature")
+	}
+	pub, err := crypto.SigToPub(common.FromHex(e.Hash), sig)
+	if err != nil {
+		return fmt.Errorf("invalid entry signature: %v", err)
+	}
+	if addr := crypto.PubkeyToAddress(*pub); addr != signer {
+		return fmt.Errorf("entry is signed by %s, not %s", addr.Hex(), signer.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package signer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslogTimeout bounds connecting to the collector and writing an entry.
+const syslogTimeout = 5 * time.Second
+
+// Formats of the audit entries sent to syslog.
+const (
+	SyslogJSON = "json"
+	syslogCEF  = "cef"
+)
+
+// syslogFacilities maps facility names to their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogSink forwards audit entries to a syslog collector such as a SIEM,
+// as RFC 5424 messages whose body is either the entry's JSON line, as in
+// the audit log, or an ArcSight CEF event. Messages on TCP and TLS are
+// separated by newlines. Forwarding is best effort: the hash-chained log
+// remains the record, and an entry the collector missed is logged and can
+// be found there. A sink is used under the lock of its audit log.
+type SyslogSink struct {
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+	facility  int
+	format    string
+	hostname  string
+	version   string
+	conn      net.Conn
+}
+
+// NewSyslogSink returns a sink for the collector at location, a udp://,
+// tcp:// or tls:// URL with optional facility and ca parameters, e.g.
+// tls://siem.internal:6514?facility=local4&ca=/etc/ssl/siem-ca.pem. CEF
+// events name version as the signer's version.
+func NewSyslogSink(location, format, version string) (*SyslogSink, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid audit syslog URL %q", location)
+	}
+	if format != SyslogJSON && format != syslogCEF {
+		return nil, fmt.Errorf("invalid audit syslog format %q: want %s or %s", format, SyslogJSON, syslogCEF)
+	}
+	s := &SyslogSink{addr: u.Host, facility: syslogFacilities["authpriv"], format: format, hostname: "-", version: version}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		s.hostname = h
+	}
+	q := u.Query()
+	if name := q.Get("facility"); name != "" {
+		facility, ok := syslogFacilities[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", name)
+		}
+		s.facility = facility
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		s.network = u.Scheme
+		if q.Has("ca") {
+			return nil, errors.New("audit syslog ca requires a tls:// URL")
+		}
+	case "tls":
+		s.network = "tcp"
+		s.tlsConfig = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		if ca := q.Get("ca"); ca != "" {
+			pem, err := os.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read audit syslog CA: %v", err)
+			}
+			s.tlsConfig.RootCAs = x509.NewCertPool()
+			if !s.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in audit syslog CA %s", ca)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid audit syslog URL %q: want udp://, tcp:// or tls://", location)
+	}
+	return s, nil
+}
+
+// send forwards e, reconnecting once if the connection broke.
+func (s *SyslogSink) send(e *AuditEntry) {
+	msg, err := s.message(e)
+	if err == nil {
+		if err = s.write(msg); err != nil && s.network == "tcp" {
+			err = s.write(msg)
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to forward audit entry to syslog", "addr", s.addr, "seq", e.Seq, "err", err)
+	}
+}
+
+func (s *SyslogSink) write(msg []byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: syslogTimeout}
+		var err error
+		if s.tlsConfig != nil {
+			s.conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.tlsConfig)
+		} else {
+			s.conn, err = dialer.Dial(s.network, s.addr)
+		}
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// message formats e as an RFC 5424 message: info for a signature, warning
+// for a refusal.
+func (s *SyslogSink) message(e *AuditEntry) ([]byte, error) {
+	severity := 6
+	if !e.Allowed {
+		severity = 4
+	}
+	var body string
+	switch s.format {
+	case syslogCEF:
+		body = cefEvent(e, s.version)
+	default:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+	msg := fmt.Sprintf("<%d>1 %s %s secure-signer %d %s - %s",
+		s.facility*8+severity, e.Time.Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, os.Getpid(), e.Kind, body)
+	if s.network == "tcp" {
+		msg += "\n"
+	}
+	return []byte(msg), nil
+}
+
+// Close closes the connection to the collector.
+func (s *SyslogSink) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// cefEvent formats e as a CEF event of the given signer version. The
+// account, chain, hashes and the failed policy check go in custom string
+// fields labelled with their names.
+func cefEvent(e *AuditEntry, version string) string {
+	outcome, severity := "signed", 3
+	if !e.Allowed {
+		outcome, severity = "denied", 6
+	}
+	header := strings.Join([]string{
+		"CEF:0", "secure-signer", "secure-signer", cefHeader(version),
+		cefHeader(e.Kind + ":" + outcome), cefHeader(e.Kind + " " + outcome), strconv.Itoa(severity),
+	}, "|")
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Time.UnixMilli(), 10),
+		"act=" + outcome,
+		"suser=" + cefValue(e.Operator),
+		"cn1Label=seq", "cn1=" + strconv.FormatUint(e.Seq, 10),
+	}
+	for i, f := range []struct{ label, value string }{
+		{"account", e.Account},
+		{"chainId", e.ChainID},
+		{"txHash", e.TxHash},
+		{"signingHash", e.SigningHash},
+		{"deniedRule", e.Decision.FailedCheck()},
+		{"entryHash", e.Hash},
+	} {
+		if f.value == "" {
+			continue
+		}
+		n := strconv.Itoa(i + 1)
+		ext = append(ext, "cs"+n+"Label="+f.label, "cs"+n+"="+cefValue(f.value))
+	}
+	if e.Error != "" {
+		ext = append(ext, "reason="+cefValue(e.Error))
+	}
+	return header + "|" + strings.Join(ext, " ")
+}
+
+// cefHeader escapes a CEF header field.
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// cefValue escapes a CEF extension value.
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
@@ -0,0 +1,82 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeldTx is a signed transaction waiting out its delay, kept in the state
+// store. Cancelling it drops the signed transaction and keeps the record.
+type HeldTx struct {
+	TxHash       common.Hash    `json:"tx_hash"`
+	ChainID      *big.Int       `json:"chain_id"`
+	From         common.Address `json:"from"`
+	To           string         `json:"to,omitempty"`
+	Nonce        uint64         `json:"nonce"`
+	Value        *big.Int       `json:"value"`
+	Raw          hexutil.Bytes  `json:"raw,omitempty"`
+	HeldAt       time.Time      `json:"held_at"`
+	ReleaseAt    time.Time      `json:"release_at"`
+	CancelledAt  *time.Time     `json:"cancelled_at,omitempty"`
+	CancelledBy  string         `json:"cancelled_by,omitempty"`
+	CancelReason string         `json:"cancel_reason,omitempty"`
+}
+
+func (h *HeldTx) Status(now time.Time) string {
+	switch {
+	case h.CancelledAt != nil:
+		return "cancelled"
+	case now.Before(h.ReleaseAt):
+		return "held"
+	}
+	return "due"
+}
+
+// HeldError reports a transaction that was signed and queued under a time
+// delay instead of being returned.
+type HeldError struct {
+	TxHash    common.Hash
+	ReleaseAt time.Time
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("time delay: transaction %s is held until %s", e.TxHash.Hex(), e.ReleaseAt.Format(time.RFC3339))
+}
+
+// IsHeld reports whether err is a *HeldError. The nonce of a held
+// transaction is used and must not be released.
+func IsHeld(err error) bool {
+	var held *HeldError
+	return errors.As(err, &held)
+}
+
+// hold queues the signed transaction raw, with hash txHash, for release
+// after delay and returns a *HeldError, so that no caller passes the
+// signature on.
+func (e *Engine) hold(tx *types.Transaction, chainID *big.Int, raw []byte, txHash common.Hash, delay time.Duration) error {
+	now := time.Now().UTC()
+	h := &HeldTx{
+		TxHash:    txHash,
+		ChainID:   chainID,
+		From:      e.Key.Address(),
+		Nonce:     tx.Nonce(),
+		Value:     tx.Value(),
+		Raw:       raw,
+		HeldAt:    now,
+		ReleaseAt: now.Add(delay),
+	}
+	if tx.To() != nil {
+		h.To = tx.To().Hex()
+	}
+	err := e.Store.UpdateHeldTx(txHash, func(*HeldTx) (*HeldTx, error) { return h, nil })
+	if err != nil {
+		return fmt.Errorf("failed to queue signed transaction %s: %v", txHash.Hex(), err)
+	}
+	return &HeldError{TxHash: txHash, ReleaseAt: h.ReleaseAt}
+}
@@ -0,0 +1,19 @@
+// Package signer policy-checks and signs Ethereum transactions for a key.
+//
+// An Engine combines a parsed policy, a key backend and a Store for the
+// cumulative state, such as spend counters and the nonces already signed:
+//
+//	pol, err := policy.Parse(data)
+//	...
+//	store, err := signer.OpenStore("signer-state.db")
+//	...
+//	e := signer.New(pol, key, store)
+//	signedTx, decision, err := e.SignTx(ctx, tx, chainID)
+//
+// The optional fields of Engine add the audit log, alerts, approvals and
+// hooks the secure-signer CLI and daemon use. Nothing in the package exits
+// the process: a transaction the policy refuses is a *policy.DeniedError
+// carrying the decision, which wraps a *ReplayError when its nonce was
+// already signed for another transaction, and a transaction held under a
+// time delay comes with a *HeldError.
+package signer
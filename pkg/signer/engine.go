@@ -0,0 +1,407 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Engine policy-checks and signs transactions for a key. It is shared by the
+// CLI and the daemon so both enforce exactly the same rules.
+type Engine struct {
+	// Policy may be swapped while the daemon is running; each request
+	// works from a single snapshot.
+	Policy atomic.Pointer[policy.Policy]
+	// PolicyErr is why the last reload of the policy was rejected, nil
+	// once one is accepted.
+	PolicyErr atomic.Pointer[error]
+	Key       keys.Backend
+	Store     Store
+	// OverrideReason, when set, allows signing outside the policy signing
+	// window.
+	OverrideReason string
+	// ApprovalsDir holds alert acknowledgements.
+	ApprovalsDir string
+	// Audit, when set, records every signing attempt.
+	Audit *AuditLog
+	// Alerts, when set, delivers the policy's high-value alerts.
+	Alerts *Alerter
+	// ENS, when set, resolves the ENS names in the policy whitelist.
+	ENS *policy.ENSResolver
+	// Session, when set, is the daemon's session key, which must be
+	// unlocked before anything is checked or reserved.
+	Session *SessionKey
+	// L1Fees, when set, estimates the L1 data fee of transactions on OP
+	// Stack chains.
+	L1Fees *L1FeeOracle
+	// PostSign, when set, runs the post-sign hooks for every transaction
+	// signed.
+	PostSign *PostSignHooks
+	// TokenDecimals, when set, checks the decimals of the tokens in the
+	// policy against their contracts.
+	TokenDecimals *TokenDecimals
+	// prices prices transactions for the policy's USD limits.
+	prices *priceOracle
+	// AllowReplace allows signing a transaction for a nonce that already
+	// has a different signed transaction.
+	AllowReplace bool
+	// ConfirmRecipient, when set, must confirm transactions paying a
+	// recipient the key has never paid before.
+	ConfirmRecipient func(from, to common.Address) error
+	// Confirm, when set, must release each transaction that passed the
+	// policy before its spend is reserved and it is signed.
+	// The L1 data fee is nil unless it was estimated.
+	Confirm func(from common.Address, tx *types.Transaction, chainID, l1Fee *big.Int) error
+}
+
+// New returns an engine that signs with key under policy, keeping
+// its cumulative state in store. The optional fields may be set before it
+// is first used.
+func New(pol *policy.Policy, key keys.Backend, store Store) *Engine {
+	e := &Engine{Key: key, Store: store, prices: newPriceOracle()}
+	e.Policy.Store(pol)
+	return e
+}
+
+// CurrentPolicy returns a snapshot of the policy that applies to the
+// engine's key.
+func (e *Engine) CurrentPolicy() *policy.Policy {
+	return e.Policy.Load().ForSender(e.Key.Address())
+}
+
+// SignTx runs the policy checks on tx, reserves its value against the
+// cumulative limits and, if everything passes, signs it for chainID. A
+// reservation is kept even if signing then fails, erring on the side of
+// overcounting. The returned decision explains the checks whether or not
+// they passed; a denial is a *policy.DeniedError carrying it, and a transaction
+// held under a time delay comes with a *HeldError.
+func (e *Engine) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, *policy.Decision, error) {
+	auth, err := e.Authorize(ctx, tx, chainID)
+	if err != nil {
+		return nil, auth.Decision, err
+	}
+	signedTx, err := auth.Sign(ctx)
+	return signedTx, auth.Decision, err
+}
+
+// Authorization is a transaction that passed the policy and has its spend
+// reserved, ready to be signed.
+type Authorization struct {
+	engine   *Engine
+	policy   *policy.Policy
+	Tx       *types.Transaction
+	chainID  *big.Int
+	Decision *policy.Decision
+	// holdFor, when set, is the time delay the signed transaction is held
+	// for before it is released.
+	holdFor time.Duration
+}
+
+// Authorize runs the policy checks and spend reservation of SignTx without
+// signing, so callers can sign the result later or on another goroutine.
+// The returned authorization is never nil and carries the decision. A
+// denial is recorded in the audit log here; an allowed transaction when it
+// is signed.
+func (e *Engine) Authorize(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*Authorization, error) {
+	ctx, span := tracing.StartSpan(ctx, "policy.check", attribute.String("secure_signer.chain_id", chainID.String()))
+	auth, err := e.checkTx(ctx, tx, chainID)
+	span.SetAttributes(attribute.Bool("secure_signer.allowed", auth.Decision.Allowed))
+	if rule := policy.DeniedRule(auth.Decision, err); rule != "" {
+		span.SetAttributes(attribute.String("secure_signer.denied_rule", rule))
+	}
+	tracing.EndSpan(span, err)
+	if err != nil {
+		if aerr := e.AuditTx(tx, chainID, auth.Decision, common.Hash{}, err); aerr != nil {
+			slog.Warn("failed to write audit log", "err", aerr)
+		}
+	}
+	return auth, err
+}
+
+func (e *Engine) checkTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*Authorization, error) {
+	pol := e.CurrentPolicy()
+	auth := &Authorization{engine: e, policy: pol, Tx: tx, chainID: chainID, Decision: new(policy.Decision)}
+	d := auth.Decision
+	deny := func(err error) (*Authorization, error) {
+		return auth, &policy.DeniedError{Err: fmt.Errorf("policy check failed: %v", err), Decision: d}
+	}
+	if err := e.Session.Check(); err != nil {
+		return auth, err
+	}
+	if err := d.Record("freeze", checkFrozen(e.Store)); err != nil {
+		return deny(err)
+	}
+	if pol.HasENSNames() {
+		resolved, err := e.ENS.ResolvePolicy(ctx, pol)
+		if err := d.Record("ens", err); err != nil {
+			return deny(err)
+		}
+		pol, auth.policy = resolved, resolved
+	}
+	now := time.Now()
+	if err := pol.Evaluate(ctx, tx, e.Key.Address(), chainID, now, e.OverrideReason, d); err != nil {
+		return deny(err)
+	}
+	l1Fee, err := e.checkL1Fee(ctx, pol, tx, chainID, d)
+	if err != nil {
+		return deny(err)
+	}
+	if err := e.checkTokenDecimals(ctx, pol, tx, d); err != nil {
+		return deny(err)
+	}
+	if err := e.checkUSDLimit(ctx, pol, tx.Value(), chainID, now, d); err != nil {
+		return deny(err)
+	}
+	if err := d.Record("replay", e.checkReplay(ctx, tx, chainID)); err != nil {
+		return auth, &policy.DeniedError{Err: err, Decision: d}
+	}
+	if e.ConfirmRecipient != nil {
+		if err := d.Record("new_recipient", e.checkNewRecipient(ctx, tx)); err != nil {
+			return auth, &policy.DeniedError{Err: err, Decision: d}
+		}
+	}
+	if pol.Approvals != nil {
+		if err := d.Record("approvals", e.checkApprovals(pol, tx, chainID)); err != nil {
+			return auth, &policy.DeniedError{Err: err, Decision: d}
+		}
+	}
+	if pol.Alerts != nil {
+		if err := d.Record("alerts", e.checkAlert(ctx, pol.Alerts, tx, chainID)); err != nil {
+			return deny(err)
+		}
+	}
+	if pol.PreSignHook != nil {
+		if err := d.Record("pre_sign_hook", e.checkPreSignHook(ctx, pol, tx, chainID, now)); err != nil {
+			return deny(err)
+		}
+	}
+	if e.Confirm != nil {
+		if err := d.Record("confirm", e.Confirm(e.Key.Address(), tx, chainID, l1Fee)); err != nil {
+			return auth, &policy.DeniedError{Err: err, Decision: d}
+		}
+	}
+	if err := e.reserveSpend(ctx, pol, tx, chainID, now, d); err != nil {
+		return deny(err)
+	}
+	if td := pol.TimeDelay; td != nil && td.Applies(tx) {
+		auth.holdFor = td.Delay()
+		d.Pass("time_delay", td.DelayMinutes, nil)
+	}
+	d.Allowed = true
+	return auth, nil
+}
+
+// Sign signs the authorized transaction. It is safe to call concurrently
+// for different authorizations. A held transaction is returned with its
+// *HeldError, for callers tracking what was signed; it must not be passed
+// on.
+func (a *Authorization) Sign(ctx context.Context) (*types.Transaction, error) {
+	var signedTx *types.Transaction
+	err := a.SignWith(ctx, func() ([]byte, common.Hash, error) {
+		var err error
+		if signedTx, err = signTxWithBackend(ctx, a.engine.Key, a.Tx, a.chainID); err != nil {
+			return nil, common.Hash{}, err
+		}
+		raw, err := signedTx.MarshalBinary()
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		return raw, signedTx.Hash(), nil
+	})
+	if err != nil && !IsHeld(err) {
+		return nil, err
+	}
+	return signedTx, err
+}
+
+// SignWith completes the authorization with sign, which signs the
+// authorized transaction in whatever encoding the chain needs and returns
+// the signed transaction and its hash. The signature is recorded against
+// the nonce and in the audit log like one made by sign. A transaction
+// under a time delay is queued, and an error returned in its place.
+func (a *Authorization) SignWith(ctx context.Context, sign func() ([]byte, common.Hash, error)) error {
+	raw, txHash, err := sign()
+	if err == nil {
+		err = a.engine.recordSigned(ctx, a.Tx, txHash, a.chainID)
+	}
+	if err != nil {
+		txHash = common.Hash{}
+	}
+	if aerr := a.engine.AuditTx(a.Tx, a.chainID, a.Decision, txHash, err); aerr != nil && err == nil {
+		return AuditRecordFailed(aerr)
+	}
+	if err != nil {
+		return err
+	}
+	a.engine.completeApproval(a.policy, a.Tx, a.chainID)
+	a.engine.completeAlert(a.policy, a.Tx, a.chainID)
+	a.engine.recordRecipient(ctx, a.Tx)
+	if a.holdFor > 0 {
+		err := a.engine.hold(a.Tx, a.chainID, raw, txHash, a.holdFor)
+		var held *HeldError
+		if errors.As(err, &held) {
+			a.engine.postSigned(ctx, a.policy, a.Tx, a.chainID, raw, txHash, held)
+		}
+		return err
+	}
+	a.engine.postSigned(ctx, a.policy, a.Tx, a.chainID, raw, txHash, nil)
+	return nil
+}
+
+// AuditTx records an attempt to sign tx for chainID. txHash is the hash of
+// the signed transaction, zero unless signing succeeded.
+func (e *Engine) AuditTx(tx *types.Transaction, chainID *big.Int, d *policy.Decision, txHash common.Hash, err error) error {
+	if e.Audit == nil {
+		return nil
+	}
+	// Blobs are recorded by their versioned hashes only.
+	request, merr := tx.WithoutBlobTxSidecar().MarshalJSON()
+	if merr != nil {
+		return merr
+	}
+	entry := &AuditEntry{
+		Kind:        AuditTransaction,
+		Account:     e.Key.Address().Hex(),
+		ChainID:     chainID.String(),
+		Request:     request,
+		SigningHash: types.LatestSignerForChainID(chainID).Hash(tx).Hex(),
+		Allowed:     d.Allowed,
+		Decision:    d,
+	}
+	if txHash != (common.Hash{}) {
+		entry.TxHash = txHash.Hex()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return e.Audit.record(entry)
+}
+
+// spendLimits returns the rolling limits configured in p.
+func spendLimits(p *policy.Policy) []spendLimit {
+	var limits []spendLimit
+	if p.DailyLimitWei != nil {
+		limits = append(limits, spendLimit{name: "daily", window: 24 * time.Hour, max: p.DailyLimitWei})
+	}
+	if p.WeeklyLimitWei != nil {
+		limits = append(limits, spendLimit{name: "weekly", window: 7 * 24 * time.Hour, max: p.WeeklyLimitWei})
+	}
+	return limits
+}
+
+// txLimits returns the rate limits configured in p.
+func txLimits(p *policy.Policy) []spendLimit {
+	var limits []spendLimit
+	if p.MaxTxPerHour > 0 {
+		limits = append(limits, spendLimit{name: "hourly transaction", window: time.Hour, max: new(big.Int).SetUint64(p.MaxTxPerHour)})
+	}
+	if p.MaxTxPerDay > 0 {
+		limits = append(limits, spendLimit{name: "daily transaction", window: 24 * time.Hour, max: new(big.Int).SetUint64(p.MaxTxPerDay)})
+	}
+	return limits
+}
+
+// reserveSpend records the value of tx against the sender's rolling limits
+// on chainID and the daily limits of its recipient and token, if any, and
+// counts it against the rate limits.
+func (e *Engine) reserveSpend(ctx context.Context, pol *policy.Policy, tx *types.Transaction, chainID *big.Int, now time.Time, d *policy.Decision) error {
+	from := e.Key.Address().Hex()
+	var reservations []spendReservation
+	if limits := spendLimits(pol); len(limits) > 0 {
+		reservations = append(reservations, spendReservation{
+			key:    spendKey(chainID, from, "eth"),
+			amount: tx.Value(),
+			limits: limits,
+		})
+	}
+	if rp, ok := pol.Recipient(*tx.To()); ok && rp.DailyLimitWei != nil {
+		reservations = append(reservations, spendReservation{
+			key:    spendKey(chainID, from, "eth", tx.To().Hex()),
+			amount: tx.Value(),
+			limits: []spendLimit{{name: "recipient daily", window: 24 * time.Hour, max: rp.DailyLimitWei}},
+		})
+	}
+	if r, ok := tokenReservation(pol, tx, chainID, from); ok {
+		reservations = append(reservations, r)
+	}
+	if limits := txLimits(pol); len(limits) > 0 {
+		reservations = append(reservations, spendReservation{
+			key:    spendKey(chainID, from, "tx"),
+			amount: big.NewInt(1),
+			limits: limits,
+		})
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+	if e.Store == nil {
+		return d.Record("limits", errors.New("policy has cumulative limits but no state store is configured"))
+	}
+	err := tracing.StoreOp(ctx, "ReserveSpend", func() error { return e.Store.ReserveSpend(now, reservations) })
+	var le *LimitError
+	if errors.As(err, &le) {
+		return d.Fail("limits."+le.limit.name, le.limit.max, new(big.Int).Add(le.spent, le.amount), err.Error())
+	}
+	if err != nil {
+		return d.Record("limits", err)
+	}
+	for _, r := range reservations {
+		for _, limit := range r.limits {
+			d.Pass("limits."+limit.name, limit.max, nil)
+		}
+	}
+	return nil
+}
+
+// spendKey identifies a spending counter by chain, sender and asset,
+// optionally narrowed to a recipient.
+func spendKey(chainID *big.Int, parts ...string) string {
+	key := chainID.String()
+	for _, p := range parts {
+		key += ":" + strings.ToLower(p)
+	}
+	return key
+}
+
+// signTxWithBackend signs tx for chainID with key. No policy is applied.
+func signTxWithBackend(ctx context.Context, key keys.Backend, tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	ctx, span := tracing.StartSpan(ctx, "key.sign", attribute.String("secure_signer.backend", fmt.Sprintf("%T", UnwrapKey(key))))
+	defer func() { tracing.EndSpan(span, err) }()
+	signer := types.LatestSignerForChainID(chainID)
+	if ts, ok := key.(keys.TxSigningBackend); ok {
+		signedTx, err := ts.SignTx(ctx, tx, chainID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign tx: %v", err)
+		}
+		// The device may have been handed a different transaction or
+		// account; only release what matches the request.
+		if signer.Hash(signedTx) != signer.Hash(tx) {
+			return nil, errors.New("device signed a different transaction")
+		}
+		if from, err := types.Sender(signer, signedTx); err != nil || from != key.Address() {
+			return nil, errors.New("device signature does not match the selected account")
+		}
+		return signedTx, nil
+	}
+	sig, err := key.SignHash(ctx, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tx: %v", err)
+	}
+	signedTx, err = tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tx: %v", err)
+	}
+	return signedTx, nil
+}
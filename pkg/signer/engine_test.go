@@ -0,0 +1,160 @@
+package signer
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var testRecipient = common.HexToAddress("0x2000000000000000000000000000000000000002")
+
+// testEngine returns an engine with a fresh key and sqlite store, signing
+// under the policy document doc.
+func testEngine(t *testing.T, doc string) *Engine {
+	t.Helper()
+	pol, err := policy.Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := keys.SecretKeyFromECDSA(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return New(pol, keys.NewLocalKey(secret), store)
+}
+
+// signTransfer signs a transfer of value wei to testRecipient with nonce on
+// chain 1, returning the check that denied it, or "" if it was signed.
+func signTransfer(t *testing.T, e *Engine, nonce uint64, value int64) string {
+	t.Helper()
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		To:        &testRecipient,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(1),
+		GasTipCap: big.NewInt(1),
+		Value:     big.NewInt(value),
+	})
+	_, d, err := e.SignTx(t.Context(), tx, big.NewInt(1))
+	if err == nil {
+		return ""
+	}
+	if check := d.FailedCheck(); check != "" {
+		return check
+	}
+	t.Fatalf("SignTx failed without a failed check: %v", err)
+	return ""
+}
+
+func TestSpendLimits(t *testing.T) {
+	e := testEngine(t, `{
+		"max_amount_wei": 100,
+		"daily_limit_wei": 150,
+		"whitelist": {"`+testRecipient.Hex()+`": {"daily_limit_wei": 120}}
+	}`)
+	steps := []struct {
+		value int64
+		want  string
+	}{
+		{100, ""},
+		{30, "limits.recipient daily"},
+		{20, ""},
+		// A denied transaction is not counted.
+		{1, "limits.recipient daily"},
+	}
+	for i, step := range steps {
+		if got := signTransfer(t, e, uint64(i), step.value); got != step.want {
+			t.Fatalf("transfer %d of %d wei: failed check = %q, want %q", i, step.value, got, step.want)
+		}
+	}
+
+	e = testEngine(t, `{"max_amount_wei": 100, "daily_limit_wei": 150, "weekly_limit_wei": 120, "whitelist": ["`+testRecipient.Hex()+`"]}`)
+	for i, step := range []struct {
+		value int64
+		want  string
+	}{{100, ""}, {51, "limits.daily"}, {21, "limits.weekly"}, {20, ""}} {
+		if got := signTransfer(t, e, uint64(i), step.value); got != step.want {
+			t.Fatalf("transfer %d of %d wei: failed check = %q, want %q", i, step.value, got, step.want)
+		}
+	}
+}
+
+func TestReserveSpendRollingWindow(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			reserve := func(at time.Duration, amount int64) error {
+				return store.ReserveSpend(start.Add(at), []spendReservation{{
+					key:    "1:sender:eth",
+					amount: big.NewInt(amount),
+					limits: []spendLimit{{name: "daily", window: 24 * time.Hour, max: big.NewInt(150)}},
+				}})
+			}
+			steps := []struct {
+				at     time.Duration
+				amount int64
+				ok     bool
+			}{
+				{0, 100, true},
+				{time.Hour, 50, true},
+				{2 * time.Hour, 1, false},
+				// The first spend has left the window; the second has not.
+				{24*time.Hour + time.Second, 100, true},
+				{24*time.Hour + 2*time.Second, 1, false},
+				{25*time.Hour + time.Second, 50, true},
+			}
+			for _, step := range steps {
+				err := reserve(step.at, step.amount)
+				if (err == nil) != step.ok {
+					t.Fatalf("reserving %d at +%s: err = %v, want ok %v", step.amount, step.at, err, step.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	e := testEngine(t, `{"max_amount_wei": 100, "daily_limit_wei": 150, "max_tx_per_hour": 3, "max_tx_per_day": 10, "whitelist": ["`+testRecipient.Hex()+`"]}`)
+	steps := []struct {
+		value int64
+		want  string
+	}{
+		{100, ""},
+		// Denied by the spending limit, so it does not use up a
+		// transaction either.
+		{100, "limits.daily"},
+		{10, ""},
+		{10, ""},
+		{1, "limits.hourly transaction"},
+	}
+	for i, step := range steps {
+		if got := signTransfer(t, e, uint64(i), step.value); got != step.want {
+			t.Fatalf("transfer %d: failed check = %q, want %q", i, got, step.want)
+		}
+	}
+
+	e = testEngine(t, `{"max_amount_wei": 100, "max_tx_per_day": 1, "whitelist": ["`+testRecipient.Hex()+`"]}`)
+	if got := signTransfer(t, e, 0, 0); got != "" {
+		t.Fatalf("first transfer: failed check = %q", got)
+	}
+	if got := signTransfer(t, e, 1, 0); got != "limits.daily transaction" {
+		t.Fatalf("second transfer: failed check = %q, want %q", got, "limits.daily transaction")
+	}
+}
@@ -0,0 +1,352 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/fileutil"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fileStore is a Store backed by a single JSON file. Every update holds an
+// exclusive lock on a sibling .lock file, so concurrent CLI invocations and
+// the daemon can share it, and rewrites the file atomically. Its audit log
+// is the audit file next to it, <name>-audit.jsonl.
+type fileStore struct {
+	AuditStore
+	path string
+	mu   sync.Mutex
+}
+
+// fileState is the on-disk layout of a fileStore.
+type fileState struct {
+	Spends map[string][]spendRecord `json:"spends"`
+	Nonces map[string]uint64        `json:"nonces,omitempty"`
+	// Signed holds the transaction signed for each nonce, per chain and
+	// sender.
+	Signed map[string]map[uint64]SignedRecord `json:"signed,omitempty"`
+	// Recipients holds, per sender, when each recipient was first paid.
+	Recipients map[string]map[common.Address]time.Time `json:"recipients,omitempty"`
+	// Idempotency holds, per sender, the outcome of each request made with
+	// an idempotency key.
+	Idempotency map[string]map[string]idempotencyRecord `json:"idempotency,omitempty"`
+	// Freeze is the current freeze, if signing is frozen.
+	Freeze *FreezeState `json:"freeze,omitempty"`
+	// Approvals holds the pending approval requests.
+	Approvals map[common.Hash]*ApprovalRequest `json:"approvals,omitempty"`
+	// Held holds the time-delayed transactions.
+	Held map[common.Hash]*HeldTx `json:"held,omitempty"`
+}
+
+type spendRecord struct {
+	Amount *big.Int  `json:"amount"`
+	At     time.Time `json:"at"`
+}
+
+func newFileStore(path string) *fileStore {
+	return &fileStore{AuditStore: OpenAuditFile(strings.TrimSuffix(path, ".json") + "-audit.jsonl"), path: path}
+}
+
+func (s *fileStore) Close() error { return nil }
+
+// Ping takes the lock and parses the state without rewriting it.
+func (s *fileStore) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := fileutil.Lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, new(fileState))
+}
+
+// update runs fn on the current state under the lock and persists the
+// result if fn succeeds.
+func (s *fileStore) update(fn func(*fileState) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := fileutil.Lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state := new(fileState)
+	data, err := os.ReadFile(s.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, state); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	if state.Spends == nil {
+		state.Spends = make(map[string][]spendRecord)
+	}
+	if state.Nonces == nil {
+		state.Nonces = make(map[string]uint64)
+	}
+	if state.Signed == nil {
+		state.Signed = make(map[string]map[uint64]SignedRecord)
+	}
+	if state.Recipients == nil {
+		state.Recipients = make(map[string]map[common.Address]time.Time)
+	}
+	if state.Idempotency == nil {
+		state.Idempotency = make(map[string]map[string]idempotencyRecord)
+	}
+	if state.Approvals == nil {
+		state.Approvals = make(map[common.Hash]*ApprovalRequest)
+	}
+	if state.Held == nil {
+		state.Held = make(map[common.Hash]*HeldTx)
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(s.path, data)
+}
+
+func (s *fileStore) ReserveSpend(now time.Time, reservations []spendReservation) error {
+	return s.update(func(state *fileState) error {
+		pruned := make([][]spendRecord, len(reservations))
+		for i, r := range reservations {
+			for _, rec := range state.Spends[r.key] {
+				if rec.At.After(now.Add(-maxSpendWindow)) {
+					pruned[i] = append(pruned[i], rec)
+				}
+			}
+			if err := checkSpend(pruned[i], r.amount, now, r.limits); err != nil {
+				return err
+			}
+		}
+		for i, r := range reservations {
+			state.Spends[r.key] = append(pruned[i], spendRecord{Amount: new(big.Int).Set(r.amount), At: now})
+		}
+		return nil
+	})
+}
+
+func (s *fileStore) NextNonce(key string, floor uint64) (uint64, error) {
+	var nonce uint64
+	err := s.update(func(state *fileState) error {
+		nonce = max(state.Nonces[key], floor)
+		state.Nonces[key] = nonce + 1
+		return nil
+	})
+	return nonce, err
+}
+
+func (s *fileStore) ReleaseNonce(key string, nonce uint64) error {
+	return s.update(func(state *fileState) error {
+		if next, ok := state.Nonces[key]; ok && next == nonce+1 {
+			state.Nonces[key] = nonce
+		}
+		return nil
+	})
+}
+
+func (s *fileStore) Nonce(key string) (uint64, bool, error) {
+	var (
+		next uint64
+		ok   bool
+	)
+	err := s.update(func(state *fileState) error {
+		next, ok = state.Nonces[key]
+		return nil
+	})
+	return next, ok, err
+}
+
+func (s *fileStore) SetNonce(key string, next uint64) error {
+	return s.update(func(state *fileState) error {
+		state.Nonces[key] = next
+		return nil
+	})
+}
+
+func (s *fileStore) SignedTx(key string, nonce uint64) (SignedRecord, bool, error) {
+	var (
+		rec SignedRecord
+		ok  bool
+	)
+	err := s.update(func(state *fileState) error {
+		rec, ok = state.Signed[key][nonce]
+		return nil
+	})
+	return rec, ok, err
+}
+
+func (s *fileStore) RecordSigned(key string, rec SignedRecord, replace bool) error {
+	return s.update(func(state *fileState) error {
+		existing, ok := state.Signed[key][rec.Nonce]
+		if ok && existing.SigningHash != rec.SigningHash && !replace {
+			return &ReplayError{Existing: existing}
+		}
+		if state.Signed[key] == nil {
+			state.Signed[key] = make(map[uint64]SignedRecord)
+		}
+		state.Signed[key][rec.Nonce] = rec
+		return nil
+	})
+}
+
+func (s *fileStore) KnownRecipient(key string, to common.Address) (bool, error) {
+	var known bool
+	err := s.update(func(state *fileState) error {
+		_, known = state.Recipients[key][to]
+		return nil
+	})
+	return known, err
+}
+
+func (s *fileStore) AddRecipient(key string, to common.Address, now time.Time) error {
+	return s.update(func(state *fileState) error {
+		if state.Recipients[key] == nil {
+			state.Recipients[key] = make(map[common.Address]time.Time)
+		}
+		if _, ok := state.Recipients[key][to]; !ok {
+			state.Recipients[key][to] = now
+		}
+		return nil
+	})
+}
+
+func (s *fileStore) ClaimIdempotency(key, id string, request common.Hash, now time.Time) (idempotencyRecord, bool, error) {
+	var (
+		rec idempotencyRecord
+		ok  bool
+	)
+	err := s.update(func(state *fileState) error {
+		records := state.Idempotency[key]
+		if records == nil {
+			records = make(map[string]idempotencyRecord)
+			state.Idempotency[key] = records
+		}
+		for k, r := range records {
+			if now.Sub(r.At) > idempotencyTTL || (len(r.Raw) == 0 && now.Sub(r.At) > idempotencyStale) {
+				delete(records, k)
+			}
+		}
+		if rec, ok = records[id]; ok {
+			return nil
+		}
+		records[id] = idempotencyRecord{Request: request, At: now.UTC()}
+		return nil
+	})
+	return rec, ok, err
+}
+
+func (s *fileStore) CompleteIdempotency(key, id string, rec *idempotencyRecord) error {
+	return s.update(func(state *fileState) error {
+		if rec == nil {
+			delete(state.Idempotency[key], id)
+			return nil
+		}
+		if state.Idempotency[key] == nil {
+			state.Idempotency[key] = make(map[string]idempotencyRecord)
+		}
+		state.Idempotency[key][id] = *rec
+		return nil
+	})
+}
+
+func (s *fileStore) Freeze() (*FreezeState, error) {
+	var f *FreezeState
+	err := s.update(func(state *fileState) error {
+		f = state.Freeze
+		return nil
+	})
+	return f, err
+}
+
+func (s *fileStore) UpdateFreeze(fn func(*FreezeState) (*FreezeState, error)) error {
+	return s.update(func(state *fileState) error {
+		f, err := fn(state.Freeze)
+		if err != nil {
+			return err
+		}
+		state.Freeze = f
+		return nil
+	})
+}
+
+func (s *fileStore) ApprovalRequest(id common.Hash) (*ApprovalRequest, error) {
+	var req *ApprovalRequest
+	err := s.update(func(state *fileState) error {
+		req = state.Approvals[id]
+		return nil
+	})
+	return req, err
+}
+
+func (s *fileStore) UpdateApprovalRequest(id common.Hash, fn func(*ApprovalRequest) (*ApprovalRequest, error)) error {
+	return s.update(func(state *fileState) error {
+		req, err := fn(state.Approvals[id])
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			delete(state.Approvals, id)
+		} else {
+			state.Approvals[id] = req
+		}
+		return nil
+	})
+}
+
+func (s *fileStore) HeldTx(hash common.Hash) (*HeldTx, error) {
+	var h *HeldTx
+	err := s.update(func(state *fileState) error {
+		h = state.Held[hash]
+		return nil
+	})
+	return h, err
+}
+
+func (s *fileStore) HeldTxs() ([]*HeldTx, error) {
+	var held []*HeldTx
+	err := s.update(func(state *fileState) error {
+		for _, h := range state.Held {
+			held = append(held, h)
+		}
+		return nil
+	})
+	sort.Slice(held, func(i, j int) bool { return held[i].HeldAt.Before(held[j].HeldAt) })
+	return held, err
+}
+
+func (s *fileStore) UpdateHeldTx(hash common.Hash, fn func(*HeldTx) (*HeldTx, error)) error {
+	return s.update(func(state *fileState) error {
+		h, err := fn(state.Held[hash])
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			delete(state.Held, hash)
+		} else {
+			state.Held[hash] = h
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// FreezeState is a freeze recorded in the state store. While there is one,
+// every signature is refused. The approvers and quorum that may lift the
+// freeze are taken from the policy when it is frozen, and approvals are
+// EIP-191 personal signatures over the random ID, so approvals for an
+// earlier freeze cannot be replayed.
+type FreezeState struct {
+	ID        common.Hash     `json:"id"`
+	FrozenAt  time.Time       `json:"frozen_at"`
+	FrozenBy  string          `json:"frozen_by"`
+	Reason    string          `json:"reason"`
+	Approvers []string        `json:"approvers"`
+	Quorum    int             `json:"quorum"`
+	Approvals []ApprovalEntry `json:"approvals"`
+}
+
+// ApprovedBy returns the distinct listed approvers with a valid signature on
+// the freeze ID.
+func (f *FreezeState) ApprovedBy() map[common.Address]bool {
+	return (&ApprovalRequest{ID: f.ID, Approvals: f.Approvals}).ApprovedBy(&policy.ApprovalPolicy{Approvers: f.Approvers, Quorum: f.Quorum})
+}
+
+// checkFrozen returns an error while store records a freeze. A freeze that
+// cannot be read counts as one.
+func checkFrozen(store Store) error {
+	f, err := store.Freeze()
+	if err != nil {
+		return fmt.Errorf("signing is frozen: failed to read the freeze: %v", err)
+	}
+	if f == nil {
+		return nil
+	}
+	return fmt.Errorf("signing is frozen since %s by %s: %s", f.FrozenAt.Format(time.RFC3339), f.FrozenBy, f.Reason)
+}
+
+// frozenGuard refuses every signature of its backend while its store
+// records a freeze, so that a running daemon stops signing as soon as it is
+// frozen.
+type frozenGuard struct {
+	keys.Backend
+	store Store
+}
+
+func (g frozenGuard) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	if err := checkFrozen(g.store); err != nil {
+		return nil, err
+	}
+	return g.Backend.SignHash(ctx, hash)
+}
+
+// frozenWalletGuard is frozenGuard for backends that sign whole
+// transactions and typed data themselves.
+type frozenWalletGuard struct {
+	frozenGuard
+	wallet interface {
+		keys.TxSigningBackend
+		keys.TypedDataSigningBackend
+	}
+}
+
+func (g frozenWalletGuard) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if err := checkFrozen(g.store); err != nil {
+		return nil, err
+	}
+	return g.wallet.SignTx(ctx, tx, chainID)
+}
+
+func (g frozenWalletGuard) SignTypedData(ctx context.Context, domainSeparator, messageHash []byte) ([]byte, error) {
+	if err := checkFrozen(g.store); err != nil {
+		return nil, err
+	}
+	return g.wallet.SignTypedData(ctx, domainSeparator, messageHash)
+}
+
+// GuardFrozen wraps key so that it refuses to sign while store records a
+// freeze.
+func GuardFrozen(key keys.Backend, store Store) keys.Backend {
+	guard := frozenGuard{Backend: key, store: store}
+	if wallet, ok := key.(interface {
+		keys.TxSigningBackend
+		keys.TypedDataSigningBackend
+	}); ok {
+		return frozenWalletGuard{frozenGuard: guard, wallet: wallet}
+	}
+	return guard
+}
+
+// UnwrapKey returns the backend behind the freeze guard of key.
+func UnwrapKey(key keys.Backend) keys.Backend {
+	switch g := key.(type) {
+	case frozenGuard:
+		return g.Backend
+	case frozenWalletGuard:
+		return g.Backend
+	}
+	return key
+}
@@ -0,0 +1,99 @@
+package signer
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestFreeze(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := checkFrozen(store); err != nil {
+				t.Fatalf("not frozen yet: %v", err)
+			}
+			freeze := &FreezeState{ID: common.HexToHash("0x01"), FrozenAt: time.Now().UTC(), FrozenBy: "ops", Reason: "incident", Quorum: 2}
+			set := func(cur *FreezeState) (*FreezeState, error) {
+				if cur != nil {
+					return nil, errors.New("already frozen")
+				}
+				return freeze, nil
+			}
+			if err := store.UpdateFreeze(set); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpdateFreeze(set); err == nil {
+				t.Fatal("froze twice")
+			}
+			if err := checkFrozen(store); err == nil {
+				t.Fatal("signing not refused while frozen")
+			}
+
+			// Approvals recorded concurrently must all be kept.
+			var wg sync.WaitGroup
+			for i := range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := store.UpdateFreeze(func(cur *FreezeState) (*FreezeState, error) {
+						next := *cur
+						next.Approvals = append(append([]ApprovalEntry(nil), cur.Approvals...), ApprovalEntry{Approver: common.BigToAddress(common.Big1), Signature: []byte{byte(i)}})
+						return &next, nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			f, err := store.Freeze()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.ID != freeze.ID || len(f.Approvals) != 8 {
+				t.Fatalf("freeze %s has %d approvals, want %s with 8", f.ID, len(f.Approvals), freeze.ID)
+			}
+
+			if err := store.UpdateFreeze(func(*FreezeState) (*FreezeState, error) { return nil, nil }); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkFrozen(store); err != nil {
+				t.Fatalf("still frozen after lifting: %v", err)
+			}
+		})
+	}
+}
+
+func TestGuardFrozen(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := keys.SecretKeyFromECDSA(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := GuardFrozen(keys.NewLocalKey(secret), store)
+	if _, err := key.SignHash(t.Context(), make([]byte, 32)); err != nil {
+		t.Fatalf("refused while not frozen: %v", err)
+	}
+	err = store.UpdateFreeze(func(*FreezeState) (*FreezeState, error) {
+		return &FreezeState{FrozenAt: time.Now(), FrozenBy: "ops", Reason: "incident"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.SignHash(t.Context(), make([]byte, 32)); err == nil {
+		t.Fatal("signed while frozen")
+	}
+}
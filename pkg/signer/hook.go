@@ -0,0 +1,113 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxHookResponse caps the response read from a hook.
+const maxHookResponse = 1 << 20
+
+// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+// body of webhook deliveries and hook requests.
+const WebhookSignatureHeader = "X-Signer-Signature"
+
+// callHook hands body to h and returns its response: the body of a 2xx
+// answer, or the standard output of a command that exited 0.
+func callHook(ctx context.Context, h *policy.HookTarget, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout())
+	defer cancel()
+	if h.URL == "" {
+		cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s", h.Timeout())
+		}
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("%v: %s", err, msg)
+			}
+			return nil, err
+		}
+		return out, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sig, ok := h.Signature(body); ok {
+		req.Header.Set(WebhookSignatureHeader, sig)
+	}
+	resp, err := tracing.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s", h.Timeout())
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(io.LimitReader(resp.Body, maxHookResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("answered %s", resp.Status)
+	}
+	return out, nil
+}
+
+// preSignResponse is the answer of a pre-sign hook.
+type preSignResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+// checkPreSignHook asks the policy's pre-sign hook to approve tx.
+func (e *Engine) checkPreSignHook(ctx context.Context, pol *policy.Policy, tx *types.Transaction, chainID *big.Int, now time.Time) (err error) {
+	h := pol.PreSignHook
+	ctx, span := tracing.StartSpan(ctx, "pre_sign_hook")
+	defer func() { tracing.EndSpan(span, err) }()
+	input := policy.RegoInput(tx, e.Key.Address(), chainID, now)
+	input["signing_hash"] = types.LatestSignerForChainID(chainID).Hash(tx).Hex()
+	input["policy"] = pol.Digest
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	out, err := callHook(ctx, &h.HookTarget, body)
+	if err != nil {
+		return fmt.Errorf("pre-sign hook %s failed: %v", h.Name(), err)
+	}
+	var resp preSignResponse
+	if out = bytes.TrimSpace(out); bytes.HasPrefix(out, []byte("{")) {
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return fmt.Errorf("pre-sign hook %s answered invalid JSON: %v", h.Name(), err)
+		}
+	} else {
+		resp.Decision = string(out)
+	}
+	if !strings.EqualFold(resp.Decision, "approve") {
+		if resp.Reason != "" {
+			return fmt.Errorf("pre-sign hook denied the transaction: %s", resp.Reason)
+		}
+		return errors.New("pre-sign hook denied the transaction")
+	}
+	return nil
+}
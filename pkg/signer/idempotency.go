@@ -0,0 +1,151 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// idempotencyTTL is how long an idempotency key is remembered after it was
+// first used.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyStale is how long a claim may stay pending, for example after
+// the signer crashed while signing, before a retry may take it over.
+const idempotencyStale = 5 * time.Minute
+
+// maxIdempotencyKey is the longest idempotency key accepted.
+const maxIdempotencyKey = 255
+
+// idempotencyHeader carries the idempotency key of a daemon request.
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotencyRecord is the stored outcome of a request with an idempotency
+// key. Raw is empty while the request is being signed.
+type idempotencyRecord struct {
+	// Request fingerprints the request, so that a key reused for a
+	// different request is refused.
+	Request common.Hash   `json:"request"`
+	Raw     hexutil.Bytes `json:"raw,omitempty"`
+	// ReleaseAt is set when the transaction is held under a time delay.
+	ReleaseAt *time.Time `json:"release_at,omitempty"`
+	At        time.Time  `json:"at"`
+}
+
+// IdempotencyRequest fingerprints what a request asks to be signed: the
+// chain, recipient, value and data. The nonce, gas and fees are left out,
+// as a retry may fill them in differently.
+func IdempotencyRequest(chainID *big.Int, to *common.Address, value *big.Int, data []byte) common.Hash {
+	var toBytes []byte
+	if to != nil {
+		toBytes = to.Bytes()
+	}
+	return crypto.Keccak256Hash(common.LeftPadBytes(chainID.Bytes(), 32), toBytes, common.LeftPadBytes(value.Bytes(), 32), data)
+}
+
+func checkIdempotencyKey(id string) error {
+	if len(id) > maxIdempotencyKey {
+		return fmt.Errorf("idempotency key is longer than %d bytes", maxIdempotencyKey)
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return errors.New("idempotency key must be printable ASCII without spaces")
+		}
+	}
+	return nil
+}
+
+// ClaimIdempotent claims the idempotency key id for request. If the key
+// was already used for it, it returns the transaction signed then with
+// found set, and for a transaction held under a time delay the *HeldError
+// too. The caller must call CompleteIdempotent once it has signed.
+func (e *Engine) ClaimIdempotent(ctx context.Context, id string, request common.Hash) (tx *types.Transaction, found bool, err error) {
+	if err := checkIdempotencyKey(id); err != nil {
+		return nil, false, err
+	}
+	var rec idempotencyRecord
+	err = tracing.StoreOp(ctx, "ClaimIdempotency", func() (err error) {
+		rec, found, err = e.Store.ClaimIdempotency(e.Key.Address().Hex(), id, request, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %v", err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	if rec.Request != request {
+		return nil, true, fmt.Errorf("idempotency key %q was used for a different request", id)
+	}
+	if len(rec.Raw) == 0 {
+		return nil, true, fmt.Errorf("a request with idempotency key %q is still being signed; retry later", id)
+	}
+	tx = new(types.Transaction)
+	if err := tx.UnmarshalBinary(rec.Raw); err != nil {
+		return nil, true, fmt.Errorf("invalid transaction stored for idempotency key %q: %v", id, err)
+	}
+	slog.Info("idempotent request replayed", "idempotency_key", id, "tx_hash", tx.Hash().Hex())
+	if rec.ReleaseAt != nil {
+		return tx, true, &HeldError{TxHash: tx.Hash(), ReleaseAt: *rec.ReleaseAt}
+	}
+	return tx, true, nil
+}
+
+// CompleteIdempotent records the outcome of the request claimed under id:
+// the signed transaction, including one that is held, so that retries get
+// it back, or, when signing failed, nothing, so that a retry signs anew.
+func (e *Engine) CompleteIdempotent(ctx context.Context, id string, request common.Hash, signedTx *types.Transaction, err error) {
+	var rec *idempotencyRecord
+	var held *HeldError
+	if signedTx != nil && (err == nil || errors.As(err, &held)) {
+		raw, merr := signedTx.MarshalBinary()
+		if merr != nil {
+			slog.Warn("failed to record idempotency key", "idempotency_key", id, "err", merr)
+			return
+		}
+		rec = &idempotencyRecord{Request: request, Raw: raw, At: time.Now().UTC()}
+		if held != nil {
+			rec.ReleaseAt = &held.ReleaseAt
+		}
+	}
+	cerr := tracing.StoreOp(ctx, "CompleteIdempotency", func() error {
+		return e.Store.CompleteIdempotency(e.Key.Address().Hex(), id, rec)
+	})
+	if cerr != nil {
+		slog.Warn("failed to record idempotency key", "idempotency_key", id, "err", cerr)
+	}
+}
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey passes the Idempotency-Key header of requests on in
+// their context, where the JSON-RPC methods find it.
+func WithIdempotencyKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(idempotencyHeader); id != "" {
+			r = r.WithContext(ContextWithIdempotencyKey(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ContextWithIdempotencyKey returns ctx carrying the idempotency key id,
+// which the engine then honours when signing.
+func ContextWithIdempotencyKey(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, id)
+}
+
+func IdempotencyKey(ctx context.Context) string {
+	id, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return id
+}
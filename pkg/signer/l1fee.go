@@ -0,0 +1,92 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// gasPriceOracle is the OP Stack GasPriceOracle predeploy.
+var gasPriceOracle = common.HexToAddress("0x420000000000000000000000000000000000000F")
+
+// selectorGetL1Fee is the 4-byte selector of getL1Fee(bytes).
+var selectorGetL1Fee = []byte{0x49, 0x94, 0x8e, 0x0e}
+
+// isOPStack reports whether chainID is an OP Stack chain in the registry,
+// whose transactions also pay an L1 data fee.
+func isOPStack(chainID *big.Int) bool {
+	c, ok := chains.ByID(chainID)
+	return ok && c.OPStack
+}
+
+// L1FeeOracle estimates the L1 data fee of OP Stack transactions with the
+// GasPriceOracle of the chain.
+type L1FeeOracle struct {
+	client *ethclient.Client
+}
+
+// NewL1FeeOracle returns an oracle using client, or nil without one.
+func NewL1FeeOracle(client *ethclient.Client) *L1FeeOracle {
+	if client == nil {
+		return nil
+	}
+	return &L1FeeOracle{client: client}
+}
+
+// l1Fee returns the L1 data fee the chain charges for tx. The oracle
+// prices the unsigned encoding, which differs from the signed one only in
+// the signature.
+func (o *L1FeeOracle) l1Fee(ctx context.Context, tx *types.Transaction) (*big.Int, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte{}, selectorGetL1Fee...)
+	data = append(data, txbuilder.Word(big.NewInt(32))...)
+	data = append(data, txbuilder.Word(big.NewInt(int64(len(raw))))...)
+	data = append(data, common.RightPadBytes(raw, (len(raw)+31)/32*32)...)
+	out, err := o.client.CallContract(ctx, ethereum.CallMsg{To: &gasPriceOracle, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 data fee: %v", err)
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("failed to fetch L1 data fee: unexpected GasPriceOracle response")
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// checkL1Fee estimates the L1 data fee of tx on an OP Stack chain and
+// applies the total cost cap to its cost including that fee. It returns
+// nil on other chains, and offline, where the fee cannot be estimated.
+func (e *Engine) checkL1Fee(ctx context.Context, pol *policy.Policy, tx *types.Transaction, chainID *big.Int, d *policy.Decision) (*big.Int, error) {
+	if !isOPStack(chainID) {
+		return nil, nil
+	}
+	if e.L1Fees == nil {
+		slog.Warn("L1 data fee not estimated without an rpc endpoint", "chain", chainID)
+		return nil, nil
+	}
+	fee, err := e.L1Fees.l1Fee(ctx, tx)
+	if err != nil {
+		return nil, d.Record("fees.l1_data_fee", err)
+	}
+	limit := pol.Fees.MaxTotalCostWei
+	if limit == nil {
+		return fee, nil
+	}
+	total := new(big.Int).Add(tx.Cost(), fee)
+	if total.Cmp(limit) > 0 {
+		return nil, d.Fail("fees.max_total_cost_wei", limit, total, fmt.Sprintf("total cost %s including the L1 data fee %s exceeds policy cap %s", total, fee, limit))
+	}
+	d.Pass("fees.max_total_cost_wei", limit, total)
+	return fee, nil
+}
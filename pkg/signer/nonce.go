@@ -0,0 +1,77 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// NonceManager hands out nonces for an account from the state store, so
+// concurrent CLI invocations and daemon requests never reuse one. When a
+// node is available its pending nonce is the floor, which picks up
+// transactions sent from elsewhere.
+type NonceManager struct {
+	Store  Store
+	Client *ethclient.Client
+	From   common.Address
+	// OnGap, when set, is told that count nonces from floor were handed
+	// out but are not pending on the node; otherwise the gap is logged.
+	OnGap func(floor, count uint64)
+}
+
+func NonceKey(chainID *big.Int, from common.Address) string {
+	return spendKey(chainID, from.Hex())
+}
+
+// Next hands out the next nonce for the account on chainID.
+func (m *NonceManager) Next(ctx context.Context, chainID *big.Int) (uint64, error) {
+	floor, err := m.PendingNonce(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var nonce uint64
+	err = tracing.StoreOp(ctx, "NextNonce", func() (err error) {
+		nonce, err = m.Allocate(chainID, floor)
+		return err
+	})
+	return nonce, err
+}
+
+// PendingNonce returns the node's pending nonce, or 0 without a node.
+func (m *NonceManager) PendingNonce(ctx context.Context) (uint64, error) {
+	if m.Client == nil {
+		return 0, nil
+	}
+	return txbuilder.FetchNonce(ctx, m.Client, m.From)
+}
+
+// Allocate hands out the next nonce that is at least floor, warning when
+// the store is ahead of floor, i.e. nonces were handed out that the node
+// has not seen.
+func (m *NonceManager) Allocate(chainID *big.Int, floor uint64) (uint64, error) {
+	nonce, err := m.Store.NextNonce(NonceKey(chainID, m.From), floor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate nonce: %v", err)
+	}
+	if m.Client != nil && nonce > floor {
+		if m.OnGap != nil {
+			m.OnGap(floor, nonce-floor)
+		} else {
+			slog.Warn("nonce gap: handed-out nonces are not pending on the node", "account", m.From.Hex(), "floor", floor, "count", nonce-floor)
+		}
+	}
+	return nonce, nil
+}
+
+// Release returns an unused nonce so it does not leave a gap.
+func (m *NonceManager) Release(chainID *big.Int, nonce uint64) {
+	if err := m.Store.ReleaseNonce(NonceKey(chainID, m.From), nonce); err != nil {
+		slog.Warn("failed to release nonce", "account", m.From.Hex(), "nonce", nonce, "err", err)
+	}
+}
@@ -0,0 +1,106 @@
+package signer
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresMigrations is the schema of a Postgres store. Times are Unix
+// nanoseconds, as in the SQLite schema, so both share their queries.
+var postgresMigrations = []string{
+	`CREATE TABLE spends (
+		counter TEXT NOT NULL,
+		amount NUMERIC(78, 0) NOT NULL,
+		at BIGINT NOT NULL
+	);
+	CREATE INDEX spends_counter_at ON spends (counter, at);
+	CREATE TABLE nonces (
+		key TEXT PRIMARY KEY,
+		next BIGINT NOT NULL
+	);
+	CREATE TABLE signed (
+		key TEXT NOT NULL,
+		nonce BIGINT NOT NULL,
+		signing_hash TEXT NOT NULL,
+		tx_hash TEXT NOT NULL,
+		at BIGINT NOT NULL,
+		PRIMARY KEY (key, nonce)
+	);
+	CREATE TABLE recipients (
+		key TEXT NOT NULL,
+		address TEXT NOT NULL,
+		first_paid BIGINT NOT NULL,
+		PRIMARY KEY (key, address)
+	);
+	CREATE TABLE idempotency (
+		key TEXT NOT NULL,
+		id TEXT NOT NULL,
+		request TEXT NOT NULL,
+		raw BYTEA,
+		release_at BIGINT,
+		at BIGINT NOT NULL,
+		PRIMARY KEY (key, id)
+	)`,
+	`CREATE TABLE freeze (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		state TEXT NOT NULL
+	)`,
+	`CREATE TABLE approvals (
+		id TEXT PRIMARY KEY,
+		request TEXT NOT NULL
+	);
+	CREATE TABLE held (
+		hash TEXT PRIMARY KEY,
+		held_at BIGINT NOT NULL,
+		tx TEXT NOT NULL
+	);
+	CREATE TABLE audit (
+		seq BIGINT PRIMARY KEY,
+		hash TEXT NOT NULL,
+		entry TEXT NOT NULL
+	)`,
+}
+
+var postgresDialect = &sqlDialect{
+	name:       "postgres",
+	migrations: postgresMigrations,
+	rebind:     postgresRebind,
+	// Transactions run at READ COMMITTED, so updates serialize on an
+	// advisory lock held until they commit.
+	lock: func(ctx context.Context, tx *sql.Tx, name string) error {
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, name)
+		return err
+	},
+}
+
+// postgresRebind numbers the ? placeholders of query as $1, $2 and so on.
+func postgresRebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// newPostgresStore connects to the Postgres database at location, a
+// postgres:// or postgresql:// URL as understood by pgx, e.g.
+// postgres://signer:password@db:5432/signer?sslmode=verify-full, and
+// creates or migrates the schema. Several daemon replicas can share it, so
+// that the signer needs no persistent disk for its state: they share the
+// nonces, spending counters, signed transactions, idempotency keys,
+// approval requests, time-delayed transactions, the freeze and, with
+// -audit-log store, the audit log. Alert acknowledgements, the unlocked
+// session key and an audit log file stay local to each replica.
+func newPostgresStore(location string) (*sqlStore, error) {
+	return openSQLStore("pgx", location, postgresDialect)
+}
@@ -0,0 +1,149 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Post-sign hook events.
+const (
+	postSignSigned = "signed"
+	postSignHeld   = "held"
+)
+
+// postSignHook is one entry of the -post-sign-hooks file, a JSON array: a
+// hook target run for every transaction signed, such as a broadcaster, an
+// accounting export or a ticketing integration. Events lists the events to
+// run it for, all of them when empty. Its answer is ignored.
+type postSignHook struct {
+	policy.HookTarget
+	Events []string `json:"events"`
+}
+
+// postSignEvent is the JSON document a post-sign hook gets. Raw is the
+// signed transaction, ready to broadcast; it is left out for a transaction
+// held under a time delay, which is only released with the delayed
+// command.
+type postSignEvent struct {
+	Event          string     `json:"event"`
+	Time           time.Time  `json:"time"`
+	Account        string     `json:"account"`
+	ChainID        string     `json:"chain_id"`
+	Nonce          uint64     `json:"nonce"`
+	To             string     `json:"to,omitempty"`
+	Value          string     `json:"value"`
+	TxHash         string     `json:"tx_hash"`
+	Raw            string     `json:"raw,omitempty"`
+	ReleaseAt      *time.Time `json:"release_at,omitempty"`
+	Policy         string     `json:"policy"`
+	Operator       string     `json:"operator,omitempty"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+}
+
+// PostSignHooks runs the post-sign hooks. Each event runs every hook
+// subscribed to it concurrently in the background, so a slow hook does not
+// delay signing; a failed hook is logged. A nil *PostSignHooks runs
+// nothing.
+type PostSignHooks struct {
+	hooks   []*postSignHook
+	running sync.WaitGroup
+}
+
+// LoadPostSignHooks reads the post-sign hooks file.
+func LoadPostSignHooks(file string) (*PostSignHooks, error) {
+	h := new(PostSignHooks)
+	if err := jsonfile.Read(file, &h.hooks); err != nil {
+		return nil, fmt.Errorf("failed to read post-sign hooks: %v", err)
+	}
+	for i, hook := range h.hooks {
+		if err := hook.Prepare(); err != nil {
+			return nil, fmt.Errorf("post-sign hook %d: %v", i, err)
+		}
+		for _, ev := range hook.Events {
+			if ev != postSignSigned && ev != postSignHeld {
+				return nil, fmt.Errorf("post-sign hook %d: unknown event %q", i, ev)
+			}
+		}
+	}
+	return h, nil
+}
+
+// run starts the hooks subscribed to ev. They keep the trace of ctx but
+// not its deadline.
+func (h *PostSignHooks) run(ctx context.Context, ev postSignEvent) {
+	if h == nil {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("failed to encode post-sign event", "err", err)
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, hook := range h.hooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, ev.Event) {
+			continue
+		}
+		h.running.Add(1)
+		go func() {
+			defer h.running.Done()
+			ctx, span := tracing.StartSpan(ctx, "post_sign_hook")
+			_, err := callHook(ctx, &hook.HookTarget, body)
+			tracing.EndSpan(span, err)
+			if err != nil {
+				slog.Warn("post-sign hook failed", "hook", hook.Name(), "tx_hash", ev.TxHash, "err", err)
+			}
+		}()
+	}
+}
+
+// Wait waits for the hooks started so far to finish.
+func (h *PostSignHooks) Wait() {
+	if h != nil {
+		h.running.Wait()
+	}
+}
+
+// postSigned runs the post-sign hooks for tx, signed for chainID as raw
+// with hash txHash. held is the *HeldError of a transaction held under a
+// time delay, nil otherwise.
+func (e *Engine) postSigned(ctx context.Context, pol *policy.Policy, tx *types.Transaction, chainID *big.Int, raw []byte, txHash common.Hash, held *HeldError) {
+	if e.PostSign == nil {
+		return
+	}
+	ev := postSignEvent{
+		Event:          postSignSigned,
+		Time:           time.Now().UTC(),
+		Account:        e.Key.Address().Hex(),
+		ChainID:        chainID.String(),
+		Nonce:          tx.Nonce(),
+		Value:          tx.Value().String(),
+		TxHash:         txHash.Hex(),
+		Raw:            hexutil.Encode(raw),
+		Policy:         pol.Digest,
+		IdempotencyKey: IdempotencyKey(ctx),
+	}
+	if tx.To() != nil {
+		ev.To = tx.To().Hex()
+	}
+	if e.Audit != nil {
+		ev.Operator = e.Audit.Operator
+	}
+	if held != nil {
+		ev.Event, ev.Raw, ev.ReleaseAt = postSignHeld, "", &held.ReleaseAt
+	}
+	e.PostSign.run(ctx, ev)
+}
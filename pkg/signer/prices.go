@@ -0,0 +1,232 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Selectors of the Chainlink aggregator calls; ERC-20 tokens share
+// decimals().
+var (
+	selectorLatestRoundData = []byte{0xfe, 0xaf, 0x96, 0x8c} // latestRoundData()
+	selectorDecimals        = []byte{0x31, 0x3c, 0xe5, 0x67} // decimals()
+)
+
+// defaultChainlinkFeeds are the Chainlink USD feeds on Ethereum mainnet,
+// keyed by native currency symbol.
+var defaultChainlinkFeeds = map[string]string{
+	"ETH": "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419",
+}
+
+// defaultCoinGeckoIDs are the CoinGecko coin IDs of native currencies.
+var defaultCoinGeckoIDs = map[string]string{
+	"ETH":  "ethereum",
+	"POL":  "polygon-ecosystem-token",
+	"BNB":  "binancecoin",
+	"xDAI": "xdai",
+	"AVAX": "avalanche-2",
+}
+
+// usdPrice is the price of one unit of a currency, in USD.
+type usdPrice struct {
+	price   *big.Rat
+	updated time.Time
+	source  string
+	fetched time.Time
+}
+
+// priceOracle fetches USD prices and caches them briefly, so that a busy
+// daemon does not query the sources for every transaction.
+type priceOracle struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]usdPrice
+}
+
+func newPriceOracle() *priceOracle {
+	return &priceOracle{client: &http.Client{Timeout: policy.PriceRequestTimeout}, cache: make(map[string]usdPrice)}
+}
+
+// price returns a fresh USD price of symbol from src.
+func (o *priceOracle) price(ctx context.Context, src *policy.PriceSource, symbol string, now time.Time) (usdPrice, error) {
+	if src == nil {
+		src = &policy.PriceSource{}
+	}
+	feed := lookupSymbol(src.ChainlinkFeeds, defaultChainlinkFeeds, symbol)
+	coin := lookupSymbol(src.CoinGeckoIDs, defaultCoinGeckoIDs, symbol)
+	key := strings.Join([]string{symbol, src.RPC, feed, src.CoinGeckoURL, coin}, "|")
+	o.mu.Lock()
+	cached, ok := o.cache[key]
+	o.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < policy.PriceCacheTTL && now.Sub(cached.updated) <= src.MaxAge() {
+		return cached, nil
+	}
+
+	var errs []error
+	var p usdPrice
+	var err error
+	if src.RPC != "" && feed != "" {
+		if p, err = fetchChainlinkPrice(ctx, src.RPC, common.HexToAddress(feed)); err != nil {
+			errs = append(errs, fmt.Errorf("chainlink: %v", err))
+		} else if age := now.Sub(p.updated); age > src.MaxAge() {
+			errs = append(errs, fmt.Errorf("chainlink: price is %s old", age.Round(time.Second)))
+		} else {
+			return o.store(key, p, now), nil
+		}
+	}
+	if src.CoinGeckoURL != "off" && coin != "" {
+		if p, err = o.fetchCoinGeckoPrice(ctx, src.CoinGeckoURL, coin); err != nil {
+			errs = append(errs, fmt.Errorf("coingecko: %v", err))
+		} else if age := now.Sub(p.updated); age > src.MaxAge() {
+			errs = append(errs, fmt.Errorf("coingecko: price is %s old", age.Round(time.Second)))
+		} else {
+			return o.store(key, p, now), nil
+		}
+	}
+	if len(errs) == 0 {
+		return usdPrice{}, fmt.Errorf("no price source configured for %s", symbol)
+	}
+	return usdPrice{}, errors.Join(errs...)
+}
+
+func (o *priceOracle) store(key string, p usdPrice, now time.Time) usdPrice {
+	p.fetched = now
+	o.mu.Lock()
+	o.cache[key] = p
+	o.mu.Unlock()
+	return p
+}
+
+// lookupSymbol returns the entry for symbol in m, falling back to defaults
+// when m has none.
+func lookupSymbol(m, defaults map[string]string, symbol string) string {
+	for k, v := range m {
+		if strings.EqualFold(k, symbol) {
+			return v
+		}
+	}
+	for k, v := range defaults {
+		if strings.EqualFold(k, symbol) {
+			return v
+		}
+	}
+	return ""
+}
+
+// fetchChainlinkPrice reads the latest answer of a Chainlink aggregator.
+func fetchChainlinkPrice(ctx context.Context, endpoint string, feed common.Address) (usdPrice, error) {
+	ctx, cancel := context.WithTimeout(ctx, policy.PriceRequestTimeout)
+	defer cancel()
+	client, err := txbuilder.Dial(ctx, endpoint)
+	if err != nil {
+		return usdPrice{}, err
+	}
+	defer client.Close()
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: selectorDecimals}, nil)
+	if err != nil {
+		return usdPrice{}, err
+	}
+	if len(out) != 32 {
+		return usdPrice{}, fmt.Errorf("%s does not look like a price feed", feed.Hex())
+	}
+	decimals := new(big.Int).SetBytes(out)
+	if out, err = client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: selectorLatestRoundData}, nil); err != nil {
+		return usdPrice{}, err
+	}
+	if len(out) != 5*32 {
+		return usdPrice{}, fmt.Errorf("%s does not look like a price feed", feed.Hex())
+	}
+	answer := new(big.Int).SetBytes(out[32:64])
+	if out[32]&0x80 != 0 || answer.Sign() == 0 || decimals.Cmp(big.NewInt(36)) > 0 {
+		return usdPrice{}, errors.New("feed has no valid answer")
+	}
+	updated := new(big.Int).SetBytes(out[96:128])
+	price := new(big.Rat).SetFrac(answer, new(big.Int).Exp(big.NewInt(10), decimals, nil))
+	return usdPrice{price: price, updated: time.Unix(updated.Int64(), 0), source: policy.PriceSourceChainlink}, nil
+}
+
+// fetchCoinGeckoPrice fetches the USD price of coin from the CoinGecko
+// simple price API.
+func (o *priceOracle) fetchCoinGeckoPrice(ctx context.Context, endpoint, coin string) (usdPrice, error) {
+	if endpoint == "" {
+		endpoint = policy.DefaultCoinGeckoURL
+	}
+	query := url.Values{"ids": {coin}, "vs_currencies": {"usd"}, "include_last_updated_at": {"true"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return usdPrice{}, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return usdPrice{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return usdPrice{}, fmt.Errorf("request failed: %s", resp.Status)
+	}
+	var result map[string]struct {
+		USD           json.Number `json:"usd"`
+		LastUpdatedAt int64       `json:"last_updated_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return usdPrice{}, fmt.Errorf("invalid response: %v", err)
+	}
+	entry, ok := result[coin]
+	if !ok {
+		return usdPrice{}, fmt.Errorf("no price for %s", coin)
+	}
+	price, ok := new(big.Rat).SetString(entry.USD.String())
+	if !ok || price.Sign() <= 0 {
+		return usdPrice{}, fmt.Errorf("invalid price %q for %s", entry.USD, coin)
+	}
+	return usdPrice{price: price, updated: time.Unix(entry.LastUpdatedAt, 0), source: policy.PriceSourceCoinGecko}, nil
+}
+
+// weiToUSD returns the USD value of wei of a currency with 18 decimals.
+func weiToUSD(wei *big.Int, price *big.Rat) *big.Rat {
+	v := new(big.Rat).SetFrac(wei, new(big.Int).Exp(big.NewInt(10), big.NewInt(policy.NativeCurrencyScale), nil))
+	return v.Mul(v, price)
+}
+
+// checkUSDLimit applies max_amount_usd to the native currency value of
+// tx, priced at sign time.
+func (e *Engine) checkUSDLimit(ctx context.Context, pol *policy.Policy, value, chainID *big.Int, now time.Time, d *policy.Decision) error {
+	if pol.MaxAmountUSD <= 0 || value.Sign() == 0 {
+		return nil
+	}
+	symbol := policy.DefaultNativeSymbol
+	if c, ok := chains.ByID(chainID); ok && c.Symbol != "" {
+		symbol = c.Symbol
+	}
+	limit := new(big.Rat).SetFloat64(pol.MaxAmountUSD)
+	p, err := e.prices.price(ctx, pol.PriceSource, symbol, now)
+	if err != nil {
+		if pol.PriceSource != nil && pol.PriceSource.FailOpen {
+			slog.Warn("USD limit not applied without a price", "symbol", symbol, "err", err)
+			d.Pass("max_amount_usd", limit.FloatString(2), nil)
+			return nil
+		}
+		return d.Fail("max_amount_usd", limit.FloatString(2), nil, fmt.Sprintf("no fresh %s/USD price: %v", symbol, err))
+	}
+	usd := weiToUSD(value, p.price)
+	if usd.Cmp(limit) > 0 {
+		return d.Fail("max_amount_usd", limit.FloatString(2), usd.FloatString(2), fmt.Sprintf("amount worth %s USD at %s USD/%s (%s) exceeds max policy limit %s USD", usd.FloatString(2), p.price.FloatString(2), symbol, p.source, limit.FloatString(2)))
+	}
+	d.Pass("max_amount_usd", limit.FloatString(2), usd.FloatString(2))
+	return nil
+}
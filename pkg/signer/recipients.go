@@ -0,0 +1,56 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// recipientKey is the store key under which the recipients paid by from
+// are tracked, on every chain.
+func recipientKey(from common.Address) string {
+	return strings.ToLower(from.Hex())
+}
+
+// checkNewRecipient asks e.confirmRecipient to confirm tx if it pays an
+// address the key has never paid before.
+func (e *Engine) checkNewRecipient(ctx context.Context, tx *types.Transaction) error {
+	from := e.Key.Address()
+	to, ok := policy.TxRecipient(tx)
+	if !ok || to == from {
+		return nil
+	}
+	var known bool
+	err := tracing.StoreOp(ctx, "KnownRecipient", func() (err error) {
+		known, err = e.Store.KnownRecipient(recipientKey(from), to)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up recipient: %v", err)
+	}
+	if known {
+		return nil
+	}
+	return e.ConfirmRecipient(from, to)
+}
+
+// recordRecipient marks the recipient of the signed tx as known.
+func (e *Engine) recordRecipient(ctx context.Context, tx *types.Transaction) {
+	to, ok := policy.TxRecipient(tx)
+	if !ok {
+		return
+	}
+	err := tracing.StoreOp(ctx, "AddRecipient", func() error {
+		return e.Store.AddRecipient(recipientKey(e.Key.Address()), to, time.Now())
+	})
+	if err != nil {
+		slog.Warn("failed to record recipient", "recipient", to.Hex(), "err", err)
+	}
+}
@@ -0,0 +1,559 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each operation on a Redis store, retries included.
+const redisTimeout = 10 * time.Second
+
+// redisRetries is how often an update is retried when another client
+// changed the keys it read before it could write them.
+const redisRetries = 50
+
+// defaultRedisPrefix is prepended to every key of a Redis store unless the
+// store URL sets prefix.
+const defaultRedisPrefix = "secure-signer:"
+
+// redisStore is a Store kept in Redis, so that several daemon replicas can
+// share their nonces, spending counters, signed transactions, idempotency
+// keys, approval requests, time-delayed transactions, the freeze and, with
+// -audit-log store, the audit log: no two replicas hand out the same
+// nonce, the spending limits hold for all of them together, an approval
+// given to one counts for all, and a freeze stops them all. Each update reads and
+// writes its keys in a WATCH/MULTI transaction, which is retried when
+// another replica changed them in between.
+//
+// Alert acknowledgements, the unlocked session key and an audit log file
+// stay local to each replica.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// newRedisStore connects to the store at location, a redis:// or rediss://
+// URL as understood by redis.ParseURL with an optional prefix parameter for
+// the keys, e.g. rediss://:password@redis:6380/0?prefix=signer-eu:.
+func newRedisStore(location string) (*redisStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis store URL: %v", err)
+	}
+	q := u.Query()
+	prefix := defaultRedisPrefix
+	if q.Has("prefix") {
+		prefix = q.Get("prefix")
+		q.Del("prefix")
+		u.RawQuery = q.Encode()
+	}
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis store URL: %v", err)
+	}
+	s := &redisStore{client: redis.NewClient(opts), prefix: prefix}
+	if err := s.Ping(); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	return s, nil
+}
+
+func (s *redisStore) Close() error { return s.client.Close() }
+
+func (s *redisStore) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *redisStore) key(kind, key string) string {
+	return s.prefix + kind + ":" + key
+}
+
+// update runs fn in a transaction watching keys, retrying it when another
+// client changed one of them first. fn reads through tx and writes with
+// tx.TxPipelined, which fails if a watched key changed.
+func (s *redisStore) update(keys []string, fn func(ctx context.Context, tx *redis.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	for range redisRetries {
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error { return fn(ctx, tx) }, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errors.New("redis store is too contended; retry later")
+}
+
+// do runs fn with a timeout, for operations a single command does
+// atomically.
+func (s *redisStore) do(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+func (s *redisStore) ReserveSpend(now time.Time, reservations []spendReservation) error {
+	keys := make([]string, len(reservations))
+	for i, r := range reservations {
+		keys[i] = s.key("spends", r.key)
+	}
+	return s.update(keys, func(ctx context.Context, tx *redis.Tx) error {
+		pruned := make([][]spendRecord, len(reservations))
+		for i, r := range reservations {
+			var records []spendRecord
+			data, err := tx.Get(ctx, keys[i]).Bytes()
+			switch {
+			case err == nil:
+				if err := json.Unmarshal(data, &records); err != nil {
+					return fmt.Errorf("invalid spend records %s: %v", keys[i], err)
+				}
+			case !errors.Is(err, redis.Nil):
+				return err
+			}
+			for _, rec := range records {
+				if rec.At.After(now.Add(-maxSpendWindow)) {
+					pruned[i] = append(pruned[i], rec)
+				}
+			}
+			if err := checkSpend(pruned[i], r.amount, now, r.limits); err != nil {
+				return err
+			}
+		}
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, r := range reservations {
+				data, err := json.Marshal(append(pruned[i], spendRecord{Amount: new(big.Int).Set(r.amount), At: now}))
+				if err != nil {
+					return err
+				}
+				// Records older than the longest window no longer count.
+				pipe.Set(ctx, keys[i], data, maxSpendWindow)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// getNonce reads a stored next nonce.
+func getNonce(ctx context.Context, c redis.Cmdable, key string) (uint64, bool, error) {
+	next, err := c.Get(ctx, key).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	return next, err == nil, err
+}
+
+func (s *redisStore) NextNonce(key string, floor uint64) (uint64, error) {
+	var nonce uint64
+	k := s.key("nonce", key)
+	err := s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		next, _, err := getNonce(ctx, tx, k)
+		if err != nil {
+			return err
+		}
+		nonce = max(next, floor)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, k, nonce+1, 0)
+			return nil
+		})
+		return err
+	})
+	return nonce, err
+}
+
+func (s *redisStore) ReleaseNonce(key string, nonce uint64) error {
+	k := s.key("nonce", key)
+	return s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		next, ok, err := getNonce(ctx, tx, k)
+		if err != nil || !ok || next != nonce+1 {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, k, nonce, 0)
+			return nil
+		})
+		return err
+	})
+}
+
+func (s *redisStore) Nonce(key string) (uint64, bool, error) {
+	var (
+		next uint64
+		ok   bool
+	)
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		next, ok, err = getNonce(ctx, s.client, s.key("nonce", key))
+		return err
+	})
+	return next, ok, err
+}
+
+func (s *redisStore) SetNonce(key string, next uint64) error {
+	return s.do(func(ctx context.Context) error {
+		return s.client.Set(ctx, s.key("nonce", key), next, 0).Err()
+	})
+}
+
+// getJSON reads the JSON value of a hash field into v, reporting whether it
+// was set.
+func getJSON(ctx context.Context, c redis.Cmdable, key, field string, v any) (bool, error) {
+	data, err := c.HGet(ctx, key, field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid record %s %s: %v", key, field, err)
+	}
+	return true, nil
+}
+
+func (s *redisStore) SignedTx(key string, nonce uint64) (SignedRecord, bool, error) {
+	var (
+		rec SignedRecord
+		ok  bool
+	)
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		ok, err = getJSON(ctx, s.client, s.key("signed", key), strconv.FormatUint(nonce, 10), &rec)
+		return err
+	})
+	return rec, ok, err
+}
+
+func (s *redisStore) RecordSigned(key string, rec SignedRecord, replace bool) error {
+	k := s.key("signed", key)
+	field := strconv.FormatUint(rec.Nonce, 10)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		var existing SignedRecord
+		ok, err := getJSON(ctx, tx, k, field, &existing)
+		if err != nil {
+			return err
+		}
+		if ok && existing.SigningHash != rec.SigningHash && !replace {
+			return &ReplayError{Existing: existing}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, k, field, data)
+			return nil
+		})
+		return err
+	})
+}
+
+func (s *redisStore) KnownRecipient(key string, to common.Address) (bool, error) {
+	var known bool
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		known, err = s.client.HExists(ctx, s.key("recipients", key), to.Hex()).Result()
+		return err
+	})
+	return known, err
+}
+
+func (s *redisStore) AddRecipient(key string, to common.Address, now time.Time) error {
+	return s.do(func(ctx context.Context) error {
+		return s.client.HSetNX(ctx, s.key("recipients", key), to.Hex(), now.UTC().Format(time.RFC3339Nano)).Err()
+	})
+}
+
+func (s *redisStore) ClaimIdempotency(key, id string, request common.Hash, now time.Time) (idempotencyRecord, bool, error) {
+	var (
+		rec idempotencyRecord
+		ok  bool
+	)
+	k := s.key("idempotency", key)
+	err := s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		all, err := tx.HGetAll(ctx, k).Result()
+		if err != nil {
+			return err
+		}
+		var expired []string
+		ok = false
+		for field, data := range all {
+			var r idempotencyRecord
+			if err := json.Unmarshal([]byte(data), &r); err != nil {
+				return fmt.Errorf("invalid record %s %s: %v", k, field, err)
+			}
+			if now.Sub(r.At) > idempotencyTTL || (len(r.Raw) == 0 && now.Sub(r.At) > idempotencyStale) {
+				expired = append(expired, field)
+			} else if field == id {
+				rec, ok = r, true
+			}
+		}
+		var claim []byte
+		if !ok {
+			if claim, err = json.Marshal(idempotencyRecord{Request: request, At: now.UTC()}); err != nil {
+				return err
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if len(expired) > 0 {
+				pipe.HDel(ctx, k, expired...)
+			}
+			if claim != nil {
+				pipe.HSet(ctx, k, id, claim)
+			}
+			return nil
+		})
+		return err
+	})
+	return rec, ok, err
+}
+
+func (s *redisStore) CompleteIdempotency(key, id string, rec *idempotencyRecord) error {
+	k := s.key("idempotency", key)
+	return s.do(func(ctx context.Context) error {
+		if rec == nil {
+			return s.client.HDel(ctx, k, id).Err()
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return s.client.HSet(ctx, k, id, data).Err()
+	})
+}
+
+func (s *redisStore) Freeze() (*FreezeState, error) {
+	var f *FreezeState
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		f, err = getFreeze(ctx, s.client, s.prefix+"freeze")
+		return err
+	})
+	return f, err
+}
+
+// getFreeze reads the freeze stored at key, if any.
+func getFreeze(ctx context.Context, c redis.Cmdable, key string) (*FreezeState, error) {
+	data, err := c.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := new(FreezeState)
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid freeze %s: %v", key, err)
+	}
+	return f, nil
+}
+
+func (s *redisStore) UpdateFreeze(fn func(*FreezeState) (*FreezeState, error)) error {
+	k := s.prefix + "freeze"
+	return s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		cur, err := getFreeze(ctx, tx, k)
+		if err != nil {
+			return err
+		}
+		f, err := fn(cur)
+		if err != nil {
+			return err
+		}
+		var data []byte
+		if f != nil {
+			if data, err = json.Marshal(f); err != nil {
+				return err
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if f == nil {
+				pipe.Del(ctx, k)
+			} else {
+				pipe.Set(ctx, k, data, 0)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// updateField atomically replaces field of the hash at key, nil if unset,
+// with what fn returns for it; nil removes the field.
+func (s *redisStore) updateField(key, field string, fn func(data []byte) ([]byte, error)) error {
+	return s.update([]string{key}, func(ctx context.Context, tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, key, field).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		next, err := fn(data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if next == nil {
+				pipe.HDel(ctx, key, field)
+			} else {
+				pipe.HSet(ctx, key, field, next)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+func (s *redisStore) ApprovalRequest(id common.Hash) (*ApprovalRequest, error) {
+	req := new(ApprovalRequest)
+	var ok bool
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		ok, err = getJSON(ctx, s.client, s.prefix+"approvals", id.Hex(), req)
+		return err
+	})
+	if !ok {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *redisStore) UpdateApprovalRequest(id common.Hash, fn func(*ApprovalRequest) (*ApprovalRequest, error)) error {
+	k := s.prefix + "approvals"
+	return s.updateField(k, id.Hex(), func(data []byte) ([]byte, error) {
+		var cur *ApprovalRequest
+		if data != nil {
+			cur = new(ApprovalRequest)
+			if err := json.Unmarshal(data, cur); err != nil {
+				return nil, fmt.Errorf("invalid record %s %s: %v", k, id.Hex(), err)
+			}
+		}
+		req, err := fn(cur)
+		if err != nil || req == nil {
+			return nil, err
+		}
+		return json.Marshal(req)
+	})
+}
+
+func (s *redisStore) HeldTx(hash common.Hash) (*HeldTx, error) {
+	h := new(HeldTx)
+	var ok bool
+	err := s.do(func(ctx context.Context) error {
+		var err error
+		ok, err = getJSON(ctx, s.client, s.prefix+"held", hash.Hex(), h)
+		return err
+	})
+	if !ok {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *redisStore) HeldTxs() ([]*HeldTx, error) {
+	var held []*HeldTx
+	k := s.prefix + "held"
+	err := s.do(func(ctx context.Context) error {
+		all, err := s.client.HGetAll(ctx, k).Result()
+		if err != nil {
+			return err
+		}
+		for field, data := range all {
+			h := new(HeldTx)
+			if err := json.Unmarshal([]byte(data), h); err != nil {
+				return fmt.Errorf("invalid record %s %s: %v", k, field, err)
+			}
+			held = append(held, h)
+		}
+		return nil
+	})
+	sort.Slice(held, func(i, j int) bool { return held[i].HeldAt.Before(held[j].HeldAt) })
+	return held, err
+}
+
+func (s *redisStore) UpdateHeldTx(hash common.Hash, fn func(*HeldTx) (*HeldTx, error)) error {
+	k := s.prefix + "held"
+	return s.updateField(k, hash.Hex(), func(data []byte) ([]byte, error) {
+		var cur *HeldTx
+		if data != nil {
+			cur = new(HeldTx)
+			if err := json.Unmarshal(data, cur); err != nil {
+				return nil, fmt.Errorf("invalid record %s %s: %v", k, hash.Hex(), err)
+			}
+		}
+		h, err := fn(cur)
+		if err != nil || h == nil {
+			return nil, err
+		}
+		return json.Marshal(h)
+	})
+}
+
+// AppendAudit appends to a list, watched so that two replicas never chain
+// to the same entry.
+func (s *redisStore) AppendAudit(seal func(head AuditHead) (*AuditEntry, error)) error {
+	k := s.prefix + "audit"
+	return s.update([]string{k}, func(ctx context.Context, tx *redis.Tx) error {
+		var head AuditHead
+		data, err := tx.LIndex(ctx, k, -1).Bytes()
+		switch {
+		case err == nil:
+			last := new(AuditEntry)
+			if err := json.Unmarshal(data, last); err != nil {
+				return fmt.Errorf("invalid audit entry %s: %v", k, err)
+			}
+			head = AuditHead{Seq: last.Seq, Hash: last.Hash}
+		case !errors.Is(err, redis.Nil):
+			return err
+		}
+		e, err := seal(head)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(e); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.RPush(ctx, k, data)
+			return nil
+		})
+		return err
+	})
+}
+
+func (s *redisStore) ReadAudit(fn func(*AuditEntry) error) error {
+	k := s.prefix + "audit"
+	for start := int64(0); ; start += auditPage {
+		var page []string
+		err := s.do(func(ctx context.Context) error {
+			var err error
+			page, err = s.client.LRange(ctx, k, start, start+auditPage-1).Result()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for i, data := range page {
+			e := new(AuditEntry)
+			if err := jsonfile.Decode([]byte(data), e); err != nil {
+				return fmt.Errorf("entry %d: %v", start+int64(i)+1, err)
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(page) < auditPage {
+			return nil
+		}
+	}
+}
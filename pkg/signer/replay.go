@@ -0,0 +1,77 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SignedRecord is a transaction signed for a nonce, kept so that a second,
+// different transaction is not signed for the same nonce by accident.
+type SignedRecord struct {
+	Nonce       uint64      `json:"nonce"`
+	SigningHash common.Hash `json:"signing_hash"`
+	TxHash      common.Hash `json:"tx_hash"`
+	At          time.Time   `json:"at"`
+}
+
+// ReplayError reports a transaction for a nonce that already has a
+// different signed transaction.
+type ReplayError struct {
+	Existing SignedRecord
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("nonce %d was already signed for transaction %s at %s", e.Existing.Nonce, e.Existing.TxHash.Hex(), e.Existing.At.Format(time.RFC3339))
+}
+
+// checkReplay refuses tx if a different transaction has been signed with
+// its nonce, unless the engine allows replacements. Re-signing the same
+// transaction, as retries do, is allowed.
+func (e *Engine) checkReplay(ctx context.Context, tx *types.Transaction, chainID *big.Int) error {
+	if e.AllowReplace {
+		return nil
+	}
+	var (
+		rec SignedRecord
+		ok  bool
+	)
+	err := tracing.StoreOp(ctx, "SignedTx", func() (err error) {
+		rec, ok, err = e.Store.SignedTx(NonceKey(chainID, e.Key.Address()), tx.Nonce())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up signed transactions: %v", err)
+	}
+	if ok && rec.SigningHash != types.LatestSignerForChainID(chainID).Hash(tx) {
+		return &ReplayError{Existing: rec}
+	}
+	return nil
+}
+
+// recordSigned stores the transaction signed from tx, with hash txHash, as
+// the one signed for its nonce. It repeats the replay check atomically, so
+// of two different transactions signed concurrently for a nonce only one is
+// kept.
+func (e *Engine) recordSigned(ctx context.Context, tx *types.Transaction, txHash common.Hash, chainID *big.Int) error {
+	rec := SignedRecord{
+		Nonce:       tx.Nonce(),
+		SigningHash: types.LatestSignerForChainID(chainID).Hash(tx),
+		TxHash:      txHash,
+		At:          time.Now().UTC(),
+	}
+	err := tracing.StoreOp(ctx, "RecordSigned", func() error {
+		return e.Store.RecordSigned(NonceKey(chainID, e.Key.Address()), rec, e.AllowReplace)
+	})
+	var re *ReplayError
+	if err != nil && !errors.As(err, &re) {
+		return fmt.Errorf("failed to record signed transaction: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrLocked reports a signing attempt while the session key is locked.
+var ErrLocked = errors.New("key is locked")
+
+// SessionKey is a keystore key that the daemon only holds decrypted for a
+// limited time. It starts locked; unlock decrypts it for a TTL, after which
+// it is dropped again and signing fails until it is unlocked anew.
+type SessionKey struct {
+	file    string
+	address common.Address
+	maxTTL  time.Duration
+
+	mu      sync.Mutex
+	key     *keys.LocalKey
+	pub     *ecdsa.PublicKey
+	expires time.Time
+	timer   *time.Timer
+}
+
+// NewSessionKey returns a locked session for the keystore file, whose
+// address is read without decrypting it.
+func NewSessionKey(file string, maxTTL time.Duration) (*SessionKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var ks struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(data, &ks); err != nil || !common.IsHexAddress(ks.Address) {
+		return nil, fmt.Errorf("keystore %s has no address", file)
+	}
+	return &SessionKey{file: file, address: common.HexToAddress(ks.Address), maxTTL: maxTTL}, nil
+}
+
+func (s *SessionKey) Address() common.Address { return s.address }
+
+// PublicKey returns the public key once the key has been unlocked at least
+// once, and nil before.
+func (s *SessionKey) PublicKey() *ecdsa.PublicKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pub
+}
+
+func (s *SessionKey) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	key, err := s.unlocked()
+	if err != nil {
+		return nil, err
+	}
+	return key.SignHash(ctx, hash)
+}
+
+// unlocked returns the decrypted key, or an error while it is locked.
+func (s *SessionKey) unlocked() (*keys.LocalKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil || !time.Now().Before(s.expires) {
+		return nil, fmt.Errorf("account %s: %w", s.address.Hex(), ErrLocked)
+	}
+	return s.key, nil
+}
+
+// Check returns an error unless the key is unlocked. It is nil-safe, so
+// the engine can call it whether or not the daemon uses sessions.
+func (s *SessionKey) Check() error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.unlocked()
+	return err
+}
+
+// Unlock decrypts the key for ttl, or for the maximum TTL when ttl is zero.
+func (s *SessionKey) Unlock(passphrase string, ttl time.Duration) (time.Time, error) {
+	if ttl <= 0 || ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	k, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return time.Time{}, errors.New("failed to decrypt keystore")
+	}
+	key, err := keys.SecretKeyFromECDSA(k.PrivateKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if key.Address() != s.address {
+		key.Destroy()
+		return time.Time{}, errors.New("keystore address does not match its key")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil {
+		s.key.Key.Destroy()
+	}
+	s.key = keys.NewLocalKey(key)
+	s.pub = key.PublicKey()
+	s.expires = time.Now().Add(ttl)
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(ttl, s.Lock)
+	return s.expires, nil
+}
+
+// Lock wipes the decrypted key.
+func (s *SessionKey) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil {
+		s.key.Key.Destroy()
+		slog.Info("account locked", "account", s.address.Hex())
+	}
+	s.key = nil
+	s.expires = time.Time{}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// SessionStatus is the response of the session endpoints.
+type SessionStatus struct {
+	Account   string     `json:"account"`
+	Unlocked  bool       `json:"unlocked"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (s *SessionKey) Status() SessionStatus {
+	st := SessionStatus{Account: s.address.Hex()}
+	if s.Check() == nil {
+		s.mu.Lock()
+		expires := s.expires.UTC()
+		s.mu.Unlock()
+		st.Unlocked, st.ExpiresAt = true, &expires
+	}
+	return st
+}
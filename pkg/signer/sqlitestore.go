@@ -0,0 +1,131 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations is the schema of a SQLite store. Times are Unix
+// nanoseconds and amounts decimal strings, as SQLite has no wider integer.
+var sqliteMigrations = []string{
+	`CREATE TABLE spends (
+		counter TEXT NOT NULL,
+		amount TEXT NOT NULL,
+		at INTEGER NOT NULL
+	);
+	CREATE INDEX spends_counter_at ON spends (counter, at);
+	CREATE TABLE nonces (
+		key TEXT PRIMARY KEY,
+		next INTEGER NOT NULL
+	);
+	CREATE TABLE signed (
+		key TEXT NOT NULL,
+		nonce INTEGER NOT NULL,
+		signing_hash TEXT NOT NULL,
+		tx_hash TEXT NOT NULL,
+		at INTEGER NOT NULL,
+		PRIMARY KEY (key, nonce)
+	);
+	CREATE TABLE recipients (
+		key TEXT NOT NULL,
+		address TEXT NOT NULL,
+		first_paid INTEGER NOT NULL,
+		PRIMARY KEY (key, address)
+	);
+	CREATE TABLE idempotency (
+		key TEXT NOT NULL,
+		id TEXT NOT NULL,
+		request TEXT NOT NULL,
+		raw BLOB,
+		release_at INTEGER,
+		at INTEGER NOT NULL,
+		PRIMARY KEY (key, id)
+	)`,
+	`CREATE TABLE freeze (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		state TEXT NOT NULL
+	)`,
+	`CREATE TABLE approvals (
+		id TEXT PRIMARY KEY,
+		request TEXT NOT NULL
+	);
+	CREATE TABLE held (
+		hash TEXT PRIMARY KEY,
+		held_at INTEGER NOT NULL,
+		tx TEXT NOT NULL
+	);
+	CREATE TABLE audit (
+		seq INTEGER PRIMARY KEY,
+		hash TEXT NOT NULL,
+		entry TEXT NOT NULL
+	)`,
+}
+
+var sqliteDialect = &sqlDialect{
+	name:       "sqlite",
+	migrations: sqliteMigrations,
+	rebind:     func(query string) string { return query },
+	// Transactions begin IMMEDIATE, taking the database's write lock, so
+	// they already exclude each other.
+}
+
+// newSQLiteStore opens the SQLite database at path, creating it readable
+// only by the owner if needed. A new database takes over the state of the
+// JSON file store next to it, if any, so that upgrading from the JSON
+// default keeps the nonces and spending history.
+func newSQLiteStore(path string) (*sqlStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	created := err == nil
+	switch {
+	case created:
+		f.Close()
+	case !errors.Is(err, os.ErrExist):
+		return nil, fmt.Errorf("failed to create store %s: %v", path, err)
+	}
+	// Writers wait up to busy_timeout for each other, and WAL lets readers
+	// go on meanwhile.
+	dsn := path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	s, err := openSQLStore("sqlite", dsn, sqliteDialect)
+	if err != nil {
+		if created {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+	if created {
+		legacy := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+		if err := s.importLegacy(legacy); err != nil {
+			s.Close()
+			os.Remove(path)
+			return nil, fmt.Errorf("failed to import %s: %v", legacy, err)
+		}
+	}
+	return s, nil
+}
+
+// importLegacy copies the JSON file store at path into s, if there is one.
+func (s *sqlStore) importLegacy(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := new(fileState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return err
+	}
+	if err := s.importFileState(state); err != nil {
+		return err
+	}
+	slog.Info("imported state store; it is no longer updated", "path", path)
+	return nil
+}
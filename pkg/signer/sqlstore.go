@@ -0,0 +1,701 @@
+package signer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// sqlTimeout bounds each operation on a SQL store.
+const sqlTimeout = 10 * time.Second
+
+// sqlDialect adapts sqlStore to a database.
+type sqlDialect struct {
+	name string
+	// migrations create and upgrade the schema: applying migration i brings
+	// it to version i+1. Released migrations must never change.
+	migrations []string
+	// rebind rewrites the ? placeholders of a query for the database.
+	rebind func(query string) string
+	// lock takes a lock on name for the rest of tx, for databases whose
+	// write transactions do not already exclude each other.
+	lock func(ctx context.Context, tx *sql.Tx, name string) error
+}
+
+// sqlStore is a Store in a SQL database. Every update runs in a
+// transaction, holding a lock on what it reads and then writes, so that
+// the processes sharing the database never hand out the same nonce or
+// exceed a spending limit together.
+type sqlStore struct {
+	db      *sql.DB
+	dialect *sqlDialect
+}
+
+// sqlTx is a transaction of a sqlStore.
+type sqlTx struct {
+	tx      *sql.Tx
+	dialect *sqlDialect
+}
+
+func (t *sqlTx) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *sqlTx) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *sqlTx) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *sqlTx) lock(ctx context.Context, name string) error {
+	if t.dialect.lock == nil {
+		return nil
+	}
+	return t.dialect.lock(ctx, t.tx, name)
+}
+
+// openSQLStore opens the database and brings its schema up to date.
+func openSQLStore(driver, dsn string, dialect *sqlDialect) (*sqlStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlStore{db: db, dialect: dialect}
+	if err := s.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %v", dialect.name, err)
+	}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s store: %v", dialect.name, err)
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database has not seen yet.
+func (s *sqlStore) migrate() error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		// Replicas starting together must not create or migrate the schema
+		// at the same time.
+		if err := tx.lock(ctx, "schema_migrations"); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+			return err
+		}
+		var version int
+		if err := tx.queryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+			return err
+		}
+		if version > len(s.dialect.migrations) {
+			return fmt.Errorf("schema version %d is newer than this signer knows (%d)", version, len(s.dialect.migrations))
+		}
+		for i := version; i < len(s.dialect.migrations); i++ {
+			if _, err := tx.exec(ctx, s.dialect.migrations[i]); err != nil {
+				return fmt.Errorf("migration %d: %v", i+1, err)
+			}
+			if _, err := tx.exec(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update runs fn in a transaction, committing it if fn succeeds.
+func (s *sqlStore) update(fn func(ctx context.Context, tx *sqlTx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx, &sqlTx{tx: tx, dialect: s.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) Close() error { return s.db.Close() }
+
+func (s *sqlStore) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlStore) ReserveSpend(now time.Time, reservations []spendReservation) error {
+	// Lock the counters in a fixed order, so that updates of the same
+	// counters cannot deadlock.
+	counters := make([]string, len(reservations))
+	for i, r := range reservations {
+		counters[i] = r.key
+	}
+	sort.Strings(counters)
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		for _, counter := range counters {
+			if err := tx.lock(ctx, "spends:"+counter); err != nil {
+				return err
+			}
+		}
+		for _, r := range reservations {
+			if _, err := tx.exec(ctx, `DELETE FROM spends WHERE counter = ? AND at <= ?`, r.key, now.Add(-maxSpendWindow).UnixNano()); err != nil {
+				return err
+			}
+			records, err := querySpends(ctx, tx, r.key)
+			if err != nil {
+				return err
+			}
+			if err := checkSpend(records, r.amount, now, r.limits); err != nil {
+				return err
+			}
+		}
+		for _, r := range reservations {
+			if _, err := tx.exec(ctx, `INSERT INTO spends (counter, amount, at) VALUES (?, ?, ?)`, r.key, r.amount.String(), now.UnixNano()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func querySpends(ctx context.Context, tx *sqlTx, counter string) ([]spendRecord, error) {
+	rows, err := tx.query(ctx, `SELECT CAST(amount AS TEXT), at FROM spends WHERE counter = ?`, counter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []spendRecord
+	for rows.Next() {
+		var (
+			amount string
+			at     int64
+		)
+		if err := rows.Scan(&amount, &at); err != nil {
+			return nil, err
+		}
+		v, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid spend amount %q for %s", amount, counter)
+		}
+		records = append(records, spendRecord{Amount: v, At: time.Unix(0, at)})
+	}
+	return records, rows.Err()
+}
+
+// queryNonce reads a stored next nonce.
+func queryNonce(ctx context.Context, tx *sqlTx, key string) (uint64, bool, error) {
+	var next int64
+	err := tx.queryRow(ctx, `SELECT next FROM nonces WHERE key = ?`, key).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint64(next), true, nil
+}
+
+func setNonce(ctx context.Context, tx *sqlTx, key string, next uint64) error {
+	_, err := tx.exec(ctx, `INSERT INTO nonces (key, next) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET next = excluded.next`, key, int64(next))
+	return err
+}
+
+func (s *sqlStore) NextNonce(key string, floor uint64) (uint64, error) {
+	var nonce uint64
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "nonces:"+key); err != nil {
+			return err
+		}
+		next, _, err := queryNonce(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		nonce = max(next, floor)
+		return setNonce(ctx, tx, key, nonce+1)
+	})
+	return nonce, err
+}
+
+func (s *sqlStore) ReleaseNonce(key string, nonce uint64) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		_, err := tx.exec(ctx, `UPDATE nonces SET next = ? WHERE key = ? AND next = ?`, int64(nonce), key, int64(nonce+1))
+		return err
+	})
+}
+
+func (s *sqlStore) Nonce(key string) (uint64, bool, error) {
+	var (
+		next uint64
+		ok   bool
+	)
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var err error
+		next, ok, err = queryNonce(ctx, tx, key)
+		return err
+	})
+	return next, ok, err
+}
+
+func (s *sqlStore) SetNonce(key string, next uint64) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		return setNonce(ctx, tx, key, next)
+	})
+}
+
+func querySigned(ctx context.Context, tx *sqlTx, key string, nonce uint64) (SignedRecord, bool, error) {
+	var (
+		signingHash, txHash string
+		at                  int64
+	)
+	err := tx.queryRow(ctx, `SELECT signing_hash, tx_hash, at FROM signed WHERE key = ? AND nonce = ?`, key, int64(nonce)).Scan(&signingHash, &txHash, &at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SignedRecord{}, false, nil
+	}
+	if err != nil {
+		return SignedRecord{}, false, err
+	}
+	return SignedRecord{Nonce: nonce, SigningHash: common.HexToHash(signingHash), TxHash: common.HexToHash(txHash), At: time.Unix(0, at).UTC()}, true, nil
+}
+
+func (s *sqlStore) SignedTx(key string, nonce uint64) (SignedRecord, bool, error) {
+	var (
+		rec SignedRecord
+		ok  bool
+	)
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var err error
+		rec, ok, err = querySigned(ctx, tx, key, nonce)
+		return err
+	})
+	return rec, ok, err
+}
+
+func (s *sqlStore) RecordSigned(key string, rec SignedRecord, replace bool) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "signed:"+key+":"+strconv.FormatUint(rec.Nonce, 10)); err != nil {
+			return err
+		}
+		existing, ok, err := querySigned(ctx, tx, key, rec.Nonce)
+		if err != nil {
+			return err
+		}
+		if ok && existing.SigningHash != rec.SigningHash && !replace {
+			return &ReplayError{Existing: existing}
+		}
+		_, err = tx.exec(ctx, `INSERT INTO signed (key, nonce, signing_hash, tx_hash, at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (key, nonce) DO UPDATE SET signing_hash = excluded.signing_hash, tx_hash = excluded.tx_hash, at = excluded.at`,
+			key, int64(rec.Nonce), rec.SigningHash.Hex(), rec.TxHash.Hex(), rec.At.UnixNano())
+		return err
+	})
+}
+
+func (s *sqlStore) KnownRecipient(key string, to common.Address) (bool, error) {
+	var known bool
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var one int
+		err := tx.queryRow(ctx, `SELECT 1 FROM recipients WHERE key = ? AND address = ?`, key, to.Hex()).Scan(&one)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		known = err == nil
+		return err
+	})
+	return known, err
+}
+
+func (s *sqlStore) AddRecipient(key string, to common.Address, now time.Time) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		_, err := tx.exec(ctx, `INSERT INTO recipients (key, address, first_paid) VALUES (?, ?, ?) ON CONFLICT (key, address) DO NOTHING`, key, to.Hex(), now.UnixNano())
+		return err
+	})
+}
+
+func (s *sqlStore) ClaimIdempotency(key, id string, request common.Hash, now time.Time) (idempotencyRecord, bool, error) {
+	var (
+		rec idempotencyRecord
+		ok  bool
+	)
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "idempotency:"+key+":"+id); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `DELETE FROM idempotency WHERE key = ? AND (at < ? OR (raw IS NULL AND at < ?))`,
+			key, now.Add(-idempotencyTTL).UnixNano(), now.Add(-idempotencyStale).UnixNano()); err != nil {
+			return err
+		}
+		var (
+			requestHex string
+			raw        []byte
+			releaseAt  sql.NullInt64
+			at         int64
+		)
+		err := tx.queryRow(ctx, `SELECT request, raw, release_at, at FROM idempotency WHERE key = ? AND id = ?`, key, id).Scan(&requestHex, &raw, &releaseAt, &at)
+		switch {
+		case err == nil:
+			rec, ok = idempotencyRecord{Request: common.HexToHash(requestHex), Raw: raw, At: time.Unix(0, at).UTC()}, true
+			if releaseAt.Valid {
+				t := time.Unix(0, releaseAt.Int64).UTC()
+				rec.ReleaseAt = &t
+			}
+			return nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		_, err = tx.exec(ctx, `INSERT INTO idempotency (key, id, request, at) VALUES (?, ?, ?, ?)`, key, id, request.Hex(), now.UnixNano())
+		return err
+	})
+	return rec, ok, err
+}
+
+func (s *sqlStore) CompleteIdempotency(key, id string, rec *idempotencyRecord) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if rec == nil {
+			_, err := tx.exec(ctx, `DELETE FROM idempotency WHERE key = ? AND id = ?`, key, id)
+			return err
+		}
+		var releaseAt sql.NullInt64
+		if rec.ReleaseAt != nil {
+			releaseAt = sql.NullInt64{Int64: rec.ReleaseAt.UnixNano(), Valid: true}
+		}
+		_, err := tx.exec(ctx, `INSERT INTO idempotency (key, id, request, raw, release_at, at) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (key, id) DO UPDATE SET request = excluded.request, raw = excluded.raw, release_at = excluded.release_at, at = excluded.at`,
+			key, id, rec.Request.Hex(), []byte(rec.Raw), releaseAt, rec.At.UnixNano())
+		return err
+	})
+}
+
+// queryJSON scans the JSON column selected by query into v, reporting
+// whether there was a row.
+func queryJSON(ctx context.Context, tx *sqlTx, v any, query string, args ...any) (bool, error) {
+	var data string
+	err := tx.queryRow(ctx, query, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return false, fmt.Errorf("invalid record: %v", err)
+	}
+	return true, nil
+}
+
+func queryFreeze(ctx context.Context, tx *sqlTx) (*FreezeState, error) {
+	f := new(FreezeState)
+	ok, err := queryJSON(ctx, tx, f, `SELECT state FROM freeze WHERE id = 1`)
+	if !ok {
+		return nil, err
+	}
+	return f, nil
+}
+
+func setFreeze(ctx context.Context, tx *sqlTx, f *FreezeState) error {
+	if f == nil {
+		_, err := tx.exec(ctx, `DELETE FROM freeze WHERE id = 1`)
+		return err
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	_, err = tx.exec(ctx, `INSERT INTO freeze (id, state) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET state = excluded.state`, string(data))
+	return err
+}
+
+func (s *sqlStore) Freeze() (*FreezeState, error) {
+	var f *FreezeState
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var err error
+		f, err = queryFreeze(ctx, tx)
+		return err
+	})
+	return f, err
+}
+
+func (s *sqlStore) UpdateFreeze(fn func(*FreezeState) (*FreezeState, error)) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "freeze"); err != nil {
+			return err
+		}
+		cur, err := queryFreeze(ctx, tx)
+		if err != nil {
+			return err
+		}
+		f, err := fn(cur)
+		if err != nil {
+			return err
+		}
+		return setFreeze(ctx, tx, f)
+	})
+}
+
+func queryApprovalRequest(ctx context.Context, tx *sqlTx, id common.Hash) (*ApprovalRequest, error) {
+	req := new(ApprovalRequest)
+	ok, err := queryJSON(ctx, tx, req, `SELECT request FROM approvals WHERE id = ?`, id.Hex())
+	if !ok {
+		return nil, err
+	}
+	return req, nil
+}
+
+func setApprovalRequest(ctx context.Context, tx *sqlTx, id common.Hash, req *ApprovalRequest) error {
+	if req == nil {
+		_, err := tx.exec(ctx, `DELETE FROM approvals WHERE id = ?`, id.Hex())
+		return err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = tx.exec(ctx, `INSERT INTO approvals (id, request) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET request = excluded.request`, id.Hex(), string(data))
+	return err
+}
+
+func (s *sqlStore) ApprovalRequest(id common.Hash) (*ApprovalRequest, error) {
+	var req *ApprovalRequest
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var err error
+		req, err = queryApprovalRequest(ctx, tx, id)
+		return err
+	})
+	return req, err
+}
+
+func (s *sqlStore) UpdateApprovalRequest(id common.Hash, fn func(*ApprovalRequest) (*ApprovalRequest, error)) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "approvals:"+id.Hex()); err != nil {
+			return err
+		}
+		cur, err := queryApprovalRequest(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		req, err := fn(cur)
+		if err != nil {
+			return err
+		}
+		return setApprovalRequest(ctx, tx, id, req)
+	})
+}
+
+func queryHeldTx(ctx context.Context, tx *sqlTx, hash common.Hash) (*HeldTx, error) {
+	h := new(HeldTx)
+	ok, err := queryJSON(ctx, tx, h, `SELECT tx FROM held WHERE hash = ?`, hash.Hex())
+	if !ok {
+		return nil, err
+	}
+	return h, nil
+}
+
+func setHeldTx(ctx context.Context, tx *sqlTx, hash common.Hash, h *HeldTx) error {
+	if h == nil {
+		_, err := tx.exec(ctx, `DELETE FROM held WHERE hash = ?`, hash.Hex())
+		return err
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	_, err = tx.exec(ctx, `INSERT INTO held (hash, held_at, tx) VALUES (?, ?, ?) ON CONFLICT (hash) DO UPDATE SET held_at = excluded.held_at, tx = excluded.tx`,
+		hash.Hex(), h.HeldAt.UnixNano(), string(data))
+	return err
+}
+
+func (s *sqlStore) HeldTx(hash common.Hash) (*HeldTx, error) {
+	var h *HeldTx
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		var err error
+		h, err = queryHeldTx(ctx, tx, hash)
+		return err
+	})
+	return h, err
+}
+
+func (s *sqlStore) HeldTxs() ([]*HeldTx, error) {
+	var held []*HeldTx
+	err := s.update(func(ctx context.Context, tx *sqlTx) error {
+		rows, err := tx.query(ctx, `SELECT tx FROM held ORDER BY held_at, hash`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var data string
+			if err := rows.Scan(&data); err != nil {
+				return err
+			}
+			h := new(HeldTx)
+			if err := json.Unmarshal([]byte(data), h); err != nil {
+				return fmt.Errorf("invalid held transaction: %v", err)
+			}
+			held = append(held, h)
+		}
+		return rows.Err()
+	})
+	return held, err
+}
+
+func (s *sqlStore) UpdateHeldTx(hash common.Hash, fn func(*HeldTx) (*HeldTx, error)) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "held:"+hash.Hex()); err != nil {
+			return err
+		}
+		cur, err := queryHeldTx(ctx, tx, hash)
+		if err != nil {
+			return err
+		}
+		h, err := fn(cur)
+		if err != nil {
+			return err
+		}
+		return setHeldTx(ctx, tx, hash, h)
+	})
+}
+
+// auditPage is how many audit entries ReadAudit reads per transaction, so
+// that reading a long log does not run into sqlTimeout.
+const auditPage = 1000
+
+func (s *sqlStore) AppendAudit(seal func(head AuditHead) (*AuditEntry, error)) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		if err := tx.lock(ctx, "audit"); err != nil {
+			return err
+		}
+		var (
+			head AuditHead
+			seq  int64
+		)
+		err := tx.queryRow(ctx, `SELECT seq, hash FROM audit ORDER BY seq DESC LIMIT 1`).Scan(&seq, &head.Hash)
+		switch {
+		case err == nil:
+			head.Seq = uint64(seq)
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		e, err := seal(head)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `INSERT INTO audit (seq, hash, entry) VALUES (?, ?, ?)`, int64(e.Seq), e.Hash, string(data))
+		return err
+	})
+}
+
+func (s *sqlStore) ReadAudit(fn func(*AuditEntry) error) error {
+	var after int64
+	for {
+		var page []*AuditEntry
+		err := s.update(func(ctx context.Context, tx *sqlTx) error {
+			rows, err := tx.query(ctx, `SELECT seq, entry FROM audit WHERE seq > ? ORDER BY seq LIMIT ?`, after, auditPage)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var data string
+				if err := rows.Scan(&after, &data); err != nil {
+					return err
+				}
+				e := new(AuditEntry)
+				if err := jsonfile.Decode([]byte(data), e); err != nil {
+					return fmt.Errorf("entry %d: %v", after, err)
+				}
+				page = append(page, e)
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			return err
+		}
+		for _, e := range page {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(page) < auditPage {
+			return nil
+		}
+	}
+}
+
+// importFileState copies the state of a JSON file store into s, for a new
+// database taking over from one.
+func (s *sqlStore) importFileState(state *fileState) error {
+	return s.update(func(ctx context.Context, tx *sqlTx) error {
+		for counter, records := range state.Spends {
+			for _, r := range records {
+				if _, err := tx.exec(ctx, `INSERT INTO spends (counter, amount, at) VALUES (?, ?, ?)`, counter, r.Amount.String(), r.At.UnixNano()); err != nil {
+					return err
+				}
+			}
+		}
+		for key, next := range state.Nonces {
+			if err := setNonce(ctx, tx, key, next); err != nil {
+				return err
+			}
+		}
+		for key, records := range state.Signed {
+			for _, r := range records {
+				if _, err := tx.exec(ctx, `INSERT INTO signed (key, nonce, signing_hash, tx_hash, at) VALUES (?, ?, ?, ?, ?)`,
+					key, int64(r.Nonce), r.SigningHash.Hex(), r.TxHash.Hex(), r.At.UnixNano()); err != nil {
+					return err
+				}
+			}
+		}
+		for key, recipients := range state.Recipients {
+			for to, at := range recipients {
+				if _, err := tx.exec(ctx, `INSERT INTO recipients (key, address, first_paid) VALUES (?, ?, ?)`, key, to.Hex(), at.UnixNano()); err != nil {
+					return err
+				}
+			}
+		}
+		for key, records := range state.Idempotency {
+			for id, r := range records {
+				// Pending claims belong to the process that made them.
+				if len(r.Raw) == 0 {
+					continue
+				}
+				var releaseAt sql.NullInt64
+				if r.ReleaseAt != nil {
+					releaseAt = sql.NullInt64{Int64: r.ReleaseAt.UnixNano(), Valid: true}
+				}
+				if _, err := tx.exec(ctx, `INSERT INTO idempotency (key, id, request, raw, release_at, at) VALUES (?, ?, ?, ?, ?, ?)`,
+					key, id, r.Request.Hex(), []byte(r.Raw), releaseAt, r.At.UnixNano()); err != nil {
+					return err
+				}
+			}
+		}
+		for id, req := range state.Approvals {
+			if err := setApprovalRequest(ctx, tx, id, req); err != nil {
+				return err
+			}
+		}
+		for hash, h := range state.Held {
+			if err := setHeldTx(ctx, tx, hash, h); err != nil {
+				return err
+			}
+		}
+		if state.Freeze != nil {
+			return setFreeze(ctx, tx, state.Freeze)
+		}
+		return nil
+	})
+}
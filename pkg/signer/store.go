@@ -0,0 +1,157 @@
+package signer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultStore is the state store used when -store is not given.
+const DefaultStore = "signer-state.db"
+
+// maxSpendWindow is the longest rolling window a spend limit may use; older
+// records are pruned.
+const maxSpendWindow = 7 * 24 * time.Hour
+
+// spendLimit caps the total recorded under a key within a rolling window.
+type spendLimit struct {
+	name   string
+	window time.Duration
+	max    *big.Int
+}
+
+// LimitError reports a spend that would exceed a rolling limit.
+type LimitError struct {
+	limit  spendLimit
+	spent  *big.Int
+	amount *big.Int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %s used + %s requested > %s", e.limit.name, e.spent, e.amount, e.limit.max)
+}
+
+// spendReservation adds amount to a spend counter, subject to limits.
+type spendReservation struct {
+	key    string
+	amount *big.Int
+	limits []spendLimit
+}
+
+// Store persists the signer's cumulative state across invocations.
+type Store interface {
+	// ReserveSpend atomically checks that adding each reservation's amount
+	// at now to its counter keeps every rolling window within the limit,
+	// and records them all. It returns a *LimitError and records nothing if
+	// any limit would be exceeded.
+	ReserveSpend(now time.Time, reservations []spendReservation) error
+	// NextNonce atomically hands out the next nonce for key: the stored
+	// next nonce, or floor if that is higher. The nonce after it becomes
+	// the stored next nonce.
+	NextNonce(key string, floor uint64) (uint64, error)
+	// ReleaseNonce undoes NextNonce for a transaction that was never
+	// signed, provided nonce is still the last one handed out for key.
+	ReleaseNonce(key string, nonce uint64) error
+	// Nonce returns the stored next nonce for key, if any.
+	Nonce(key string) (uint64, bool, error)
+	// SetNonce overwrites the stored next nonce for key.
+	SetNonce(key string, next uint64) error
+	// SignedTx returns the transaction signed for nonce under key, if any.
+	SignedTx(key string, nonce uint64) (SignedRecord, bool, error)
+	// RecordSigned records rec as the transaction signed for its nonce
+	// under key. Unless replace is set, it returns a *ReplayError and
+	// records nothing if a different transaction was already signed for
+	// that nonce.
+	RecordSigned(key string, rec SignedRecord, replace bool) error
+	// KnownRecipient reports whether to has been paid from key before.
+	KnownRecipient(key string, to common.Address) (bool, error)
+	// AddRecipient records that to was paid from key at now, keeping the
+	// time it was first paid.
+	AddRecipient(key string, to common.Address, now time.Time) error
+	// ClaimIdempotency returns the record of idempotency key id under key if
+	// there is a live one. Otherwise it records a pending claim on id for
+	// request at now, dropping records older than idempotencyTTL and claims
+	// older than idempotencyStale.
+	ClaimIdempotency(key, id string, request common.Hash, now time.Time) (idempotencyRecord, bool, error)
+	// CompleteIdempotency replaces the pending claim on id under key with
+	// rec, or drops it if rec is nil.
+	CompleteIdempotency(key, id string, rec *idempotencyRecord) error
+	// Freeze returns the current freeze, or nil if signing is not frozen.
+	Freeze() (*FreezeState, error)
+	// UpdateFreeze atomically replaces the current freeze, nil if there is
+	// none, with what fn returns for it; nil lifts the freeze. Nothing is
+	// written if fn fails. fn may be called more than once.
+	UpdateFreeze(fn func(*FreezeState) (*FreezeState, error)) error
+	// ApprovalRequest returns the pending approval request id, or nil if
+	// there is none.
+	ApprovalRequest(id common.Hash) (*ApprovalRequest, error)
+	// UpdateApprovalRequest atomically replaces approval request id, nil if
+	// there is none, with what fn returns for it; nil removes the request.
+	// Nothing is written if fn fails. fn may be called more than once.
+	UpdateApprovalRequest(id common.Hash, fn func(*ApprovalRequest) (*ApprovalRequest, error)) error
+	// HeldTx returns the time-delayed transaction hash, or nil if there is
+	// none.
+	HeldTx(hash common.Hash) (*HeldTx, error)
+	// HeldTxs returns the time-delayed transactions, oldest first.
+	HeldTxs() ([]*HeldTx, error)
+	// UpdateHeldTx atomically replaces time-delayed transaction hash, nil
+	// if there is none, with what fn returns for it; nil removes it.
+	// Nothing is written if fn fails. fn may be called more than once.
+	UpdateHeldTx(hash common.Hash, fn func(*HeldTx) (*HeldTx, error)) error
+	// AuditStore keeps the audit log recorded with -audit-log store.
+	AuditStore
+	// Ping returns an error unless the store can be read and locked.
+	Ping() error
+	Close() error
+}
+
+// OpenStore opens the state store at location: a postgres:// or
+// postgresql:// URL for a Postgres database, or a redis:// or rediss:// URL
+// for a Redis store, which several daemon replicas can share; the path of
+// a .json file for a JSON file store; or else the path of a SQLite
+// database, optionally as a sqlite:// URL.
+func OpenStore(location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return newPostgresStore(location)
+	case strings.HasPrefix(location, "redis://"), strings.HasPrefix(location, "rediss://"):
+		return newRedisStore(location)
+	case strings.HasPrefix(location, "sqlite://"):
+		return newSQLiteStore(strings.TrimPrefix(location, "sqlite://"))
+	case strings.HasSuffix(location, ".json"):
+		return newFileStore(location), nil
+	}
+	return newSQLiteStore(location)
+}
+
+// IsSharedStore reports whether location names a Postgres or Redis store,
+// which several daemon replicas can share.
+func IsSharedStore(location string) bool {
+	for _, scheme := range []string{"postgres://", "postgresql://", "redis://", "rediss://"} {
+		if strings.HasPrefix(location, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSpend returns a *LimitError if adding amount at now to records would
+// exceed any of limits.
+func checkSpend(records []spendRecord, amount *big.Int, now time.Time, limits []spendLimit) error {
+	for _, limit := range limits {
+		spent := new(big.Int)
+		since := now.Add(-limit.window)
+		for _, r := range records {
+			if r.At.After(since) {
+				spent.Add(spent, r.Amount)
+			}
+		}
+		if new(big.Int).Add(spent, amount).Cmp(limit.max) > 0 {
+			return &LimitError{limit: limit, spent: spent, amount: amount}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package signer
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testStores opens an empty store of every kind that needs no server.
+func testStores(t *testing.T) map[string]Store {
+	stores := make(map[string]Store)
+	for _, name := range []string{"state.json", "state.db"} {
+		store, err := OpenStore(filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[name] = store
+	}
+	return stores
+}
+
+func TestApprovalRequests(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id := common.HexToHash("0xaa")
+			if req, err := store.ApprovalRequest(id); err != nil || req != nil {
+				t.Fatalf("ApprovalRequest before any = %v, %v", req, err)
+			}
+			err := store.UpdateApprovalRequest(id, func(cur *ApprovalRequest) (*ApprovalRequest, error) {
+				return &ApprovalRequest{ID: id, ChainID: common.Big1, CreatedAt: time.Now().UTC()}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Approvals added concurrently must all be kept, and a second
+			// approval by the same approver replaces the first.
+			var wg sync.WaitGroup
+			for i := range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := store.UpdateApprovalRequest(id, func(cur *ApprovalRequest) (*ApprovalRequest, error) {
+						return cur.AddApproval(ApprovalEntry{Approver: common.BigToAddress(common.Big1), Signature: []byte{byte(i)}}), nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+					err = store.UpdateApprovalRequest(id, func(cur *ApprovalRequest) (*ApprovalRequest, error) {
+						return cur.AddApproval(ApprovalEntry{Approver: common.BigToAddress(common.Big2), Signature: []byte{byte(i)}}), nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			req, err := store.ApprovalRequest(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req == nil || len(req.Approvals) != 2 {
+				t.Fatalf("request has approvals %v, want one per approver", req)
+			}
+
+			failed := errors.New("failed")
+			if err := store.UpdateApprovalRequest(id, func(*ApprovalRequest) (*ApprovalRequest, error) { return nil, failed }); !errors.Is(err, failed) {
+				t.Fatalf("UpdateApprovalRequest = %v, want %v", err, failed)
+			}
+			if req, err := store.ApprovalRequest(id); err != nil || req == nil {
+				t.Fatalf("failed update removed the request: %v", err)
+			}
+			if err := store.UpdateApprovalRequest(id, func(*ApprovalRequest) (*ApprovalRequest, error) { return nil, nil }); err != nil {
+				t.Fatal(err)
+			}
+			if req, err := store.ApprovalRequest(id); err != nil || req != nil {
+				t.Fatalf("ApprovalRequest after removal = %v, %v", req, err)
+			}
+		})
+	}
+}
+
+func TestHeldTxs(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().UTC()
+			for i, hash := range []common.Hash{common.HexToHash("0x02"), common.HexToHash("0x01"), common.HexToHash("0x03")} {
+				h := &HeldTx{TxHash: hash, ChainID: common.Big1, Value: common.Big0, Raw: []byte{1}, HeldAt: now.Add(time.Duration(i) * time.Second), ReleaseAt: now.Add(time.Hour)}
+				if err := store.UpdateHeldTx(hash, func(*HeldTx) (*HeldTx, error) { return h, nil }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			held, err := store.HeldTxs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []common.Hash
+			for _, h := range held {
+				order = append(order, h.TxHash)
+			}
+			if len(order) != 3 || order[0] != common.HexToHash("0x02") || order[2] != common.HexToHash("0x03") {
+				t.Fatalf("HeldTxs order = %v, want oldest first", order)
+			}
+
+			hash := common.HexToHash("0x01")
+			err = store.UpdateHeldTx(hash, func(cur *HeldTx) (*HeldTx, error) {
+				next := *cur
+				next.Raw, next.CancelledAt, next.CancelledBy = nil, &now, "ops"
+				return &next, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, err := store.HeldTx(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h == nil || h.Status(now) != "cancelled" || len(h.Raw) != 0 {
+				t.Fatalf("cancelled transaction = %+v", h)
+			}
+			if err := store.UpdateHeldTx(hash, func(*HeldTx) (*HeldTx, error) { return nil, nil }); err != nil {
+				t.Fatal(err)
+			}
+			if h, err := store.HeldTx(hash); err != nil || h != nil {
+				t.Fatalf("HeldTx after removal = %v, %v", h, err)
+			}
+		})
+	}
+}
+
+func TestAuditStore(t *testing.T) {
+	logs := map[string]AuditStore{"file": OpenAuditFile(filepath.Join(t.TempDir(), "audit.jsonl"))}
+	for name, store := range testStores(t) {
+		logs[name] = store
+	}
+	for name, entries := range logs {
+		t.Run(name, func(t *testing.T) {
+			// Separate logs on one store, like replicas, must still record
+			// a single chain.
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					log := &AuditLog{Entries: entries, Operator: "ops"}
+					if err := log.auditSignature(AuditMessage, common.Address{}, nil, nil, nil); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			head, err := VerifyAuditLog(entries, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head.Seq != 20 {
+				t.Fatalf("log ends at entry %d, want 20", head.Seq)
+			}
+		})
+	}
+}
+
+// TestStoreReplicas checks that two stores opened on one database, as by two
+// daemon replicas, see each other's approvals, held transactions, freeze and
+// audit entries.
+func TestStoreReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	var replicas [2]Store
+	for i := range replicas {
+		store, err := OpenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		replicas[i] = store
+	}
+	a, b := replicas[0], replicas[1]
+
+	id := common.HexToHash("0xaa")
+	if err := a.UpdateApprovalRequest(id, func(*ApprovalRequest) (*ApprovalRequest, error) { return &ApprovalRequest{ID: id}, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if req, err := b.ApprovalRequest(id); err != nil || req == nil {
+		t.Fatalf("approval request not shared: %v", err)
+	}
+	h := &HeldTx{TxHash: id, ChainID: common.Big1, Value: common.Big0, HeldAt: time.Now()}
+	if err := a.UpdateHeldTx(id, func(*HeldTx) (*HeldTx, error) { return h, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := b.HeldTxs(); err != nil || len(held) != 1 {
+		t.Fatalf("held transactions not shared: %v, %v", held, err)
+	}
+	if err := a.UpdateFreeze(func(*FreezeState) (*FreezeState, error) { return &FreezeState{FrozenBy: "ops"}, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFrozen(b); err == nil {
+		t.Fatal("freeze not shared")
+	}
+	for _, store := range replicas {
+		if err := (&AuditLog{Entries: store}).auditSignature(AuditMessage, common.Address{}, nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if head, err := VerifyAuditLog(a, nil, nil); err != nil || head.Seq != 2 {
+		t.Fatalf("audit chain ends at entry %d, %v; want 2", head.Seq, err)
+	}
+}
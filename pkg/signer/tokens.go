@@ -0,0 +1,97 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// TokenDecimals reads the decimals of ERC-20 tokens over RPC, remembering
+// them for the life of the process.
+type TokenDecimals struct {
+	client *ethclient.Client
+	mu     sync.Mutex
+	known  map[common.Address]int
+}
+
+// NewTokenDecimals returns a reader using client, or nil without one.
+func NewTokenDecimals(client *ethclient.Client) *TokenDecimals {
+	if client == nil {
+		return nil
+	}
+	return &TokenDecimals{client: client, known: make(map[common.Address]int)}
+}
+
+// decimals returns the decimals() of token.
+func (t *TokenDecimals) decimals(ctx context.Context, token common.Address) (int, error) {
+	t.mu.Lock()
+	n, ok := t.known[token]
+	t.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+	out, err := t.client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: selectorDecimals}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch decimals of %s: %v", token.Hex(), err)
+	}
+	if len(out) != 32 || new(big.Int).SetBytes(out).Cmp(big.NewInt(policy.MaxTokenDecimals)) > 0 {
+		return 0, fmt.Errorf("failed to fetch decimals of %s: unexpected response", token.Hex())
+	}
+	n = int(new(big.Int).SetBytes(out).Int64())
+	t.mu.Lock()
+	t.known[token] = n
+	t.mu.Unlock()
+	return n, nil
+}
+
+// checkTokenDecimals rejects calls to a listed token whose decimals on
+// chain differ from those in the policy, which would make its whole-token
+// limits wrong. It does nothing offline.
+func (e *Engine) checkTokenDecimals(ctx context.Context, pol *policy.Policy, tx *types.Transaction, d *policy.Decision) error {
+	if e.TokenDecimals == nil || tx.To() == nil || len(tx.Data()) == 0 {
+		return nil
+	}
+	tp, ok := pol.TokenPolicy(*tx.To())
+	if !ok || tp.Decimals == nil {
+		return nil
+	}
+	n, err := e.TokenDecimals.decimals(ctx, *tx.To())
+	if err != nil {
+		return d.Record("tokens.decimals", err)
+	}
+	if n != *tp.Decimals {
+		return d.Fail("tokens.decimals", *tp.Decimals, n, fmt.Sprintf("token %s has %d decimals, not %d as in the policy", tx.To().Hex(), n, *tp.Decimals))
+	}
+	d.Pass("tokens.decimals", *tp.Decimals, n)
+	return nil
+}
+
+// tokenReservation returns the reservation of an ERC-20 transfer in tx
+// against the daily limit of its token in p, if the token has one.
+func tokenReservation(p *policy.Policy, tx *types.Transaction, chainID *big.Int, from string) (spendReservation, bool) {
+	if tx.To() == nil {
+		return spendReservation{}, false
+	}
+	_, amount, ok := txbuilder.DecodeERC20Transfer(tx.Data())
+	if !ok {
+		return spendReservation{}, false
+	}
+	limit, ok := p.TokenDailyLimit(*tx.To())
+	if !ok {
+		return spendReservation{}, false
+	}
+	return spendReservation{
+		key:    spendKey(chainID, from, "erc20", tx.To().Hex()),
+		amount: amount,
+		limits: []spendLimit{{name: "token daily", window: 24 * time.Hour, max: limit}},
+	}, true
+}
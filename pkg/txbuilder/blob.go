@@ -0,0 +1,82 @@
+package txbuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blobBytesPerFieldElement is how much data each 32-byte field element of
+// a blob holds. The first byte of every element is left zero so it is
+// always below the BLS12-381 modulus.
+const blobBytesPerFieldElement = 31
+
+// blobCapacity is the data one blob holds.
+const blobCapacity = blobBytesPerFieldElement * params.BlobTxFieldElementsPerBlob
+
+// encodeBlobs packs data into as many blobs as it needs, 31 bytes per field
+// element, zero-padding the last one.
+func encodeBlobs(data []byte) []kzg4844.Blob {
+	blobs := make([]kzg4844.Blob, (len(data)+blobCapacity-1)/blobCapacity)
+	for i := range blobs {
+		chunk := data[i*blobCapacity : min((i+1)*blobCapacity, len(data))]
+		for j := 0; len(chunk) > 0; j++ {
+			n := copy(blobs[i][j*32+1:(j+1)*32], chunk)
+			chunk = chunk[n:]
+		}
+	}
+	return blobs
+}
+
+// NewBlobSidecar reads the blob data files, each encoded into its own
+// blobs, and computes their KZG commitments and proofs.
+func NewBlobSidecar(files []string) (*types.BlobTxSidecar, error) {
+	sidecar := new(types.BlobTxSidecar)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob: %v", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("blob file %s is empty", file)
+		}
+		sidecar.Blobs = append(sidecar.Blobs, encodeBlobs(data)...)
+	}
+	if len(sidecar.Blobs) == 0 {
+		return nil, errors.New("blob transactions need at least one blob file")
+	}
+	for i := range sidecar.Blobs {
+		commitment, err := kzg4844.BlobToCommitment(&sidecar.Blobs[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute blob commitment: %v", err)
+		}
+		proof, err := kzg4844.ComputeBlobProof(&sidecar.Blobs[i], commitment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute blob proof: %v", err)
+		}
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+	return sidecar, nil
+}
+
+// SuggestBlobFee sets fees.maxBlobFee from eth_blobBaseFee times the base
+// fee multiplier.
+func SuggestBlobFee(ctx context.Context, client *ethclient.Client, settings FeeSettings, fees *FeeParams) error {
+	baseFee, err := client.BlobBaseFee(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch blob base fee: %v", err)
+	}
+	maxBlobFee := mulFloat(baseFee, multiplierOr(settings.BaseFeeMultiplier, defaultBaseFeeMultiplier))
+	if settings.MaxBlobFeePerGasWei != nil && maxBlobFee.Cmp(settings.MaxBlobFeePerGasWei) > 0 {
+		return fmt.Errorf("suggested max blob fee %s exceeds policy cap %s", maxBlobFee, settings.MaxBlobFeePerGasWei)
+	}
+	fees.MaxBlobFee = maxBlobFee
+	return nil
+}
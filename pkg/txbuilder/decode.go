@@ -0,0 +1,20 @@
+package txbuilder
+
+// CallOutput describes calldata recognized as a token or NFT transfer.
+type CallOutput struct {
+	Kind      string `json:"kind"`
+	Recipient string `json:"recipient"`
+	Amount    string `json:"amount"`
+	TokenID   string `json:"tokenId,omitempty"`
+}
+
+// DecodeCall recognizes ERC-20 and NFT transfers in data.
+func DecodeCall(data []byte) *CallOutput {
+	if recipient, amount, ok := DecodeERC20Transfer(data); ok {
+		return &CallOutput{Kind: "erc20-transfer", Recipient: recipient.Hex(), Amount: amount.String()}
+	}
+	if t, ok := DecodeNFTTransfer(data); ok {
+		return &CallOutput{Kind: "nft-transfer", Recipient: t.To.Hex(), Amount: t.Amount.String(), TokenID: t.TokenID.String()}
+	}
+	return nil
+}
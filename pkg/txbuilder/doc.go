@@ -0,0 +1,5 @@
+// Package txbuilder builds the transactions and messages the signer signs:
+// transfers with their fees and gas, ERC-20 and NFT calldata, EIP-2612
+// permits, Safe transactions, ERC-4337 user operations and zkSync Era
+// transactions, as well as the JSON-RPC calls that fill them in.
+package txbuilder
@@ -0,0 +1,50 @@
+package txbuilder
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// transfer(address,uint256)
+	selectorERC20Transfer = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	// transferFrom(address,address,uint256), shared with ERC-721
+	selectorTransferFrom = []byte{0x23, 0xb8, 0x72, 0xdd}
+)
+
+// EncodeERC20Transfer returns calldata for transfer(to, amount).
+func EncodeERC20Transfer(to common.Address, amount *big.Int) []byte {
+	data := append([]byte{}, selectorERC20Transfer...)
+	data = append(data, common.LeftPadBytes(to.Bytes(), 32)...)
+	return append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+}
+
+// IsERC20Transfer reports whether data calls transfer or transferFrom,
+// whether or not its arguments decode.
+func IsERC20Transfer(data []byte) bool {
+	return len(data) >= 4 && (bytes.Equal(data[:4], selectorERC20Transfer) || bytes.Equal(data[:4], selectorTransferFrom))
+}
+
+// DecodeERC20Transfer reports whether data is a transfer(address,uint256)
+// or transferFrom(address,address,uint256) call and returns its recipient
+// and amount. It decodes the way token contracts do: trailing bytes are
+// ignored and an address is the low 20 bytes of its word.
+func DecodeERC20Transfer(data []byte) (to common.Address, amount *big.Int, ok bool) {
+	var args []byte
+	switch {
+	case len(data) >= 4+2*32 && bytes.Equal(data[:4], selectorERC20Transfer):
+		args = data[4:]
+	case len(data) >= 4+3*32 && bytes.Equal(data[:4], selectorTransferFrom):
+		args = data[4+32:]
+	default:
+		return common.Address{}, nil, false
+	}
+	return wordAddress(args[:32]), new(big.Int).SetBytes(args[32:64]), true
+}
+
+// wordAddress returns the address held in the low 20 bytes of an ABI word.
+func wordAddress(w []byte) common.Address {
+	return common.BytesToAddress(w[12:32])
+}
@@ -0,0 +1,72 @@
+package txbuilder
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeERC20Transfer(t *testing.T) {
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	from := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	amount := big.NewInt(1000)
+	transfer := EncodeERC20Transfer(to, amount)
+	dirty := append([]byte{}, transfer...)
+	copy(dirty[4:16], bytes.Repeat([]byte{0xff}, 12))
+	transferFrom := append(append([]byte{}, selectorTransferFrom...), addressWord(from)...)
+	transferFrom = append(append(transferFrom, addressWord(to)...), Word(amount)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"transfer", transfer, true},
+		{"padded", append(append([]byte{}, transfer...), 0x01, 0x02), true},
+		{"dirty address", dirty, true},
+		{"transferFrom", transferFrom, true},
+		{"padded transferFrom", append(append([]byte{}, transferFrom...), make([]byte, 32)...), true},
+		{"short", transfer[:67], false},
+		{"short transferFrom", transferFrom[:99], false},
+		{"selector only", selectorERC20Transfer, false},
+		{"other selector", append([]byte{0x09, 0x5e, 0xa7, 0xb3}, transfer[4:]...), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTo, gotAmount, ok := DecodeERC20Transfer(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if gotTo != to {
+				t.Errorf("to = %s, want %s", gotTo.Hex(), to.Hex())
+			}
+			if gotAmount.Cmp(amount) != 0 {
+				t.Errorf("amount = %s, want %s", gotAmount, amount)
+			}
+		})
+	}
+}
+
+func TestIsERC20Transfer(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{selectorERC20Transfer, true},
+		{append(append([]byte{}, selectorTransferFrom...), 0x01), true},
+		{selectorERC721SafeTransfer, false},
+		{[]byte{0xa9, 0x05, 0x9c}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsERC20Transfer(tt.data); got != tt.want {
+			t.Errorf("IsERC20Transfer(%x) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package txbuilder
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Multipliers used when the policy does not set them.
+const (
+	defaultGasLimitMultiplier = 1.2
+	defaultBaseFeeMultiplier  = 2.0
+)
+
+// FeeSettings controls how the gas limit and fees are filled in from an RPC
+// endpoint. The caps are hard limits: a suggestion above them is refused
+// rather than clamped, since a clamped fee may never be included, and every
+// transaction is checked against them before signing however its fees were
+// chosen.
+type FeeSettings struct {
+	GasLimitMultiplier float64  `json:"gas_limit_multiplier"`
+	BaseFeeMultiplier  float64  `json:"base_fee_multiplier"`
+	MaxGasLimit        uint64   `json:"max_gas_limit"`
+	MaxFeePerGasWei    *big.Int `json:"max_fee_per_gas_wei"`
+	MaxPriorityFeeWei  *big.Int `json:"max_priority_fee_wei"`
+	// MaxTotalCostWei caps value + gas limit × max fee per gas, plus the
+	// blob gas × max blob fee of blob transactions and, on OP Stack chains
+	// with an RPC endpoint, the L1 data fee.
+	MaxTotalCostWei *big.Int `json:"max_total_cost_wei"`
+	// MaxBlobsPerTx caps the blobs of an EIP-4844 transaction; blob
+	// transactions are rejected when it is 0. MaxBlobFeePerGasWei caps
+	// their max fee per blob gas.
+	MaxBlobsPerTx       int      `json:"max_blobs_per_tx"`
+	MaxBlobFeePerGasWei *big.Int `json:"max_blob_fee_per_gas_wei"`
+}
+
+// mulFloat returns x*f rounded up, so a safety margin is never lost to
+// float rounding.
+func mulFloat(x *big.Int, f float64) *big.Int {
+	product := new(big.Float).Mul(new(big.Float).SetInt(x), big.NewFloat(f))
+	result, accuracy := product.Int(nil)
+	if accuracy == big.Below {
+		result.Add(result, big.NewInt(1))
+	}
+	return result
+}
+
+func multiplierOr(value, def float64) float64 {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+// EstimateGasLimit returns eth_estimateGas for the call, scaled by the
+// policy's safety multiplier.
+func EstimateGasLimit(ctx context.Context, client *ethclient.Client, settings FeeSettings, msg ethereum.CallMsg) (uint64, error) {
+	estimate, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+	return ScaleGasLimit(settings, estimate)
+}
+
+// ScaleGasLimit applies the policy's safety multiplier and gas limit cap to
+// a gas estimate.
+func ScaleGasLimit(settings FeeSettings, estimate uint64) (uint64, error) {
+	gas := mulFloat(new(big.Int).SetUint64(estimate), multiplierOr(settings.GasLimitMultiplier, defaultGasLimitMultiplier))
+	if !gas.IsUint64() {
+		return 0, fmt.Errorf("gas estimate %s overflows", gas)
+	}
+	if settings.MaxGasLimit > 0 && gas.Uint64() > settings.MaxGasLimit {
+		return 0, fmt.Errorf("estimated gas limit %d exceeds policy cap %d", gas.Uint64(), settings.MaxGasLimit)
+	}
+	return gas.Uint64(), nil
+}
+
+// CreateAccessList asks the node for the access list of the call with
+// eth_createAccessList and returns it with the gas the call uses with it.
+func CreateAccessList(ctx context.Context, client *ethclient.Client, from, to common.Address, value *big.Int, data []byte) (types.AccessList, uint64, error) {
+	var result struct {
+		AccessList types.AccessList `json:"accessList"`
+		GasUsed    hexutil.Uint64   `json:"gasUsed"`
+		Error      string           `json:"error"`
+	}
+	args := map[string]any{
+		"from":  from,
+		"to":    to,
+		"value": (*hexutil.Big)(value),
+		"data":  hexutil.Bytes(data),
+	}
+	if err := client.Client().CallContext(ctx, &result, "eth_createAccessList", args, "pending"); err != nil {
+		return nil, 0, fmt.Errorf("failed to create access list: %v", err)
+	}
+	if result.Error != "" {
+		return nil, 0, fmt.Errorf("failed to create access list: call reverted: %s", result.Error)
+	}
+	return result.AccessList, uint64(result.GasUsed), nil
+}
+
+// SuggestGasPrice sets fees.gasPrice from eth_gasPrice.
+func SuggestGasPrice(ctx context.Context, client *ethclient.Client, settings FeeSettings, fees *FeeParams) error {
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch gas price: %v", err)
+	}
+	if settings.MaxFeePerGasWei != nil && price.Cmp(settings.MaxFeePerGasWei) > 0 {
+		return fmt.Errorf("suggested gas price %s exceeds policy cap %s", price, settings.MaxFeePerGasWei)
+	}
+	fees.GasPrice = price
+	return nil
+}
+
+// SuggestDynamicFees fills whichever of fees.maxPriorityFee and fees.maxFee
+// are unset. The tip comes from eth_maxPriorityFeePerGas; the fee cap is the
+// next block's base fee from eth_feeHistory times the base fee multiplier,
+// plus the tip.
+func SuggestDynamicFees(ctx context.Context, client *ethclient.Client, settings FeeSettings, fees *FeeParams) error {
+	if fees.MaxPriorityFee == nil {
+		tip, err := client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch priority fee: %v", err)
+		}
+		if settings.MaxPriorityFeeWei != nil && tip.Cmp(settings.MaxPriorityFeeWei) > 0 {
+			return fmt.Errorf("suggested priority fee %s exceeds policy cap %s", tip, settings.MaxPriorityFeeWei)
+		}
+		fees.MaxPriorityFee = tip
+	}
+	if fees.MaxFee == nil {
+		history, err := client.FeeHistory(ctx, 1, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch fee history: %v", err)
+		}
+		if len(history.BaseFee) == 0 {
+			return fmt.Errorf("fee history returned no base fee")
+		}
+		// The last entry is the base fee of the next block.
+		baseFee := history.BaseFee[len(history.BaseFee)-1]
+		maxFee := mulFloat(baseFee, multiplierOr(settings.BaseFeeMultiplier, defaultBaseFeeMultiplier))
+		maxFee.Add(maxFee, fees.MaxPriorityFee)
+		if settings.MaxFeePerGasWei != nil && maxFee.Cmp(settings.MaxFeePerGasWei) > 0 {
+			return fmt.Errorf("suggested max fee %s exceeds policy cap %s", maxFee, settings.MaxFeePerGasWei)
+		}
+		fees.MaxFee = maxFee
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package txbuilder
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// safeTransferFrom(address,address,uint256)
+	selectorERC721SafeTransfer = []byte{0x42, 0x84, 0x2e, 0x0e}
+	// safeTransferFrom(address,address,uint256,bytes)
+	selectorERC721SafeTransferData = []byte{0xb8, 0x8d, 0x4f, 0xde}
+	// safeTransferFrom(address,address,uint256,uint256,bytes)
+	selectorERC1155SafeTransfer = []byte{0xf2, 0x42, 0x43, 0x2a}
+	// safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)
+	selectorERC1155SafeBatchTransfer = []byte{0x2e, 0xb2, 0xc2, 0xd6}
+)
+
+// NFTTransfer is a decoded ERC-721 transferFrom or safeTransferFrom call,
+// or an ERC-1155 safeTransferFrom call. Amount is 1 for ERC-721.
+type NFTTransfer struct {
+	From    common.Address
+	To      common.Address
+	TokenID *big.Int
+	Amount  *big.Int
+}
+
+func Word(v *big.Int) []byte {
+	return common.LeftPadBytes(v.Bytes(), 32)
+}
+
+func addressWord(addr common.Address) []byte {
+	return common.LeftPadBytes(addr.Bytes(), 32)
+}
+
+// EncodeERC721Transfer returns calldata for safeTransferFrom(from, to, tokenID).
+func EncodeERC721Transfer(from, to common.Address, tokenID *big.Int) []byte {
+	data := append([]byte{}, selectorERC721SafeTransfer...)
+	data = append(data, addressWord(from)...)
+	data = append(data, addressWord(to)...)
+	return append(data, Word(tokenID)...)
+}
+
+// EncodeERC1155Transfer returns calldata for
+// safeTransferFrom(from, to, id, amount, "").
+func EncodeERC1155Transfer(from, to common.Address, id, amount *big.Int) []byte {
+	data := append([]byte{}, selectorERC1155SafeTransfer...)
+	data = append(data, addressWord(from)...)
+	data = append(data, addressWord(to)...)
+	data = append(data, Word(id)...)
+	data = append(data, Word(amount)...)
+	data = append(data, Word(big.NewInt(5*32))...) // offset of the bytes argument
+	return append(data, Word(new(big.Int))...)     // zero-length bytes
+}
+
+// IsNFTTransfer reports whether data calls one of the ERC-721 or ERC-1155
+// transfer functions, whether or not its arguments decode. Batch transfers
+// are included although DecodeNFTTransfer does not decode them.
+func IsNFTTransfer(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	for _, sel := range [][]byte{selectorTransferFrom, selectorERC721SafeTransfer, selectorERC721SafeTransferData, selectorERC1155SafeTransfer, selectorERC1155SafeBatchTransfer} {
+		if bytes.Equal(data[:4], sel) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeNFTTransfer reports whether data is an ERC-721 transferFrom or
+// safeTransferFrom call, or an ERC-1155 safeTransferFrom call, and returns
+// its arguments. Like DecodeERC20Transfer it ignores trailing bytes and
+// the high bytes of address words; the bytes argument is not decoded.
+// transferFrom is shared with ERC-20, so such calls decode as both.
+func DecodeNFTTransfer(data []byte) (*NFTTransfer, bool) {
+	if len(data) < 4+3*32 {
+		return nil, false
+	}
+	args := data[4:]
+	t := &NFTTransfer{
+		From:    wordAddress(args[:32]),
+		To:      wordAddress(args[32:64]),
+		TokenID: new(big.Int).SetBytes(args[64:96]),
+	}
+	switch sel := data[:4]; {
+	case bytes.Equal(sel, selectorTransferFrom), bytes.Equal(sel, selectorERC721SafeTransfer), bytes.Equal(sel, selectorERC721SafeTransferData):
+		t.Amount = big.NewInt(1)
+	case bytes.Equal(sel, selectorERC1155SafeTransfer) && len(args) >= 4*32:
+		t.Amount = new(big.Int).SetBytes(args[96:128])
+	default:
+		return nil, false
+	}
+	return t, true
+}
@@ -0,0 +1,72 @@
+package txbuilder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeNFTTransfer(t *testing.T) {
+	from := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	id := big.NewInt(7)
+	erc721 := EncodeERC721Transfer(from, to, id)
+	erc1155 := EncodeERC1155Transfer(from, to, id, big.NewInt(3))
+	withSelector := func(sel []byte, data []byte) []byte {
+		return append(append([]byte{}, sel...), data[4:]...)
+	}
+	dirty := append([]byte{}, erc721...)
+	dirty[4+32] = 0xff
+
+	tests := []struct {
+		name   string
+		data   []byte
+		amount int64 // 0 if the call must not decode
+	}{
+		{"erc721", erc721, 1},
+		{"erc721 padded", append(append([]byte{}, erc721...), 0x00), 1},
+		{"erc721 dirty address", dirty, 1},
+		{"erc721 with data", withSelector(selectorERC721SafeTransferData, erc1155), 1},
+		{"transferFrom", withSelector(selectorTransferFrom, erc721), 1},
+		{"erc1155", erc1155, 3},
+		{"erc1155 without data", erc1155[:4+4*32], 3},
+		{"erc1155 short", erc1155[:4+3*32], 0},
+		{"erc721 short", erc721[:99], 0},
+		{"batch", withSelector(selectorERC1155SafeBatchTransfer, erc1155), 0},
+		{"other selector", withSelector([]byte{0x01, 0x02, 0x03, 0x04}, erc721), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DecodeNFTTransfer(tt.data)
+			if ok != (tt.amount != 0) {
+				t.Fatalf("ok = %v, want %v", ok, tt.amount != 0)
+			}
+			if !ok {
+				return
+			}
+			if got.From != from || got.To != to {
+				t.Errorf("from, to = %s, %s, want %s, %s", got.From.Hex(), got.To.Hex(), from.Hex(), to.Hex())
+			}
+			if got.TokenID.Cmp(id) != 0 {
+				t.Errorf("token ID = %s, want %s", got.TokenID, id)
+			}
+			if got.Amount.Int64() != tt.amount {
+				t.Errorf("amount = %s, want %d", got.Amount, tt.amount)
+			}
+		})
+	}
+}
+
+func TestIsNFTTransfer(t *testing.T) {
+	for _, sel := range [][]byte{selectorTransferFrom, selectorERC721SafeTransfer, selectorERC721SafeTransferData, selectorERC1155SafeTransfer, selectorERC1155SafeBatchTransfer} {
+		if !IsNFTTransfer(sel) {
+			t.Errorf("IsNFTTransfer(%x) = false, want true", sel)
+		}
+	}
+	for _, data := range [][]byte{selectorERC20Transfer, {0x42, 0x84, 0x2e}, nil} {
+		if IsNFTTransfer(data) {
+			t.Errorf("IsNFTTransfer(%x) = true, want false", data)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package txbuilder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var (
+	// name()
+	selectorName = []byte{0x06, 0xfd, 0xde, 0x03}
+	// version()
+	selectorVersion = []byte{0x54, 0xfd, 0x4d, 0x50}
+	// nonces(address)
+	selectorNonces = []byte{0x7e, 0xce, 0xbe, 0x00}
+	// DOMAIN_SEPARATOR()
+	selectorDomainSeparator = []byte{0x36, 0x44, 0xe5, 0x15}
+)
+
+// Permit is an EIP-2612 Permit with its token's domain.
+type Permit struct {
+	Token    common.Address
+	Name     string
+	Version  string
+	ChainID  *big.Int
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+}
+
+func (p *Permit) TypedData() *apitypes.TypedData {
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              p.Name,
+			Version:           p.Version,
+			ChainId:           (*math.HexOrDecimal256)(p.ChainID),
+			VerifyingContract: p.Token.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    p.Owner.Hex(),
+			"spender":  p.Spender.Hex(),
+			"value":    p.Value.String(),
+			"nonce":    p.Nonce.String(),
+			"deadline": p.Deadline.String(),
+		},
+	}
+}
+
+// ParseDeadline reads a Unix timestamp, or a duration from now such as
+// "30m".
+func ParseDeadline(value string, now time.Time) (*big.Int, error) {
+	if ts, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return new(big.Int).SetUint64(ts), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid deadline %q: want a Unix timestamp or a duration such as 30m", value)
+	}
+	return big.NewInt(now.Add(d).Unix()), nil
+}
+
+// CallToken calls a view function of token.
+func CallToken(ctx context.Context, client *ethclient.Client, token common.Address, data []byte) ([]byte, error) {
+	return client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+}
+
+// decodeStringResult decodes an ABI string return value, or the bytes32
+// some older tokens return instead.
+func decodeStringResult(out []byte) (string, bool) {
+	if len(out) == 32 {
+		return string(bytes.TrimRight(out, "\x00")), true
+	}
+	offset, ok := abiUint(out, 0)
+	if !ok {
+		return "", false
+	}
+	length, ok := abiUint(out, offset)
+	if !ok || offset+32+length > uint64(len(out)) {
+		return "", false
+	}
+	return string(out[offset+32 : offset+32+length]), true
+}
+
+// Fetch fills in the token's name, version and the owner's nonce from the
+// chain, for the fields not given, and checks the resulting domain against
+// the token's DOMAIN_SEPARATOR.
+func (p *Permit) Fetch(ctx context.Context, client *ethclient.Client, haveName, haveVersion, haveNonce bool) error {
+	if !haveName {
+		out, err := CallToken(ctx, client, p.Token, selectorName)
+		if err != nil {
+			return fmt.Errorf("failed to fetch token name: %v", err)
+		}
+		name, ok := decodeStringResult(out)
+		if !ok {
+			return errors.New("token returned an invalid name")
+		}
+		p.Name = name
+	}
+	if !haveVersion {
+		// Most tokens do not expose version() and use "1".
+		p.Version = "1"
+		if out, err := CallToken(ctx, client, p.Token, selectorVersion); err == nil {
+			if version, ok := decodeStringResult(out); ok {
+				p.Version = version
+			}
+		}
+	}
+	if !haveNonce {
+		out, err := CallToken(ctx, client, p.Token, append(append([]byte{}, selectorNonces...), addressWord(p.Owner)...))
+		if err != nil {
+			return fmt.Errorf("failed to fetch permit nonce: %v", err)
+		}
+		if len(out) != 32 {
+			return errors.New("token does not support EIP-2612 permits")
+		}
+		p.Nonce = new(big.Int).SetBytes(out)
+	}
+	out, err := CallToken(ctx, client, p.Token, selectorDomainSeparator)
+	if err != nil || len(out) != 32 {
+		return errors.New("token does not expose DOMAIN_SEPARATOR")
+	}
+	typed := p.TypedData()
+	separator, err := typed.HashStruct("EIP712Domain", typed.Domain.Map())
+	if err != nil {
+		return fmt.Errorf("failed to hash domain: %v", err)
+	}
+	if !bytes.Equal(separator, out) {
+		return fmt.Errorf("domain (name %q, version %q) does not match the token's DOMAIN_SEPARATOR %s", p.Name, p.Version, hexutil.Encode(out))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package txbuilder
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Dial connects to the JSON-RPC endpoint at url, tracing calls
+// made over HTTP.
+func Dial(ctx context.Context, url string) (*ethclient.Client, error) {
+	c, err := rpc.DialOptions(ctx, url, rpc.WithHTTPClient(tracing.HTTPClient))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(c), nil
+}
+
+// FetchChainID returns the endpoint's chain ID. When want is non-nil (the
+// operator gave -chain explicitly) the endpoint must agree with it.
+func FetchChainID(ctx context.Context, client *ethclient.Client, want *big.Int) (*big.Int, error) {
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chain ID: %v", err)
+	}
+	if want != nil && want.Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("rpc endpoint is on chain %s, not %s", chainID, want)
+	}
+	return chainID, nil
+}
+
+// FetchNonce returns the pending nonce of from.
+func FetchNonce(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error) {
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch nonce: %v", err)
+	}
+	return nonce, nil
+}
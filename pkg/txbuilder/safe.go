@@ -0,0 +1,105 @@
+package txbuilder
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Safe transaction operations.
+const (
+	SafeOperationCall         = 0
+	SafeOperationDelegateCall = 1
+)
+
+// selectorSafeNonce is the 4-byte selector of nonce().
+var selectorSafeNonce = []byte{0xaf, 0xfe, 0xd0, 0xe0}
+
+// SafeTx is a Safe multisig transaction.
+type SafeTx struct {
+	To             common.Address
+	Value          *big.Int
+	Data           []byte
+	Operation      uint8
+	SafeTxGas      *big.Int
+	BaseGas        *big.Int // dataGas in Safe 1.0.0
+	GasPrice       *big.Int
+	GasToken       common.Address
+	RefundReceiver common.Address
+	Nonce          *big.Int
+}
+
+// TypedData returns the SafeTx EIP-712 typed data for tx on safe. Safes
+// before 1.3.0 leave the chain ID out of their domain, and Safe 1.0.0 names
+// baseGas dataGas, which changes the type hash.
+func (tx *SafeTx) TypedData(safe common.Address, chainID *big.Int, version string) *apitypes.TypedData {
+	domain := []apitypes.Type{{Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}}
+	domainValues := apitypes.TypedDataDomain{ChainId: (*math.HexOrDecimal256)(chainID), VerifyingContract: safe.Hex()}
+	if legacySafeVersion(version) {
+		domain = domain[1:]
+		domainValues.ChainId = nil
+	}
+	baseGas := "baseGas"
+	if version == "1.0.0" {
+		baseGas = "dataGas"
+	}
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domain,
+			"SafeTx": {
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "operation", Type: "uint8"},
+				{Name: "safeTxGas", Type: "uint256"},
+				{Name: baseGas, Type: "uint256"},
+				{Name: "gasPrice", Type: "uint256"},
+				{Name: "gasToken", Type: "address"},
+				{Name: "refundReceiver", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+			},
+		},
+		PrimaryType: "SafeTx",
+		Domain:      domainValues,
+		Message: apitypes.TypedDataMessage{
+			"to":             tx.To.Hex(),
+			"value":          tx.Value.String(),
+			"data":           hexutil.Encode(tx.Data),
+			"operation":      fmt.Sprint(tx.Operation),
+			"safeTxGas":      tx.SafeTxGas.String(),
+			baseGas:          tx.BaseGas.String(),
+			"gasPrice":       tx.GasPrice.String(),
+			"gasToken":       tx.GasToken.Hex(),
+			"refundReceiver": tx.RefundReceiver.Hex(),
+			"nonce":          tx.Nonce.String(),
+		},
+	}
+}
+
+// legacySafeVersion reports whether version predates Safe 1.3.0.
+func legacySafeVersion(version string) bool {
+	switch version {
+	case "1.0.0", "1.1.0", "1.1.1", "1.2.0":
+		return true
+	}
+	return false
+}
+
+// FetchSafeNonce returns the nonce of safe's next transaction.
+func FetchSafeNonce(ctx context.Context, client *ethclient.Client, safe common.Address) (*big.Int, error) {
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &safe, Data: selectorSafeNonce}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch safe nonce: %v", err)
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("%s does not look like a safe", safe.Hex())
+	}
+	return new(big.Int).SetBytes(out), nil
+}
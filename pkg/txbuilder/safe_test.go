@@ -0,0 +1,67 @@
+package txbuilder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+func TestSafeTxHash(t *testing.T) {
+	safe := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	tx := &SafeTx{
+		To:             common.HexToAddress("0x2000000000000000000000000000000000000002"),
+		Value:          big.NewInt(1000),
+		Data:           []byte{0xde, 0xad, 0xbe, 0xef},
+		Operation:      SafeOperationCall,
+		SafeTxGas:      big.NewInt(50000),
+		BaseGas:        big.NewInt(21000),
+		GasPrice:       big.NewInt(7),
+		RefundReceiver: common.HexToAddress("0x4000000000000000000000000000000000000004"),
+		Nonce:          big.NewInt(5),
+	}
+	chainID := big.NewInt(1)
+	// The type hashes are the DOMAIN_SEPARATOR_TYPEHASH and
+	// SAFE_TX_TYPEHASH constants of each GnosisSafe.sol release.
+	tests := []struct {
+		version    string
+		domainType string
+		safeTxType string
+	}{
+		{"1.0.0", "0x035aff83d86937d35b32e04f0ddc6ff469290eef2f1b692d8a815c89404d4749", "0x14d461bc7412367e924637b363c7bf29b8f47e2f84869f4426e5633d8af47b20"},
+		{"1.1.1", "0x035aff83d86937d35b32e04f0ddc6ff469290eef2f1b692d8a815c89404d4749", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+		{"1.3.0", "0x47e79534a245952e8b16893a336b85a3d9ea9fa8c573f3d803afb92a79469218", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			typed := tx.TypedData(safe, chainID, tt.version)
+			if got := common.BytesToHash(typed.TypeHash("SafeTx")).Hex(); got != tt.safeTxType {
+				t.Fatalf("SafeTx type hash = %s, want %s", got, tt.safeTxType)
+			}
+			if got := common.BytesToHash(typed.TypeHash("EIP712Domain")).Hex(); got != tt.domainType {
+				t.Fatalf("EIP712Domain type hash = %s, want %s", got, tt.domainType)
+			}
+
+			// Recompute the hash as the contract's getTransactionHash does.
+			domain := abiEncode(t, []string{"bytes32", "address"}, common.HexToHash(tt.domainType), safe)
+			if tt.version == "1.3.0" {
+				domain = abiEncode(t, []string{"bytes32", "uint256", "address"}, common.HexToHash(tt.domainType), chainID, safe)
+			}
+			message := abiEncode(t,
+				[]string{"bytes32", "address", "uint256", "bytes32", "uint8", "uint256", "uint256", "uint256", "address", "address", "uint256"},
+				common.HexToHash(tt.safeTxType), tx.To, tx.Value, crypto.Keccak256Hash(tx.Data), tx.Operation,
+				tx.SafeTxGas, tx.BaseGas, tx.GasPrice, tx.GasToken, tx.RefundReceiver, tx.Nonce)
+			want := crypto.Keccak256Hash([]byte{0x19, 0x01}, crypto.Keccak256(domain), crypto.Keccak256(message))
+
+			hash, _, err := apitypes.TypedDataAndHash(*typed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := common.BytesToHash(hash); got != want {
+				t.Fatalf("safeTxHash = %s, want %s", got.Hex(), want.Hex())
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package txbuilder
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// authorizationGas is the intrinsic gas a set-code transaction pays for
+// its authorizations.
+func authorizationGas(auths []types.SetCodeAuthorization) uint64 {
+	return uint64(len(auths)) * params.CallNewAccountGas
+}
@@ -0,0 +1,185 @@
+package txbuilder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Transaction types accepted by -tx-type.
+const (
+	TxTypeLegacy     = "legacy"
+	TxTypeAccessList = "access-list"
+	TxTypeDynamic    = "dynamic"
+	TxTypeBlob       = "blob"
+	TxTypeSetCode    = "set-code"
+)
+
+// TransferGas is the gas limit used for plain value transfers.
+const TransferGas = 21000
+
+// FeeParams holds the fee-related flag values for building a transaction,
+// and the payloads specific to some types: the EIP-2930 access list, which
+// every type but legacy carries, the EIP-4844 blob sidecar and the EIP-7702
+// authorization list.
+type FeeParams struct {
+	TxType         string
+	GasPrice       *big.Int
+	MaxFee         *big.Int
+	MaxPriorityFee *big.Int
+	MaxBlobFee     *big.Int
+	AccessList     types.AccessList
+	Blobs          *types.BlobTxSidecar
+	AuthList       []types.SetCodeAuthorization
+}
+
+func ParseWei(name, value string) (*big.Int, error) {
+	wei, ok := new(big.Int).SetString(value, 10)
+	if !ok || wei.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return wei, nil
+}
+
+func ParseCalldata(value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+	return data, nil
+}
+
+func Build(nonce uint64, to common.Address, amount *big.Int, data []byte, gasLimit uint64, chainID *big.Int, fees FeeParams) (*types.Transaction, error) {
+	if gasLimit == 0 {
+		if len(data) > 0 {
+			return nil, errors.New("gas-limit is required when data is set")
+		}
+		gasLimit = TransferGas + accessListGas(fees.AccessList) + authorizationGas(fees.AuthList)
+	}
+	if len(fees.AuthList) > 0 && fees.TxType != TxTypeSetCode {
+		return nil, errors.New("authorization lists require set-code transactions")
+	}
+	switch fees.TxType {
+	case TxTypeLegacy:
+		if len(fees.AccessList) > 0 {
+			return nil, errors.New("access lists require access-list or dynamic transactions")
+		}
+		return types.NewTransaction(nonce, to, amount, gasLimit, fees.GasPrice, data), nil
+	case TxTypeAccessList:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			GasPrice:   fees.GasPrice,
+			Gas:        gasLimit,
+			To:         &to,
+			Value:      amount,
+			Data:       data,
+			AccessList: fees.AccessList,
+		}), nil
+	case TxTypeDynamic:
+		if fees.MaxFee == nil || fees.MaxPriorityFee == nil {
+			return nil, errors.New("max-fee and max-priority-fee are required for dynamic fee transactions")
+		}
+		if fees.MaxPriorityFee.Cmp(fees.MaxFee) > 0 {
+			return nil, errors.New("max-priority-fee must not exceed max-fee")
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			GasTipCap:  fees.MaxPriorityFee,
+			GasFeeCap:  fees.MaxFee,
+			Gas:        gasLimit,
+			To:         &to,
+			Value:      amount,
+			Data:       data,
+			AccessList: fees.AccessList,
+		}), nil
+	case TxTypeBlob:
+		if fees.MaxFee == nil || fees.MaxPriorityFee == nil || fees.MaxBlobFee == nil {
+			return nil, errors.New("max-fee, max-priority-fee and max-blob-fee are required for blob transactions")
+		}
+		if fees.MaxPriorityFee.Cmp(fees.MaxFee) > 0 {
+			return nil, errors.New("max-priority-fee must not exceed max-fee")
+		}
+		if fees.Blobs == nil {
+			return nil, errors.New("blob transactions need at least one blob")
+		}
+		return types.NewTx(&types.BlobTx{
+			ChainID:    uint256.MustFromBig(chainID),
+			Nonce:      nonce,
+			GasTipCap:  uint256.MustFromBig(fees.MaxPriorityFee),
+			GasFeeCap:  uint256.MustFromBig(fees.MaxFee),
+			Gas:        gasLimit,
+			To:         to,
+			Value:      uint256.MustFromBig(amount),
+			Data:       data,
+			AccessList: fees.AccessList,
+			BlobFeeCap: uint256.MustFromBig(fees.MaxBlobFee),
+			BlobHashes: fees.Blobs.BlobHashes(),
+			Sidecar:    fees.Blobs,
+		}), nil
+	case TxTypeSetCode:
+		if fees.MaxFee == nil || fees.MaxPriorityFee == nil {
+			return nil, errors.New("max-fee and max-priority-fee are required for set-code transactions")
+		}
+		if fees.MaxPriorityFee.Cmp(fees.MaxFee) > 0 {
+			return nil, errors.New("max-priority-fee must not exceed max-fee")
+		}
+		if len(fees.AuthList) == 0 {
+			return nil, errors.New("set-code transactions need at least one authorization")
+		}
+		return types.NewTx(&types.SetCodeTx{
+			ChainID:    uint256.MustFromBig(chainID),
+			Nonce:      nonce,
+			GasTipCap:  uint256.MustFromBig(fees.MaxPriorityFee),
+			GasFeeCap:  uint256.MustFromBig(fees.MaxFee),
+			Gas:        gasLimit,
+			To:         to,
+			Value:      uint256.MustFromBig(amount),
+			Data:       data,
+			AccessList: fees.AccessList,
+			AuthList:   fees.AuthList,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown tx type %q (want %s, %s, %s, %s or %s)", fees.TxType, TxTypeLegacy, TxTypeAccessList, TxTypeDynamic, TxTypeBlob, TxTypeSetCode)
+	}
+}
+
+// accessListGas is the intrinsic gas an access list adds to a transaction.
+func accessListGas(list types.AccessList) uint64 {
+	return uint64(len(list))*params.TxAccessListAddressGas + uint64(list.StorageKeys())*params.TxAccessListStorageKeyGas
+}
+
+// TxSender recovers the sender of a signed transaction, using the signer
+// matching the transaction's own replay protection.
+func TxSender(tx *types.Transaction) (common.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.LatestSignerForChainID(tx.ChainId())
+	}
+	return types.Sender(signer, tx)
+}
+
+// DecodeRawTx parses a hex-encoded, signed transaction in any typed or legacy
+// envelope.
+func DecodeRawTx(rawHex string) (*types.Transaction, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(rawHex), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw tx hex: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid raw tx: %v", err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,90 @@
+package txbuilder
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// etherUnits maps the accepted amount suffixes to their decimals.
+var etherUnits = []struct {
+	suffix   string
+	decimals int
+}{
+	{"ether", 18},
+	{"eth", 18},
+	{"gwei", 9},
+	{"wei", 0},
+}
+
+// ParseEther parses an amount of wei, given either as an integer number of
+// wei or as a decimal with a unit, such as 1.5eth or 2500gwei. The
+// conversion is exact; amounts finer than 1 wei are rejected.
+func ParseEther(name, value string) (*big.Int, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	for _, u := range etherUnits {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			wei, err := ParseDecimal(strings.TrimSpace(num), u.decimals)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %q: %v", name, value, err)
+			}
+			return wei, nil
+		}
+	}
+	if strings.Contains(s, ".") {
+		return nil, fmt.Errorf("invalid %s: %q: decimals need a unit such as eth or gwei", name, value)
+	}
+	return ParseWei(name, value)
+}
+
+// HasEtherUnit reports whether value carries an amount unit.
+func HasEtherUnit(value string) bool {
+	s := strings.ToLower(strings.TrimSpace(value))
+	for _, u := range etherUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDecimal converts a non-negative decimal string to an integer scaled
+// by 10^decimals.
+func ParseDecimal(s string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("missing number")
+	}
+	if strings.Trim(whole+frac, "0123456789") != "" {
+		return nil, fmt.Errorf("not a decimal number")
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("more than %d decimal places", decimals)
+	}
+	n, _ := new(big.Int).SetString("0"+whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	return n, nil
+}
+
+// FormatDecimal formats n scaled down by 10^decimals, without trailing
+// zeros.
+func FormatDecimal(n *big.Int, decimals int) string {
+	s := new(big.Int).Abs(n).String()
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-decimals], strings.TrimRight(s[len(s)-decimals:], "0")
+	if n.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// FormatWei formats an amount of wei as both wei and ether, as in
+// "1500000000000000000 wei (1.5 ETH)".
+func FormatWei(wei *big.Int) string {
+	return fmt.Sprintf("%s wei (%s ETH)", wei, FormatDecimal(wei, 18))
+}
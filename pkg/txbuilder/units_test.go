@@ -0,0 +1,80 @@
+package txbuilder
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseEther(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1500000000000000000", "1500000000000000000"},
+		{"1.5eth", "1500000000000000000"},
+		{"1.5 ETH", "1500000000000000000"},
+		{"2ether", "2000000000000000000"},
+		{"2500gwei", "2500000000000"},
+		{"0.000000001gwei", "1"},
+		{"1.000000000000000001eth", "1000000000000000001"},
+		{".5eth", "500000000000000000"},
+		{"7wei", "7"},
+		{"1.50eth", "1500000000000000000"},
+		{"123456789012345678901234567890eth", "123456789012345678901234567890000000000000000000"},
+		// Errors.
+		{"1.5", ""},
+		{"1.0000000000000000001eth", ""},
+		{"0.5wei", ""},
+		{"0.0000000001gwei", ""},
+		{"1.5btc", ""},
+		{"-1eth", ""},
+		{"-1", ""},
+		{"eth", ""},
+		{"1e18", ""},
+		{"1.2.3eth", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseEther("amount", tt.in)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("ParseEther(%q) = %s, want an error", tt.in, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("ParseEther(%q): %v", tt.in, err)
+		case tt.want != "" && got.String() != tt.want:
+			t.Errorf("ParseEther(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		n        string
+		decimals int
+		want     string
+	}{
+		{"0", 18, "0"},
+		{"1", 18, "0.000000000000000001"},
+		{"1500000000000000000", 18, "1.5"},
+		{"1000000000000000000", 18, "1"},
+		{"-2500000000", 9, "-2.5"},
+		{"123", 0, "123"},
+	}
+	for _, tt := range tests {
+		n, _ := new(big.Int).SetString(tt.n, 10)
+		got := FormatDecimal(n, tt.decimals)
+		if got != tt.want {
+			t.Errorf("FormatDecimal(%s, %d) = %s, want %s", tt.n, tt.decimals, got, tt.want)
+		}
+		// Formatting and parsing round-trip exactly.
+		if n.Sign() >= 0 {
+			back, err := ParseDecimal(got, tt.decimals)
+			if err != nil || back.Cmp(n) != 0 {
+				t.Errorf("ParseDecimal(%s, %d) = %v, %v; want %s", got, tt.decimals, back, err, tt.n)
+			}
+		}
+	}
+	if got := FormatWei(big.NewInt(1500000000000000000)); got != "1500000000000000000 wei (1.5 ETH)" {
+		t.Errorf("FormatWei = %s", got)
+	}
+}
@@ -0,0 +1,273 @@
+package txbuilder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ERC-4337 EntryPoint versions.
+const (
+	entryPointV06 = "0.6"
+	entryPointV07 = "0.7"
+)
+
+// KnownEntryPoints maps the canonical EntryPoint deployments to their
+// version.
+var KnownEntryPoints = map[common.Address]string{
+	common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"): entryPointV06,
+	common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032"): entryPointV07,
+}
+
+var (
+	// execute(address,uint256,bytes)
+	selectorExecute = []byte{0xb6, 0x1d, 0x27, 0xf6}
+	// executeBatch(address[],bytes[]) of v0.6 accounts
+	selectorExecuteBatch = []byte{0x18, 0xdf, 0xb3, 0xc7}
+	// executeBatch(address[],uint256[],bytes[]) of v0.7 accounts
+	selectorExecuteBatchValues = []byte{0x47, 0xe1, 0xda, 0x2a}
+)
+
+// UserOperation is a UserOperation in the bundler JSON-RPC format. v0.6
+// operations use InitCode and PaymasterAndData; v0.7 ones the unpacked
+// Factory and Paymaster fields instead.
+type UserOperation struct {
+	Sender               common.Address  `json:"sender"`
+	Nonce                *hexutil.Big    `json:"nonce"`
+	InitCode             hexutil.Bytes   `json:"initCode,omitempty"`
+	Factory              *common.Address `json:"factory,omitempty"`
+	FactoryData          hexutil.Bytes   `json:"factoryData,omitempty"`
+	CallData             hexutil.Bytes   `json:"callData"`
+	CallGasLimit         *hexutil.Big    `json:"callGasLimit"`
+	VerificationGasLimit *hexutil.Big    `json:"verificationGasLimit"`
+	PreVerificationGas   *hexutil.Big    `json:"preVerificationGas"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     hexutil.Bytes   `json:"paymasterAndData,omitempty"`
+
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData,omitempty"`
+
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// Validate checks that op has the fields of the given EntryPoint version
+// and no others.
+func (op *UserOperation) Validate(version string) error {
+	required := map[string]*hexutil.Big{
+		"nonce":                op.Nonce,
+		"callGasLimit":         op.CallGasLimit,
+		"verificationGasLimit": op.VerificationGasLimit,
+		"preVerificationGas":   op.PreVerificationGas,
+		"maxFeePerGas":         op.MaxFeePerGas,
+		"maxPriorityFeePerGas": op.MaxPriorityFeePerGas,
+	}
+	for name, v := range required {
+		if v == nil {
+			return fmt.Errorf("user operation has no %s", name)
+		}
+		if v.ToInt().Sign() < 0 {
+			return fmt.Errorf("user operation %s is negative", name)
+		}
+	}
+	unpacked := op.Factory != nil || len(op.FactoryData) > 0 || op.Paymaster != nil ||
+		op.PaymasterVerificationGasLimit != nil || op.PaymasterPostOpGasLimit != nil || len(op.PaymasterData) > 0
+	switch version {
+	case entryPointV06:
+		if unpacked {
+			return errors.New("factory and paymaster fields require EntryPoint v0.7; use initCode and paymasterAndData")
+		}
+	case entryPointV07:
+		if len(op.InitCode) > 0 || len(op.PaymasterAndData) > 0 {
+			return errors.New("initCode and paymasterAndData are v0.6 fields; use factory and paymaster")
+		}
+		if op.Factory == nil && len(op.FactoryData) > 0 {
+			return errors.New("factoryData requires factory")
+		}
+		if op.Paymaster == nil && (op.PaymasterVerificationGasLimit != nil || op.PaymasterPostOpGasLimit != nil || len(op.PaymasterData) > 0) {
+			return errors.New("paymaster fields require paymaster")
+		}
+		for _, v := range []*hexutil.Big{op.CallGasLimit, op.VerificationGasLimit, op.MaxFeePerGas, op.MaxPriorityFeePerGas, op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit} {
+			if v != nil && v.ToInt().BitLen() > 128 {
+				return errors.New("v0.7 gas limits and fees must fit in 128 bits")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown EntryPoint version %q (want %s or %s)", version, entryPointV06, entryPointV07)
+	}
+	return nil
+}
+
+// packed returns the v0.7 initCode and paymasterAndData of op, which are
+// the fields as given for v0.6.
+func (op *UserOperation) packed() (initCode, paymasterAndData []byte) {
+	initCode, paymasterAndData = op.InitCode, op.PaymasterAndData
+	if op.Factory != nil {
+		initCode = append(op.Factory.Bytes(), op.FactoryData...)
+	}
+	if op.Paymaster != nil {
+		paymasterAndData = append(op.Paymaster.Bytes(), uint128(op.PaymasterVerificationGasLimit)...)
+		paymasterAndData = append(paymasterAndData, uint128(op.PaymasterPostOpGasLimit)...)
+		paymasterAndData = append(paymasterAndData, op.PaymasterData...)
+	}
+	return initCode, paymasterAndData
+}
+
+// uint128 is v left-padded to 16 bytes; nil is zero.
+func uint128(v *hexutil.Big) []byte {
+	if v == nil {
+		return make([]byte, 16)
+	}
+	return common.LeftPadBytes(v.ToInt().Bytes(), 16)
+}
+
+// Hash returns the userOpHash that EntryPoint getUserOpHash computes for
+// op on chainID.
+func (op *UserOperation) Hash(version string, entryPoint common.Address, chainID *big.Int) common.Hash {
+	initCode, paymasterAndData := op.packed()
+	var words [][]byte
+	words = append(words,
+		addressWord(op.Sender),
+		Word(op.Nonce.ToInt()),
+		crypto.Keccak256(initCode),
+		crypto.Keccak256(op.CallData),
+	)
+	if version == entryPointV06 {
+		words = append(words,
+			Word(op.CallGasLimit.ToInt()),
+			Word(op.VerificationGasLimit.ToInt()),
+			Word(op.PreVerificationGas.ToInt()),
+			Word(op.MaxFeePerGas.ToInt()),
+			Word(op.MaxPriorityFeePerGas.ToInt()),
+		)
+	} else {
+		// accountGasLimits and gasFees pack two uint128s into a bytes32.
+		words = append(words,
+			append(uint128(op.VerificationGasLimit), uint128(op.CallGasLimit)...),
+			Word(op.PreVerificationGas.ToInt()),
+			append(uint128(op.MaxPriorityFeePerGas), uint128(op.MaxFeePerGas)...),
+		)
+	}
+	words = append(words, crypto.Keccak256(paymasterAndData))
+	return crypto.Keccak256Hash(crypto.Keccak256(words...), addressWord(entryPoint), Word(chainID))
+}
+
+// RPCParam is op in the form eth_sendUserOperation expects for version:
+// v0.6 bundlers want initCode and paymasterAndData even when empty.
+func (op *UserOperation) RPCParam(version string) any {
+	if version == entryPointV07 {
+		return op
+	}
+	return struct {
+		Sender               common.Address `json:"sender"`
+		Nonce                *hexutil.Big   `json:"nonce"`
+		InitCode             hexutil.Bytes  `json:"initCode"`
+		CallData             hexutil.Bytes  `json:"callData"`
+		CallGasLimit         *hexutil.Big   `json:"callGasLimit"`
+		VerificationGasLimit *hexutil.Big   `json:"verificationGasLimit"`
+		PreVerificationGas   *hexutil.Big   `json:"preVerificationGas"`
+		MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+		PaymasterAndData     hexutil.Bytes  `json:"paymasterAndData"`
+		Signature            hexutil.Bytes  `json:"signature"`
+	}{op.Sender, op.Nonce, append(hexutil.Bytes{}, op.InitCode...), op.CallData, op.CallGasLimit, op.VerificationGasLimit,
+		op.PreVerificationGas, op.MaxFeePerGas, op.MaxPriorityFeePerGas, append(hexutil.Bytes{}, op.PaymasterAndData...), op.Signature}
+}
+
+// TotalGas is the most gas op can be charged for.
+func (op *UserOperation) TotalGas() *big.Int {
+	total := new(big.Int)
+	for _, v := range []*hexutil.Big{op.CallGasLimit, op.VerificationGasLimit, op.PreVerificationGas, op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit} {
+		if v != nil {
+			total.Add(total, v.ToInt())
+		}
+	}
+	return total
+}
+
+// UserOpCall is a call a smart account makes on behalf of a UserOperation.
+type UserOpCall struct {
+	Target common.Address
+	Value  *big.Int
+}
+
+// DecodeUserOpCalls decodes the calls of an execute or executeBatch
+// callData, the account interface of the reference SimpleAccount and most
+// smart accounts. It reports false for any other callData.
+func DecodeUserOpCalls(data []byte) ([]UserOpCall, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	args := data[4:]
+	switch {
+	case bytes.Equal(data[:4], selectorExecute):
+		if len(args) < 3*32 || !bytes.Equal(args[:12], make([]byte, 12)) {
+			return nil, false
+		}
+		return []UserOpCall{{common.BytesToAddress(args[12:32]), new(big.Int).SetBytes(args[32:64])}}, true
+	case bytes.Equal(data[:4], selectorExecuteBatch), bytes.Equal(data[:4], selectorExecuteBatchValues):
+		targets, ok := abiArray(args, 0)
+		if !ok {
+			return nil, false
+		}
+		calls := make([]UserOpCall, len(targets))
+		for i, w := range targets {
+			if !bytes.Equal(w[:12], make([]byte, 12)) {
+				return nil, false
+			}
+			calls[i] = UserOpCall{common.BytesToAddress(w[12:]), new(big.Int)}
+		}
+		if bytes.Equal(data[:4], selectorExecuteBatchValues) {
+			values, ok := abiArray(args, 1)
+			if !ok || len(values) != len(calls) {
+				return nil, false
+			}
+			for i, w := range values {
+				calls[i].Value.SetBytes(w)
+			}
+		}
+		return calls, true
+	}
+	return nil, false
+}
+
+// abiArray returns the words of the static-element array that is argument
+// n of ABI-encoded args.
+func abiArray(args []byte, n int) ([][]byte, bool) {
+	offset, ok := abiUint(args, uint64(n)*32)
+	if !ok {
+		return nil, false
+	}
+	length, ok := abiUint(args, offset)
+	if !ok || length > uint64(len(args))/32 {
+		return nil, false
+	}
+	start := offset + 32
+	if start+length*32 > uint64(len(args)) {
+		return nil, false
+	}
+	words := make([][]byte, length)
+	for i := range words {
+		words[i] = args[start+uint64(i)*32 : start+uint64(i+1)*32]
+	}
+	return words, true
+}
+
+// abiUint reads the word at pos of args as an offset or length.
+func abiUint(args []byte, pos uint64) (uint64, bool) {
+	if pos+32 > uint64(len(args)) {
+		return 0, false
+	}
+	v := new(big.Int).SetBytes(args[pos : pos+32])
+	if !v.IsUint64() || v.Uint64() > uint64(len(args)) {
+		return 0, false
+	}
+	return v.Uint64(), true
+}
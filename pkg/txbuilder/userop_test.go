@@ -0,0 +1,139 @@
+package txbuilder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	entryPointV06Address = common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	entryPointV07Address = common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+)
+
+// abiEncode is Solidity's abi.encode of static values of the given types.
+func abiEncode(t *testing.T, types []string, values ...any) []byte {
+	t.Helper()
+	var args abi.Arguments
+	for _, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, abi.Argument{Type: typ})
+	}
+	data, err := args.Pack(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// entryPointHash computes getUserOpHash the way the EntryPoint contracts
+// do: keccak256(abi.encode(keccak256(pack(op)), entryPoint, chainid)),
+// with pack following UserOperationLib of v0.6 or v0.7.
+func entryPointHash(t *testing.T, version string, op *UserOperation, entryPoint common.Address, chainID *big.Int) common.Hash {
+	t.Helper()
+	n := func(v *hexutil.Big) *big.Int {
+		if v == nil {
+			return new(big.Int)
+		}
+		return v.ToInt()
+	}
+	var packed []byte
+	if version == entryPointV06 {
+		packed = abiEncode(t,
+			[]string{"address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes32"},
+			op.Sender, n(op.Nonce), crypto.Keccak256Hash(op.InitCode), crypto.Keccak256Hash(op.CallData),
+			n(op.CallGasLimit), n(op.VerificationGasLimit), n(op.PreVerificationGas),
+			n(op.MaxFeePerGas), n(op.MaxPriorityFeePerGas), crypto.Keccak256Hash(op.PaymasterAndData))
+	} else {
+		pack128 := func(hi, lo *hexutil.Big) common.Hash {
+			return common.BigToHash(new(big.Int).Or(new(big.Int).Lsh(n(hi), 128), n(lo)))
+		}
+		var initCode, paymasterAndData []byte
+		if op.Factory != nil {
+			initCode = append(op.Factory.Bytes(), op.FactoryData...)
+		}
+		if op.Paymaster != nil {
+			gas := pack128(op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit)
+			paymasterAndData = append(append(op.Paymaster.Bytes(), gas[:]...), op.PaymasterData...)
+		}
+		packed = abiEncode(t,
+			[]string{"address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "bytes32", "bytes32"},
+			op.Sender, n(op.Nonce), crypto.Keccak256Hash(initCode), crypto.Keccak256Hash(op.CallData),
+			pack128(op.VerificationGasLimit, op.CallGasLimit), n(op.PreVerificationGas),
+			pack128(op.MaxPriorityFeePerGas, op.MaxFeePerGas), crypto.Keccak256Hash(paymasterAndData))
+	}
+	return crypto.Keccak256Hash(abiEncode(t, []string{"bytes32", "address", "uint256"},
+		crypto.Keccak256Hash(packed), entryPoint, chainID))
+}
+
+func TestUserOperationHash(t *testing.T) {
+	b := func(v int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(v)) }
+	factory := common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454")
+	paymaster := common.HexToAddress("0x00000000000000fB866DaAA79352cC568a005D96")
+	base := func() *UserOperation {
+		return &UserOperation{
+			Sender:               common.HexToAddress("0x1234567890123456789012345678901234567890"),
+			Nonce:                b(0),
+			CallData:             EncodeERC20Transfer(common.HexToAddress("0x00000000000000000000000000000000000000aa"), big.NewInt(1)),
+			CallGasLimit:         b(6942069),
+			VerificationGasLimit: b(420000),
+			PreVerificationGas:   b(6942069),
+			MaxFeePerGas:         b(69420),
+			MaxPriorityFeePerGas: b(69),
+		}
+	}
+	withV06Extras := base()
+	withV06Extras.Nonce = (*hexutil.Big)(new(big.Int).Lsh(big.NewInt(5), 64)) // key 5, sequence 0
+	withV06Extras.InitCode = append(factory.Bytes(), 0x5f, 0xbf, 0xb9, 0xcf)
+	withV06Extras.PaymasterAndData = append(paymaster.Bytes(), 0x01, 0x02)
+	withV07Extras := base()
+	withV07Extras.Factory = &factory
+	withV07Extras.FactoryData = hexutil.Bytes{0x5f, 0xbf, 0xb9, 0xcf}
+	withV07Extras.Paymaster = &paymaster
+	withV07Extras.PaymasterVerificationGasLimit = b(100000)
+	withV07Extras.PaymasterPostOpGasLimit = b(50000)
+	withV07Extras.PaymasterData = hexutil.Bytes{0x01, 0x02}
+
+	tests := []struct {
+		name       string
+		version    string
+		entryPoint common.Address
+		op         *UserOperation
+	}{
+		{"v0.6", entryPointV06, entryPointV06Address, base()},
+		{"v0.6 with initCode and paymaster", entryPointV06, entryPointV06Address, withV06Extras},
+		{"v0.7", entryPointV07, entryPointV07Address, base()},
+		{"v0.7 with factory and paymaster", entryPointV07, entryPointV07Address, withV07Extras},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op.Validate(tt.version); err != nil {
+				t.Fatalf("invalid test operation: %v", err)
+			}
+			for _, chainID := range []*big.Int{big.NewInt(1), big.NewInt(11155111)} {
+				want := entryPointHash(t, tt.version, tt.op, tt.entryPoint, chainID)
+				if got := tt.op.Hash(tt.version, tt.entryPoint, chainID); got != want {
+					t.Errorf("chain %s: Hash = %s, want %s", chainID, got.Hex(), want.Hex())
+				}
+			}
+		})
+	}
+	// The signature is not part of the hash, but every other field is.
+	op := base()
+	h := op.Hash(entryPointV06, entryPointV06Address, big.NewInt(1))
+	op.Signature = hexutil.Bytes{0x01}
+	if op.Hash(entryPointV06, entryPointV06Address, big.NewInt(1)) != h {
+		t.Error("Hash depends on the signature")
+	}
+	op.MaxFeePerGas = b(69421)
+	if op.Hash(entryPointV06, entryPointV06Address, big.NewInt(1)) == h {
+		t.Error("Hash ignores maxFeePerGas")
+	}
+}
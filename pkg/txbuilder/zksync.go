@@ -0,0 +1,189 @@
+package txbuilder
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ZkSyncTxType is the EIP-2718 type of zkSync Era EIP-712 transactions.
+const ZkSyncTxType = 0x71
+
+// ZkSyncTx is a zkSync Era EIP-712 transaction. The fields it shares with
+// Ethereum transactions are checked against the policy as the dynamic fee
+// transaction it is built from.
+type ZkSyncTx struct {
+	chainID        *big.Int
+	Nonce          uint64
+	from           common.Address
+	To             common.Address
+	gas            uint64
+	gasTipCap      *big.Int
+	gasFeeCap      *big.Int
+	value          *big.Int
+	data           []byte
+	GasPerPubdata  *big.Int
+	FactoryDeps    [][]byte
+	Paymaster      common.Address
+	PaymasterInput []byte
+}
+
+// NewZkSyncTx takes the common fields of tx, a dynamic fee transaction.
+func NewZkSyncTx(tx *types.Transaction, from common.Address) (*ZkSyncTx, error) {
+	if tx.Type() != types.DynamicFeeTxType {
+		return nil, errors.New("zkSync transactions require a dynamic fee transaction")
+	}
+	if tx.To() == nil {
+		return nil, errors.New("zkSync transactions deploy contracts through the ContractDeployer and need a recipient")
+	}
+	return &ZkSyncTx{
+		chainID:   tx.ChainId(),
+		Nonce:     tx.Nonce(),
+		from:      from,
+		To:        *tx.To(),
+		gas:       tx.Gas(),
+		gasTipCap: tx.GasTipCap(),
+		gasFeeCap: tx.GasFeeCap(),
+		value:     tx.Value(),
+		data:      tx.Data(),
+	}, nil
+}
+
+// zksyncAddress returns addr as the uint256 zkSync signs addresses as.
+func zksyncAddress(addr common.Address) string {
+	return new(big.Int).SetBytes(addr.Bytes()).String()
+}
+
+// TypedData returns the EIP-712 typed data signed for tx.
+func (tx *ZkSyncTx) TypedData() (*apitypes.TypedData, error) {
+	deps := make([]interface{}, len(tx.FactoryDeps))
+	for i, code := range tx.FactoryDeps {
+		hash, err := hashBytecode(code)
+		if err != nil {
+			return nil, fmt.Errorf("factory dependency %d: %v", i, err)
+		}
+		deps[i] = hash.Hex()
+	}
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Transaction": {
+				{Name: "txType", Type: "uint256"},
+				{Name: "from", Type: "uint256"},
+				{Name: "to", Type: "uint256"},
+				{Name: "gasLimit", Type: "uint256"},
+				{Name: "gasPerPubdataByteLimit", Type: "uint256"},
+				{Name: "maxFeePerGas", Type: "uint256"},
+				{Name: "maxPriorityFeePerGas", Type: "uint256"},
+				{Name: "paymaster", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "factoryDeps", Type: "bytes32[]"},
+				{Name: "paymasterInput", Type: "bytes"},
+			},
+		},
+		PrimaryType: "Transaction",
+		Domain:      apitypes.TypedDataDomain{Name: "zkSync", Version: "2", ChainId: (*math.HexOrDecimal256)(tx.chainID)},
+		Message: apitypes.TypedDataMessage{
+			"txType":                 fmt.Sprint(ZkSyncTxType),
+			"from":                   zksyncAddress(tx.from),
+			"to":                     zksyncAddress(tx.To),
+			"gasLimit":               fmt.Sprint(tx.gas),
+			"gasPerPubdataByteLimit": tx.GasPerPubdata.String(),
+			"maxFeePerGas":           tx.gasFeeCap.String(),
+			"maxPriorityFeePerGas":   tx.gasTipCap.String(),
+			"paymaster":              zksyncAddress(tx.Paymaster),
+			"nonce":                  fmt.Sprint(tx.Nonce),
+			"value":                  tx.value.String(),
+			"data":                   hexutil.Encode(tx.data),
+			"factoryDeps":            deps,
+			"paymasterInput":         hexutil.Encode(tx.PaymasterInput),
+		},
+	}, nil
+}
+
+// hashBytecode returns the zkSync hash of contract bytecode: its SHA-256
+// with the first four bytes replaced by the version and the length in
+// 32-byte words.
+func hashBytecode(code []byte) (common.Hash, error) {
+	if len(code)%32 != 0 {
+		return common.Hash{}, errors.New("bytecode length must be a multiple of 32 bytes")
+	}
+	words := len(code) / 32
+	if words >= 1<<16 {
+		return common.Hash{}, errors.New("bytecode is too long")
+	}
+	if words%2 == 0 {
+		return common.Hash{}, errors.New("bytecode must have an odd number of 32-byte words")
+	}
+	hash := sha256.Sum256(code)
+	hash[0], hash[1] = 1, 0
+	binary.BigEndian.PutUint16(hash[2:4], uint16(words))
+	return hash, nil
+}
+
+// Encode returns the signed transaction envelope for tx and sig, a
+// [R || S || V] signature with V in {0, 1}.
+func (tx *ZkSyncTx) Encode(sig []byte) ([]byte, error) {
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	v := sig[crypto.RecoveryIDOffset]
+	paymaster := []interface{}{}
+	if tx.Paymaster != (common.Address{}) {
+		paymaster = []interface{}{tx.Paymaster, tx.PaymasterInput}
+	}
+	deps := tx.FactoryDeps
+	if deps == nil {
+		deps = [][]byte{}
+	}
+	payload, err := rlp.EncodeToBytes([]interface{}{
+		tx.Nonce,
+		tx.gasTipCap,
+		tx.gasFeeCap,
+		tx.gas,
+		tx.To,
+		tx.value,
+		tx.data,
+		uint64(v),
+		r,
+		s,
+		tx.chainID,
+		tx.from,
+		tx.GasPerPubdata,
+		deps,
+		zksyncSignature(sig),
+		paymaster,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{ZkSyncTxType}, payload...), nil
+}
+
+// zksyncSignature returns sig with V in {27, 28}, as zkSync carries it in
+// the transaction.
+func zksyncSignature(sig []byte) []byte {
+	out := append([]byte{}, sig...)
+	out[crypto.RecoveryIDOffset] += 27
+	return out
+}
+
+// ZkSyncTxHash returns the hash zkSync Era identifies a signed transaction
+// by: the hash of its EIP-712 signing hash and the hash of the signature.
+func ZkSyncTxHash(signingHash, sig []byte) common.Hash {
+	return crypto.Keccak256Hash(signingHash, crypto.Keccak256(zksyncSignature(sig)))
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"sigs.k8s.io/yaml"
+)
+
+func runPolicy(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secure-signer policy <lint|test> [flags]")
+	}
+	switch args[0] {
+	case "lint":
+		return runPolicyLint(args[1:])
+	case "test":
+		return runPolicyTest(args[1:])
+	}
+	return fmt.Errorf("unknown policy command %q", args[0])
+}
+
+// lintReport collects the problems found in a policy file. Errors make the
+// lint fail; warnings flag settings that are legal but probably unintended.
+type lintReport struct {
+	errors   []string
+	warnings []string
+}
+
+func (r *lintReport) errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *lintReport) warnf(format string, args ...any) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// address checks that s is an address with a valid EIP-55 checksum.
+func (r *lintReport) address(field, s string) {
+	if !common.IsHexAddress(s) {
+		r.errorf("%s: invalid address %q", field, s)
+		return
+	}
+	want := common.HexToAddress(s).Hex()
+	body := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	switch {
+	case body == want[2:]:
+	case body == strings.ToLower(body) || body == strings.ToUpper(body):
+		r.warnf("%s: %s is not checksummed (want %s)", field, s, want)
+	default:
+		r.errorf("%s: %s has an invalid checksum (want %s)", field, s, want)
+	}
+}
+
+func (r *lintReport) nonNegative(field string, v *big.Int) {
+	if v != nil && v.Sign() < 0 {
+		r.errorf("%s: must not be negative", field)
+	}
+}
+
+// lintPolicy validates a policy file beyond what loading it requires,
+// loading it as a signed policy if signed is set.
+func lintPolicy(data []byte, signed bool) *lintReport {
+	r := new(lintReport)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(new(policy.Policy)); err != nil {
+		r.errorf("schema: %v", err)
+	}
+	parse := policy.Parse
+	if signed {
+		parse = policy.ParseSigned
+	}
+	p, err := parse(data)
+	if err != nil {
+		r.errorf("%v", err)
+		return r
+	}
+	r.lint(p)
+	for addr, sp := range p.Senders {
+		r.address("senders", addr)
+		// Findings in a sender policy are reported under its address.
+		sub := new(lintReport)
+		sub.lint(sp)
+		for _, e := range sub.errors {
+			r.errorf("senders.%s.%s", addr, e)
+		}
+		for _, w := range sub.warnings {
+			r.warnf("senders.%s.%s", addr, w)
+		}
+	}
+	return r
+}
+
+// lint checks the rules of a single policy.
+func (r *lintReport) lint(p *policy.Policy) {
+	for addr := range p.Whitelist {
+		if policy.IsENSName(addr) {
+			if _, err := policy.NormalizeENSName(addr); err != nil {
+				r.errorf("whitelist: %v", err)
+			}
+			continue
+		}
+		r.address("whitelist", addr)
+	}
+	if p.ContactsSHA256 == "" && p.LoadedContactsSHA256() != "" {
+		r.warnf("contacts_sha256: not set, so the address book can change the whitelist without the policy; it has sha256 %s", p.LoadedContactsSHA256())
+	}
+	if p.Rego != nil && p.Rego.SHA256 == "" {
+		r.warnf("rego.sha256: not set, so the Rego modules can change without the policy; they have sha256 %s", p.Rego.LoadedSHA256())
+	}
+	if pp := p.Poisoning; pp != nil {
+		switch pp.Action {
+		case "", policy.PoisoningDeny, policy.PoisoningWarn:
+		case policy.PoisoningOff:
+			r.warnf("address_poisoning.action: off; lookalike recipients are not detected")
+		default:
+			r.errorf("address_poisoning.action: must be deny, warn or off")
+		}
+		if pp.PrefixChars < 0 || pp.PrefixChars > 20 || pp.SuffixChars < 0 || pp.SuffixChars > 20 {
+			r.errorf("address_poisoning: prefix_chars and suffix_chars must be between 1 and 20")
+		}
+	}
+	if ap := p.Allowances; ap != nil {
+		switch ap.Action {
+		case "", policy.AllowanceDeny:
+		case policy.AllowanceOff:
+			r.warnf("allowances.action: off; unlimited token approvals are not rejected")
+		default:
+			r.errorf("allowances.action: must be deny or off")
+		}
+		if ap.AllowUnlimited {
+			r.warnf("allowances.allow_unlimited: unlimited token approvals are allowed for every token")
+		}
+		if ap.MaxAmount != nil && ap.MaxAmount.Sign() < 0 {
+			r.errorf("allowances.max_amount: must not be negative")
+		}
+		for token, tp := range ap.Tokens {
+			r.address("allowances.tokens", token)
+			if tp.MaxAmount != nil && tp.MaxAmount.Sign() < 0 {
+				r.errorf("allowances.tokens.%s.max_amount: must not be negative", token)
+			}
+			for spender, sp := range tp.Spenders {
+				r.address("allowances.tokens."+token+".spenders", spender)
+				if sp.MaxAmount != nil && sp.MaxAmount.Sign() < 0 {
+					r.errorf("allowances.tokens.%s.spenders.%s.max_amount: must not be negative", token, spender)
+				}
+			}
+		}
+	}
+	if p.MaxAmountUSD < 0 {
+		r.errorf("max_amount_usd: must not be negative")
+	}
+	if ps := p.PriceSource; ps != nil {
+		if p.MaxAmountUSD == 0 {
+			r.warnf("price_source: no USD limit is set")
+		}
+		for symbol, addr := range ps.ChainlinkFeeds {
+			r.address("price_source.chainlink_feeds."+symbol, addr)
+		}
+		if len(ps.ChainlinkFeeds) > 0 && ps.RPC == "" {
+			r.errorf("price_source.chainlink_feeds: set price_source.rpc to read the feeds")
+		}
+		if ps.MaxAgeMinutes < 0 {
+			r.errorf("price_source.max_age_minutes: must not be negative")
+		}
+		if ps.FailOpen {
+			r.warnf("price_source.fail_open: USD limits are not applied while no price is available")
+		}
+	}
+	if zp := p.ZkSync; zp != nil {
+		for _, addr := range zp.Paymasters {
+			r.address("zksync.paymasters", addr)
+		}
+	}
+	for name, addr := range p.ENSPins {
+		if !policy.IsENSName(name) {
+			r.errorf("ens_pins: %q is not an ENS name", name)
+		}
+		r.address("ens_pins."+name, addr)
+	}
+	for _, addr := range p.Blacklist {
+		r.address("blacklist", addr)
+	}
+	if p.BlacklistFile != "" {
+		if _, err := policy.ReadAddressFile(p.BlacklistFile); err != nil {
+			r.errorf("blacklist_file: %v", err)
+		}
+	}
+	for addr, tp := range p.Tokens {
+		r.address("tokens", addr)
+		if tp.MaxAmount == nil {
+			r.warnf("tokens: %s has no max_amount; all its transfers are rejected", addr)
+		}
+		r.nonNegative("tokens."+addr+".max_amount", tp.MaxAmount)
+		if limit, ok := p.TokenDailyLimit(common.HexToAddress(addr)); ok && tp.MaxAmount != nil && limit.Cmp(tp.MaxAmount) < 0 {
+			r.warnf("tokens.%s.daily_limit: lower than max_per_tx", addr)
+		}
+	}
+	for addr := range p.NFTs {
+		r.address("nfts", addr)
+	}
+	for addr, cp := range p.Contracts {
+		r.address("contracts", addr)
+		if len(cp.Selectors) == 0 {
+			r.warnf("contracts: %s has no selectors; all calls to it are rejected", addr)
+		}
+		for _, sel := range cp.Selectors {
+			if _, err := policy.ParseSelector(sel); err != nil {
+				r.errorf("contracts: %s: %v", addr, err)
+			}
+		}
+		if len(cp.Arguments) > 0 && common.IsHexAddress(addr) {
+			if _, err := policy.LoadABI(p.ABIDir, common.HexToAddress(addr)); err != nil {
+				r.errorf("contracts.%s.arguments: no ABI registered: %v", addr, err)
+			}
+		}
+		for i, rule := range cp.Arguments {
+			if rule.Function == "" || rule.Argument == "" {
+				r.errorf("contracts.%s.arguments[%d]: function and argument are required", addr, i)
+			}
+			r.nonNegative(fmt.Sprintf("contracts.%s.arguments[%d].max", addr, i), rule.Max)
+		}
+	}
+	for name, list := range p.Lists {
+		for _, entry := range list {
+			if strings.HasPrefix(entry, "0x") && len(entry) == 42 {
+				r.address("lists."+name, entry)
+			}
+		}
+	}
+
+	if p.Rego == nil {
+		if p.MaxAmountWei == nil {
+			r.errorf("max_amount_wei: not set; every ether transfer is rejected")
+		}
+		if len(p.Whitelist) == 0 {
+			r.warnf("whitelist: empty; every transaction is rejected")
+		}
+	}
+	r.nonNegative("max_amount_wei", p.MaxAmountWei)
+	for addr, rp := range p.Whitelist {
+		r.nonNegative("whitelist."+addr+".max_amount_wei", rp.MaxAmountWei)
+		r.nonNegative("whitelist."+addr+".daily_limit_wei", rp.DailyLimitWei)
+	}
+	r.nonNegative("daily_limit_wei", p.DailyLimitWei)
+	r.nonNegative("weekly_limit_wei", p.WeeklyLimitWei)
+	if p.DailyLimitWei != nil && p.MaxAmountWei != nil && p.DailyLimitWei.Cmp(p.MaxAmountWei) < 0 {
+		r.warnf("daily_limit_wei: lower than max_amount_wei")
+	}
+	if p.DailyLimitWei != nil && p.WeeklyLimitWei != nil && p.WeeklyLimitWei.Cmp(p.DailyLimitWei) < 0 {
+		r.warnf("weekly_limit_wei: lower than daily_limit_wei")
+	}
+	if p.MaxTxPerHour > 0 && p.MaxTxPerDay > 0 && p.MaxTxPerDay < p.MaxTxPerHour {
+		r.warnf("max_tx_per_day: lower than max_tx_per_hour")
+	}
+	if len(p.AllowedChainIDs) == 0 {
+		r.warnf("allowed_chain_ids: not set; the policy applies on every chain")
+	}
+	for _, addr := range p.AllowedDelegates {
+		r.address("allowed_delegates", addr)
+	}
+	if p.AllowAnyChainAuthorizations {
+		r.warnf("allow_any_chain_authorizations: authorizations can be replayed on every chain")
+	}
+	if p.SigningWindow != nil {
+		if _, err := p.SigningWindow.Contains(time.Now()); err != nil {
+			r.errorf("signing_window: %v", err)
+		}
+	}
+	if ap := p.Approvals; ap != nil {
+		for _, addr := range ap.Approvers {
+			r.address("approvals.approvers", addr)
+		}
+		if ap.Quorum <= 0 || ap.Quorum > len(ap.Approvers) {
+			r.errorf("approvals: quorum %d is not between 1 and %d", ap.Quorum, len(ap.Approvers))
+		}
+	}
+	if ap := p.Unfreeze; ap != nil {
+		for _, addr := range ap.Approvers {
+			r.address("unfreeze.approvers", addr)
+		}
+		if ap.Quorum <= 0 || ap.Quorum > len(ap.Approvers) {
+			r.errorf("unfreeze: quorum %d is not between 1 and %d", ap.Quorum, len(ap.Approvers))
+		}
+	}
+	if td := p.TimeDelay; td != nil {
+		r.nonNegative("time_delay.min_value_wei", td.MinValueWei)
+		for addr, min := range td.Tokens {
+			r.address("time_delay.tokens", addr)
+			r.nonNegative("time_delay.tokens."+addr, min)
+		}
+		if td.MinValueWei == nil && len(td.Tokens) == 0 {
+			r.warnf("time_delay: no thresholds set; no transaction is held")
+		}
+		if td.DelayMinutes <= 0 {
+			r.errorf("time_delay.delay_minutes: must be positive")
+		}
+	}
+	if al := p.Alerts; al != nil {
+		r.nonNegative("alerts.min_value_wei", al.MinValueWei)
+		for addr, min := range al.Tokens {
+			r.address("alerts.tokens", addr)
+			r.nonNegative("alerts.tokens."+addr, min)
+		}
+		if al.MinValueWei == nil && len(al.Tokens) == 0 {
+			r.warnf("alerts: no thresholds set; nothing triggers an alert")
+		}
+		if al.AckTimeoutMinutes < 0 {
+			r.errorf("alerts.ack_timeout_minutes: must not be negative")
+		}
+	}
+	if h := p.PreSignHook; h != nil && strings.HasPrefix(h.URL, "http://") {
+		r.warnf("pre_sign_hook.url: approvals over plain http can be forged on the way; use https")
+	}
+
+	if up := p.UserOps; up != nil {
+		for _, addr := range up.EntryPoints {
+			r.address("user_operations.entry_points", addr)
+		}
+		for _, addr := range up.Senders {
+			r.address("user_operations.senders", addr)
+		}
+		for _, addr := range up.Targets {
+			r.address("user_operations.targets", addr)
+		}
+		if len(up.Senders) == 0 {
+			r.warnf("user_operations: no senders; every user operation is rejected")
+		}
+		r.nonNegative("user_operations.max_value_wei", up.MaxValueWei)
+		r.nonNegative("user_operations.max_total_gas", up.MaxTotalGas)
+		r.nonNegative("user_operations.max_fee_per_gas_wei", up.MaxFeePerGasWei)
+		r.nonNegative("user_operations.max_priority_fee_per_gas_wei", up.MaxPriorityFeePerGasWei)
+	}
+	for token, pp := range p.Permits {
+		r.address("permits", token)
+		for _, addr := range pp.Spenders {
+			r.address("permits."+token+".spenders", addr)
+		}
+		if pp.MaxValue == nil {
+			r.warnf("permits: %s has no max_value; all its permits are rejected", token)
+		}
+		r.nonNegative("permits."+token+".max_value", pp.MaxValue)
+		if pp.MaxDeadlineSeconds < 0 {
+			r.errorf("permits.%s.max_deadline_seconds: must not be negative", token)
+		}
+	}
+	if sp := p.Safe; sp != nil {
+		for _, addr := range sp.Safes {
+			r.address("safe.safes", addr)
+		}
+		if len(sp.Safes) == 0 {
+			r.warnf("safe: no safes; every safe transaction is rejected")
+		}
+		if sp.AllowDelegateCall {
+			r.warnf("safe.allow_delegate_call: delegate calls can change the safe's owners and modules")
+		}
+	}
+
+	fees := p.Fees
+	if fees.GasLimitMultiplier != 0 && fees.GasLimitMultiplier < 1 {
+		r.warnf("fees.gas_limit_multiplier: below 1; estimated transactions may run out of gas")
+	}
+	if fees.BaseFeeMultiplier != 0 && fees.BaseFeeMultiplier < 1 {
+		r.warnf("fees.base_fee_multiplier: below 1; transactions may not be included")
+	}
+	r.nonNegative("fees.max_fee_per_gas_wei", fees.MaxFeePerGasWei)
+	r.nonNegative("fees.max_priority_fee_wei", fees.MaxPriorityFeeWei)
+	r.nonNegative("fees.max_total_cost_wei", fees.MaxTotalCostWei)
+	if fees.MaxFeePerGasWei != nil && fees.MaxPriorityFeeWei != nil && fees.MaxPriorityFeeWei.Cmp(fees.MaxFeePerGasWei) > 0 {
+		r.warnf("fees.max_priority_fee_wei: higher than max_fee_per_gas_wei")
+	}
+}
+
+func runPolicyLint(args []string) error {
+	fs := flag.NewFlagSet("policy lint", flag.ExitOnError)
+	policyOpts := addPolicyFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	data, err := os.ReadFile(policyOpts.file)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %v", err)
+	}
+	if policyOpts.pubkey != "" {
+		if err := verifyPolicySignature(data, policyOpts.pubkey, policyOpts.sigFile()); err != nil {
+			return err
+		}
+	}
+	r := lintPolicy(data, policyOpts.pubkey != "")
+	output.print(struct {
+		Errors   []string `json:"errors"`
+		Warnings []string `json:"warnings"`
+	}{append([]string{}, r.errors...), append([]string{}, r.warnings...)}, func() {
+		for _, msg := range r.errors {
+			fmt.Println("error:", msg)
+		}
+		for _, msg := range r.warnings {
+			fmt.Println("warning:", msg)
+		}
+		if len(r.errors) == 0 {
+			fmt.Printf("%s: OK (%d warnings)\n", policyOpts.file, len(r.warnings))
+		}
+	})
+	if len(r.errors) > 0 {
+		return fmt.Errorf("%s: %d errors, %d warnings", policyOpts.file, len(r.errors), len(r.warnings))
+	}
+	return nil
+}
+
+// policyTestFile is a YAML file of example transactions and the outcome the
+// policy must reach for each.
+type policyTestFile struct {
+	Cases []policyTestCase `json:"cases"`
+}
+
+type policyTestCase struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Value is in wei, or in token base units with ERC20.
+	Value testAmount `json:"value"`
+	Data  string     `json:"data"`
+	// ERC20 makes the case a transfer() of Value on this token to To.
+	ERC20          string     `json:"erc20"`
+	ChainID        int64      `json:"chain_id"`
+	Gas            uint64     `json:"gas"`
+	GasPrice       testAmount `json:"gas_price"`
+	MaxFee         testAmount `json:"max_fee"`
+	MaxPriorityFee testAmount `json:"max_priority_fee"`
+	// Time is the RFC 3339 signing time; it defaults to now.
+	Time string `json:"time"`
+	// Expect is allow or deny. Reason, if set, must appear in the denial.
+	Expect string `json:"expect"`
+	Reason string `json:"reason"`
+}
+
+// testAmount is a decimal amount written as a YAML string or integer.
+type testAmount string
+
+func (a *testAmount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = testAmount(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*a = testAmount(n.String())
+	return nil
+}
+
+func (a testAmount) wei(name string, def *big.Int) (*big.Int, error) {
+	if a == "" {
+		return def, nil
+	}
+	return txbuilder.ParseWei(name, string(a))
+}
+
+// run evaluates the case against policy. It returns the denial reason, or ""
+// if the transaction is allowed.
+func (c *policyTestCase) run(pol *policy.Policy) (string, error) {
+	if !common.IsHexAddress(c.To) {
+		return "", fmt.Errorf("invalid to %q", c.To)
+	}
+	to := common.HexToAddress(c.To)
+	var from common.Address
+	if c.From != "" {
+		if !common.IsHexAddress(c.From) {
+			return "", fmt.Errorf("invalid from %q", c.From)
+		}
+		from = common.HexToAddress(c.From)
+	}
+	value, err := c.Value.wei("value", new(big.Int))
+	if err != nil {
+		return "", err
+	}
+	data, err := txbuilder.ParseCalldata(c.Data)
+	if err != nil {
+		return "", err
+	}
+	if c.ERC20 != "" {
+		if !common.IsHexAddress(c.ERC20) {
+			return "", fmt.Errorf("invalid erc20 %q", c.ERC20)
+		}
+		data = txbuilder.EncodeERC20Transfer(to, value)
+		to = common.HexToAddress(c.ERC20)
+		value = new(big.Int)
+	}
+	fees := txbuilder.FeeParams{TxType: txbuilder.TxTypeLegacy}
+	if fees.GasPrice, err = c.GasPrice.wei("gas_price", big.NewInt(1000000000)); err != nil {
+		return "", err
+	}
+	if c.MaxFee != "" || c.MaxPriorityFee != "" {
+		fees.TxType = txbuilder.TxTypeDynamic
+		if fees.MaxFee, err = c.MaxFee.wei("max_fee", nil); err != nil {
+			return "", err
+		}
+		if fees.MaxPriorityFee, err = c.MaxPriorityFee.wei("max_priority_fee", nil); err != nil {
+			return "", err
+		}
+	}
+	gas := c.Gas
+	if gas == 0 && len(data) > 0 {
+		gas = 100000
+	}
+	chainID := big.NewInt(1)
+	if c.ChainID != 0 {
+		chainID = big.NewInt(c.ChainID)
+	}
+	tx, err := txbuilder.Build(0, to, value, data, gas, chainID, fees)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if c.Time != "" {
+		if now, err = time.Parse(time.RFC3339, c.Time); err != nil {
+			return "", fmt.Errorf("invalid time: %v", err)
+		}
+	}
+	if err := pol.ForSender(from).Evaluate(context.Background(), tx, from, chainID, now, "", nil); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+func runPolicyTest(args []string) error {
+	fs := flag.NewFlagSet("policy test", flag.ExitOnError)
+	policyOpts := addPolicyFlags(fs)
+	casesFile := fs.String("cases", "", "YAML file of example transactions and expected outcomes")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *casesFile == "" {
+		return errors.New("cases is required")
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	data, err := os.ReadFile(*casesFile)
+	if err != nil {
+		return err
+	}
+	var file policyTestFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return fmt.Errorf("failed to parse cases: %v", err)
+	}
+
+	type result struct {
+		Name    string `json:"name"`
+		Passed  bool   `json:"passed"`
+		Problem string `json:"problem,omitempty"`
+	}
+	var results []result
+	failed := 0
+	for i, c := range file.Cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("case %d", i+1)
+		}
+		denial, err := c.run(policy)
+		var problem string
+		switch {
+		case err != nil:
+			problem = err.Error()
+		case c.Expect != "allow" && c.Expect != "deny":
+			problem = "expect must be allow or deny"
+		case c.Expect == "allow" && denial != "":
+			problem = "expected allow, denied: " + denial
+		case c.Expect == "deny" && denial == "":
+			problem = "expected deny, allowed"
+		case c.Expect == "deny" && !strings.Contains(denial, c.Reason):
+			problem = fmt.Sprintf("expected denial containing %q, got: %s", c.Reason, denial)
+		}
+		if problem != "" {
+			failed++
+		}
+		results = append(results, result{Name: name, Passed: problem == "", Problem: problem})
+	}
+	output.print(results, func() {
+		for _, r := range results {
+			if r.Passed {
+				fmt.Printf("PASS %s\n", r.Name)
+			} else {
+				fmt.Printf("FAIL %s: %s\n", r.Name, r.Problem)
+			}
+		}
+		if failed == 0 {
+			fmt.Printf("all %d policy tests passed\n", len(results))
+		}
+	})
+	if failed > 0 {
+		return fmt.Errorf("%d of %d policy tests failed", failed, len(file.Cases))
+	}
+	return nil
+}
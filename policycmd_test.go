@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"sigs.k8s.io/yaml"
+)
+
+func TestLintPolicy(t *testing.T) {
+	const recipient = "0x2000000000000000000000000000000000000002"
+	const base = `"max_amount_wei": 100, "allowed_chain_ids": [1], `
+	tests := []struct {
+		name     string
+		doc      string
+		errors   []string
+		warnings []string
+	}{
+		{"clean", `{` + base + `"whitelist": ["` + recipient + `"]}`, nil, nil},
+		{"upper-case prefix", `{` + base + `"whitelist": ["0XfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"]}`, nil, nil},
+		{"unknown field", `{"max_amount": 100}`, []string{"schema: "}, nil},
+		{"does not load", `{"whitelist": 1}`, []string{"whitelist must be"}, nil},
+		{
+			"unchecksummed address",
+			`{` + base + `"whitelist": ["0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"]}`,
+			nil, []string{"whitelist: 0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359 is not checksummed"},
+		},
+		{
+			"bad checksum",
+			`{` + base + `"whitelist": ["0xFB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"]}`,
+			[]string{"0xFB6916095ca1df60bB79Ce92cE3Ea74c37c5d359 has an invalid checksum"}, nil,
+		},
+		{
+			"sender policy",
+			`{` + base + `"whitelist": ["` + recipient + `"], "senders": {"` + recipient + `": {` + base + `"whitelist": ["` + recipient + `"], "address_poisoning": {"action": "off"}}}}`,
+			nil, []string{"senders." + recipient + ".address_poisoning.action: off"},
+		},
+		{
+			"bad poisoning action",
+			`{"address_poisoning": {"action": "block"}}`,
+			[]string{"address_poisoning.action: must be deny, warn or off"}, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := lintPolicy([]byte(tt.doc), false)
+			check := func(kind string, got, want []string) {
+				for _, w := range want {
+					found := false
+					for _, g := range got {
+						found = found || strings.Contains(g, w)
+					}
+					if !found {
+						t.Errorf("%s = %q, want one containing %q", kind, got, w)
+					}
+				}
+			}
+			check("errors", r.errors, tt.errors)
+			check("warnings", r.warnings, tt.warnings)
+			if len(tt.errors) == 0 && len(r.errors) > 0 {
+				t.Errorf("errors = %q, want none", r.errors)
+			}
+			if len(tt.errors)+len(tt.warnings) == 0 && len(r.warnings) > 0 {
+				t.Errorf("warnings = %q, want none", r.warnings)
+			}
+		})
+	}
+}
+
+func TestPolicyTestCases(t *testing.T) {
+	pol, err := policy.Parse([]byte(`{
+		"max_amount_wei": 1000,
+		"whitelist": ["0x2000000000000000000000000000000000000002"],
+		"tokens": {"0x1000000000000000000000000000000000000001": {"max_amount": 50}},
+		"signing_window": {"start_hour": 9, "end_hour": 17}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file policyTestFile
+	err = yaml.UnmarshalStrict([]byte(`
+cases:
+  - name: within limit
+    to: "0x2000000000000000000000000000000000000002"
+    value: 1000
+    time: "2026-01-05T10:00:00Z"
+    expect: allow
+  - name: over limit
+    to: "0x2000000000000000000000000000000000000002"
+    value: "1001"
+    time: "2026-01-05T10:00:00Z"
+    expect: deny
+    reason: exceeds max policy limit
+  - name: token transfer
+    to: "0x2000000000000000000000000000000000000002"
+    erc20: "0x1000000000000000000000000000000000000001"
+    value: 51
+    time: "2026-01-05T10:00:00Z"
+    expect: deny
+    reason: token amount
+  - name: after hours
+    to: "0x2000000000000000000000000000000000000002"
+    time: "2026-01-05T18:00:00Z"
+    expect: deny
+    reason: signing window
+`), &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range file.Cases {
+		denial, err := c.run(pol)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		if (c.Expect == "allow") != (denial == "") || !strings.Contains(denial, c.Reason) {
+			t.Errorf("%s: denial %q, want %s %q", c.Name, denial, c.Expect, c.Reason)
+		}
+	}
+
+	for _, c := range []policyTestCase{
+		{To: "not an address"},
+		{To: "0x2000000000000000000000000000000000000002", Value: "1.5"},
+		{To: "0x2000000000000000000000000000000000000002", Time: "tomorrow"},
+	} {
+		if _, err := c.run(pol); err == nil {
+			t.Errorf("case %+v ran, want an error", c)
+		}
+	}
+	if err := yaml.UnmarshalStrict([]byte("cases:\n  - to: x\n    expected: allow\n"), new(policyTestFile)); err == nil {
+		t.Error("case with an unknown field parsed")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// policyFlags selects the policy file and, optionally, the key it must be
+// signed with.
+type policyFlags struct {
+	file   string
+	pubkey string
+	sig    string
+}
+
+func addPolicyFlags(fs *flag.FlagSet) *policyFlags {
+	p := new(policyFlags)
+	fs.StringVar(&p.file, "policy", "policy.json", "Path to policy JSON file")
+	fs.StringVar(&p.pubkey, "policy-pubkey", "", "Hex Ed25519 or secp256k1 public key the policy file must be signed with")
+	fs.StringVar(&p.sig, "policy-sig", "", "Detached hex signature over the policy file (default <policy>.sig)")
+	return p
+}
+
+// load reads the policy file. With -policy-pubkey it refuses a policy whose
+// detached signature is missing or does not verify, or that loads files it
+// does not pin.
+func (p *policyFlags) load() (*policy.Policy, error) {
+	data, err := os.ReadFile(p.file)
+	if err != nil {
+		return nil, err
+	}
+	if p.pubkey != "" {
+		if err := verifyPolicySignature(data, p.pubkey, p.sigFile()); err != nil {
+			return nil, err
+		}
+		return policy.ParseSigned(data)
+	}
+	return policy.Parse(data)
+}
+
+func (p *policyFlags) sigFile() string {
+	if p.sig != "" {
+		return p.sig
+	}
+	return p.file + ".sig"
+}
+
+// verifyPolicySignature checks the detached signature in sigFile over data.
+// Ed25519 signatures are over the raw file; secp256k1 signatures, 64 or 65
+// bytes, are over its Keccak-256 hash.
+func verifyPolicySignature(data []byte, pubkeyHex, sigFile string) error {
+	pub, err := hex.DecodeString(strings.TrimPrefix(pubkeyHex, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid policy-pubkey: %v", err)
+	}
+	sigData, err := os.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("policy signature: %v", err)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(sigData)), "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid policy signature: %v", err)
+	}
+	var ok bool
+	switch len(pub) {
+	case ed25519.PublicKeySize:
+		ok = len(sig) == ed25519.SignatureSize && ed25519.Verify(pub, data, sig)
+	case 33, 65:
+		ok = (len(sig) == 64 || len(sig) == 65) && crypto.VerifySignature(pub, crypto.Keccak256(data), sig[:64])
+	default:
+		return errors.New("policy-pubkey must be a 32-byte Ed25519 or 33/65-byte secp256k1 key")
+	}
+	if !ok {
+		return errors.New("policy signature verification failed")
+	}
+	return nil
+}
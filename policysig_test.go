@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testPolicy = `{"max_amount_wei": 100, "whitelist": ["0x2000000000000000000000000000000000000002"]}`
+
+// writeSignedPolicy writes doc to policy.json in dir with a detached
+// signature by sign, returning the policy path.
+func writeSignedPolicy(t *testing.T, dir, doc string, sign func([]byte) []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", []byte(hex.EncodeToString(sign([]byte(doc)))+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyPolicySignature(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]struct {
+		pubkey string
+		sign   func([]byte) []byte
+	}{
+		"ed25519": {hex.EncodeToString(edPub), func(data []byte) []byte { return ed25519.Sign(edPriv, data) }},
+		"secp256k1": {"0x" + hex.EncodeToString(crypto.CompressPubkey(&ecPriv.PublicKey)), func(data []byte) []byte {
+			sig, err := crypto.Sign(crypto.Keccak256(data), ecPriv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		}},
+	}
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			path := writeSignedPolicy(t, t.TempDir(), testPolicy, key.sign)
+			opts := &policyFlags{file: path, pubkey: key.pubkey}
+			if _, err := opts.load(); err != nil {
+				t.Fatalf("signed policy refused: %v", err)
+			}
+
+			// Raising the limit after signing must be caught.
+			tampered := []byte(`{"max_amount_wei": 100000, "whitelist": ["0x2000000000000000000000000000000000000002"]}`)
+			if err := os.WriteFile(path, tampered, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := opts.load(); err == nil {
+				t.Fatal("tampered policy accepted")
+			}
+			if err := verifyPolicySignature([]byte(testPolicy), key.pubkey, path+".sig"); err != nil {
+				t.Fatalf("original policy refused: %v", err)
+			}
+		})
+	}
+
+	path := writeSignedPolicy(t, t.TempDir(), testPolicy, keys["ed25519"].sign)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		opts policyFlags
+	}{
+		{"other key", policyFlags{file: path, pubkey: hex.EncodeToString(otherPub)}},
+		{"missing signature", policyFlags{file: path, pubkey: keys["ed25519"].pubkey, sig: path + ".missing"}},
+		{"short key", policyFlags{file: path, pubkey: "abcd"}},
+		{"malformed key", policyFlags{file: path, pubkey: "not hex"}},
+	} {
+		if _, err := tt.opts.load(); err == nil {
+			t.Errorf("%s: policy accepted", tt.name)
+		}
+	}
+}
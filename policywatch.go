@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/fsnotify/fsnotify"
+)
+
+// policyReloadDelay coalesces the burst of events an editor or an atomic
+// rename produces into a single reload.
+const policyReloadDelay = 200 * time.Millisecond
+
+// watchPolicy reloads the policy into eng whenever the policy file or its
+// signature changes, until ctx is done. A policy that fails to load or
+// verify is logged, reported to hooks and ignored, leaving the current one
+// in force.
+func watchPolicy(ctx context.Context, opts *policyFlags, eng *signer.Engine, hooks *webhookNotifier) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory rather than the file so replacing the file by
+	// rename is picked up too.
+	watched := map[string]bool{filepath.Clean(opts.file): true}
+	if opts.pubkey != "" {
+		watched[filepath.Clean(opts.sigFile())] = true
+	}
+	dirs := make(map[string]bool)
+	for file := range watched {
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if watched[filepath.Clean(ev.Name)] && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					reload = time.After(policyReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("policy watcher failed", "err", err)
+				hooks.daemonError("policy_watch", err)
+			case <-reload:
+				reload = nil
+				policy, err := opts.load()
+				if err != nil {
+					slog.Warn("policy reload rejected", "keeping", eng.Policy.Load().Digest, "err", err)
+					hooks.daemonError("policy_reload", err)
+					eng.PolicyErr.Store(&err)
+					continue
+				}
+				eng.PolicyErr.Store(nil)
+				old := eng.Policy.Swap(policy)
+				if old.Digest != policy.Digest {
+					slog.Info("policy reloaded", "old", old.Digest, "new", policy.Digest)
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"flag"
+)
+
+// addPostSignHooksFlag registers -post-sign-hooks.
+func addPostSignHooksFlag(fs *flag.FlagSet) *string {
+	return fs.String("post-sign-hooks", "", "JSON file of hooks, HTTP endpoints or commands, run with every signed transaction")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"golang.org/x/term"
+)
+
+// chainName returns a readable name for chainID.
+func chainName(chainID *big.Int) string {
+	if c, ok := chains.ByID(chainID); ok {
+		return fmt.Sprintf("%s (%s)", c.Name, chainID)
+	}
+	return "chain " + chainID.String()
+}
+
+// formatNative formats an amount of wei in the native currency of chainID,
+// as txbuilder.FormatWei does for ether.
+func formatNative(wei, chainID *big.Int) string {
+	if c, ok := chains.ByID(chainID); ok && c.Symbol != "" {
+		return fmt.Sprintf("%s wei (%s %s)", wei, txbuilder.FormatDecimal(wei, 18), c.Symbol)
+	}
+	return txbuilder.FormatWei(wei)
+}
+
+// printPreview writes a human-readable summary of tx, sent by from on
+// chainID, to w. The L1 data fee, when known, counts towards the total.
+func printPreview(w io.Writer, tx *types.Transaction, from common.Address, chainID, l1Fee *big.Int) {
+	fmt.Fprintln(w, "Chain:", chainName(chainID))
+	fmt.Fprintln(w, "From:", from.Hex())
+	if tx.To() != nil {
+		fmt.Fprintln(w, "To:", tx.To().Hex())
+	} else {
+		fmt.Fprintln(w, "To: (contract creation)")
+	}
+	fmt.Fprintln(w, "Value:", formatNative(tx.Value(), chainID))
+	switch call := txbuilder.DecodeCall(tx.Data()); {
+	case call != nil && call.TokenID != "":
+		fmt.Fprintf(w, "Call: %s of token %s (amount %s) to %s\n", call.Kind, call.TokenID, call.Amount, call.Recipient)
+	case call != nil:
+		fmt.Fprintf(w, "Call: %s of %s base units to %s\n", call.Kind, call.Amount, call.Recipient)
+	case len(tx.Data()) >= 4 && tx.To() != nil:
+		if decoded, guessed := describeCall("", *tx.To(), tx.Data()); decoded != nil {
+			printCall(w, decoded, guessed)
+			break
+		}
+		fmt.Fprintf(w, "Call: selector %s with %d bytes of arguments\n", hexutil.Encode(tx.Data()[:4]), len(tx.Data())-4)
+	case len(tx.Data()) > 0:
+		fmt.Fprintf(w, "Data: %s\n", hexutil.Encode(tx.Data()))
+	}
+	fmt.Fprintln(w, "Nonce:", tx.Nonce())
+	fmt.Fprintln(w, "Gas limit:", tx.Gas())
+	fmt.Fprintln(w, "Max fee per gas:", txbuilder.FormatDecimal(tx.GasFeeCap(), 9), "gwei")
+	if l1Fee == nil {
+		fmt.Fprintln(w, "Max total cost:", formatNative(tx.Cost(), chainID))
+		return
+	}
+	fmt.Fprintln(w, "L1 data fee:", formatNative(l1Fee, chainID))
+	fmt.Fprintln(w, "Max total cost:", formatNative(new(big.Int).Add(tx.Cost(), l1Fee), chainID), "including the L1 data fee")
+}
+
+func addConfirmFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("confirm", false, "Show a summary of each transaction and sign it only after the last 4 characters of its recipient are typed")
+}
+
+// confirmTx shows the preview of tx on the terminal and waits for the
+// operator to type the last 4 characters of its recipient.
+func confirmTx(from common.Address, tx *types.Transaction, chainID, l1Fee *big.Int) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("confirm requires stdin to be a terminal")
+	}
+	printPreview(os.Stderr, tx, from, chainID, l1Fee)
+	to, ok := policy.TxRecipient(tx)
+	if !ok {
+		return fmt.Errorf("confirm does not support contract creation")
+	}
+	fmt.Fprintf(os.Stderr, "Type the last 4 characters of the recipient %s to sign: ", to.Hex())
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if !strings.EqualFold(strings.TrimSpace(line), to.Hex()[len(to.Hex())-4:]) {
+		return fmt.Errorf("transaction to %s not confirmed", to.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// qrFlags render a payload as a QR code so it can cross an air gap by
+// camera.
+type qrFlags struct {
+	terminal bool
+	png      string
+}
+
+func addQRFlags(fs *flag.FlagSet, what string) *qrFlags {
+	q := new(qrFlags)
+	fs.BoolVar(&q.terminal, "qr", false, fmt.Sprintf("Print the %s as a QR code on stderr", what))
+	fs.StringVar(&q.png, "qr-png", "", fmt.Sprintf("Write the %s as a QR code PNG to this file", what))
+	return q
+}
+
+// render renders payload as requested by the flags. The text a scanner
+// decodes from the code is exactly payload.
+func (q *qrFlags) render(payload []byte) error {
+	if !q.terminal && q.png == "" {
+		return nil
+	}
+	code, err := qrcode.New(string(payload), qrcode.Low)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code (%d bytes): %v", len(payload), err)
+	}
+	if q.terminal {
+		fmt.Fprint(os.Stderr, code.ToSmallString(false))
+	}
+	if q.png != "" {
+		data, err := code.PNG(-8)
+		if err != nil {
+			return fmt.Errorf("failed to render QR code: %v", err)
+		}
+		if err := os.WriteFile(q.png, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// rbacGrant permits the listed identities to sign with some keys, on some
+// chains and up to some ether value per transaction. An empty list or a nil
+// limit does not restrict; a grant restricting chains or value does not
+// cover message and raw signing, whose chain and value are unknown.
+type rbacGrant struct {
+	// Identities lists who the grant is for: client:<name> for a client of
+	// the clients file, whether it used its API key or its certificate,
+	// cert:<name> for a certificate accepted by the client CA alone and
+	// jwt:<subject> for a bearer token. "*" is everyone.
+	Identities []string `json:"identities"`
+	Keys       []string `json:"keys"`
+	ChainIDs   []uint64 `json:"chain_ids"`
+	// MaxValueWei caps the native ether value of a transaction only; the
+	// amounts of token and NFT transfers, which carry no value, are left
+	// to the policy tokens and nfts.
+	MaxValueWei *big.Int `json:"max_value_wei"`
+}
+
+// rbacConfig is the file given to serve -rbac. When it is set, a caller
+// may only sign what one of its grants permits. The grants are checked
+// before the policy, which still applies in full.
+type rbacConfig struct {
+	Grants []rbacGrant `json:"grants"`
+}
+
+func loadRBAC(file string) (*rbacConfig, error) {
+	cfg := new(rbacConfig)
+	if err := jsonfile.Read(file, cfg); err != nil {
+		return nil, fmt.Errorf("failed to read rbac: %v", err)
+	}
+	for i, g := range cfg.Grants {
+		if len(g.Identities) == 0 {
+			return nil, fmt.Errorf("rbac grant %d: identities is required", i)
+		}
+		for _, id := range g.Identities {
+			kind, name, _ := strings.Cut(id, ":")
+			if id != "*" && (name == "" || (kind != "client" && kind != "cert" && kind != "jwt")) {
+				return nil, fmt.Errorf("rbac grant %d: invalid identity %q: want client:, cert: or jwt: and a name", i, id)
+			}
+		}
+		for _, k := range g.Keys {
+			if !common.IsHexAddress(k) {
+				return nil, fmt.Errorf("rbac grant %d: invalid key address %q", i, k)
+			}
+		}
+		if g.MaxValueWei != nil && g.MaxValueWei.Sign() < 0 {
+			return nil, fmt.Errorf("rbac grant %d: max_value_wei must not be negative", i)
+		}
+	}
+	return cfg, nil
+}
+
+// grantsFor returns the grants of the identity id.
+func (cfg *rbacConfig) grantsFor(id string) []rbacGrant {
+	var grants []rbacGrant
+	for _, g := range cfg.Grants {
+		for _, gid := range g.Identities {
+			if gid == "*" || gid == id {
+				grants = append(grants, g)
+				break
+			}
+		}
+	}
+	return grants
+}
+
+// permits reports whether g covers signing with account on chainID for
+// value; chainID and value are nil for message signing.
+func (g rbacGrant) permits(account common.Address, chainID, value *big.Int) bool {
+	if len(g.Keys) > 0 {
+		listed := false
+		for _, k := range g.Keys {
+			listed = listed || strings.EqualFold(k, account.Hex())
+		}
+		if !listed {
+			return false
+		}
+	}
+	if len(g.ChainIDs) > 0 {
+		if chainID == nil || !chainID.IsUint64() {
+			return false
+		}
+		listed := false
+		for _, id := range g.ChainIDs {
+			listed = listed || id == chainID.Uint64()
+		}
+		if !listed {
+			return false
+		}
+	}
+	if g.MaxValueWei != nil && (value == nil || value.Cmp(g.MaxValueWei) > 0) {
+		return false
+	}
+	return true
+}
+
+// checkGrants returns an error unless a grant of the caller of ctx permits
+// signing with account on chainID for value, when the daemon enforces
+// grants. chainID and value are nil for message signing.
+func checkGrants(ctx context.Context, account common.Address, chainID, value *big.Int) error {
+	c := callerFrom(ctx)
+	if c == nil || !c.rbac {
+		return nil
+	}
+	for _, g := range c.grants {
+		if g.permits(account, chainID, value) {
+			return nil
+		}
+	}
+	what := "messages"
+	if chainID != nil {
+		what = fmt.Sprintf("%s wei on chain %s", value, chainID)
+	}
+	slog.Warn("request not granted", "client", c.id, "account", account.Hex(), "chain_id", chainID, "value", value)
+	return fmt.Errorf("forbidden: no grant lets %s sign %s with %s", c.id, what, account.Hex())
+}
+
+// errRBACUnauthenticated rejects -rbac without a way to identify callers.
+var errRBACUnauthenticated = errors.New("rbac requires clients, tls-client-ca or jwt-config")
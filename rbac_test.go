@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGrantPermitsValue(t *testing.T) {
+	account := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	g := rbacGrant{Identities: []string{"*"}, MaxValueWei: big.NewInt(100)}
+	tests := []struct {
+		name  string
+		value *big.Int
+		want  bool
+	}{
+		{"no value", new(big.Int), true},
+		{"at cap", big.NewInt(100), true},
+		{"over cap", big.NewInt(101), false},
+		{"message", nil, false},
+	}
+	for _, tt := range tests {
+		chainID := big.NewInt(1)
+		if tt.value == nil {
+			chainID = nil
+		}
+		if got := g.permits(account, chainID, tt.value); got != tt.want {
+			t.Errorf("%s: permits = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRBACRejectsMaxAmountWei(t *testing.T) {
+	// max_value_wei only caps ether value; the old name must not be
+	// silently dropped.
+	file := filepath.Join(t.TempDir(), "rbac.json")
+	if err := os.WriteFile(file, []byte(`{"grants": [{"identities": ["*"], "max_amount_wei": 1}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRBAC(file); err == nil {
+		t.Fatal("loadRBAC accepted max_amount_wei")
+	}
+}
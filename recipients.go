@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/term"
+)
+
+// addNewRecipientFlag registers -confirm-new-recipient and returns the
+// confirmation function for the engine.
+func addNewRecipientFlag(fs *flag.FlagSet) func(from, to common.Address) error {
+	confirmed := fs.Bool("confirm-new-recipient", false, "Sign transactions to recipients this key has never paid without asking")
+	return func(from, to common.Address) error {
+		return confirmNewRecipient(*confirmed, from, to)
+	}
+}
+
+// confirmNewRecipient allows paying to, which from has never paid, if
+// confirmed is set or the operator confirms it at the terminal.
+func confirmNewRecipient(confirmed bool, from, to common.Address) error {
+	if confirmed {
+		fmt.Fprintf(os.Stderr, "warning: %s has never been paid from %s\n", to.Hex(), from.Hex())
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("%s has never been paid from %s; check the address and pass -confirm-new-recipient to sign", to.Hex(), from.Hex())
+	}
+	fmt.Fprintf(os.Stderr, "%s has never been paid from %s.\nCheck the full address; type it again to sign: ", to.Hex(), from.Hex())
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	typed := strings.TrimSpace(line)
+	if !common.IsHexAddress(typed) || common.HexToAddress(typed) != to {
+		return fmt.Errorf("new recipient %s not confirmed", to.Hex())
+	}
+	return nil
+}
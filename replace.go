@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultBump is the default fee increase for replacements; geth's
+// transaction pool rejects replacements that bump both fees by less.
+const defaultBump = "10%"
+
+// parseBump parses a fee increase given as a percentage, with or without
+// the trailing %.
+func parseBump(value string) (int64, error) {
+	pct, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), "%"), 10, 64)
+	if err != nil || pct < 0 {
+		return 0, fmt.Errorf("invalid bump %q", value)
+	}
+	return pct, nil
+}
+
+// bumpWei raises wei by pct percent, rounding up so the increase is never
+// short of pct.
+func bumpWei(wei *big.Int, pct int64) *big.Int {
+	bumped := new(big.Int).Mul(wei, big.NewInt(100+pct))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// bumpFees returns an unsigned copy of tx with its fees raised by pct
+// percent.
+func bumpFees(tx *types.Transaction, pct int64) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: bumpWei(tx.GasPrice(), pct),
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}), nil
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   bumpWei(tx.GasPrice(), pct),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  bumpWei(tx.GasTipCap(), pct),
+			GasFeeCap:  bumpWei(tx.GasFeeCap(), pct),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}), nil
+	}
+	return nil, fmt.Errorf("cannot replace transactions of type %d", tx.Type())
+}
+
+// replaceFlags are the flags shared by replace and cancel.
+type replaceFlags struct {
+	keys           *keySource
+	policyOpts     *policyFlags
+	storePath      *string
+	overrideReason *string
+	approvalsDir   *string
+	alertsConfig   *string
+	auditOpts      *auditFlags
+	explain        *bool
+	bump           *string
+	rpcOpts        *rpcFlags
+	send           *bool
+	sendOpts       *broadcastFlags
+	output         *outputFormat
+}
+
+func addReplaceFlags(fs *flag.FlagSet) *replaceFlags {
+	return &replaceFlags{
+		keys:           addKeyFlags(fs),
+		policyOpts:     addPolicyFlags(fs),
+		storePath:      fs.String("store", signer.DefaultStore, "State store for cumulative policy limits, approvals, time-delayed transactions and the freeze switch"),
+		overrideReason: addOverrideFlag(fs),
+		approvalsDir:   fs.String("approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements"),
+		alertsConfig:   addAlertFlags(fs),
+		auditOpts:      addAuditFlags(fs),
+		explain:        fs.Bool("explain", false, "Print the policy decision as JSON to stderr"),
+		bump:           fs.String("bump", defaultBump, "Fee increase in percent"),
+		rpcOpts:        addRPCFlags(fs),
+		send:           fs.Bool("send", false, "Broadcast the signed transaction via -rpc"),
+		sendOpts:       addBroadcastFlags(fs),
+		output:         addOutputFlag(fs),
+	}
+}
+
+// signReplacement policy-checks and signs tx like sign does, then prints
+// and optionally broadcasts it.
+func (r *replaceFlags) signReplacement(ctx context.Context, key keys.Backend, store signer.Store, pol *policy.Policy, client *ethclient.Client, tx *types.Transaction, chainID *big.Int) error {
+	eng := signer.New(pol, key, store)
+	eng.ENS = policy.NewENSResolver(client)
+	eng.L1Fees = signer.NewL1FeeOracle(client)
+	eng.TokenDecimals = signer.NewTokenDecimals(client)
+	// Replacing a pending transaction is the point of replace and cancel.
+	eng.AllowReplace = true
+	eng.OverrideReason = *r.overrideReason
+	eng.ApprovalsDir = *r.approvalsDir
+	var err error
+	if eng.Alerts, err = signer.LoadAlerter(*r.alertsConfig); err != nil {
+		return err
+	}
+	if eng.Audit, err = r.auditOpts.open(store); err != nil {
+		return err
+	}
+	signedTx, d, err := eng.SignTx(ctx, tx, chainID)
+	if *r.explain {
+		printDecision(d)
+	}
+	if err != nil {
+		return err
+	}
+	if !*r.send {
+		client = nil
+	}
+	return printSignedTx(ctx, signedTx, key.Address(), d, client, r.sendOpts, r.output)
+}
+
+func runReplace(args []string) error {
+	fs := flag.NewFlagSet("replace", flag.ExitOnError)
+	txHash := fs.String("tx-hash", "", "Hash of the pending transaction to speed up")
+	opts := addReplaceFlags(fs)
+	parseFlags(fs, args)
+
+	if *txHash == "" {
+		return errors.New("tx-hash is required")
+	}
+	pct, err := parseBump(*opts.bump)
+	if err != nil {
+		return err
+	}
+	if opts.rpcOpts.url == "" || opts.rpcOpts.offline {
+		return errors.New("replace requires rpc")
+	}
+	ctx := context.Background()
+	store, err := signer.OpenStore(*opts.storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key, err := openKeyBackend(ctx, *opts.keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy, err := opts.policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	client, err := opts.rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	chainID, err := txbuilder.FetchChainID(ctx, client, nil)
+	if err != nil {
+		return err
+	}
+
+	tx, pending, err := client.TransactionByHash(ctx, common.HexToHash(*txHash))
+	if err != nil {
+		return fmt.Errorf("failed to fetch transaction: %v", err)
+	}
+	if !pending {
+		return fmt.Errorf("transaction %s is already mined", *txHash)
+	}
+	from, err := txbuilder.TxSender(tx)
+	if err != nil {
+		return fmt.Errorf("failed to recover sender: %v", err)
+	}
+	if from != key.Address() {
+		return fmt.Errorf("transaction %s was sent by %s, not %s", *txHash, from.Hex(), key.Address().Hex())
+	}
+	if tx.To() == nil {
+		return errors.New("cannot replace contract creations")
+	}
+	replacement, err := bumpFees(tx, pct)
+	if err != nil {
+		return err
+	}
+	return opts.signReplacement(ctx, key, store, policy, client, replacement, chainID)
+}
+
+func runCancel(args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	nonce := fs.Uint64("nonce", 0, "Nonce of the pending transaction to cancel")
+	chainID := addChainFlag(fs, 1, "Chain ID (default Ethereum mainnet, or fetched via -rpc when omitted)")
+	feeOpts := addFeeFlags(fs)
+	opts := addReplaceFlags(fs)
+	parseFlags(fs, args)
+
+	if !flagWasSet(fs, "nonce") {
+		return errors.New("nonce is required")
+	}
+	pct, err := parseBump(*opts.bump)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	store, err := signer.OpenStore(*opts.storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key, err := openKeyBackend(ctx, *opts.keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy, err := opts.policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	if *opts.send && (opts.rpcOpts.url == "" || opts.rpcOpts.offline) {
+		return errors.New("send requires rpc")
+	}
+	chain := chainID.big()
+	client, err := opts.rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+		var want *big.Int
+		if flagWasSet(fs, "chain") {
+			want = chain
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return err
+		}
+	}
+	fees, err := feeOpts.params(ctx, client, policy.Fees)
+	if err != nil {
+		return err
+	}
+	// Suggested fees are what a new transaction would pay now; the stuck
+	// one paid at least that when it was sent, so bump them. Fees given
+	// explicitly are used as they are.
+	if client != nil {
+		if fees.TxType == txbuilder.TxTypeLegacy && !flagWasSet(fs, "gas-price") {
+			fees.GasPrice = bumpWei(fees.GasPrice, pct)
+		}
+		if fees.TxType == txbuilder.TxTypeDynamic && !flagWasSet(fs, "max-fee") {
+			fees.MaxFee = bumpWei(fees.MaxFee, pct)
+		}
+		if fees.TxType == txbuilder.TxTypeDynamic && !flagWasSet(fs, "max-priority-fee") {
+			fees.MaxPriorityFee = bumpWei(fees.MaxPriorityFee, pct)
+		}
+	}
+	tx, err := txbuilder.Build(*nonce, key.Address(), new(big.Int), nil, txbuilder.TransferGas, chain, fees)
+	if err != nil {
+		return fmt.Errorf("failed to build tx: %v", err)
+	}
+	return opts.signReplacement(ctx, key, store, policy, client, tx, chain)
+}
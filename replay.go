@@ -0,0 +1,9 @@
+package main
+
+import (
+	"flag"
+)
+
+func addAllowReplaceFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("allow-replace", false, "Allow signing a different transaction for a nonce that was already signed")
+}
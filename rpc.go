@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// rpcFlags select the optional JSON-RPC endpoint used to fill in
+// transaction fields.
+type rpcFlags struct {
+	url     string
+	offline bool
+	fs      *flag.FlagSet
+}
+
+// rpcDefault selects the public endpoint of the -chain preset.
+const rpcDefault = "default"
+
+func addRPCFlags(fs *flag.FlagSet) *rpcFlags {
+	r := &rpcFlags{fs: fs}
+	fs.StringVar(&r.url, "rpc", "", "JSON-RPC endpoint used to fetch the nonce and chain ID when not given (\""+rpcDefault+"\" for the public endpoint of the -chain preset)")
+	fs.BoolVar(&r.offline, "offline", false, "Never contact an RPC endpoint")
+	return r
+}
+
+// dial connects to the configured endpoint. It returns a nil client when
+// running offline or when no endpoint is configured.
+func (r *rpcFlags) dial(ctx context.Context) (*ethclient.Client, error) {
+	if r.offline {
+		if r.url != "" {
+			return nil, errors.New("rpc and offline are mutually exclusive")
+		}
+		return nil, nil
+	}
+	if r.url == "" {
+		return nil, nil
+	}
+	url := r.url
+	if url == rpcDefault {
+		f := r.fs.Lookup("chain")
+		if f == nil {
+			return nil, errors.New("rpc default requires -chain")
+		}
+		chain, ok := f.Value.(*chainFlag)
+		if !ok {
+			return nil, errors.New("rpc default requires -chain")
+		}
+		var err error
+		if url, err = chain.defaultRPC(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := txbuilder.Dial(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rpc: %v", err)
+	}
+	return client, nil
+}
+
+// flagWasSet reports whether name was given explicitly on the command line.
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
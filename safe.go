@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// safeTxServiceTimeout bounds each Safe Transaction Service request.
+const safeTxServiceTimeout = 30 * time.Second
+
+// safeTxService is a client of the Safe Transaction Service API.
+type safeTxService struct {
+	url    string
+	client *http.Client
+}
+
+// propose submits tx with the first signature, from sender.
+func (s *safeTxService) propose(ctx context.Context, safe common.Address, tx *txbuilder.SafeTx, hash common.Hash, sender common.Address, signature string) error {
+	body := map[string]any{
+		"to":                      tx.To.Hex(),
+		"value":                   tx.Value.String(),
+		"data":                    hexutil.Encode(tx.Data),
+		"operation":               tx.Operation,
+		"safeTxGas":               tx.SafeTxGas.String(),
+		"baseGas":                 tx.BaseGas.String(),
+		"gasPrice":                tx.GasPrice.String(),
+		"gasToken":                tx.GasToken.Hex(),
+		"refundReceiver":          tx.RefundReceiver.Hex(),
+		"nonce":                   tx.Nonce.String(),
+		"contractTransactionHash": hash.Hex(),
+		"sender":                  sender.Hex(),
+		"signature":               signature,
+		"origin":                  "secure-signer",
+	}
+	return s.post(ctx, "/api/v1/safes/"+safe.Hex()+"/multisig-transactions/", body)
+}
+
+// confirm adds a signature to a transaction already proposed.
+func (s *safeTxService) confirm(ctx context.Context, hash common.Hash, signature string) error {
+	return s.post(ctx, "/api/v1/multisig-transactions/"+hash.Hex()+"/confirmations/", map[string]string{"signature": signature})
+}
+
+func (s *safeTxService) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.url, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("transaction service answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func parseOptionalAddress(name, value string) (common.Address, error) {
+	if value == "" {
+		return common.Address{}, nil
+	}
+	if !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("invalid %s address %q", name, value)
+	}
+	return common.HexToAddress(value), nil
+}
+
+func runSignSafe(args []string) error {
+	fs := flag.NewFlagSet("sign-safe", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	safeFlag := fs.String("safe", "", "Safe address")
+	to := fs.String("to", "", "Address the Safe calls")
+	value := fs.String("value", "0", "Value in wei sent by the Safe")
+	data := fs.String("data", "", "Calldata in hex")
+	operation := fs.Uint("operation", txbuilder.SafeOperationCall, "0 for a call, 1 for a delegate call")
+	safeTxGas := fs.String("safe-tx-gas", "0", "Gas for the Safe's inner call (0 uses all available gas)")
+	baseGas := fs.String("base-gas", "0", "Gas costs outside the inner call, for refunds (dataGas in Safe 1.0.0)")
+	gasPrice := fs.String("gas-price", "0", "Gas price for refunds paid by the Safe (0 disables refunds)")
+	gasToken := fs.String("gas-token", "", "Token refunds are paid in (default ether)")
+	refundReceiver := fs.String("refund-receiver", "", "Receiver of refunds (default the executor)")
+	nonce := fs.String("nonce", "", "Safe nonce (fetched via -rpc when omitted)")
+	chainID := addChainFlag(fs, 1, "Chain ID (fetched via -rpc when omitted)")
+	version := fs.String("safe-version", "1.3.0", "Safe contract version, which selects the EIP-712 domain")
+	txService := fs.String("tx-service", "", "Safe Transaction Service URL, such as https://safe-transaction-mainnet.safe.global")
+	propose := fs.Bool("propose", false, "Propose the transaction to -tx-service with this signature")
+	confirm := fs.Bool("confirm", false, "Add this signature to the transaction already proposed to -tx-service")
+	rpcOpts := addRPCFlags(fs)
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if !common.IsHexAddress(*safeFlag) {
+		return fmt.Errorf("invalid safe address %q", *safeFlag)
+	}
+	if !common.IsHexAddress(*to) {
+		return fmt.Errorf("invalid to address %q", *to)
+	}
+	if *propose && *confirm {
+		return errors.New("propose and confirm are mutually exclusive")
+	}
+	if (*propose || *confirm) && *txService == "" {
+		return errors.New("propose and confirm require tx-service")
+	}
+	if *operation > txbuilder.SafeOperationDelegateCall {
+		return fmt.Errorf("invalid operation %d", *operation)
+	}
+	safe := common.HexToAddress(*safeFlag)
+	tx := &txbuilder.SafeTx{To: common.HexToAddress(*to), Operation: uint8(*operation)}
+	var err error
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{{"value", *value, &tx.Value}, {"safe-tx-gas", *safeTxGas, &tx.SafeTxGas}, {"base-gas", *baseGas, &tx.BaseGas}, {"gas-price", *gasPrice, &tx.GasPrice}} {
+		if *f.dst, err = txbuilder.ParseWei(f.name, f.value); err != nil {
+			return err
+		}
+	}
+	if tx.Data, err = txbuilder.ParseCalldata(*data); err != nil {
+		return err
+	}
+	if tx.GasToken, err = parseOptionalAddress("gas-token", *gasToken); err != nil {
+		return err
+	}
+	if tx.RefundReceiver, err = parseOptionalAddress("refund-receiver", *refundReceiver); err != nil {
+		return err
+	}
+
+	pol, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	chain := chainID.big()
+	if client != nil {
+		var want *big.Int
+		if flagWasSet(fs, "chain") {
+			want = chain
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return err
+		}
+	}
+	if *nonce != "" {
+		if tx.Nonce, err = txbuilder.ParseWei("nonce", *nonce); err != nil {
+			return err
+		}
+	} else {
+		if client == nil {
+			return errors.New("nonce is required offline")
+		}
+		if tx.Nonce, err = txbuilder.FetchSafeNonce(ctx, client, safe); err != nil {
+			return err
+		}
+	}
+
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	pol = pol.ForSender(key.Address())
+	typed := tx.TypedData(safe, chain, *version)
+	d := new(policy.Decision)
+	if err := pol.CheckSafeTx(ctx, safe, tx, chain, time.Now(), *overrideReason, d); err != nil {
+		return audit.Denied(signer.AuditSafe, typed, nil, fmt.Errorf("policy check failed: %v", err))
+	}
+	hash, sig, err := signTypedData(ctx, key, typed)
+	if err != nil {
+		err = fmt.Errorf("failed to sign safe transaction: %v", err)
+	}
+	if err := audit.Signed(signer.AuditSafe, key.Address(), typed, hash, err); err != nil {
+		return err
+	}
+	sigOut := newSignatureOutput(sig)
+	safeTxHash := common.BytesToHash(hash)
+
+	service := &safeTxService{url: *txService, client: &http.Client{Timeout: safeTxServiceTimeout}}
+	switch {
+	case *propose:
+		if err := service.propose(ctx, safe, tx, safeTxHash, key.Address(), sigOut.Signature); err != nil {
+			return fmt.Errorf("failed to propose safe transaction: %v", err)
+		}
+	case *confirm:
+		if err := service.confirm(ctx, safeTxHash, sigOut.Signature); err != nil {
+			return fmt.Errorf("failed to confirm safe transaction: %v", err)
+		}
+	}
+	out := struct {
+		Safe       string `json:"safe"`
+		Nonce      string `json:"nonce"`
+		SafeTxHash string `json:"safeTxHash"`
+		Signer     string `json:"signer"`
+		Proposed   bool   `json:"proposed"`
+		Confirmed  bool   `json:"confirmed"`
+		signatureOutput
+	}{safe.Hex(), tx.Nonce.String(), safeTxHash.Hex(), key.Address().Hex(), *propose, *confirm, sigOut}
+	output.print(out, func() {
+		fmt.Println("Safe:", out.Safe)
+		fmt.Println("Nonce:", out.Nonce)
+		fmt.Println("SafeTx hash:", out.SafeTxHash)
+		out.printText()
+		switch {
+		case *propose:
+			fmt.Println("Proposed to", *txService)
+		case *confirm:
+			fmt.Println("Confirmation sent to", *txService)
+		}
+	})
+	return nil
+}
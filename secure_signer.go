@@ -1,120 +1,126 @@
 package main
 
 import (
-	"crypto/ecdsa"
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"math/big"
+	"log/slog"
 	"os"
-	"strings"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
 )
 
-type Policy struct {
-	MaxAmountWei *big.Int   `json:"max_amount_wei"`
-	Whitelist    []string   `json:"whitelist"`
+// command is a single CLI subcommand with its own flag set.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
 }
 
-func loadPrivateKey(hexKey string) (*ecdsa.PrivateKey, error) {
-	return crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+var commands = []command{
+	{"sign", "Build, policy-check and sign a transaction", runSign},
+	{"sign-batch", "Policy-check and sign a file of transfers with sequential nonces", runSignBatch},
+	{"prepare", "Build an unsigned transaction file for offline signing", runPrepare},
+	{"replace", "Speed up a pending transaction by re-signing it with higher fees", runReplace},
+	{"cancel", "Cancel a pending transaction with a zero-value self-send", runCancel},
+	{"sign-typed-data", "Sign EIP-712 typed data", runSignTypedData},
+	{"sign-message", "Sign an EIP-191 personal message", runSignMessage},
+	{"sign-permit", "Sign an EIP-2612 token permit", runSignPermit},
+	{"sign-authorization", "Sign an EIP-7702 authorization delegating the account to a contract", runSignAuthorization},
+	{"sign-userop", "Sign an ERC-4337 UserOperation and optionally submit it to a bundler", runSignUserOp},
+	{"sign-zksync", "Build, policy-check and sign a zkSync Era EIP-712 transaction", runSignZkSync},
+	{"sign-safe", "Sign a Safe multisig transaction and optionally propose it to the Safe Transaction Service", runSignSafe},
+	{"approve", "Approve a pending transaction request", runApprove},
+	{"ack", "Acknowledge a high-value transaction alert", runAck},
+	{"delayed", "List, release or cancel time-delayed transactions", runDelayed},
+	{"freeze", "Refuse all signing until a quorum of approvers unfreezes it", runFreeze},
+	{"unfreeze", "Approve lifting a freeze", runUnfreeze},
+	{"unlock", "Unlock or lock the key of a daemon started with serve -unlock-ttl", runUnlock},
+	{"verify-message", "Verify an EIP-191 personal message signature", runVerifyMessage},
+	{"verify", "Recover the signer of a raw transaction", runVerify},
+	{"decode", "Decode a raw transaction", runDecode},
+	{"policy", "Lint a policy file or test it against example transactions", runPolicy},
+	{"audit", "Verify or export the signing audit log", runAudit},
+	{"keygen", "Generate a new private key", runKeygen},
+	{"keys", "List, add, remove or label the keys of the keyring", runKeys},
+	{"contacts", "List, add or remove the named recipients of the address book", runContacts},
+	{"address", "Print the address of a key", runAddress},
+	{"addresses", "List addresses derived from a mnemonic", runAddresses},
+	{"devices", "List attached hardware wallets", runDevices},
+	{"nonce", "Show or reset the locally tracked nonce of an account", runNonce},
+	{"broadcast", "Submit a raw transaction to an RPC endpoint", runBroadcast},
+	{"serve", "Run a JSON-RPC signing daemon", runServe},
 }
 
-func loadPolicy(file string) (*Policy, error) {
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
-	}
-	var policy Policy
-	if err := json.Unmarshal(data, &policy); err != nil {
-		return nil, err
+// withHint adds to err how to resolve it from the command line, for the
+// errors the packages report without naming commands or flags.
+func withHint(err error) error {
+	var (
+		replay *signer.ReplayError
+		held   *signer.HeldError
+	)
+	switch {
+	case errors.As(err, &replay):
+		return fmt.Errorf("%w; pass -allow-replace to sign a replacement", err)
+	case errors.As(err, &held):
+		return fmt.Errorf("%w; release it with the delayed command", err)
+	case errors.Is(err, signer.ErrLocked):
+		return fmt.Errorf("%w; unlock it with secure-signer unlock", err)
 	}
-	return &policy, nil
+	return err
 }
 
-func checkPolicy(policy *Policy, to common.Address, amount *big.Int) error {
-	// Check whitelist
-	allowed := false
-	for _, addr := range policy.Whitelist {
-		if strings.EqualFold(addr, to.Hex()) {
-			allowed = true
-			break
-		}
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: secure-signer [-log-level level] [-log-format text|json] [-log-file file] [-config file] [-profile name] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", c.name, c.summary)
 	}
-	if !allowed {
-		return errors.New("recipient not in whitelist")
-	}
-	// Check amount
-	if amount.Cmp(policy.MaxAmountWei) > 0 {
-		return errors.New("amount exceeds max policy limit")
-	}
-	return nil
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'secure-signer <command> -h' for command flags.")
 }
 
 func main() {
-	var privKeyHex string
-	var toAddr string
-	var amountWeiStr string
-	var nonce uint64
-	var chainID int64
-	var policyFile string
-
-	flag.StringVar(&privKeyHex, "key", "", "Private key in hex")
-	flag.StringVar(&toAddr, "to", "", "Recipient address")
-	flag.StringVar(&amountWeiStr, "amount", "0", "Amount in wei")
-	flag.Uint64Var(&nonce, "nonce", 0, "Account nonce")
-	flag.Int64Var(&chainID, "chain", 1, "Chain ID (default Ethereum mainnet)")
-	flag.StringVar(&policyFile, "policy", "policy.json", "Path to policy JSON file")
-	flag.Parse()
-
-	if privKeyHex == "" || toAddr == "" {
-		log.Fatal("key and to are required")
+	fs := flag.NewFlagSet("secure-signer", flag.ExitOnError)
+	fs.Usage = usage
+	logOpts := addLogFlags(fs)
+	config := addConfigFlags(fs, configFlags{file: os.Getenv(envConfig), profile: os.Getenv(envProfile)})
+	fs.Parse(os.Args[1:])
+	globalConfig = *config
+	args := fs.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
 	}
-
-	privateKey, err := loadPrivateKey(privKeyHex)
-	if err != nil {
-		log.Fatalf("failed to load private key: %v", err)
+	name := args[0]
+	if name == "help" {
+		usage()
+		return
 	}
-
-	policy, err := loadPolicy(policyFile)
+	closeLog, err := logOpts.setup()
 	if err != nil {
-		log.Fatalf("failed to load policy: %v", err)
-	}
-
-	amountWei, ok := new(big.Int).SetString(amountWeiStr, 10)
-	if !ok {
-		log.Fatal("invalid amount")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-
-	to := common.HexToAddress(toAddr)
-
-	// Policy checks
-	if err := checkPolicy(policy, to, amountWei); err != nil {
-		log.Fatalf("policy check failed: %v", err)
-	}
-
-	// Create transaction
-	tx := types.NewTransaction(nonce, to, amountWei, 21000, big.NewInt(1_000_000_000), nil)
-
-	// Sign transaction
-	signer := types.LatestSignerForChainID(big.NewInt(chainID))
-	signedTx, err := types.SignTx(tx, signer, privateKey)
-	if err != nil {
-		log.Fatalf("failed to sign tx: %v", err)
-	}
-
-	// Serialize
-	rawTxBytes, err := signedTx.MarshalBinary()
-	if err != nil {
-		log.Fatalf("failed to serialize tx: %v", err)
+	for _, c := range commands {
+		if c.name == name {
+			err := c.run(args[1:])
+			if err != nil {
+				err = withHint(err)
+				slog.Error("command failed", "command", name, "err", err)
+				if logOpts.file != "" {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+			closeLog()
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
 	}
-
-	fmt.Println("RawTxHex:", hex.EncodeToString(rawTxBytes))
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
 }
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/tracing"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// signTxArgs are the eth_signTransaction parameters.
+type signTxArgs struct {
+	From                 common.MixedcaseAddress `json:"from"`
+	To                   *common.Address         `json:"to"`
+	Gas                  *hexutil.Uint64         `json:"gas"`
+	GasPrice             *hexutil.Big            `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big            `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big            `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big            `json:"value"`
+	Nonce                *hexutil.Uint64         `json:"nonce"`
+	Data                 *hexutil.Bytes          `json:"data"`
+	Input                *hexutil.Bytes          `json:"input"`
+	ChainID              *hexutil.Big            `json:"chainId"`
+	AccessList           *types.AccessList       `json:"accessList"`
+	// AuthorizationList makes the transaction an EIP-7702 set-code
+	// transaction; it requires maxFeePerGas.
+	AuthorizationList []types.SetCodeAuthorization `json:"authorizationList"`
+}
+
+// signTxResult is the eth_signTransaction response.
+type signTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// ethService implements the eth_ namespace served by the daemon. Every
+// signing request goes through the same policy checks as the CLI.
+type ethService struct {
+	*signer.Engine
+	chainID  *big.Int
+	nonces   *signer.NonceManager
+	metrics  *signerMetrics
+	webhooks *webhookNotifier
+}
+
+// observe records the outcome of a signing request of kind that started at
+// start in the metrics and webhooks. rule names the policy rule that denied
+// it, if any.
+func (s *ethService) observe(kind string, start time.Time, rule, txHash string, err error) {
+	s.metrics.observe(kind, s.Key.Address(), start, rule, err)
+	ev := webhookEvent{Event: eventSigned, Kind: kind, Account: s.Key.Address().Hex(), TxHash: txHash}
+	if err != nil {
+		ev.Event, ev.Error = eventError, err.Error()
+		if rule != "" {
+			ev.Event, ev.Rule = eventDenied, rule
+		}
+	}
+	s.webhooks.notify(ev)
+}
+
+// checkFrom returns an error unless from is the daemon's account and the
+// caller may sign with it.
+func (s *ethService) checkFrom(ctx context.Context, from common.Address) error {
+	if from != s.Key.Address() {
+		return fmt.Errorf("unknown account %s", from.Hex())
+	}
+	return authorize(ctx, opSign, from)
+}
+
+// Accounts implements eth_accounts.
+func (s *ethService) Accounts(ctx context.Context) ([]common.Address, error) {
+	if err := authorize(ctx, opView, s.Key.Address()); err != nil {
+		return nil, err
+	}
+	return []common.Address{s.Key.Address()}, nil
+}
+
+// SignTransaction implements eth_signTransaction.
+func (s *ethService) SignTransaction(ctx context.Context, args signTxArgs) (res *signTxResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "eth_signTransaction", attribute.String("secure_signer.from", args.From.Address().Hex()))
+	defer func() {
+		if res != nil {
+			span.SetAttributes(attribute.String("secure_signer.tx_hash", res.Tx.Hash().Hex()), attribute.Int64("secure_signer.nonce", int64(res.Tx.Nonce())))
+		}
+		tracing.EndSpan(span, err)
+	}()
+	start := time.Now()
+	if err := s.checkFrom(ctx, args.From.Address()); err != nil {
+		return nil, err
+	}
+	if args.To == nil {
+		return nil, errors.New("to is required")
+	}
+	if args.Gas == nil {
+		return nil, errors.New("gas is required")
+	}
+	chainID := s.chainID
+	if args.ChainID != nil {
+		chainID = args.ChainID.ToInt()
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	if err := checkGrants(ctx, s.Key.Address(), chainID, value); err != nil {
+		return nil, err
+	}
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+
+	fees := txbuilder.FeeParams{TxType: txbuilder.TxTypeLegacy, AuthList: args.AuthorizationList}
+	if args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil {
+		fees.TxType = txbuilder.TxTypeDynamic
+		if len(args.AuthorizationList) > 0 {
+			fees.TxType = txbuilder.TxTypeSetCode
+		}
+		fees.MaxFee = (*big.Int)(args.MaxFeePerGas)
+		fees.MaxPriorityFee = (*big.Int)(args.MaxPriorityFeePerGas)
+	} else if args.GasPrice != nil {
+		fees.GasPrice = args.GasPrice.ToInt()
+		if args.AccessList != nil {
+			fees.TxType = txbuilder.TxTypeAccessList
+		}
+	} else {
+		return nil, errors.New("gasPrice or maxFeePerGas is required")
+	}
+
+	if args.AccessList != nil {
+		fees.AccessList = *args.AccessList
+	}
+	// A retry with the Idempotency-Key of an earlier request gets the
+	// transaction signed then, without taking another nonce.
+	idemKey := signer.IdempotencyKey(ctx)
+	complete := func(*types.Transaction, error) {}
+	if idemKey != "" {
+		request := signer.IdempotencyRequest(chainID, args.To, value, data)
+		prev, found, err := s.ClaimIdempotent(ctx, idemKey, request)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			raw, err := prev.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize tx: %v", err)
+			}
+			return &signTxResult{Raw: raw, Tx: prev}, nil
+		}
+		complete = func(signedTx *types.Transaction, err error) {
+			s.CompleteIdempotent(ctx, idemKey, request, signedTx, err)
+		}
+	}
+	var nonce uint64
+	release := func() {}
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else {
+		var err error
+		if nonce, err = s.nonces.Next(ctx, chainID); err != nil {
+			complete(nil, err)
+			return nil, err
+		}
+		release = func() { s.nonces.Release(chainID, nonce) }
+	}
+	tx, err := txbuilder.Build(nonce, *args.To, value, data, uint64(*args.Gas), chainID, fees)
+	if err != nil {
+		release()
+		complete(nil, err)
+		return nil, fmt.Errorf("failed to build tx: %v", err)
+	}
+	signedTx, d, err := s.SignTx(ctx, tx, chainID)
+	complete(signedTx, err)
+	if err != nil {
+		s.observe(signer.AuditTransaction, start, policy.DeniedRule(d, err), "", err)
+		if !signer.IsHeld(err) {
+			release()
+		}
+		slog.Warn("eth_signTransaction denied", "err", err, "decision", d)
+		return nil, err
+	}
+	s.observe(signer.AuditTransaction, start, "", signedTx.Hash().Hex(), nil)
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize tx: %v", err)
+	}
+	slog.Info("eth_signTransaction signed", "tx_hash", signedTx.Hash().Hex())
+	return &signTxResult{Raw: raw, Tx: signedTx}, nil
+}
+
+// Sign implements eth_sign, signing data with the EIP-191 personal message
+// prefix.
+func (s *ethService) Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if err := s.checkFrom(ctx, addr.Address()); err != nil {
+		return nil, err
+	}
+	if err := checkGrants(ctx, s.Key.Address(), nil, nil); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	request := messageRequest(data)
+	hash := accounts.TextHash(data)
+	if !s.CurrentPolicy().AllowEthSign {
+		err := errors.New("policy check failed: eth_sign is not allowed")
+		s.observe(signer.AuditMessage, start, "allow_eth_sign", "", err)
+		return nil, s.Audit.Denied(signer.AuditMessage, request, hash, err)
+	}
+	if err := s.CurrentPolicy().CheckSigningWindow(time.Now(), ""); err != nil {
+		err = fmt.Errorf("policy check failed: %v", err)
+		s.observe(signer.AuditMessage, start, "signing_window", "", err)
+		return nil, s.Audit.Denied(signer.AuditMessage, request, hash, err)
+	}
+	sig, err := keys.SignPersonalMessage(ctx, s.Key, data)
+	err = s.Audit.Signed(signer.AuditMessage, s.Key.Address(), request, hash, err)
+	s.observe(signer.AuditMessage, start, "", "", err)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// serviceConfig configures one signing service: the daemon's own, from
+// the command line, or a tenant's, from the tenants file.
+type serviceConfig struct {
+	keys         keySource
+	unlockTTL    time.Duration
+	policy       *policyFlags
+	storePath    string
+	approvalsDir string
+	alertsConfig string
+	webhooksFile string
+	postSignFile string
+	audit        auditFlags
+	allowReplace bool
+}
+
+// serviceShared holds what every service of a daemon shares.
+type serviceShared struct {
+	chainID *big.Int
+	client  *ethclient.Client
+	watch   bool
+	metrics bool
+}
+
+// startService opens the key, policy, store and audit log of cfg and
+// returns the service with a handler serving its JSON-RPC, Web3Signer,
+// approval, session and metrics endpoints. close releases the store and
+// stops the background work of the service.
+func startService(ctx context.Context, cfg *serviceConfig, shared serviceShared) (service *ethService, handler http.Handler, close func(), err error) {
+	store, err := signer.OpenStore(cfg.storePath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open store: %v", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	rpcServer := rpc.NewServer()
+	close = func() {
+		rpcServer.Stop()
+		cancel()
+		if service != nil {
+			service.PostSign.Wait()
+			service.Audit.Close()
+		}
+		store.Close()
+	}
+	defer func() {
+		if err != nil {
+			close()
+		}
+	}()
+
+	var session *signer.SessionKey
+	var key keys.Backend
+	if cfg.unlockTTL > 0 {
+		if session, err = openSessionKey(cfg.keys, cfg.unlockTTL); err != nil {
+			return nil, nil, nil, err
+		}
+		key = signer.GuardFrozen(session, store)
+	} else if key, err = openKeyBackend(ctx, cfg.keys, store); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load private key: %v", err)
+	}
+	pol, err := cfg.policy.load()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load policy: %v", err)
+	}
+
+	service = &ethService{
+		Engine:  signer.New(pol, key, store),
+		chainID: shared.chainID,
+		// The node, when given, is only used to reconcile the nonces handed
+		// out to requests that omit one.
+		nonces: &signer.NonceManager{Store: store, Client: shared.client, From: key.Address()},
+	}
+	service.Session = session
+	service.ApprovalsDir = cfg.approvalsDir
+	service.AllowReplace = cfg.allowReplace
+	service.ENS = policy.NewENSResolver(shared.client)
+	service.L1Fees = signer.NewL1FeeOracle(shared.client)
+	service.TokenDecimals = signer.NewTokenDecimals(shared.client)
+	if service.Alerts, err = signer.LoadAlerter(cfg.alertsConfig); err != nil {
+		return nil, nil, nil, err
+	}
+	if shared.metrics {
+		service.metrics = newSignerMetrics()
+	}
+	if cfg.webhooksFile != "" {
+		if service.webhooks, err = loadWebhooks(ctx, cfg.webhooksFile); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if cfg.postSignFile != "" {
+		if service.PostSign, err = signer.LoadPostSignHooks(cfg.postSignFile); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if service.Audit, err = cfg.audit.open(store); err != nil {
+		return nil, nil, nil, err
+	}
+	if signer.IsSharedStore(cfg.storePath) && cfg.audit.path != "" && cfg.audit.path != auditInStore {
+		slog.Warn("the state store is shared but the audit log is a local file, so each replica records a chain of its own; use -audit-log store for one chain", "audit_log", cfg.audit.path)
+	}
+	if shared.watch {
+		if err = watchPolicy(ctx, cfg.policy, service.Engine, service.webhooks); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to watch policy: %v", err)
+		}
+	}
+	if err = rpcServer.RegisterName("eth", service); err != nil {
+		return nil, nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	service.registerWeb3Signer(mux)
+	service.registerApprovals(mux)
+	if session != nil {
+		registerSession(mux, session)
+	}
+	if service.metrics != nil {
+		mux.Handle("GET /metrics", authorized(opView, key.Address(), service.metrics.handler().ServeHTTP))
+	}
+	mux.Handle("/", signer.WithIdempotencyKey(rpcServer))
+	return service, mux, close, nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg := &serviceConfig{keys: *addKeyFlags(fs)}
+	listen := fs.String("listen", "127.0.0.1:8550", "HTTP listen address")
+	grpcListen := fs.String("grpc-listen", "", "Also serve the gRPC Signer service (signer.proto) on this address, with the TLS and client authentication of -listen")
+	chainID := addChainFlag(fs, 1, "Chain ID used when a request omits chainId")
+	cfg.policy = addPolicyFlags(fs)
+	fs.StringVar(&cfg.storePath, "store", signer.DefaultStore, "State store for cumulative policy limits, approvals, time-delayed transactions and the freeze switch: a SQLite database, a .json file, or a postgres:// or redis:// URL shared by the replicas of the daemon")
+	fs.StringVar(&cfg.approvalsDir, "approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements")
+	alertsConfig := addAlertFlags(fs)
+	postSignHooks := addPostSignHooksFlag(fs)
+	allowReplace := addAllowReplaceFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	watch := fs.Bool("watch-policy", true, "Reload the policy file when it changes")
+	metrics := fs.Bool("metrics", true, "Serve Prometheus metrics on /metrics")
+	fs.StringVar(&cfg.webhooksFile, "webhooks", "", "JSON file of webhooks notified of signing outcomes and daemon errors")
+	fs.DurationVar(&cfg.unlockTTL, "unlock-ttl", 0, "Start with the -keystore key locked and only hold it decrypted for this long after each unlock (see the unlock command)")
+	authOpts := addAuthFlags(fs)
+	drainTimeout := fs.Duration("drain-timeout", 30*time.Second, "On SIGTERM or SIGINT, how long to let in-flight requests finish before exiting")
+	tenantsFile := fs.String("tenants", "", "JSON file of tenants, each served under /t/<name>/ with its own key, policy, store, audit log and clients, instead of a single key")
+	rpcOpts := addRPCFlags(fs)
+	parseFlags(fs, args)
+
+	tlsConfig, auth, err := authOpts.open(*listen, *tenantsFile != "")
+	if err != nil {
+		return err
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), version)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
+	// The node, when given, is shared by every service.
+	client, err := rpcOpts.dial(context.Background())
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	shared := serviceShared{chainID: chainID.big(), client: client, watch: *watch, metrics: *metrics}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var handler http.Handler
+	var served []any
+	txSigner := &grpcSigner{targets: make(map[string]grpcTarget), tenants: *tenantsFile != ""}
+	if *tenantsFile != "" {
+		if err := checkTenantFlags(fs); err != nil {
+			return err
+		}
+		tenants, err := loadTenants(*tenantsFile, authOpts)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		accounts := make(map[common.Address]string)
+		for _, t := range tenants {
+			// Every tenant forwards its audit entries to the same collector.
+			t.service.audit.syslog, t.service.audit.syslogFormat = auditOpts.syslog, auditOpts.syslogFormat
+			service, h, close, err := startService(ctx, &t.service, shared)
+			if err != nil {
+				return fmt.Errorf("tenant %s: %v", t.name, err)
+			}
+			defer close()
+			// Tenants sharing a key would share its nonces.
+			if other, ok := accounts[service.Key.Address()]; ok {
+				return fmt.Errorf("tenant %s: account %s is already used by tenant %s", t.name, service.Key.Address().Hex(), other)
+			}
+			accounts[service.Key.Address()] = t.name
+			txSigner.targets[t.name] = grpcTarget{service: service, auth: t.auth}
+			prefix := "/t/" + t.name
+			mux.Handle(prefix+"/", http.StripPrefix(prefix, t.auth.middleware(h)))
+			slog.Info("serving tenant", "tenant", t.name, "account", service.Key.Address().Hex(), "path", prefix+"/", "policy", service.CurrentPolicy().Digest)
+		}
+		handler, served = mux, []any{"tenants", len(tenants)}
+	} else {
+		cfg.alertsConfig = *alertsConfig
+		cfg.postSignFile = *postSignHooks
+		cfg.allowReplace = *allowReplace
+		cfg.audit = *auditOpts
+		service, h, close, err := startService(ctx, cfg, shared)
+		if err != nil {
+			return err
+		}
+		defer close()
+		txSigner.targets[""] = grpcTarget{service: service, auth: auth}
+		handler, served = auth.middleware(h), []any{"account", service.Key.Address().Hex(), "policy", service.CurrentPolicy().Digest}
+	}
+
+	// The probes answer before authentication, for every tenant at once.
+	top := http.NewServeMux()
+	services := make(map[string]*ethService)
+	for name, t := range txSigner.targets {
+		services[name] = t.service
+	}
+	registerHealth(top, services)
+	// Requests continue the trace of their caller, if any.
+	top.Handle("/", otelhttp.NewHandler(handler, "jsonrpc"))
+
+	var shutdownGRPC func(context.Context)
+	if *grpcListen != "" {
+		if shutdownGRPC, err = serveGRPC(*grpcListen, tlsConfig, txSigner); err != nil {
+			return err
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           top,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			slog.Info("serving", append(served, "listen", "https://"+*listen, "mtls", tlsConfig.ClientCAs != nil)...)
+			errc <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		slog.Info("serving", append(served, "listen", "http://"+*listen)...)
+		errc <- srv.ListenAndServe()
+	}()
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+	select {
+	case err := <-errc:
+		return err
+	case <-signals.Done():
+	}
+	// A second signal exits at once.
+	stopSignals()
+
+	// Stop accepting requests and let the ones in flight finish; the
+	// deferred closes then release the stores and audit logs of the
+	// services. Every audit entry and store update is already synced to
+	// disk when its request returns.
+	slog.Info("shutting down; draining requests", "timeout", *drainTimeout)
+	drain, cancelDrain := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancelDrain()
+	if shutdownGRPC != nil {
+		shutdownGRPC(drain)
+	}
+	if err := srv.Shutdown(drain); err != nil {
+		slog.Warn("drain timed out; abandoning requests in flight", "err", err)
+		srv.Close()
+	}
+	slog.Info("shut down")
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+// openSessionKey returns a locked session for the keystore selected by
+// src, directly or through the keyring.
+func openSessionKey(src keySource, maxTTL time.Duration) (*signer.SessionKey, error) {
+	if src.from != "" {
+		var err error
+		if src, err = resolveKeySource(src); err != nil {
+			return nil, err
+		}
+	}
+	if src.keystoreFile == "" || src.hexKey != "" || src.fromStdin || src.mnemonic || src.mnemonicFile != "" || src.pkcs11Module != "" {
+		return nil, errors.New("unlock-ttl requires a -keystore key")
+	}
+	return signer.NewSessionKey(src.keystoreFile, maxTTL)
+}
+
+// registerSession serves the unlock API of s: GET /session reports whether
+// the key is unlocked, POST /session/unlock decrypts it with the passphrase in
+// the request for a TTL and POST /session/lock drops it at once. The
+// passphrase travels in the request, so the daemon must only listen where
+// that is safe.
+func registerSession(mux *http.ServeMux, s *signer.SessionKey) {
+	mux.HandleFunc("GET /session", authorized(opView, s.Address(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	}))
+	mux.HandleFunc("POST /session/unlock", authorized(opAdmin, s.Address(), func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Passphrase string `json:"passphrase"`
+			TTL        string `json:"ttl"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "Bad request format", http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+		}
+		expires, err := s.Unlock(req.Passphrase, ttl)
+		if err != nil {
+			slog.Warn("unlock failed", "account", s.Address().Hex(), "remote", r.RemoteAddr, "err", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		slog.Info("account unlocked", "account", s.Address().Hex(), "remote", r.RemoteAddr, "expires", expires)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	}))
+	mux.HandleFunc("POST /session/lock", authorized(opAdmin, s.Address(), func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	}))
+}
+
+func runUnlock(args []string) error {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	daemonOpts := addDaemonFlags(fs, "URL of the daemon started with serve -unlock-ttl")
+	passphraseFile := fs.String("passphrase-file", "", "File holding the keystore passphrase (prompted when omitted)")
+	ttl := fs.Duration("ttl", 0, "How long the key stays unlocked (default and maximum: the daemon's -unlock-ttl)")
+	lock := fs.Bool("lock", false, "Lock the key now instead of unlocking it")
+	status := fs.Bool("status", false, "Only report whether the key is unlocked")
+	parseFlags(fs, args)
+
+	daemon, err := daemonOpts.open()
+	if err != nil {
+		return err
+	}
+	var resp *http.Response
+	switch {
+	case *status:
+		resp, err = daemon.do(http.MethodGet, "/session", nil)
+	case *lock:
+		resp, err = daemon.do(http.MethodPost, "/session/lock", nil)
+	default:
+		passphrase, perr := readPassphrase(*passphraseFile, "Keystore passphrase: ")
+		if perr != nil {
+			return perr
+		}
+		req := struct {
+			Passphrase string `json:"passphrase"`
+			TTL        string `json:"ttl,omitempty"`
+		}{Passphrase: passphrase}
+		if *ttl > 0 {
+			req.TTL = ttl.String()
+		}
+		body, merr := json.Marshal(req)
+		if merr != nil {
+			return merr
+		}
+		resp, err = daemon.do(http.MethodPost, "/session/unlock", bytes.NewReader(body))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon refused: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var st signer.SessionStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("invalid daemon response: %v", err)
+	}
+	fmt.Println("Account:", st.Account)
+	if !st.Unlocked {
+		fmt.Println("Status: locked")
+		return nil
+	}
+	fmt.Println("Status: unlocked")
+	fmt.Println("ExpiresAt:", st.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
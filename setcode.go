@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// setCodeMagic prefixes the RLP of an EIP-7702 authorization when it is
+// hashed for signing.
+const setCodeMagic = 0x05
+
+// authorizationHash is the hash an EIP-7702 authorization is signed over.
+func authorizationHash(auth types.SetCodeAuthorization) (common.Hash, error) {
+	enc, err := rlp.EncodeToBytes([]any{&auth.ChainID, auth.Address, auth.Nonce})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte{setCodeMagic}, enc), nil
+}
+
+// signAuthorization signs auth with key and checks that the signature
+// recovers to it.
+func signAuthorization(ctx context.Context, key keys.Backend, auth types.SetCodeAuthorization) (types.SetCodeAuthorization, error) {
+	hash, err := authorizationHash(auth)
+	if err != nil {
+		return auth, err
+	}
+	sig, err := key.SignHash(ctx, hash[:])
+	if err != nil {
+		return auth, err
+	}
+	auth.R.SetBytes(sig[:32])
+	auth.S.SetBytes(sig[32:64])
+	auth.V = sig[crypto.RecoveryIDOffset]
+	if authority, err := auth.Authority(); err != nil || authority != key.Address() {
+		return auth, errors.New("signature does not recover to the signing key")
+	}
+	return auth, nil
+}
+
+// authorizationRequest is the audit log form of an authorization.
+func authorizationRequest(auth types.SetCodeAuthorization) any {
+	return struct {
+		ChainID string         `json:"chainId"`
+		Address common.Address `json:"address"`
+		Nonce   uint64         `json:"nonce"`
+	}{auth.ChainID.Dec(), auth.Address, auth.Nonce}
+}
+
+// readAuthorizations reads signed authorizations from JSON files, each
+// holding one authorization as written by sign-authorization or a list of
+// them.
+func readAuthorizations(files []string) ([]types.SetCodeAuthorization, error) {
+	var auths []types.SetCodeAuthorization
+	for _, file := range files {
+		var list []types.SetCodeAuthorization
+		if err := jsonfile.Read(file, &list); err != nil {
+			var auth types.SetCodeAuthorization
+			if err := jsonfile.Read(file, &auth); err != nil {
+				return nil, fmt.Errorf("failed to read authorization: %v", err)
+			}
+			list = []types.SetCodeAuthorization{auth}
+		}
+		auths = append(auths, list...)
+	}
+	return auths, nil
+}
+
+func runSignAuthorization(args []string) error {
+	fs := flag.NewFlagSet("sign-authorization", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	delegate := fs.String("delegate", "", "Contract to delegate the account's code to (the zero address clears the delegation)")
+	chainID := addChainFlag(fs, 0, "Chain ID the authorization is valid on (fetched via -rpc when omitted; 0 authorizes every chain)")
+	nonce := fs.Uint64("nonce", 0, "Account nonce the authorization is valid at (fetched via -rpc when omitted)")
+	self := fs.Bool("self", false, "The account sends the set-code transaction itself, so the fetched nonce is incremented")
+	rpcOpts := addRPCFlags(fs)
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	out := fs.String("out", "", "Write the signed authorization to this JSON file")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if !common.IsHexAddress(*delegate) {
+		return fmt.Errorf("invalid delegate address %q", *delegate)
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy = policy.ForSender(key.Address())
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	auth := types.SetCodeAuthorization{
+		ChainID: *uint256.NewInt(chainID.id),
+		Address: common.HexToAddress(*delegate),
+		Nonce:   *nonce,
+	}
+	if !flagWasSet(fs, "chain") {
+		if client == nil {
+			return errors.New("chain is required offline")
+		}
+		id, err := txbuilder.FetchChainID(ctx, client, nil)
+		if err != nil {
+			return err
+		}
+		auth.ChainID = *uint256.MustFromBig(id)
+	}
+	if !flagWasSet(fs, "nonce") {
+		if client == nil {
+			return errors.New("nonce is required offline")
+		}
+		if auth.Nonce, err = txbuilder.FetchNonce(ctx, client, key.Address()); err != nil {
+			return err
+		}
+		if *self {
+			auth.Nonce++
+		}
+	}
+
+	request := authorizationRequest(auth)
+	hash, err := authorizationHash(auth)
+	if err != nil {
+		return err
+	}
+	if !auth.ChainID.IsZero() {
+		if err := policy.CheckChainID(auth.ChainID.ToBig()); err != nil {
+			return audit.Denied(signer.AuditAuthorization, request, hash[:], fmt.Errorf("policy check failed: %v", err))
+		}
+	}
+	if err := policy.CheckDelegate(auth); err != nil {
+		return audit.Denied(signer.AuditAuthorization, request, hash[:], fmt.Errorf("policy check failed: %v", err))
+	}
+	if err := policy.CheckSigningWindow(time.Now(), *overrideReason); err != nil {
+		return audit.Denied(signer.AuditAuthorization, request, hash[:], fmt.Errorf("policy check failed: %v", err))
+	}
+	signed, err := signAuthorization(ctx, key, auth)
+	if err != nil {
+		err = fmt.Errorf("failed to sign authorization: %v", err)
+	}
+	if err := audit.Signed(signer.AuditAuthorization, key.Address(), request, hash[:], err); err != nil {
+		return err
+	}
+	if *out != "" {
+		if err := jsonfile.Write(*out, signed); err != nil {
+			return fmt.Errorf("failed to write authorization: %v", err)
+		}
+	}
+	output.print(signed, func() {
+		fmt.Println("Authority:", key.Address().Hex())
+		fmt.Println("Delegate:", signed.Address.Hex())
+		fmt.Println("Chain ID:", signed.ChainID.Dec())
+		fmt.Println("Nonce:", signed.Nonce)
+		fmt.Println("Signing hash:", hash.Hex())
+		if *out != "" {
+			fmt.Println("Written to", *out)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	txOpts := addTxFlags(fs)
+	unsignedFile := fs.String("unsigned-file", "", "Sign the transaction in this file, written by prepare, instead of building one from flags")
+	signedFile := fs.String("signed-file", "", "Also write the signed transaction to this file, for broadcast -signed-file")
+	qr := addQRFlags(fs, "signed transaction file")
+	policyOpts := addPolicyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store for cumulative policy limits, approvals, time-delayed transactions and the freeze switch")
+	overrideReason := addOverrideFlag(fs)
+	confirmRecipient := addNewRecipientFlag(fs)
+	allowReplace := addAllowReplaceFlag(fs)
+	confirm := addConfirmFlag(fs)
+	explain := fs.Bool("explain", false, "Print the policy decision as JSON to stderr")
+	approvalsDir := fs.String("approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements")
+	alertsConfig := addAlertFlags(fs)
+	postSignHooks := addPostSignHooksFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	rpcOpts := addRPCFlags(fs)
+	simulate := fs.String("simulate", simulateOff, "Simulate before signing: strict (refuse on revert), warn or off; strict by default with -prestate")
+	prestateFile := fs.String("prestate", "", "Simulate offline against this state snapshot (genesis alloc format) instead of -rpc")
+	send := fs.Bool("send", false, "Broadcast the signed transaction via -rpc")
+	requestID := fs.String("request-id", "", "Idempotency key: repeating the same request with the same id returns the transaction signed the first time instead of signing again with a new nonce")
+	sendOpts := addBroadcastFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *unsignedFile != "" && txOpts.anySet() {
+		return errors.New("unsigned-file and transaction flags are mutually exclusive")
+	}
+	if *unsignedFile == "" && txOpts.to == "" {
+		return errors.New("to is required")
+	}
+	if *send && (rpcOpts.url == "" || rpcOpts.offline) {
+		return errors.New("send requires rpc")
+	}
+
+	ctx := context.Background()
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+
+	pol, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	var (
+		tx       *types.Transaction
+		chain    *big.Int
+		signedTx *types.Transaction
+		release  = func() {}
+	)
+	if *unsignedFile != "" {
+		if tx, chain, err = readUnsignedTx(*unsignedFile, key.Address()); err != nil {
+			return err
+		}
+		if client != nil {
+			if _, err := txbuilder.FetchChainID(ctx, client, chain); err != nil {
+				return err
+			}
+		}
+	} else {
+		nonces := &signer.NonceManager{Store: store, Client: client, From: key.Address(), OnGap: warnNonceGap}
+		defer func() {
+			if signedTx == nil {
+				release()
+			}
+		}()
+		if err := txOpts.resolveTo(ctx, client, pol.ForSender(key.Address())); err != nil {
+			return err
+		}
+		tx, chain, err = txOpts.build(ctx, client, key.Address(), pol.Fees, func(chainID *big.Int) (uint64, error) {
+			nonce, err := nonces.Next(ctx, chainID)
+			if err == nil {
+				release = func() { nonces.Release(chainID, nonce) }
+			}
+			return nonce, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	eng := signer.New(pol, key, store)
+	eng.ENS = policy.NewENSResolver(client)
+	eng.L1Fees = signer.NewL1FeeOracle(client)
+	eng.TokenDecimals = signer.NewTokenDecimals(client)
+	eng.OverrideReason = *overrideReason
+	eng.ConfirmRecipient = confirmRecipient
+	eng.AllowReplace = *allowReplace
+	if *confirm {
+		eng.Confirm = confirmTx
+	}
+	eng.ApprovalsDir = *approvalsDir
+	if eng.Alerts, err = signer.LoadAlerter(*alertsConfig); err != nil {
+		return err
+	}
+	if *postSignHooks != "" {
+		if eng.PostSign, err = signer.LoadPostSignHooks(*postSignHooks); err != nil {
+			return err
+		}
+		defer eng.PostSign.Wait()
+	}
+	if eng.Audit, err = auditOpts.open(store); err != nil {
+		return err
+	}
+
+	var (
+		d      *policy.Decision
+		replay bool
+	)
+	complete := func(*types.Transaction, error) {}
+	if *requestID != "" {
+		request := signer.IdempotencyRequest(chain, tx.To(), tx.Value(), tx.Data())
+		prev, found, err := eng.ClaimIdempotent(ctx, *requestID, request)
+		if found && prev != nil {
+			// The nonce taken for this attempt is not needed.
+			release()
+			signedTx, replay = prev, true
+		}
+		if err != nil {
+			return err
+		}
+		complete = func(signedTx *types.Transaction, err error) {
+			eng.CompleteIdempotent(ctx, *requestID, request, signedTx, err)
+		}
+	}
+	if !replay {
+		if *prestateFile != "" {
+			mode := *simulate
+			if !flagWasSet(fs, "simulate") {
+				mode = simulateStrict
+			}
+			err = runLocalSimulation(output.progress(), *prestateFile, mode, key.Address(), tx, chain)
+		} else {
+			err = runSimulation(ctx, client, *simulate, key.Address(), tx)
+		}
+		if err != nil {
+			complete(nil, err)
+			return err
+		}
+		signedTx, d, err = eng.SignTx(ctx, tx, chain)
+		complete(signedTx, err)
+		if *explain {
+			printDecision(d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	sf, err := newSignedTxFile(signedTx, key.Address())
+	if err != nil {
+		return err
+	}
+	if *signedFile != "" {
+		if err := jsonfile.Write(*signedFile, sf); err != nil {
+			return err
+		}
+	}
+	payload, err := json.Marshal(sf)
+	if err != nil {
+		return err
+	}
+	if err := qr.render(payload); err != nil {
+		return err
+	}
+
+	var sendClient *ethclient.Client
+	if *send {
+		sendClient = client
+	}
+	return printSignedTx(ctx, signedTx, key.Address(), d, sendClient, sendOpts, output)
+}
+
+// printSignedTx prints signedTx in the output format and, when client is
+// non-nil, broadcasts it.
+func printSignedTx(ctx context.Context, signedTx *types.Transaction, from common.Address, d *policy.Decision, client *ethclient.Client, sendOpts *broadcastFlags, output *outputFormat) error {
+	out, err := newTxOutput(signedTx, from)
+	if err != nil {
+		return err
+	}
+	out.PolicyDecision = d
+	if *output == outputText {
+		fmt.Println("RawTxHex:", strings.TrimPrefix(out.RawTx, "0x"))
+		if out.ExplorerURL != "" {
+			fmt.Println("Explorer:", out.ExplorerURL)
+		}
+	}
+	var sendErr error
+	if client != nil {
+		var receipt *types.Receipt
+		receipt, sendErr = sendTx(ctx, client, signedTx, sendOpts, output.progress())
+		if receipt == nil && sendErr != nil {
+			return sendErr
+		}
+		out.Receipt = newReceiptOutput(receipt)
+	}
+	if *output == outputJSON {
+		writeJSON(out)
+	}
+	return sendErr
+}
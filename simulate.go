@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Simulation modes accepted by -simulate.
+const (
+	simulateOff    = "off"
+	simulateWarn   = "warn"
+	simulateStrict = "strict"
+)
+
+// errSimulationReverted is wrapped by simulateTx when the call reverts.
+var errSimulationReverted = errors.New("simulation reverted")
+
+// simulateTx executes tx as an eth_call from from against the pending state.
+// A revert is reported as errSimulationReverted with the decoded reason when
+// the node returns revert data.
+func simulateTx(ctx context.Context, client *ethclient.Client, from common.Address, tx *types.Transaction) error {
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}
+	if tx.Type() == types.LegacyTxType {
+		msg.GasPrice = tx.GasPrice()
+	} else {
+		msg.GasFeeCap = tx.GasFeeCap()
+		msg.GasTipCap = tx.GasTipCap()
+	}
+	_, err := client.PendingCallContract(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if revert, ok := dataErr.ErrorData().(string); ok {
+			return fmt.Errorf("%w: %s", errSimulationReverted, revertReason(revert))
+		}
+	}
+	return fmt.Errorf("%w: %v", errSimulationReverted, err)
+}
+
+// revertReason decodes Error(string) and Panic(uint256) revert data, falling
+// back to the raw hex for custom errors.
+func revertReason(data string) string {
+	raw, err := hexutil.Decode(data)
+	if err != nil || len(raw) == 0 {
+		return "execution reverted"
+	}
+	if reason, err := abi.UnpackRevert(raw); err == nil {
+		return reason
+	}
+	return "custom error " + data
+}
+
+func checkSimulateMode(mode string) error {
+	switch mode {
+	case simulateOff, simulateWarn, simulateStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown simulate mode %q (want %s, %s or %s)", mode, simulateStrict, simulateWarn, simulateOff)
+}
+
+// runSimulation applies mode to the result of simulating tx. Warnings go to
+// stderr; strict mode turns a revert into an error.
+func runSimulation(ctx context.Context, client *ethclient.Client, mode string, from common.Address, tx *types.Transaction) error {
+	if err := checkSimulateMode(mode); err != nil || mode == simulateOff {
+		return err
+	}
+	if client == nil {
+		return errors.New("simulate requires rpc")
+	}
+	err := simulateTx(ctx, client, from, tx)
+	if err != nil && mode == simulateWarn && errors.Is(err, errSimulationReverted) {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		return nil
+	}
+	return err
+}
+
+// runLocalSimulation previews tx against the prestate snapshot in file,
+// writing the preview to w, and applies mode to a revert, like runSimulation does for RPC simulation.
+func runLocalSimulation(w io.Writer, file, mode string, from common.Address, tx *types.Transaction, chainID *big.Int) error {
+	if err := checkSimulateMode(mode); err != nil || mode == simulateOff {
+		return err
+	}
+	pre, err := loadPrestate(file)
+	if err != nil {
+		return err
+	}
+	sim, err := simulateLocal(pre, from, tx, chainID)
+	if err != nil {
+		return err
+	}
+	printSimulation(w, sim)
+	if sim.reverted != nil {
+		if mode == simulateWarn {
+			fmt.Fprintln(os.Stderr, "warning:", sim.reverted)
+			return nil
+		}
+		return sim.reverted
+	}
+	return nil
+}
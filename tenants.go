@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+// tenantConfig is one entry of the tenants file given to serve -tenants.
+// Each tenant is served under /t/<name>/ by a service of its own, with its
+// own key, policy, state store, nonces, approvals, audit log and clients,
+// so that one daemon can serve several teams without them sharing
+// anything but the node connection. A freeze is kept in the state store,
+// so freeze -store with a tenant's store stops that tenant only.
+type tenantConfig struct {
+	Name string `json:"name"`
+	// Dir holds the tenant's alert acknowledgements, its state store unless
+	// Store is set, its audit log unless AuditLog is set and its
+	// policy.json unless Policy is set.
+	Dir string `json:"dir"`
+	// Store is the tenant's state store as for -store, e.g. a redis:// URL.
+	Store string `json:"store"`
+	// AuditLog is the tenant's audit log as for -audit-log; store keeps it
+	// in the tenant's state store.
+	AuditLog string `json:"audit_log"`
+	// Key, Keystore, PassphraseFile and From select the key as the flags
+	// of the same names do.
+	Key            string `json:"key"`
+	Keystore       string `json:"keystore"`
+	PassphraseFile string `json:"passphrase_file"`
+	From           string `json:"from"`
+	UnlockTTL      string `json:"unlock_ttl"`
+	Policy         string `json:"policy"`
+	PolicyPubkey   string `json:"policy_pubkey"`
+	AuditKey       string `json:"audit_key"`
+	Webhooks       string `json:"webhooks"`
+	PostSignHooks  string `json:"post_sign_hooks"`
+	AlertsConfig   string `json:"alerts_config"`
+	AllowReplace   bool   `json:"allow_replace"`
+	// Clients, JWTConfig and RBAC authenticate and authorize the tenant's
+	// callers as -clients, -jwt-config and -rbac do for a single-key
+	// daemon. Clients or JWTConfig is required.
+	Clients   string `json:"clients"`
+	JWTConfig string `json:"jwt_config"`
+	RBAC      string `json:"rbac"`
+}
+
+// tenant is a loaded tenant, ready to start.
+type tenant struct {
+	name    string
+	service serviceConfig
+	auth    *clientAuth
+}
+
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// loadTenants reads the tenants file. Authentication is loaded for each
+// tenant against the daemon's TLS flags.
+func loadTenants(file string, authOpts *authFlags) ([]*tenant, error) {
+	var configs []tenantConfig
+	if err := jsonfile.Read(file, &configs); err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %v", err)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("tenants file lists no tenants")
+	}
+	names := make(map[string]bool)
+	dirs := make(map[string]string)
+	var tenants []*tenant
+	for _, c := range configs {
+		if !tenantNamePattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("tenant %q: name must be lower-case letters, digits, - and _", c.Name)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("tenant %s: listed twice", c.Name)
+		}
+		names[c.Name] = true
+		if c.Dir == "" {
+			return nil, fmt.Errorf("tenant %s: dir is required", c.Name)
+		}
+		dir := filepath.Clean(c.Dir)
+		if other, ok := dirs[dir]; ok {
+			return nil, fmt.Errorf("tenant %s: dir %s is already used by tenant %s", c.Name, dir, other)
+		}
+		dirs[dir] = c.Name
+		if c.Clients == "" && c.JWTConfig == "" {
+			return nil, fmt.Errorf("tenant %s: clients or jwt_config is required", c.Name)
+		}
+		auth, err := loadClientAuth(c.Clients, c.JWTConfig, c.RBAC, authOpts.clientCAFile != "")
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", c.Name, err)
+		}
+		t := &tenant{name: c.Name, auth: auth}
+		t.service = serviceConfig{
+			keys: keySource{
+				hexKey:         c.Key,
+				keystoreFile:   c.Keystore,
+				passphraseFile: c.PassphraseFile,
+				from:           c.From,
+				derivationPath: keys.DefaultDerivationPath,
+				vaultAuth:      vaultAuthToken,
+			},
+			policy:       &policyFlags{file: c.Policy, pubkey: c.PolicyPubkey},
+			storePath:    filepath.Join(dir, signer.DefaultStore),
+			approvalsDir: filepath.Join(dir, signer.DefaultApprovalsDir),
+			alertsConfig: c.AlertsConfig,
+			webhooksFile: c.Webhooks,
+			postSignFile: c.PostSignHooks,
+			audit:        auditFlags{path: filepath.Join(dir, signer.DefaultAuditLog), operator: currentUser(), keyFile: c.AuditKey},
+			allowReplace: c.AllowReplace,
+		}
+		if c.Policy == "" {
+			t.service.policy.file = filepath.Join(dir, "policy.json")
+		}
+		if c.Store != "" {
+			t.service.storePath = c.Store
+		}
+		if c.AuditLog != "" {
+			t.service.audit.path = c.AuditLog
+		}
+		if c.Key == "" && c.Keystore == "" && c.From == "" {
+			return nil, fmt.Errorf("tenant %s: key, keystore or from is required", c.Name)
+		}
+		if c.UnlockTTL != "" {
+			if t.service.unlockTTL, err = time.ParseDuration(c.UnlockTTL); err != nil || t.service.unlockTTL <= 0 {
+				return nil, fmt.Errorf("tenant %s: invalid unlock_ttl %q", c.Name, c.UnlockTTL)
+			}
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// tenantFlags are the serve flags that configure the single service of a
+// daemon and are set per tenant in the tenants file instead.
+var tenantFlags = []string{
+	"key", "keystore", "passphrase-file", "key-stdin", "mnemonic", "mnemonic-file", "from",
+	"policy", "policy-pubkey", "policy-sig", "store", "approvals-dir",
+	"audit-log", "audit-key", "webhooks", "post-sign-hooks", "alerts-config", "allow-replace", "unlock-ttl",
+	"clients", "jwt-config", "rbac",
+}
+
+// checkTenantFlags rejects per-service flags given together with -tenants.
+func checkTenantFlags(fs *flag.FlagSet) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range tenantFlags {
+			if f.Name == name && err == nil {
+				err = fmt.Errorf("%s cannot be combined with tenants; set it per tenant in the tenants file", name)
+			}
+		}
+	})
+	return err
+}
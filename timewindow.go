@@ -0,0 +1,10 @@
+package main
+
+import (
+	"flag"
+)
+
+// addOverrideFlag registers -override-reason on fs.
+func addOverrideFlag(fs *flag.FlagSet) *string {
+	return fs.String("override-reason", "", "Sign outside the policy signing window; the reason is logged")
+}
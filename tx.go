@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// feeFlags holds the fee flags shared by the signing commands.
+type feeFlags struct {
+	fs             *flag.FlagSet
+	txType         string
+	gasPrice       string
+	maxFee         string
+	maxPriorityFee string
+	maxBlobFee     string
+}
+
+func addFeeFlags(fs *flag.FlagSet) *feeFlags {
+	f := &feeFlags{fs: fs}
+	fs.StringVar(&f.txType, "tx-type", txbuilder.TxTypeLegacy, "Transaction type: legacy, access-list (EIP-2930), dynamic (EIP-1559), blob (EIP-4844) or set-code (EIP-7702)")
+	fs.StringVar(&f.gasPrice, "gas-price", "1000000000", "Gas price in wei or with a unit such as 30gwei (legacy and access-list transactions; suggested via -rpc when omitted)")
+	fs.StringVar(&f.maxFee, "max-fee", "", "Max fee per gas in wei or with a unit (dynamic transactions; suggested via -rpc when omitted)")
+	fs.StringVar(&f.maxPriorityFee, "max-priority-fee", "", "Max priority fee per gas in wei or with a unit (dynamic transactions; suggested via -rpc when omitted)")
+	fs.StringVar(&f.maxBlobFee, "max-blob-fee", "", "Max fee per blob gas in wei or with a unit (blob transactions; suggested via -rpc when omitted)")
+	return f
+}
+
+// params parses the fee flags, then fills the unset ones from client when it
+// is non-nil.
+func (f *feeFlags) params(ctx context.Context, client *ethclient.Client, settings txbuilder.FeeSettings) (txbuilder.FeeParams, error) {
+	fees := txbuilder.FeeParams{TxType: f.txType}
+	var err error
+	if fees.GasPrice, err = txbuilder.ParseEther("gas-price", f.gasPrice); err != nil {
+		return fees, err
+	}
+	if f.maxFee != "" {
+		if fees.MaxFee, err = txbuilder.ParseEther("max-fee", f.maxFee); err != nil {
+			return fees, err
+		}
+	}
+	if f.maxPriorityFee != "" {
+		if fees.MaxPriorityFee, err = txbuilder.ParseEther("max-priority-fee", f.maxPriorityFee); err != nil {
+			return fees, err
+		}
+	}
+	if f.maxBlobFee != "" {
+		if fees.MaxBlobFee, err = txbuilder.ParseEther("max-blob-fee", f.maxBlobFee); err != nil {
+			return fees, err
+		}
+	}
+	if client == nil {
+		return fees, nil
+	}
+	switch fees.TxType {
+	case txbuilder.TxTypeLegacy, txbuilder.TxTypeAccessList:
+		if !flagWasSet(f.fs, "gas-price") {
+			err = txbuilder.SuggestGasPrice(ctx, client, settings, &fees)
+		}
+	case txbuilder.TxTypeDynamic, txbuilder.TxTypeSetCode:
+		err = txbuilder.SuggestDynamicFees(ctx, client, settings, &fees)
+	case txbuilder.TxTypeBlob:
+		if err = txbuilder.SuggestDynamicFees(ctx, client, settings, &fees); err == nil && fees.MaxBlobFee == nil {
+			err = txbuilder.SuggestBlobFee(ctx, client, settings, &fees)
+		}
+	}
+	return fees, err
+}
+
+// txFlags describe the transaction to build, shared by sign and prepare.
+type txFlags struct {
+	fs       *flag.FlagSet
+	to       string
+	contacts *string
+	// noChecksum accepts addresses with an invalid EIP-55 checksum.
+	noChecksum bool
+	amount     string
+	nonce      uint64
+	chain      *chainFlag
+	data       string
+	gasLimit   uint64
+	erc20      string
+	erc721     string
+	erc1155    string
+	tokenID    string
+	// accessList is a JSON file holding the access list, and
+	// txbuilder.CreateAccessList asks the node for one with eth_createAccessList.
+	accessList       string
+	createAccessList bool
+	// blobs are the data files of a blob transaction, and authorizations
+	// the signed authorization files of a set-code transaction.
+	blobs          fileList
+	authorizations fileList
+	fees           *feeFlags
+}
+
+// fileList collects a repeatable file flag.
+type fileList []string
+
+func (l *fileList) String() string { return strings.Join(*l, ",") }
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// txFlagNames lists the flags registered by addTxFlags.
+var txFlagNames = []string{"to", "amount", "nonce", "chain", "data", "gas-limit", "erc20", "erc721", "erc1155", "token-id", "access-list", "create-access-list", "blob", "authorization", "tx-type", "gas-price", "max-fee", "max-priority-fee", "max-blob-fee"}
+
+func addTxFlags(fs *flag.FlagSet) *txFlags {
+	t := &txFlags{fs: fs}
+	fs.StringVar(&t.to, "to", "", "Recipient address, contact name or ENS name (resolved via -rpc)")
+	t.contacts = addContactsFlag(fs)
+	fs.BoolVar(&t.noChecksum, "no-checksum", false, "Accept mixed-case addresses that fail the EIP-55 checksum")
+	fs.StringVar(&t.amount, "amount", "0", "Amount in wei, or with a unit such as 1.5eth or 2500gwei; token base units with -erc20 and -erc1155")
+	fs.Uint64Var(&t.nonce, "nonce", 0, "Account nonce (allocated from -store, reconciled with the -rpc pending nonce, when omitted)")
+	t.chain = addChainFlag(fs, 1, "Chain ID (default Ethereum mainnet, or fetched via -rpc when omitted)")
+	fs.StringVar(&t.data, "data", "", "Transaction calldata in hex")
+	fs.Uint64Var(&t.gasLimit, "gas-limit", 0, "Gas limit (default 21000 for plain transfers or estimated via -rpc; required with -data offline)")
+	fs.StringVar(&t.erc20, "erc20", "", "ERC-20 token address; -amount is then in token base units and sent via transfer()")
+	fs.StringVar(&t.erc721, "erc721", "", "ERC-721 collection address; sends -token-id via safeTransferFrom()")
+	fs.StringVar(&t.erc1155, "erc1155", "", "ERC-1155 collection address; sends -amount of -token-id via safeTransferFrom()")
+	fs.StringVar(&t.tokenID, "token-id", "", "NFT token ID for -erc721 or -erc1155")
+	fs.StringVar(&t.accessList, "access-list", "", "JSON file holding the EIP-2930 access list ([{\"address\": ..., \"storageKeys\": [...]}])")
+	fs.BoolVar(&t.createAccessList, "create-access-list", false, "Generate the access list with eth_createAccessList (requires -rpc)")
+	fs.Var(&t.blobs, "blob", "File of data to post in blobs (blob transactions; repeatable)")
+	fs.Var(&t.authorizations, "authorization", "JSON file of signed EIP-7702 authorizations from sign-authorization (set-code transactions; repeatable)")
+	t.fees = addFeeFlags(fs)
+	return t
+}
+
+// parseAddress parses an address flag, enforcing its EIP-55 checksum unless
+// -no-checksum is given.
+func (t *txFlags) parseAddress(name, s string) (common.Address, error) {
+	if t.noChecksum && common.IsHexAddress(s) {
+		return common.HexToAddress(s), nil
+	}
+	return policy.ParseChecksummedAddress(name, s)
+}
+
+// anySet reports whether any of the transaction flags was given.
+func (t *txFlags) anySet() bool {
+	for _, name := range txFlagNames {
+		if flagWasSet(t.fs, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// build builds the unsigned transaction described by the flags, sent by
+// from. With a client the chain ID is checked or fetched, the gas limit
+// estimated and unset fees suggested. nextNonce supplies the nonce when
+// -nonce is not given.
+func (t *txFlags) build(ctx context.Context, client *ethclient.Client, from common.Address, settings txbuilder.FeeSettings, nextNonce func(chainID *big.Int) (uint64, error)) (*types.Transaction, *big.Int, error) {
+	if t.to == "" {
+		return nil, nil, errors.New("to is required")
+	}
+	if (t.erc20 != "" || t.erc1155 != "") && txbuilder.HasEtherUnit(t.amount) {
+		return nil, nil, errors.New("amount units apply to ether only; give token amounts in base units")
+	}
+	amountWei, err := txbuilder.ParseEther("amount", t.amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err := t.parseAddress("recipient", t.to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := txbuilder.ParseCalldata(t.data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.erc20 != "" {
+		if len(data) > 0 {
+			return nil, nil, errors.New("data and erc20 are mutually exclusive")
+		}
+		token, err := t.parseAddress("erc20 token", t.erc20)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = txbuilder.EncodeERC20Transfer(to, amountWei)
+		to = token
+		amountWei = new(big.Int)
+	}
+	if t.erc721 != "" || t.erc1155 != "" {
+		if len(data) > 0 || (t.erc721 != "" && t.erc1155 != "") {
+			return nil, nil, errors.New("data, erc20, erc721 and erc1155 are mutually exclusive")
+		}
+		tokenID, ok := new(big.Int).SetString(t.tokenID, 10)
+		if !ok || tokenID.Sign() < 0 {
+			return nil, nil, errors.New("token-id is required for NFT transfers")
+		}
+		collection := t.erc721
+		if t.erc721 != "" {
+			data = txbuilder.EncodeERC721Transfer(from, to, tokenID)
+		} else {
+			if amountWei.Sign() <= 0 {
+				return nil, nil, errors.New("amount must be positive for erc1155 transfers")
+			}
+			collection = t.erc1155
+			data = txbuilder.EncodeERC1155Transfer(from, to, tokenID, amountWei)
+		}
+		if to, err = t.parseAddress("collection", collection); err != nil {
+			return nil, nil, err
+		}
+		amountWei = new(big.Int)
+	}
+
+	var accessList types.AccessList
+	switch {
+	case t.accessList != "" && t.createAccessList:
+		return nil, nil, errors.New("access-list and create-access-list are mutually exclusive")
+	case t.accessList != "":
+		if err := jsonfile.Read(t.accessList, &accessList); err != nil {
+			return nil, nil, fmt.Errorf("failed to read access list: %v", err)
+		}
+	case t.createAccessList && client == nil:
+		return nil, nil, errors.New("create-access-list requires rpc")
+	}
+
+	var authList []types.SetCodeAuthorization
+	if len(t.authorizations) > 0 {
+		if t.fees.txType != txbuilder.TxTypeSetCode {
+			return nil, nil, errors.New("authorization requires -tx-type set-code")
+		}
+		if authList, err = readAuthorizations(t.authorizations); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	chain := t.chain.big()
+	gasLimit := t.gasLimit
+	if client != nil {
+		var want *big.Int
+		if flagWasSet(t.fs, "chain") {
+			want = chain
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, client, want); err != nil {
+			return nil, nil, err
+		}
+		var gasUsed uint64
+		if t.createAccessList {
+			if accessList, gasUsed, err = txbuilder.CreateAccessList(ctx, client, from, to, amountWei, data); err != nil {
+				return nil, nil, err
+			}
+		}
+		switch {
+		case gasLimit != 0:
+		case t.createAccessList:
+			// The node reports the gas used with the access list applied.
+			if gasLimit, err = txbuilder.ScaleGasLimit(settings, gasUsed); err != nil {
+				return nil, nil, err
+			}
+		default:
+			msg := ethereum.CallMsg{From: from, To: &to, Value: amountWei, Data: data, AuthorizationList: authList}
+			if gasLimit, err = txbuilder.EstimateGasLimit(ctx, client, settings, msg); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	fees, err := t.fees.params(ctx, client, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	fees.AccessList = accessList
+	fees.AuthList = authList
+	if fees.TxType == txbuilder.TxTypeBlob {
+		if len(t.blobs) == 0 {
+			return nil, nil, errors.New("tx-type blob requires at least one -blob file")
+		}
+		if fees.Blobs, err = txbuilder.NewBlobSidecar(t.blobs); err != nil {
+			return nil, nil, err
+		}
+	} else if len(t.blobs) > 0 {
+		return nil, nil, errors.New("blob requires -tx-type blob")
+	}
+	nonce := t.nonce
+	if !flagWasSet(t.fs, "nonce") {
+		if nonce, err = nextNonce(chain); err != nil {
+			return nil, nil, err
+		}
+	}
+	tx, err := txbuilder.Build(nonce, to, amountWei, data, gasLimit, chain, fees)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build tx: %v", err)
+	}
+	return tx, chain, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// loadTypedData reads eth_signTypedData_v4 JSON.
+func loadTypedData(file string) (*apitypes.TypedData, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var typed apitypes.TypedData
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("invalid typed data: %v", err)
+	}
+	return &typed, nil
+}
+
+// signTypedData hashes typed per EIP-712 and signs it. The returned
+// signature has V in {0, 1}.
+func signTypedData(ctx context.Context, key keys.Backend, typed *apitypes.TypedData) (hash, sig []byte, err error) {
+	domainSeparator, err := typed.HashStruct("EIP712Domain", typed.Domain.Map())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash domain: %v", err)
+	}
+	messageHash, err := typed.HashStruct(typed.PrimaryType, typed.Message)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash message: %v", err)
+	}
+	hash = crypto.Keccak256([]byte("\x19\x01"), domainSeparator, messageHash)
+	if ts, ok := key.(keys.TypedDataSigningBackend); ok {
+		sig, err = ts.SignTypedData(ctx, domainSeparator, messageHash)
+		if err == nil && sig[crypto.RecoveryIDOffset] >= 27 {
+			sig[crypto.RecoveryIDOffset] -= 27
+		}
+	} else {
+		sig, err = key.SignHash(ctx, hash)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash, sig, nil
+}
+
+// signatureOutput is a [R || S || V] signature as separate components, as a
+// 65-byte hex string with V in {27, 28}, and in EIP-2098 compact form.
+type signatureOutput struct {
+	R                string `json:"r"`
+	S                string `json:"s"`
+	V                byte   `json:"v"`
+	Signature        string `json:"signature"`
+	CompactSignature string `json:"compactSignature"`
+}
+
+// newSignatureOutput formats sig, which has V in {0, 1}.
+func newSignatureOutput(sig []byte) signatureOutput {
+	r, s, v := sig[:32], sig[32:64], sig[crypto.RecoveryIDOffset]
+	full := append([]byte{}, sig...)
+	full[crypto.RecoveryIDOffset] += 27
+
+	// EIP-2098: yParity is stored in the top bit of s.
+	vs := new(big.Int).SetBytes(s)
+	if v == 1 {
+		vs.SetBit(vs, 255, 1)
+	}
+	return signatureOutput{
+		R:                hexutil.Encode(r),
+		S:                hexutil.Encode(s),
+		V:                v + 27,
+		Signature:        hexutil.Encode(full),
+		CompactSignature: hexutil.Encode(append(append([]byte{}, r...), vs.FillBytes(make([]byte, 32))...)),
+	}
+}
+
+func (o signatureOutput) printText() {
+	fmt.Println("R:", o.R)
+	fmt.Println("S:", o.S)
+	fmt.Println("V:", o.V)
+	fmt.Println("Signature:", o.Signature)
+	fmt.Println("CompactSignature:", o.CompactSignature)
+}
+
+func runSignTypedData(args []string) error {
+	fs := flag.NewFlagSet("sign-typed-data", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	file := fs.String("file", "", "eth_signTypedData_v4 JSON file")
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *file == "" {
+		return errors.New("file is required")
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	typed, err := loadTypedData(*file)
+	if err != nil {
+		return err
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy = policy.ForSender(key.Address())
+	if !policy.AllowTypedData {
+		return audit.Denied(signer.AuditTypedData, typed, nil, errors.New("policy check failed: typed data signing is not allowed"))
+	}
+	if err := policy.CheckSigningWindow(time.Now(), *overrideReason); err != nil {
+		return audit.Denied(signer.AuditTypedData, typed, nil, fmt.Errorf("policy check failed: %v", err))
+	}
+	if len(policy.AllowedChainIDs) > 0 {
+		// A domain without a chain ID is valid on every chain.
+		if typed.Domain.ChainId == nil {
+			return audit.Denied(signer.AuditTypedData, typed, nil, errors.New("policy check failed: typed data domain has no chainId"))
+		}
+		if err := policy.CheckChainID((*big.Int)(typed.Domain.ChainId)); err != nil {
+			return audit.Denied(signer.AuditTypedData, typed, nil, fmt.Errorf("policy check failed: %v", err))
+		}
+	}
+	hash, sig, err := signTypedData(ctx, key, typed)
+	if err != nil {
+		err = fmt.Errorf("failed to sign typed data: %v", err)
+	}
+	if err := audit.Signed(signer.AuditTypedData, key.Address(), typed, hash, err); err != nil {
+		return err
+	}
+	out := struct {
+		Address string `json:"address"`
+		Hash    string `json:"hash"`
+		signatureOutput
+	}{key.Address().Hex(), hexutil.Encode(hash), newSignatureOutput(sig)}
+	output.print(out, func() {
+		fmt.Println("Hash:", out.Hash)
+		out.printText()
+	})
+	return nil
+}
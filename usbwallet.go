@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// usbWalletBackend signs through a USB hardware wallet. The device shows the
+// transaction and must be confirmed by hand, so the CLI only acts as a
+// policy gate in front of it. Keys are referenced as ledger://<path> or
+// trezor://<path>, with the default BIP-44 path when <path> is empty.
+type usbWalletBackend struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+}
+
+func newLedgerBackend(path string) (*usbWalletBackend, error) {
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Ledger hub: %v", err)
+	}
+	return newUSBWalletBackend([]*usbwallet.Hub{hub}, "Ledger", path, func(w accounts.Wallet) error {
+		return w.Open("")
+	})
+}
+
+// trezorHubs returns hubs for both Trezor transports: WebUSB (Model T and
+// newer) and HID (Model One).
+func trezorHubs() ([]*usbwallet.Hub, error) {
+	webusb, err := usbwallet.NewTrezorHubWithWebUSB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Trezor WebUSB hub: %v", err)
+	}
+	hid, err := usbwallet.NewTrezorHubWithHID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Trezor HID hub: %v", err)
+	}
+	return []*usbwallet.Hub{webusb, hid}, nil
+}
+
+// newTrezorBackend opens a Trezor, answering its PIN and passphrase
+// requests. The passphrase selects a hidden wallet; it is read from
+// passphraseFile when set, otherwise prompted. An empty passphrase opens the
+// standard wallet.
+func newTrezorBackend(path, passphraseFile string) (*usbWalletBackend, error) {
+	hubs, err := trezorHubs()
+	if err != nil {
+		return nil, err
+	}
+	return newUSBWalletBackend(hubs, "Trezor", path, func(w accounts.Wallet) error {
+		err := w.Open("")
+		if errors.Is(err, usbwallet.ErrTrezorPINNeeded) {
+			// The device shows a scrambled keypad; the operator types the
+			// positions of their PIN digits, laid out like a numpad.
+			var pin string
+			if pin, err = readPassphrase("", "Trezor PIN (positions on the device keypad): "); err != nil {
+				return err
+			}
+			err = w.Open(pin)
+		}
+		if errors.Is(err, usbwallet.ErrTrezorPassphraseNeeded) {
+			var passphrase string
+			if passphrase, err = readPassphrase(passphraseFile, "Trezor passphrase (empty for the standard wallet): "); err != nil {
+				return err
+			}
+			err = w.Open(passphrase)
+		}
+		return err
+	})
+}
+
+func newUSBWalletBackend(hubs []*usbwallet.Hub, kind, path string, open func(accounts.Wallet) error) (*usbWalletBackend, error) {
+	if path == "" {
+		path = keys.DefaultDerivationPath
+	}
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var wallets []accounts.Wallet
+	for _, hub := range hubs {
+		wallets = append(wallets, hub.Wallets()...)
+	}
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("no %s device found", kind)
+	}
+	wallet := wallets[0]
+	if err := open(wallet); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", kind, err)
+	}
+	account, err := wallet.Derive(derivationPath, true)
+	if err != nil {
+		wallet.Close()
+		return nil, fmt.Errorf("failed to derive %s account: %v", kind, err)
+	}
+	return &usbWalletBackend{wallet: wallet, account: account}, nil
+}
+
+func (b *usbWalletBackend) Address() common.Address { return b.account.Address }
+
+// PublicKey is not exposed by the device drivers.
+func (b *usbWalletBackend) PublicKey() *ecdsa.PublicKey { return nil }
+
+// CheckHealth checks that the device is still connected and open.
+func (b *usbWalletBackend) CheckHealth(ctx context.Context) error {
+	if _, err := b.wallet.Status(); err != nil {
+		return fmt.Errorf("hardware wallet unavailable: %v", err)
+	}
+	return nil
+}
+
+// SignHash is refused: the devices only sign data they can display.
+func (b *usbWalletBackend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return nil, errors.New("hardware wallets cannot sign raw hashes")
+}
+
+func (b *usbWalletBackend) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return b.wallet.SignTx(b.account, tx, chainID)
+}
+
+func (b *usbWalletBackend) SignTypedData(ctx context.Context, domainSeparator, messageHash []byte) ([]byte, error) {
+	data := append([]byte("\x19\x01"), domainSeparator...)
+	return b.wallet.SignData(b.account, accounts.MimetypeTypedData, append(data, messageHash...))
+}
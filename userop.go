@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// userOpRequest is the audit log form of a UserOperation.
+func userOpRequest(op *txbuilder.UserOperation, entryPoint common.Address, chainID *big.Int) any {
+	return struct {
+		EntryPoint    common.Address           `json:"entryPoint"`
+		ChainID       string                   `json:"chainId"`
+		UserOperation *txbuilder.UserOperation `json:"userOperation"`
+	}{entryPoint, chainID.String(), op}
+}
+
+func runSignUserOp(args []string) error {
+	fs := flag.NewFlagSet("sign-userop", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store holding the freeze switch")
+	file := fs.String("file", "", "UserOperation JSON file, in the bundler RPC format")
+	entryPointFlag := fs.String("entrypoint", "", "EntryPoint contract address")
+	version := fs.String("entrypoint-version", "", "EntryPoint version, 0.6 or 0.7 (detected for the canonical deployments)")
+	chainID := addChainFlag(fs, 0, "Chain ID (fetched from -bundler when omitted)")
+	rawHash := fs.Bool("raw-hash", false, "Sign the userOpHash itself instead of its EIP-191 personal message hash, for accounts that verify it directly")
+	bundler := fs.String("bundler", "", "Bundler JSON-RPC endpoint to submit the signed operation to with eth_sendUserOperation")
+	policyOpts := addPolicyFlags(fs)
+	overrideReason := addOverrideFlag(fs)
+	auditOpts := addAuditFlags(fs)
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *file == "" {
+		return errors.New("file is required")
+	}
+	if !common.IsHexAddress(*entryPointFlag) {
+		return fmt.Errorf("invalid entrypoint address %q", *entryPointFlag)
+	}
+	entryPoint := common.HexToAddress(*entryPointFlag)
+	if *version == "" {
+		known, ok := txbuilder.KnownEntryPoints[entryPoint]
+		if !ok {
+			return errors.New("entrypoint-version is required for this entrypoint")
+		}
+		*version = known
+	}
+	op := new(txbuilder.UserOperation)
+	if err := jsonfile.Read(*file, op); err != nil {
+		return fmt.Errorf("failed to read user operation: %v", err)
+	}
+	if err := op.Validate(*version); err != nil {
+		return fmt.Errorf("invalid user operation: %v", err)
+	}
+	policy, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	audit, err := auditOpts.open(store)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var client *rpc.Client
+	if *bundler != "" {
+		if client, err = rpc.DialContext(ctx, *bundler); err != nil {
+			return fmt.Errorf("failed to connect to bundler: %v", err)
+		}
+		defer client.Close()
+	}
+	chain := chainID.big()
+	if !flagWasSet(fs, "chain") {
+		if client == nil {
+			return errors.New("chain is required without bundler")
+		}
+		if chain, err = txbuilder.FetchChainID(ctx, ethclient.NewClient(client), nil); err != nil {
+			return err
+		}
+	}
+
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	policy = policy.ForSender(key.Address())
+	userOpHash := op.Hash(*version, entryPoint, chain)
+	digest := userOpHash.Bytes()
+	if !*rawHash {
+		digest = accounts.TextHash(digest)
+	}
+	request := userOpRequest(op, entryPoint, chain)
+	if policy.UserOps == nil {
+		return audit.Denied(signer.AuditUserOp, request, digest, errors.New("policy check failed: user operation signing is not allowed"))
+	}
+	if err := policy.CheckChainID(chain); err != nil {
+		return audit.Denied(signer.AuditUserOp, request, digest, fmt.Errorf("policy check failed: %v", err))
+	}
+	if err := policy.UserOps.Check(op, entryPoint); err != nil {
+		return audit.Denied(signer.AuditUserOp, request, digest, fmt.Errorf("policy check failed: %v", err))
+	}
+	if err := policy.CheckSigningWindow(time.Now(), *overrideReason); err != nil {
+		return audit.Denied(signer.AuditUserOp, request, digest, fmt.Errorf("policy check failed: %v", err))
+	}
+	sig, err := key.SignHash(ctx, digest)
+	if err != nil {
+		err = fmt.Errorf("failed to sign user operation: %v", err)
+	}
+	if err := audit.Signed(signer.AuditUserOp, key.Address(), request, digest, err); err != nil {
+		return err
+	}
+	sigOut := newSignatureOutput(sig)
+	op.Signature = hexutil.MustDecode(sigOut.Signature)
+
+	var submitted string
+	if client != nil {
+		if err := client.CallContext(ctx, &submitted, "eth_sendUserOperation", op.RPCParam(*version), entryPoint); err != nil {
+			return fmt.Errorf("failed to submit user operation: %v", err)
+		}
+	}
+	out := struct {
+		UserOpHash    string                   `json:"userOpHash"`
+		Submitted     bool                     `json:"submitted"`
+		UserOperation *txbuilder.UserOperation `json:"userOperation"`
+		signatureOutput
+	}{userOpHash.Hex(), client != nil, op, sigOut}
+	output.print(out, func() {
+		fmt.Println("UserOp hash:", out.UserOpHash)
+		out.printText()
+		if client != nil {
+			fmt.Println("Submitted to bundler:", submitted)
+		}
+	})
+	return nil
+}
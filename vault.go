@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/keys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Vault keys are referenced in two ways:
+//
+//	vaultkv://<kv-path>[#field]  read a hex private key from a KV secret
+//	                             (v1 or v2) into memory; default field is
+//	                             "private_key".
+//	vault://<plugin-path>        delegate signing to a secrets plugin. A read
+//	                             of <plugin-path> must return "address", and a
+//	                             write of "hash" to <plugin-path>/sign must
+//	                             return a 65-byte hex "signature".
+//
+// The server address and TLS settings come from the usual VAULT_* variables.
+
+const (
+	vaultAuthToken   = "token"
+	vaultAuthAppRole = "approle"
+)
+
+// newVaultClient returns a client authenticated according to src.
+func newVaultClient(ctx context.Context, src keySource) (*vault.Client, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %v", err)
+	}
+	switch src.vaultAuth {
+	case "", vaultAuthToken:
+		if client.Token() == "" {
+			return nil, errors.New("VAULT_TOKEN is not set")
+		}
+	case vaultAuthAppRole:
+		roleID := src.vaultRoleID
+		if roleID == "" {
+			roleID = os.Getenv("VAULT_ROLE_ID")
+		}
+		secretID := os.Getenv("VAULT_SECRET_ID")
+		if src.vaultSecretIDFile != "" {
+			if secretID, err = readPassphrase(src.vaultSecretIDFile, ""); err != nil {
+				return nil, err
+			}
+		}
+		if roleID == "" || secretID == "" {
+			return nil, errors.New("approle auth needs a role ID and secret ID")
+		}
+		secret, err := client.Logical().WriteWithContext(ctx, "auth/approle/login", map[string]interface{}{
+			"role_id":   roleID,
+			"secret_id": secretID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Vault approle login failed: %v", err)
+		}
+		if secret == nil || secret.Auth == nil {
+			return nil, errors.New("Vault approle login returned no token")
+		}
+		client.SetToken(secret.Auth.ClientToken)
+	default:
+		return nil, fmt.Errorf("unknown Vault auth method %q", src.vaultAuth)
+	}
+	return client, nil
+}
+
+// loadVaultKVKey reads a hex private key from a Vault KV secret.
+func loadVaultKVKey(ctx context.Context, src keySource, ref string) (*keys.SecretKey, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok {
+		field = "private_key"
+	}
+	client, err := newVaultClient(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret: %v", err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("Vault secret %s not found", path)
+	}
+	data := secret.Data
+	// KV v2 nests the secret under "data".
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	hexKey, ok := data[field].(string)
+	if !ok {
+		return nil, fmt.Errorf("Vault secret %s has no string field %q", path, field)
+	}
+	return loadPrivateKey(hexKey)
+}
+
+// vaultPluginBackend delegates signing to a Vault secrets plugin.
+type vaultPluginBackend struct {
+	client  *vault.Client
+	path    string
+	address common.Address
+}
+
+func newVaultPluginBackend(ctx context.Context, src keySource, path string) (*vaultPluginBackend, error) {
+	client, err := newVaultClient(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault key: %v", err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("Vault key %s not found", path)
+	}
+	addr, ok := secret.Data["address"].(string)
+	if !ok || !common.IsHexAddress(addr) {
+		return nil, fmt.Errorf("Vault key %s has no valid address", path)
+	}
+	return &vaultPluginBackend{client: client, path: path, address: common.HexToAddress(addr)}, nil
+}
+
+func (b *vaultPluginBackend) Address() common.Address { return b.address }
+
+// PublicKey is not exposed by the plugin protocol.
+func (b *vaultPluginBackend) PublicKey() *ecdsa.PublicKey { return nil }
+
+// CheckHealth reads the key again to check that Vault is reachable and the
+// token still valid.
+func (b *vaultPluginBackend) CheckHealth(ctx context.Context) error {
+	secret, err := b.client.Logical().ReadWithContext(ctx, b.path)
+	if err != nil {
+		return fmt.Errorf("failed to read Vault key: %v", err)
+	}
+	if secret == nil {
+		return fmt.Errorf("Vault key %s not found", b.path)
+	}
+	return nil
+}
+
+func (b *vaultPluginBackend) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	secret, err := b.client.Logical().WriteWithContext(ctx, b.path+"/sign", map[string]interface{}{
+		"hash": hexutil.Encode(hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Vault sign failed: %v", err)
+	}
+	if secret == nil {
+		return nil, errors.New("Vault sign returned no data")
+	}
+	sigHex, _ := secret.Data["signature"].(string)
+	sig, err := hexutil.Decode(sigHex)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, errors.New("Vault sign returned an invalid signature")
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	// Never trust the plugin blindly: the signature must recover to the
+	// address it advertised.
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != b.address {
+		return nil, errors.New("Vault signature does not recover to the key address")
+	}
+	return sig, nil
+}
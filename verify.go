@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	rawHex := fs.String("raw", "", "Signed raw transaction in hex")
+	expectFrom := fs.String("expect-from", "", "Fail unless the transaction was signed by this address")
+	expectChain := fs.String("expect-chain", "", "Fail unless the transaction is for this chain ID")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if *rawHex == "" {
+		return errors.New("raw is required")
+	}
+	if *expectFrom != "" && !common.IsHexAddress(*expectFrom) {
+		return fmt.Errorf("invalid expect-from address %q", *expectFrom)
+	}
+	var wantChain *big.Int
+	if *expectChain != "" {
+		var ok bool
+		if wantChain, ok = new(big.Int).SetString(*expectChain, 10); !ok {
+			return fmt.Errorf("invalid expect-chain %q", *expectChain)
+		}
+	}
+	tx, err := txbuilder.DecodeRawTx(*rawHex)
+	if err != nil {
+		return err
+	}
+	from, err := txbuilder.TxSender(tx)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %v", err)
+	}
+
+	var mismatches []string
+	if *expectFrom != "" && common.HexToAddress(*expectFrom) != from {
+		mismatches = append(mismatches, fmt.Sprintf("signer %s does not match expected %s", from.Hex(), common.HexToAddress(*expectFrom).Hex()))
+	}
+	if wantChain != nil && tx.ChainId().Cmp(wantChain) != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("chain ID %s does not match expected %s", tx.ChainId(), wantChain))
+	}
+	output.print(struct {
+		From     string `json:"from"`
+		ChainID  string `json:"chainId"`
+		Verified bool   `json:"verified"`
+		Reason   string `json:"reason,omitempty"`
+	}{from.Hex(), tx.ChainId().String(), len(mismatches) == 0, strings.Join(mismatches, "; ")}, func() {
+		fmt.Println("From:", from.Hex())
+		fmt.Println("ChainID:", tx.ChainId())
+	})
+	if len(mismatches) > 0 {
+		return fmt.Errorf("verification failed: %s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Web3Signer-compatible REST surface (eth1 only). Clients that speak the
+// Consensys Web3Signer API can point at the daemon without changes.
+
+// web3SignerPublicKey returns the key's uncompressed public key without the
+// 0x04 prefix, which is how Web3Signer identifies eth1 keys. It is empty for
+// backends that do not expose their public key.
+func (s *ethService) web3SignerPublicKey() string {
+	pub := s.Key.PublicKey()
+	if pub == nil {
+		return ""
+	}
+	return hexutil.Encode(crypto.FromECDSAPub(pub)[1:])
+}
+
+func (s *ethService) registerWeb3Signer(mux *http.ServeMux) {
+	mux.HandleFunc("GET /upcheck", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /api/v1/eth1/publicKeys", authorized(opView, s.Key.Address(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		keys := []string{}
+		if pub := s.web3SignerPublicKey(); pub != "" {
+			keys = append(keys, pub)
+		}
+		json.NewEncoder(w).Encode(keys)
+	}))
+	mux.HandleFunc("POST /api/v1/eth1/sign/{identifier}", authorized(opSign, s.Key.Address(), s.handleWeb3SignerSign))
+}
+
+func (s *ethService) handleWeb3SignerSign(w http.ResponseWriter, r *http.Request) {
+	identifier := strings.ToLower(r.PathValue("identifier"))
+	pub := s.web3SignerPublicKey()
+	if (pub == "" || identifier != pub) && identifier != strings.ToLower(s.Key.Address().Hex()) {
+		http.Error(w, "Public Key not found", http.StatusNotFound)
+		return
+	}
+	if err := checkGrants(r.Context(), s.Key.Address(), nil, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Data hexutil.Bytes `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request format", http.StatusBadRequest)
+		return
+	}
+	// Raw data is hashed and signed as-is, so it could be the signing hash
+	// of any transaction. Only policies that explicitly opt in allow it.
+	start := time.Now()
+	hash := crypto.Keccak256(req.Data)
+	request := struct {
+		Data hexutil.Bytes `json:"data"`
+	}{req.Data}
+	if !s.CurrentPolicy().AllowRawSign {
+		err := errors.New("policy check failed: raw signing is not allowed")
+		slog.Warn("web3signer sign denied", "err", err)
+		s.observe(signer.AuditRaw, start, "allow_raw_sign", "", err)
+		s.Audit.Denied(signer.AuditRaw, request, hash, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := s.CurrentPolicy().CheckSigningWindow(time.Now(), ""); err != nil {
+		err = fmt.Errorf("policy check failed: %v", err)
+		slog.Warn("web3signer sign denied", "err", err)
+		s.observe(signer.AuditRaw, start, "signing_window", "", err)
+		s.Audit.Denied(signer.AuditRaw, request, hash, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	sig, err := s.Key.SignHash(r.Context(), hash)
+	err = s.Audit.Signed(signer.AuditRaw, s.Key.Address(), request, hash, err)
+	s.observe(signer.AuditRaw, start, "", "", err)
+	if err != nil {
+		slog.Error("web3signer sign failed", "err", err)
+		http.Error(w, "Signing failed", http.StatusInternalServerError)
+		return
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(hexutil.Encode(sig)))
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/jsonfile"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+)
+
+// Webhook events.
+const (
+	eventSigned = "signed"
+	eventDenied = "denied"
+	eventError  = "error"
+)
+
+const (
+	webhookQueueSize  = 1024
+	webhookAttempts   = 6
+	webhookTimeout    = 10 * time.Second
+	webhookBackoff    = time.Second
+	webhookMaxBackoff = 30 * time.Second
+)
+
+// webhookConfig is one entry of the -webhooks file, a JSON array. The
+// secret keys the HMAC-SHA256 signature sent with every delivery; it is
+// read from SecretFile so it stays out of the config. Events lists the
+// events to deliver, all of them when empty.
+type webhookConfig struct {
+	URL        string   `json:"url"`
+	SecretFile string   `json:"secret_file"`
+	Events     []string `json:"events"`
+}
+
+// webhookEvent is the JSON body of a delivery.
+type webhookEvent struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind,omitempty"`
+	Account string    `json:"account,omitempty"`
+	Rule    string    `json:"rule,omitempty"`
+	TxHash  string    `json:"txHash,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type webhook struct {
+	url    string
+	secret []byte
+	events map[string]bool
+	queue  chan []byte
+	client *http.Client
+}
+
+// webhookNotifier delivers events to the configured webhooks. Each webhook
+// has its own queue and sender, so a slow endpoint only delays its own
+// deliveries; when a queue is full new events for it are dropped and
+// logged rather than blocking signing. A nil *webhookNotifier delivers
+// nothing.
+type webhookNotifier struct {
+	hooks []*webhook
+}
+
+// loadWebhooks reads the webhook config file and starts a sender for each
+// webhook that runs until ctx is done.
+func loadWebhooks(ctx context.Context, file string) (*webhookNotifier, error) {
+	var configs []webhookConfig
+	if err := jsonfile.Read(file, &configs); err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %v", err)
+	}
+	n := new(webhookNotifier)
+	for i, c := range configs {
+		if !strings.HasPrefix(c.URL, "https://") && !strings.HasPrefix(c.URL, "http://") {
+			return nil, fmt.Errorf("webhook %d: invalid url %q", i, c.URL)
+		}
+		if c.SecretFile == "" {
+			return nil, fmt.Errorf("webhook %d: secret_file is required", i)
+		}
+		secret, err := os.ReadFile(c.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %d: failed to read secret: %v", i, err)
+		}
+		secret = bytes.TrimSpace(secret)
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("webhook %d: secret is empty", i)
+		}
+		h := &webhook{
+			url:    c.URL,
+			secret: secret,
+			events: make(map[string]bool),
+			queue:  make(chan []byte, webhookQueueSize),
+			client: &http.Client{Timeout: webhookTimeout},
+		}
+		for _, ev := range c.Events {
+			switch ev {
+			case eventSigned, eventDenied, eventError:
+				h.events[ev] = true
+			default:
+				return nil, fmt.Errorf("webhook %d: unknown event %q", i, ev)
+			}
+		}
+		n.hooks = append(n.hooks, h)
+	}
+	for _, h := range n.hooks {
+		go h.run(ctx)
+	}
+	return n, nil
+}
+
+// notify queues ev for every webhook subscribed to it.
+func (n *webhookNotifier) notify(ev webhookEvent) {
+	if n == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("failed to encode webhook event", "err", err)
+		return
+	}
+	for _, h := range n.hooks {
+		if len(h.events) > 0 && !h.events[ev.Event] {
+			continue
+		}
+		select {
+		case h.queue <- body:
+		default:
+			slog.Warn("webhook queue full, dropping event", "url", h.url, "event", ev.Event)
+		}
+	}
+}
+
+// daemonError notifies the webhooks of a daemon failure outside a signing
+// request.
+func (n *webhookNotifier) daemonError(kind string, err error) {
+	n.notify(webhookEvent{Event: eventError, Kind: kind, Error: err.Error()})
+}
+
+func (h *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-h.queue:
+			h.deliver(ctx, body)
+		}
+	}
+}
+
+// deliver posts body, retrying with exponential backoff until the endpoint
+// answers 2xx, the attempts run out or ctx is done.
+func (h *webhook) deliver(ctx context.Context, body []byte) {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	backoff := webhookBackoff
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = h.post(ctx, body, signature); err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, webhookMaxBackoff)
+	}
+	slog.Error("webhook delivery failed", "url", h.url, "attempts", webhookAttempts, "err", err)
+}
+
+func (h *webhook) post(ctx context.Context, body []byte, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signer.WebhookSignatureHeader, signature)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/drimblongbodol/secure-signer-cli-go/internal/chains"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/policy"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/signer"
+	"github.com/drimblongbodol/secure-signer-cli-go/pkg/txbuilder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultGasPerPubdata is the gas per pubdata byte limit zkSync SDKs use
+// by default.
+const defaultGasPerPubdata = 50000
+
+// readBytecode reads a file of contract bytecode in hex.
+func readBytecode(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(s, "0x") {
+		s = "0x" + s
+	}
+	return hexutil.Decode(s)
+}
+
+func runSignZkSync(args []string) error {
+	fs := flag.NewFlagSet("sign-zksync", flag.ExitOnError)
+	keys := addKeyFlags(fs)
+	txOpts := addTxFlags(fs)
+	txOpts.fees.txType = txbuilder.TxTypeDynamic
+	gasPerPubdata := fs.Uint64("gas-per-pubdata", defaultGasPerPubdata, "Gas per pubdata byte limit")
+	paymasterFlag := fs.String("paymaster", "", "Paymaster that pays the fees (must be listed in the policy zksync.paymasters)")
+	paymasterInput := fs.String("paymaster-input", "", "Paymaster input in hex, such as an encoded general() or approvalBased() call")
+	var factoryDeps fileList
+	fs.Var(&factoryDeps, "factory-dep", "File of contract bytecode in hex to publish with the transaction (repeatable)")
+	policyOpts := addPolicyFlags(fs)
+	storePath := fs.String("store", signer.DefaultStore, "State store for cumulative policy limits, approvals, time-delayed transactions and the freeze switch")
+	overrideReason := addOverrideFlag(fs)
+	confirmRecipient := addNewRecipientFlag(fs)
+	allowReplace := addAllowReplaceFlag(fs)
+	confirm := addConfirmFlag(fs)
+	explain := fs.Bool("explain", false, "Print the policy decision as JSON to stderr")
+	approvalsDir := fs.String("approvals-dir", signer.DefaultApprovalsDir, "Directory holding alert acknowledgements")
+	alertsConfig := addAlertFlags(fs)
+	auditOpts := addAuditFlags(fs)
+	rpcOpts := addRPCFlags(fs)
+	send := fs.Bool("send", false, "Broadcast the signed transaction via -rpc")
+	output := addOutputFlag(fs)
+	parseFlags(fs, args)
+
+	if txOpts.to == "" {
+		return errors.New("to is required")
+	}
+	if txOpts.fees.txType != txbuilder.TxTypeDynamic {
+		return errors.New("zkSync transactions require -tx-type dynamic")
+	}
+	if len(txOpts.blobs) > 0 || len(txOpts.authorizations) > 0 || txOpts.accessList != "" || txOpts.createAccessList {
+		return errors.New("blob, authorization and access lists are not supported on zkSync")
+	}
+	if *send && (rpcOpts.url == "" || rpcOpts.offline) {
+		return errors.New("send requires rpc")
+	}
+	paymaster, err := parseOptionalAddress("paymaster", *paymasterFlag)
+	if err != nil {
+		return err
+	}
+	input, err := txbuilder.ParseCalldata(*paymasterInput)
+	if err != nil {
+		return err
+	}
+	if paymaster == (common.Address{}) && len(input) > 0 {
+		return errors.New("paymaster-input requires paymaster")
+	}
+	var deps [][]byte
+	for _, file := range factoryDeps {
+		code, err := readBytecode(file)
+		if err != nil {
+			return fmt.Errorf("failed to read factory dependency: %v", err)
+		}
+		deps = append(deps, code)
+	}
+
+	ctx := context.Background()
+	store, err := signer.OpenStore(*storePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	key, err := openKeyBackend(ctx, *keys, store)
+	if err != nil {
+		return fmt.Errorf("failed to load private key: %v", err)
+	}
+	pol, err := policyOpts.load()
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+	client, err := rpcOpts.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	nonces := &signer.NonceManager{Store: store, Client: client, From: key.Address(), OnGap: warnNonceGap}
+	release := func() {}
+	signed := false
+	defer func() {
+		if !signed {
+			release()
+		}
+	}()
+	if err := txOpts.resolveTo(ctx, client, pol.ForSender(key.Address())); err != nil {
+		return err
+	}
+	tx, chain, err := txOpts.build(ctx, client, key.Address(), pol.Fees, func(chainID *big.Int) (uint64, error) {
+		nonce, err := nonces.Next(ctx, chainID)
+		if err == nil {
+			release = func() { nonces.Release(chainID, nonce) }
+		}
+		return nonce, err
+	})
+	if err != nil {
+		return err
+	}
+	if tx.Type() != types.DynamicFeeTxType {
+		return errors.New("zkSync transactions require -tx-type dynamic")
+	}
+	ztx, err := txbuilder.NewZkSyncTx(tx, key.Address())
+	if err != nil {
+		return err
+	}
+	ztx.GasPerPubdata = new(big.Int).SetUint64(*gasPerPubdata)
+	ztx.Paymaster, ztx.PaymasterInput, ztx.FactoryDeps = paymaster, input, deps
+	typed, err := ztx.TypedData()
+	if err != nil {
+		return err
+	}
+
+	eng := signer.New(pol, key, store)
+	eng.ENS = policy.NewENSResolver(client)
+	eng.OverrideReason = *overrideReason
+	eng.ConfirmRecipient = confirmRecipient
+	eng.AllowReplace = *allowReplace
+	if *confirm {
+		eng.Confirm = confirmTx
+	}
+	eng.ApprovalsDir = *approvalsDir
+	if eng.Alerts, err = signer.LoadAlerter(*alertsConfig); err != nil {
+		return err
+	}
+	if eng.Audit, err = auditOpts.open(store); err != nil {
+		return err
+	}
+	d := new(policy.Decision)
+	if err := eng.CurrentPolicy().CheckPaymaster(paymaster, d); err != nil {
+		err = &policy.DeniedError{Err: fmt.Errorf("policy check failed: %v", err), Decision: d}
+		if aerr := eng.AuditTx(tx, chain, d, common.Hash{}, err); aerr != nil {
+			return signer.AuditRecordFailed(aerr)
+		}
+		return err
+	}
+	auth, err := eng.Authorize(ctx, tx, chain)
+	auth.Decision.Checks = append(d.Checks, auth.Decision.Checks...)
+	if *explain {
+		printDecision(auth.Decision)
+	}
+	if err != nil {
+		return err
+	}
+	var raw []byte
+	var txHash common.Hash
+	err = auth.SignWith(ctx, func() ([]byte, common.Hash, error) {
+		hash, sig, err := signTypedData(ctx, key, typed)
+		if err != nil {
+			return nil, common.Hash{}, fmt.Errorf("failed to sign tx: %v", err)
+		}
+		if raw, err = ztx.Encode(sig); err != nil {
+			return nil, common.Hash{}, fmt.Errorf("failed to encode tx: %v", err)
+		}
+		txHash = txbuilder.ZkSyncTxHash(hash, sig)
+		return raw, txHash, nil
+	})
+	signed = err == nil || signer.IsHeld(err)
+	if err != nil {
+		return err
+	}
+
+	if *send {
+		var sent common.Hash
+		if err := client.Client().CallContext(ctx, &sent, "eth_sendRawTransaction", hexutil.Encode(raw)); err != nil {
+			return fmt.Errorf("failed to send tx: %v", err)
+		}
+		if sent != txHash {
+			fmt.Fprintln(os.Stderr, "warning: node reported transaction hash", sent.Hex())
+		}
+	}
+	out := struct {
+		RawTx          string           `json:"rawTx"`
+		TxHash         string           `json:"txHash"`
+		Type           uint8            `json:"type"`
+		ChainID        string           `json:"chainId"`
+		From           string           `json:"from"`
+		To             string           `json:"to"`
+		Nonce          uint64           `json:"nonce"`
+		Paymaster      string           `json:"paymaster,omitempty"`
+		Sent           bool             `json:"sent"`
+		ExplorerURL    string           `json:"explorerUrl,omitempty"`
+		PolicyDecision *policy.Decision `json:"policyDecision,omitempty"`
+	}{
+		RawTx:          hexutil.Encode(raw),
+		TxHash:         txHash.Hex(),
+		Type:           txbuilder.ZkSyncTxType,
+		ChainID:        chain.String(),
+		From:           key.Address().Hex(),
+		To:             ztx.To.Hex(),
+		Nonce:          ztx.Nonce,
+		Sent:           *send,
+		ExplorerURL:    chains.ExplorerTxURL(chain, txHash.Hex()),
+		PolicyDecision: auth.Decision,
+	}
+	if paymaster != (common.Address{}) {
+		out.Paymaster = paymaster.Hex()
+	}
+	output.print(out, func() {
+		fmt.Println("RawTxHex:", strings.TrimPrefix(out.RawTx, "0x"))
+		fmt.Println("TxHash:", out.TxHash)
+		if out.ExplorerURL != "" {
+			fmt.Println("Explorer:", out.ExplorerURL)
+		}
+	})
+	return nil
+}